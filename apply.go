@@ -0,0 +1,55 @@
+package drift
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrPendingOutOfOrder means applying migrations would skip pending ones
+	// with smaller IDs. ErrEarlierPending wraps it.
+	ErrPendingOutOfOrder = errors.New("migrations would be applied out of order")
+	ErrEarlierPending    = fmt.Errorf("%w: earlier migrations are still pending", ErrPendingOutOfOrder)
+	ErrAlreadyApplied    = errors.New("migration has already been applied")
+)
+
+// ApplyOne applies exactly one pending migration. It refuses if any pending
+// migration has a smaller ID, unless force is true, because applying
+// migrations out of order is usually a mistake.
+//
+// This is meant for staging a risky migration separately from routine ones.
+// The Migrator's other options (like WithLock) still apply.
+func (m *Migrator) ApplyOne(ctx context.Context, db *sql.DB, migrationsDir string, id MigrationID, force bool) (*Result, error) {
+	records, err := m.applied(ctx, db)
+	if err != nil {
+		return nil, fmt.Errorf("could not get applied migrations: %w", err)
+	}
+	for _, r := range records {
+		if r.ID == id {
+			return nil, fmt.Errorf("%w: %d", ErrAlreadyApplied, id)
+		}
+	}
+	files, err := m.listFiles(migrationsDir)
+	if err != nil {
+		return nil, fmt.Errorf("could not get available migrations: %w", err)
+	}
+
+	var earlier []MigrationID
+	for _, f := range diff(records, files) {
+		if f.ID < id {
+			earlier = append(earlier, f.ID)
+		}
+	}
+	if len(earlier) > 0 {
+		if !force {
+			return nil, fmt.Errorf("%w: %v", ErrEarlierPending, earlier)
+		}
+		warnf(m.io, "Applying %d out of order (earlier pending migrations: %v)", id, earlier)
+	}
+
+	one := *m
+	one.only = []MigrationID{id}
+	return one.Run(ctx, db, migrationsDir, nil)
+}
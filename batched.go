@@ -0,0 +1,61 @@
+package drift
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// reBatchable finds statements that batched migrations can repeat.
+var reBatchable = regexp.MustCompile(`(?i)^(update|delete|with)\b`)
+
+// checkBatched returns an error if a migration with a batched directive isn't
+// a single UPDATE or DELETE statement.
+func checkBatched(f migrationFile) error {
+	if !f.directives.batched {
+		return nil
+	}
+	if len(f.directives.copies) > 0 {
+		return fmt.Errorf("%w: %s: batched and copy can't be used together", ErrInvalidDirective, f.Name)
+	}
+	sts := splitSQL(f.Content)
+	if len(sts) != 1 || !reBatchable.MatchString(sts[0].text) {
+		return fmt.Errorf("%w: %s: a batched migration must be a single UPDATE or DELETE statement", ErrInvalidDirective, f.Name)
+	}
+	return nil
+}
+
+// runBatched runs the migration's statement again and again, each time in its
+// own transaction, until it affects no rows. It sleeps for the directive's
+// pause between batches.
+func (m *Migrator) runBatched(ctx context.Context, q Queryable, f migrationFile) error {
+	text := splitSQL(f.Content)[0].text
+	var total int64
+	for batch := 1; ; batch++ {
+		res, err := q.ExecContext(ctx, text)
+		if err != nil {
+			return statementError(text, err)
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			m.io.Infof("Finished %s in %d batches (%d rows)", f.Name, batch, total)
+			return nil
+		}
+		total += n
+		m.io.Debugf("Batch %d of %s affected %d rows", batch, f.Name, n)
+
+		if pause := f.directives.batchPause; pause > 0 {
+			t := time.NewTimer(pause)
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				return ctx.Err()
+			case <-t.C:
+			}
+		}
+	}
+}
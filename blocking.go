@@ -0,0 +1,142 @@
+package drift
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blockloop/scan"
+)
+
+// WithBlockingReport makes Drift check on each running migration at the
+// interval, and log a warning about any sessions holding locks it's waiting
+// for, with their queries. That's usually why a deploy hangs, and otherwise
+// it takes a second session to find out.
+//
+// It needs Postgres and a *sql.DB pool to check from, so it doesn't apply to
+// RunConn or RunTx, or with WithTransactionPooling.
+func WithBlockingReport(every time.Duration) Option {
+	return func(m *Migrator) {
+		m.blockingReport = every
+	}
+}
+
+// A blocker is a session holding a lock that a migration is waiting for.
+type blocker struct {
+	PID     int64   `db:"pid"`
+	User    string  `db:"usename"`
+	Client  string  `db:"client_addr"`
+	State   string  `db:"state"`
+	Seconds float64 `db:"seconds"`
+	Query   string  `db:"query"`
+}
+
+const qBlockers = `select
+    pid,
+    coalesce(usename, '') as usename,
+    coalesce(host(client_addr), '') as client_addr,
+    coalesce(state, '') as state,
+    coalesce(extract(epoch from now() - coalesce(xact_start, query_start)), 0)::float8 as seconds,
+    coalesce(query, '') as query
+from pg_stat_activity
+where pid = any(pg_blocking_pids($1))
+order by pid`
+
+// reportsBlocking reports whether watchBlockers can check from the pool.
+func (m *Migrator) reportsBlocking(pool conn) bool {
+	if m.blockingReport <= 0 || m.pooled {
+		return false
+	}
+	if _, ok := m.dialect.(Postgres); !ok {
+		return false
+	}
+	_, ok := pool.(*sql.DB)
+	return ok
+}
+
+// watchBlockers reports the sessions blocking the migration, which runs on
+// q, until the returned function is called. The checks run on another
+// connection from the pool.
+func (m *Migrator) watchBlockers(ctx context.Context, pool conn, q rowQueryable, f migrationFile) (stop func()) {
+	if !m.reportsBlocking(pool) {
+		return func() {}
+	}
+	pid, err := backendPID(ctx, q)
+	if err != nil {
+		m.io.Debugf("Not reporting blocking sessions: %s", err)
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		t := time.NewTicker(m.blockingReport)
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				m.reportBlockers(ctx, pool, pid, f)
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
+func (m *Migrator) reportBlockers(ctx context.Context, pool conn, pid int64, f migrationFile) {
+	ctx, cancel := m.queryContext(ctx)
+	defer cancel()
+	rows, err := pool.QueryContext(ctx, qBlockers, pid)
+	if err != nil {
+		m.io.Debugf("Could not check for blocking sessions: %s", err)
+		return
+	}
+	var bs []blocker
+	if err := scan.RowsStrict(&bs, rows); err != nil {
+		m.io.Debugf("Could not check for blocking sessions: %s", err)
+		return
+	}
+	for _, b := range bs {
+		who := b.User
+		if b.Client != "" {
+			who += "@" + b.Client
+		}
+		d := time.Duration(b.Seconds * float64(time.Second)).Round(time.Second)
+		warnf(m.io, "Migration %d (pid %d) is waiting for a lock held by pid %d (%s, %s for %s): %s",
+			f.ID, pid, b.PID, who, b.State, d, summarizeQuery(b.Query))
+	}
+}
+
+// backendPID returns the server process ID of the session q runs on.
+func backendPID(ctx context.Context, q rowQueryable) (int64, error) {
+	rows, err := q.QueryContext(ctx, "select pg_backend_pid()")
+	if err != nil {
+		return 0, err
+	}
+	var pid int64
+	if err := scan.Row(&pid, rows); err != nil {
+		return 0, err
+	}
+	return pid, nil
+}
+
+// summarizeQuery collapses the query's whitespace and shortens it for a log
+// line.
+func summarizeQuery(query string) string {
+	const max = 200
+	query = strings.Join(strings.Fields(query), " ")
+	if r := []rune(query); len(r) > max {
+		query = string(r[:max]) + "..."
+	}
+	return query
+}
@@ -0,0 +1,89 @@
+package drift
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var ErrInjectedFailure = errors.New("injected failure")
+
+// A Failpoint is a point in applying a migration where WithChaos can inject a
+// failure.
+type Failpoint string
+
+const (
+	// FailAfterClaim fails right after the migration is claimed, before any of
+	// its statements run. For no-transaction migrations (which claim
+	// themselves), it fails before running the file.
+	FailAfterClaim Failpoint = "after-claim"
+	// FailMidStatement cancels the migration shortly after its statements
+	// start running.
+	FailMidStatement Failpoint = "mid-statement"
+	// FailBeforeCommit fails after the statements have run but before the
+	// transaction commits. For no-transaction migrations, it fails after
+	// running the file.
+	FailBeforeCommit Failpoint = "before-commit"
+)
+
+// Failpoints lists every valid Failpoint.
+var Failpoints = []Failpoint{FailAfterClaim, FailMidStatement, FailBeforeCommit}
+
+// midStatementDelay is how long FailMidStatement waits before cancelling.
+const midStatementDelay = 50 * time.Millisecond
+
+// WithChaos injects a failure at the failpoint while applying the migration
+// with the given ID, or the first migration applied if id is negative.
+//
+// This is only meant for rehearsing recovery procedures against a disposable
+// database. Never use it against a database you care about.
+func WithChaos(point Failpoint, id MigrationID) Option {
+	return func(m *Migrator) {
+		m.chaos = &chaos{point: point, id: id}
+	}
+}
+
+type chaos struct {
+	point Failpoint
+	id    MigrationID
+	mu    sync.Mutex // Parallel migrations can reach failpoints concurrently.
+	fired bool
+}
+
+// at returns an injected failure if the failpoint should fire now.
+func (c *chaos) at(point Failpoint, f migrationFile) error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.fired || c.point != point {
+		return nil
+	}
+	if c.id >= 0 && c.id != f.ID {
+		return nil
+	}
+	c.fired = true
+	return fmt.Errorf("%w: %s in %s", ErrInjectedFailure, point, f.Name)
+}
+
+// midStatement returns a context that gets cancelled shortly after the
+// statements start if FailMidStatement should fire, and a function that
+// reports the injected failure (if any) after they return.
+func (c *chaos) midStatement(ctx context.Context, f migrationFile) (context.Context, func(error) error) {
+	if err := c.at(FailMidStatement, f); err != nil {
+		ctx, cancel := context.WithCancel(ctx)
+		timer := time.AfterFunc(midStatementDelay, cancel)
+		return ctx, func(runErr error) error {
+			timer.Stop()
+			cancel()
+			if runErr != nil {
+				return fmt.Errorf("%w: %s", err, runErr)
+			}
+			return err
+		}
+	}
+	return ctx, func(runErr error) error { return runErr }
+}
@@ -0,0 +1,102 @@
+package drift
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+var ErrInvalidClaimStrategy = errors.New("invalid claim strategy")
+
+// A ClaimStrategy is how Drift records a migration in the migrations table
+// as it's applied. Recording an ID that's already there must fail, so that a
+// migration is never applied twice.
+type ClaimStrategy string
+
+const (
+	// ClaimFunction calls the _drift_claim_migration function that the init
+	// migration creates. This is the default, unless the dialect is a
+	// ClaimDialect.
+	ClaimFunction ClaimStrategy = "function"
+	// ClaimInsert inserts the ID and slug into the migrations table
+	// directly, relying on its primary key to reject duplicates. Use this
+	// for databases that Drift didn't set up, which have the table but not
+	// the function.
+	ClaimInsert ClaimStrategy = "insert"
+	// ClaimCustom runs the SQL set with WithClaimSQL.
+	ClaimCustom ClaimStrategy = "custom"
+)
+
+// ClaimStrategies lists every valid ClaimStrategy.
+var ClaimStrategies = []ClaimStrategy{ClaimFunction, ClaimInsert, ClaimCustom}
+
+// WithClaimStrategy sets how migrations are recorded as they're applied.
+//
+// Migrations with a no-transaction directive record themselves, so with a
+// strategy other than ClaimFunction, they should do it the same way instead
+// of calling _drift_claim_migration.
+func WithClaimStrategy(s ClaimStrategy) Option {
+	return func(m *Migrator) {
+		m.claimStrategy = s
+	}
+}
+
+// WithClaimSQL records migrations by running the query, with the migration
+// ID and slug as its first and second parameters ($1 and $2 in Postgres). It
+// sets the claim strategy to ClaimCustom.
+func WithClaimSQL(query string) Option {
+	return func(m *Migrator) {
+		m.claimStrategy = ClaimCustom
+		m.claimSQL = query
+	}
+}
+
+// checkClaim returns an error if the claim strategy can't be used.
+func (m *Migrator) checkClaim() error {
+	switch m.claimStrategy {
+	case ClaimFunction, ClaimInsert:
+		return nil
+	case ClaimCustom:
+		if m.claimSQL == "" {
+			return fmt.Errorf("%w: %s needs claim SQL", ErrInvalidClaimStrategy, ClaimCustom)
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w: %q (want one of %v)", ErrInvalidClaimStrategy, m.claimStrategy, ClaimStrategies)
+	}
+}
+
+func (m *Migrator) claim(ctx context.Context, tx Queryable, id MigrationID, slug string) error {
+	var (
+		query string
+		args  []interface{}
+		err   error
+	)
+	switch m.claimStrategy {
+	case ClaimInsert:
+		query, args, err = m.sb().
+			Insert(m.tableName()).
+			Columns("id", "slug").
+			Values(id, slug).
+			ToSql()
+	case ClaimCustom:
+		query, args = m.claimSQL, []interface{}{id, slug}
+	default:
+		name := m.funcName("_drift_claim_migration")
+		if pd, ok := m.dialect.(ProcedureDialect); ok {
+			args = []interface{}{id, slug}
+			query, err = m.dialect.Placeholders().ReplacePlaceholders(pd.Call(name, len(args)))
+			break
+		}
+		query, args, err = m.sb().Select().
+			Column(name+"("+sq.Placeholders(2)+")", id, slug).
+			ToSql()
+	}
+	if err != nil {
+		return err
+	}
+	_, err = m.shown(tx).ExecContext(ctx, query, args...)
+	return err
+}
@@ -0,0 +1,93 @@
+package drift
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// ClickHouse is the dialect for ClickHouse. Drift doesn't include a driver
+// for it, so register one (like github.com/ClickHouse/clickhouse-go/v2) before
+// opening the database.
+//
+// ClickHouse has no transactions, so every migration runs as in
+// TransactionNone mode, one statement at a time. The migrations table is a
+// ReplacingMergeTree, which can't reject a duplicate ID: migrations are
+// claimed by inserting a row after they've run (ClaimInsert), and duplicates
+// are ignored when reading the table. So write migrations that are safe to
+// run twice (with "if not exists"), and don't run Drift concurrently, since
+// WithLock isn't supported either. The migrations table's schema is a
+// ClickHouse database, so set WithSchema("default") (or another database)
+// since the default is Postgres's "public".
+type ClickHouse struct{}
+
+var (
+	_ Dialect                 = ClickHouse{}
+	_ InitDialect             = ClickHouse{}
+	_ BatchDialect            = ClickHouse{}
+	_ TableDialect            = ClickHouse{}
+	_ ClaimDialect            = ClickHouse{}
+	_ NonTransactionalDialect = ClickHouse{}
+	_ VersionDialect          = ClickHouse{}
+)
+
+func (ClickHouse) Placeholders() sq.PlaceholderFormat {
+	return sq.Question
+}
+
+func (ClickHouse) Quote(parts ...string) string {
+	quoted := make([]string, len(parts))
+	for i, p := range parts {
+		quoted[i] = "`" + strings.ReplaceAll(p, "`", "``") + "`"
+	}
+	return strings.Join(quoted, ".")
+}
+
+func (ClickHouse) IsUndefinedTable(err error) bool {
+	// The clickhouse-go exception only has the code in a field, so match its
+	// message instead.
+	return err != nil && strings.Contains(err.Error(), "code: 60,") // UNKNOWN_TABLE
+}
+
+func (ClickHouse) TableExistsQuery() string {
+	return "select 1 from system.tables where database = ? and name = ?"
+}
+
+func (ClickHouse) ServerVersionQuery() string {
+	return "select version()"
+}
+
+func (ClickHouse) Lock(context.Context, *sql.Conn, int64) error {
+	return ErrLockUnsupported
+}
+
+func (ClickHouse) Unlock(context.Context, *sql.Conn, int64) error {
+	return ErrLockUnsupported
+}
+
+func (ClickHouse) ClaimStrategy() ClaimStrategy {
+	return ClaimInsert
+}
+
+func (ClickHouse) NonTransactional() {}
+
+// SplitBatches splits the content into statements, since ClickHouse runs one
+// statement per query.
+func (ClickHouse) SplitBatches(content string) []string {
+	sts := splitSQL(content)
+	batches := make([]string, 0, len(sts))
+	for _, st := range sts {
+		batches = append(batches, strings.Repeat("\n", strings.Count(content[:st.start], "\n"))+st.text)
+	}
+	return batches
+}
+
+func (ClickHouse) InitTemplate() string {
+	return clickHouseInitContent
+}
+
+//go:embed templates/init_clickhouse.sql
+var clickHouseInitContent string
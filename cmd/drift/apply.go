@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/spf13/cobra"
+
+	"github.com/metagram-net/drift"
+)
+
+const applyLong string = `Apply exactly one pending migration by ID.
+
+This is useful for staging a risky migration separately from routine ones. It
+refuses to run if earlier migrations are still pending, unless --force is
+given.`
+
+func applyCmd(cli *CLI) *cobra.Command {
+	var (
+		force bool
+		lock  bool
+	)
+
+	cmd := &cobra.Command{
+		Use:               "apply <id>",
+		Short:             "Apply one specific migration",
+		Long:              applyLong,
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeIDArg(cli),
+		Run: func(cmd *cobra.Command, args []string) {
+			id := parseID(cli, args[0])
+
+			db, err := openDB()
+			if err != nil {
+				cli.Exitf(1, "open database connection: %s", err)
+			}
+			defer db.Close()
+
+			var opts []drift.Option
+			if lock {
+				opts = append(opts, drift.WithLock())
+			}
+			_, err = newMigrator(cli, opts...).ApplyOne(cmd.Context(), db, migrationsDir(), id, force)
+			if errors.Is(err, drift.ErrEarlierPending) {
+				cli.Exitf(1, "%s (use --force to apply it anyway)", err)
+			}
+			if err != nil {
+				cli.Exitf(1, "apply migration: %s", err)
+			}
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.BoolVar(&force, "force", false, "Apply the migration even if earlier migrations are still pending")
+	flags.BoolVar(&lock, "lock", false, "Hold an advisory lock so concurrent runs wait for each other")
+	return cmd
+}
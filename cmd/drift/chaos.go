@@ -0,0 +1,34 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/metagram-net/drift"
+)
+
+var errInvalidChaos = errors.New("invalid chaos failpoint")
+
+// parseChaos parses a --chaos value: a failpoint name, optionally followed by
+// a colon and the ID of the migration to fail.
+func parseChaos(s string) (drift.Failpoint, drift.MigrationID, error) {
+	name, rawID, hasID := strings.Cut(s, ":")
+
+	point := drift.Failpoint(name)
+	valid := false
+	for _, p := range drift.Failpoints {
+		valid = valid || p == point
+	}
+	if !valid {
+		return "", 0, fmt.Errorf("%w: %q (want one of %v)", errInvalidChaos, name, drift.Failpoints)
+	}
+
+	id := drift.MigrationID(-1)
+	if hasID {
+		if err := id.Set(rawID); err != nil {
+			return "", 0, fmt.Errorf("%w: %s", errInvalidChaos, err)
+		}
+	}
+	return point, id, nil
+}
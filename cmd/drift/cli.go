@@ -19,6 +19,20 @@ type CLI struct {
 	stderr io.Writer
 
 	verbosity Verbosity
+	// quiet hides info logs and warnings but not errors, unlike SilentLevel.
+	quiet bool
+
+	// colorOut and colorErr are set if stdout and stderr can be colored.
+	colorOut bool
+	colorErr bool
+
+	interrupts *interrupts
+
+	// usage records the running command if telemetry is enabled.
+	usage *usage
+
+	// tracer exports migration traces if an OTLP endpoint is configured.
+	tracer *otlpTracer
 }
 
 func (cli *CLI) SetVerbosity(v Verbosity) {
@@ -26,14 +40,22 @@ func (cli *CLI) SetVerbosity(v Verbosity) {
 }
 
 func (cli CLI) fwritef(w io.Writer, level Verbosity, format string, args ...interface{}) (n int, err error) {
-	if cli.verbosity < level {
+	if cli.verbosity < level || (cli.quiet && level == InfoLevel) {
 		return
 	}
 	return fmt.Fprintf(w, format+"\n", args...)
 }
 
 func (cli CLI) Exitf(code int, format string, args ...interface{}) {
-	cli.fwritef(cli.stderr, InfoLevel, format, args...)
+	if cli.verbosity >= InfoLevel {
+		msg := fmt.Sprintf(format, args...)
+		if code != 0 {
+			msg = cli.errColor(colorRed, msg)
+		}
+		fmt.Fprintln(cli.stderr, msg)
+	}
+	cli.usage.finish(code)
+	cli.tracer.flush()
 	os.Exit(code)
 }
 
@@ -45,6 +67,26 @@ func (cli CLI) Debugf(format string, args ...interface{}) (n int, err error) {
 	return cli.fwritef(cli.stderr, DebugLevel, format, args...)
 }
 
+// Warnf logs a warning, unless in quiet mode.
+func (cli CLI) Warnf(format string, args ...interface{}) (n int, err error) {
+	if cli.quiet {
+		return
+	}
+	return cli.alertf(colorYellow, "Warning: "+format, args...)
+}
+
+// Errorf logs an error, even in quiet mode.
+func (cli CLI) Errorf(format string, args ...interface{}) (n int, err error) {
+	return cli.alertf(colorRed, "Error: "+format, args...)
+}
+
+func (cli CLI) alertf(c color, format string, args ...interface{}) (n int, err error) {
+	if cli.verbosity < InfoLevel {
+		return
+	}
+	return fmt.Fprintln(cli.stderr, cli.errColor(c, fmt.Sprintf(format, args...)))
+}
+
 func (cli CLI) Printf(format string, args ...interface{}) (n int, err error) {
 	return cli.fwritef(cli.stdout, SilentLevel, format, args...)
 }
@@ -0,0 +1,39 @@
+package main
+
+import "os"
+
+// A color is an ANSI SGR color code.
+type color string
+
+const (
+	colorRed    color = "31"
+	colorGreen  color = "32"
+	colorYellow color = "33"
+)
+
+// colorEnabled reports whether output to f should be colored: only on a
+// terminal, and not if NO_COLOR is set (https://no-color.org) or the terminal
+// is dumb.
+func colorEnabled(f *os.File) bool {
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	return isTerminal(f)
+}
+
+func paint(enabled bool, c color, s string) string {
+	if !enabled || s == "" {
+		return s
+	}
+	return "\x1b[" + string(c) + "m" + s + "\x1b[0m"
+}
+
+// outColor colors s for stdout, if stdout is colored.
+func (cli CLI) outColor(c color, s string) string {
+	return paint(cli.colorOut, c, s)
+}
+
+// errColor colors s for stderr, if stderr is colored.
+func (cli CLI) errColor(c color, s string) string {
+	return paint(cli.colorErr, c, s)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/metagram-net/drift"
+)
+
+// Cobra adds the `drift completion bash|zsh|fish|powershell` command itself.
+// These functions fill in migration IDs and slugs from the migrations
+// directory.
+
+// completeIDs completes migration IDs, described by their slugs.
+func completeIDs(cli *CLI) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(_ *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		var comps []string
+		for _, info := range listMigrations(cli) {
+			if id := info.ID.String(); strings.HasPrefix(id, toComplete) {
+				comps = append(comps, id+"\t"+info.Slug)
+			}
+		}
+		return comps, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// completeIDArg completes a single migration ID argument.
+func completeIDArg(cli *CLI) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	ids := completeIDs(cli)
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return ids(cmd, args, toComplete)
+	}
+}
+
+// completeKeys completes migration IDs and slugs, for commands that accept
+// either.
+func completeKeys(cli *CLI) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(_ *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		var comps []string
+		for _, info := range listMigrations(cli) {
+			if id := info.ID.String(); strings.HasPrefix(id, toComplete) {
+				comps = append(comps, id+"\t"+info.Slug)
+			}
+			if strings.HasPrefix(info.Slug, toComplete) {
+				comps = append(comps, info.Slug+"\t"+info.ID.String())
+			}
+		}
+		return comps, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// listMigrations lists the migration files for completion. Completion
+// shouldn't print errors, so it just completes nothing if that fails.
+func listMigrations(cli *CLI) []drift.MigrationInfo {
+	if err := loadConfig(); err != nil {
+		return nil
+	}
+	cli.SetVerbosity(SilentLevel)
+	infos, err := newMigrator(cli).List(migrationsDir())
+	if err != nil {
+		return nil
+	}
+	return infos
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// configTemplate is the example config from the README, with every setting
+// commented out. Keep them in sync.
+//
+//go:embed drift.toml
+var configTemplate string
+
+func configCmd(cli *CLI) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Create or inspect the config file",
+		Args:  cobra.NoArgs,
+	}
+	cmd.AddCommand(configInitCmd(cli), configShowCmd(cli))
+	return cmd
+}
+
+const configInitLong string = `Write a drift.toml with every supported setting documented and commented out.
+
+Use - as the path to print it instead.`
+
+func configInitCmd(cli *CLI) *cobra.Command {
+	var force bool
+
+	cmd := &cobra.Command{
+		Use:   "init [path]",
+		Short: "Write a commented config file",
+		Long:  configInitLong,
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			path := "drift.toml"
+			if len(args) > 0 {
+				path = args[0]
+			}
+			if path == "-" {
+				cli.Printf("%s", strings.TrimSuffix(configTemplate, "\n"))
+				return
+			}
+
+			flag := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+			if force {
+				flag = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+			}
+			//#nosec G302 G304 // The user chose the path, and the config isn't secret.
+			f, err := os.OpenFile(path, flag, 0o644)
+			if errors.Is(err, os.ErrExist) {
+				cli.Exitf(1, "%s already exists (use --force to overwrite it)", path)
+			}
+			if err != nil {
+				cli.Exitf(1, "create config file: %s", err)
+			}
+			_, werr := f.WriteString(configTemplate)
+			if cerr := f.Close(); werr == nil {
+				werr = cerr
+			}
+			if werr != nil {
+				cli.Exitf(1, "write config file: %s", werr)
+			}
+			cli.Infof("Created config file: %s", path)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.BoolVar(&force, "force", false, "Overwrite the file if it already exists")
+	return cmd
+}
+
+const configShowLong string = `Print the resolved configuration and where each setting came from.
+
+Settings come from flags, then DRIFT_* environment variables, then the config
+file, then the defaults. Passwords and tokens are redacted.`
+
+func configShowCmd(cli *CLI) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "show",
+		Short: "Print the resolved configuration",
+		Long:  configShowLong,
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, _ []string) {
+			if file := viper.ConfigFileUsed(); file != "" {
+				cli.Printf("# Config file: %s", file)
+			} else {
+				cli.Printf("# Config file: (none)")
+			}
+
+			keys := viper.AllKeys()
+			sort.Strings(keys)
+			for _, key := range keys {
+				var v strings.Builder
+				enc := json.NewEncoder(&v)
+				enc.SetEscapeHTML(false)
+				if err := enc.Encode(redact(key, viper.Get(key))); err != nil {
+					cli.Exitf(1, "format %s: %s", key, err)
+				}
+				cli.Printf("%s = %s  # %s", key, strings.TrimSuffix(v.String(), "\n"), configSource(cmd, key))
+			}
+		},
+	}
+	return cmd
+}
+
+// configSource describes where the resolved value of the key came from, in
+// viper's order of precedence. selectModule and selectTarget override some
+// settings, which shows up as "module" or "target".
+func configSource(cmd *cobra.Command, key string) string {
+	if f := cmd.Flag(key); f != nil && f.Changed {
+		return "flag"
+	}
+	if _, ok := os.LookupEnv(configEnv(key)); ok {
+		return "env " + configEnv(key)
+	}
+	if module := viper.GetString("module"); module != "" {
+		switch key {
+		case "migrations-dir", "migrations-table", "migrations-schema":
+			if viper.IsSet("modules." + module + "." + strings.TrimPrefix(key, "migrations-")) {
+				return "module " + module
+			}
+		}
+	}
+	if name := viper.GetString("target"); name != "" && targetSets(name, key) {
+		return "target " + name
+	}
+	if viper.InConfig(key) {
+		return "file"
+	}
+	return "default"
+}
+
+// configEnv returns the environment variable that sets the key.
+func configEnv(key string) string {
+	return "DRIFT_" + strings.ToUpper(strings.ReplaceAll(key, "-", "_"))
+}
+
+// reSecretKey matches setting names whose values are secret.
+var reSecretKey = regexp.MustCompile(`(?i)password|secret|token|webhook`)
+
+// reDSNPassword matches the password in a key=value connection string.
+var reDSNPassword = regexp.MustCompile(`(password\s*=\s*)('[^']*'|\S+)`)
+
+const redacted = "REDACTED"
+
+// redact hides passwords in connection strings and URLs, and the values of
+// secret-looking settings.
+func redact(key string, v interface{}) interface{} {
+	s, ok := v.(string)
+	if !ok || s == "" {
+		return v
+	}
+	if reSecretKey.MatchString(key) && !strings.HasSuffix(key, "-file") {
+		return redacted
+	}
+	if u, err := url.Parse(s); err == nil && u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), redacted)
+			return u.String()
+		}
+	}
+	return reDSNPassword.ReplaceAllString(s, "${1}"+redacted)
+}
+
+var errUndefinedVariable = errors.New("environment variable is not set")
+
+// reInterpolation matches ${VAR}, ${VAR:-default}, and the $${ escape.
+var reInterpolation = regexp.MustCompile(`\$\$\{|\$\{([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}`)
+
+// interpolateConfig expands environment variables in the config file's string
+// values, so secrets can stay out of the file:
+//
+//	database-url = "postgres://app:${DB_PASSWORD}@db/app"
+//
+// ${VAR:-default} uses the default if VAR is unset or empty, and $${ is a
+// literal ${. A bare $ is left alone, since passwords can contain them.
+func interpolateConfig() error {
+	for _, key := range viper.AllKeys() {
+		if !viper.InConfig(key) {
+			continue
+		}
+		switch v := viper.Get(key).(type) {
+		case string:
+			s, err := interpolate(v)
+			if err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			if s != v {
+				viper.Set(key, s)
+			}
+		case []interface{}:
+			changed := false
+			out := make([]string, len(v))
+			for i, e := range v {
+				str, ok := e.(string)
+				if !ok {
+					// Arrays of tables (like targets) expand when they're used.
+					changed = false
+					break
+				}
+				s, err := interpolate(str)
+				if err != nil {
+					return fmt.Errorf("%s: %w", key, err)
+				}
+				changed = changed || s != str
+				out[i] = s
+			}
+			if changed {
+				viper.Set(key, out)
+			}
+		}
+	}
+	return nil
+}
+
+func interpolate(s string) (string, error) {
+	var err error
+	out := reInterpolation.ReplaceAllStringFunc(s, func(match string) string {
+		if match == "$${" {
+			return "${"
+		}
+		sm := reInterpolation.FindStringSubmatch(match)
+		name, fallback, hasDefault := sm[1], sm[2], strings.Contains(match, ":-")
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+		if hasDefault {
+			return fallback
+		}
+		if _, ok := os.LookupEnv(name); !ok && err == nil {
+			err = fmt.Errorf("%w: %s", errUndefinedVariable, name)
+		}
+		return ""
+	})
+	return out, err
+}
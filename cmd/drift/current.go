@@ -0,0 +1,41 @@
+package main
+
+import "github.com/spf13/cobra"
+
+const currentLong string = `Print the ID of the latest applied migration.
+
+This is the database's schema version, as a single number for deploy scripts
+and dashboards. Pass -v to print the migration's slug after it.
+
+Exits with a non-zero status if the migrations table doesn't exist. Prints
+nothing if no migrations have been applied.`
+
+func currentCmd(cli *CLI) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "current",
+		Short: "Print the ID of the latest applied migration",
+		Long:  currentLong,
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, _ []string) {
+			db, err := openDB()
+			if err != nil {
+				cli.Exitf(1, "open database connection: %s", err)
+			}
+			defer db.Close()
+
+			h, err := newMigrator(cli).Current(cmd.Context(), db)
+			if err != nil {
+				cli.Exitf(1, "current: %s", err)
+			}
+			if h == nil {
+				return
+			}
+			if cmd.Flags().Changed("verbosity") {
+				cli.Printf("%d %s", h.ID, h.Slug)
+				return
+			}
+			cli.Printf("%d", h.ID)
+		},
+	}
+	return cmd
+}
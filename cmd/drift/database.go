@@ -0,0 +1,143 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/metagram-net/drift"
+)
+
+var (
+	errNoDatabaseName     = errors.New("the database URL doesn't name a database")
+	errDropNotInteractive = errors.New("refusing to drop the database without --force when stdin is not a terminal")
+	errDropProtected      = errors.New("refusing to drop the database in a protected environment")
+)
+
+func dbCmd(cli *CLI) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "db",
+		Short: "Create or drop the configured database",
+		Args:  cobra.NoArgs,
+	}
+	cmd.AddCommand(dbCreateCmd(cli), dbDropCmd(cli))
+	return cmd
+}
+
+const dbCreateLong string = `Create the database named in the database URL.
+
+This connects to the maintenance database on the same server (see the
+maintenance-database setting) to create it, so a fresh development setup is
+just:
+
+    drift db create && drift migrate
+
+It does nothing if the database already exists.`
+
+func dbCreateCmd(cli *CLI) *cobra.Command {
+	return &cobra.Command{
+		Use:   "create",
+		Short: "Create the configured database",
+		Long:  dbCreateLong,
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, _ []string) {
+			ctx := cmd.Context()
+			name, err := databaseName()
+			if err != nil {
+				cli.Exitf(1, "create database: %s", err)
+			}
+			admin, err := openNamedDB(viper.GetString("maintenance-database"))
+			if err != nil {
+				cli.Exitf(1, "open maintenance database connection: %s", err)
+			}
+			defer admin.Close()
+
+			exists, err := drift.DatabaseExists(ctx, admin, name)
+			if err != nil {
+				cli.Exitf(1, "create database: %s", err)
+			}
+			if exists {
+				cli.Infof("Database already exists: %s", name)
+				return
+			}
+			if err := drift.CreateDatabase(ctx, admin, name); err != nil {
+				cli.Exitf(1, "create database: %s", err)
+			}
+			cli.Infof("Created database: %s", name)
+		},
+	}
+}
+
+const dbDropLong string = `Drop the database named in the database URL.
+
+This connects to the maintenance database on the same server (see the
+maintenance-database setting) to drop it. It asks for confirmation unless
+--force is set, and refuses to run in a protected environment.`
+
+func dbDropCmd(cli *CLI) *cobra.Command {
+	var force bool
+
+	cmd := &cobra.Command{
+		Use:   "drop",
+		Short: "Drop the configured database",
+		Long:  dbDropLong,
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, _ []string) {
+			ctx := cmd.Context()
+			if viper.GetBool("protected") {
+				cli.Exitf(1, "drop database: %s", errDropProtected)
+			}
+			name, err := databaseName()
+			if err != nil {
+				cli.Exitf(1, "drop database: %s", err)
+			}
+			if !force {
+				if !isTerminal(os.Stdin) {
+					cli.Exitf(1, "drop database: %s", errDropNotInteractive)
+				}
+				ok, err := askYesNo(cli, fmt.Sprintf("Drop database %s and everything in it?", name))
+				if err != nil {
+					cli.Exitf(1, "%s", err)
+				}
+				if !ok {
+					cli.Exitf(1, "Not dropping the database.")
+				}
+			}
+
+			admin, err := openNamedDB(viper.GetString("maintenance-database"))
+			if err != nil {
+				cli.Exitf(1, "open maintenance database connection: %s", err)
+			}
+			defer admin.Close()
+
+			if err := drift.DropDatabase(ctx, admin, name); err != nil {
+				cli.Exitf(1, "drop database: %s", err)
+			}
+			cli.Infof("Dropped database: %s", name)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.BoolVarP(&force, "force", "f", false, "Drop without asking for confirmation")
+	return cmd
+}
+
+// databaseName returns the name of the database in the configured URL.
+func databaseName() (string, error) {
+	url, _, err := connectionConfig(nil)
+	if err != nil {
+		return "", err
+	}
+	cfg, err := pgx.ParseConfig(url)
+	if err != nil {
+		return "", err
+	}
+	if cfg.Database == "" {
+		return "", errNoDatabaseName
+	}
+	return cfg.Database, nil
+}
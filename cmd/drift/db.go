@@ -0,0 +1,123 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/spf13/viper"
+
+	"github.com/metagram-net/drift/internal/dburl"
+)
+
+var errUnknownAuth = errors.New("unknown database-auth (expected password, rds-iam, or cloudsql-iam)")
+
+// openDB opens a connection pool for the configured database URL.
+func openDB() (*sql.DB, error) {
+	url, opts, err := connectionConfig(nil)
+	if err != nil {
+		return nil, err
+	}
+	d, err := dialect()
+	if err != nil {
+		return nil, err
+	}
+	if !isPostgres(d) {
+		// The Postgres connection options don't apply, so use the driver
+		// as is. It has to be built in.
+		return sql.Open(driverName(url), url)
+	}
+	return dburl.Open(url, opts...)
+}
+
+// openNamedDB opens a connection pool for a different database on the
+// configured server, using the same connection settings otherwise.
+func openNamedDB(name string) (*sql.DB, error) {
+	url, opts, err := connectionConfig(nil)
+	if err != nil {
+		return nil, err
+	}
+	return dburl.OpenDatabase(url, name, opts...)
+}
+
+// connectionConfig returns the configured database URL and connection
+// options. Secrets can come from files (like Kubernetes or Docker secrets) or
+// a prompt instead of the URL, so they don't show up in the environment or in
+// argv. The session settings override the configured ones.
+func connectionConfig(session map[string]string) (string, []dburl.Option, error) {
+	url := viper.GetString("database-url")
+	if file := viper.GetString("database-url-file"); file != "" {
+		b, err := readSecret(file)
+		if err != nil {
+			return "", nil, err
+		}
+		url = b
+	}
+
+	var opts []dburl.Option
+	switch auth := viper.GetString("database-auth"); auth {
+	case "", "password":
+	case "rds-iam":
+		opts = append(opts, dburl.WithPasswordFunc(dburl.RDSIAMToken))
+	case "cloudsql-iam":
+		opts = append(opts, dburl.WithPasswordFunc(dburl.CloudSQLIAMToken))
+	default:
+		return "", nil, fmt.Errorf("%w: %s", errUnknownAuth, auth)
+	}
+
+	if viper.GetBool("simple-protocol") {
+		opts = append(opts, dburl.WithSimpleProtocol())
+	}
+
+	settings := viper.GetStringMapString("session")
+	for name, value := range session {
+		settings[name] = value
+	}
+	if len(settings) > 0 {
+		opts = append(opts, dburl.WithSession(settings))
+	}
+
+	switch file := viper.GetString("database-password-file"); {
+	case file != "":
+		password, err := readSecret(file)
+		if err != nil {
+			return "", nil, err
+		}
+		opts = append(opts, dburl.WithPassword(password))
+	case viper.GetBool("prompt-password"):
+		password, err := promptPassword()
+		if err != nil {
+			return "", nil, err
+		}
+		opts = append(opts, dburl.WithPassword(password))
+	}
+	return url, opts, nil
+}
+
+// readSecret reads a secret from a file, without the trailing newline that
+// editors and `echo` tend to add.
+func readSecret(path string) (string, error) {
+	b, err := os.ReadFile(path) //#nosec G304 // The user chose the path.
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
+
+var (
+	promptOnce     sync.Once
+	promptedSecret string
+	promptErr      error
+)
+
+// promptPassword asks for the database password on the terminal, once per
+// process.
+func promptPassword() (string, error) {
+	promptOnce.Do(func() {
+		promptedSecret, promptErr = readPassword("Database password: ")
+	})
+	return promptedSecret, promptErr
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/viper"
+
+	"github.com/metagram-net/drift"
+)
+
+var errUnknownDriver = errors.New("no dialect for driver")
+
+// databaseURL returns the configured database URL without any of the
+// password settings applied.
+func databaseURL() string {
+	url := viper.GetString("database-url")
+	if file := viper.GetString("database-url-file"); file != "" {
+		// openDB reports the error, if there is one.
+		url, _ = readSecret(file)
+	}
+	return url
+}
+
+// driverName returns the name of the database driver: the driver setting, or
+// else the database URL's scheme (like cockroachdb or sqlserver), or else
+// postgres.
+func driverName(url string) string {
+	if name := viper.GetString("driver"); name != "" {
+		return name
+	}
+	if scheme, _, ok := strings.Cut(url, "://"); ok {
+		return scheme
+	}
+	// A key/value connection string, or the default server.
+	return "postgres"
+}
+
+// dialect returns the dialect registered for the driver.
+func dialect() (drift.Dialect, error) {
+	name := driverName(databaseURL())
+	d, ok := drift.LookupDialect(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q (want one of %v)", errUnknownDriver, name, drift.Dialects())
+	}
+	return d, nil
+}
+
+// isPostgres reports whether the dialect connects through the pgx driver,
+// with the Postgres connection options.
+func isPostgres(d drift.Dialect) bool {
+	switch d.(type) {
+	case drift.Postgres, drift.Cockroach:
+		return true
+	}
+	return false
+}
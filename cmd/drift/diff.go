@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"net/url"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+
+	"github.com/metagram-net/drift"
+	"github.com/metagram-net/drift/internal/dburl"
+)
+
+const diffLong string = `Compare the schemas of two databases.
+
+Each database is either the name of a target in the config file or a
+connection URL. Drift reads the tables, columns, constraints, and indexes of
+both from the system catalogs and lists what changes from the first database
+to the second. Changes that were made by hand (like a hotfix index that never
+became a migration) show up here.
+
+With --sql, this prints the DDL that would make the first database match the
+second instead. Review it before running it: it drops objects that only exist
+in the first database.
+
+Exits with a non-zero status if the schemas differ.`
+
+func diffCmd(cli *CLI) *cobra.Command {
+	var showSQL bool
+
+	cmd := &cobra.Command{
+		Use:   "diff <from> <to>",
+		Short: "Compare the schemas of two databases",
+		Long:  diffLong,
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx := cmd.Context()
+			from := inspectDatabase(ctx, cli, args[0])
+			to := inspectDatabase(ctx, cli, args[1])
+
+			cs := drift.DiffSchemas(from, to)
+			if len(cs) == 0 {
+				cli.Infof("No differences found.")
+				return
+			}
+
+			if showSQL {
+				cli.Printf("%s", strings.TrimSuffix(drift.SchemaChangesSQL(cs), "\n"))
+			} else {
+				var b bytes.Buffer
+				t := tablewriter.NewWriter(&b)
+				t.SetAutoFormatHeaders(false)
+				t.SetAutoWrapText(false)
+				t.SetHeader([]string{"Object", "Name", "Change"})
+				for _, c := range cs {
+					t.Append([]string{c.Object, c.Name, c.Detail})
+				}
+				t.Render()
+				cli.Printf("%s", b.String())
+			}
+			cli.Exitf(1, "Found %d differences", len(cs))
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.BoolVar(&showSQL, "sql", false, "Print the DDL that makes the first database match the second")
+	return cmd
+}
+
+// inspectDatabase reads the schema of the database named by a config target
+// or a connection URL.
+func inspectDatabase(ctx context.Context, cli *CLI, name string) *drift.Schema {
+	var db *sql.DB
+	var err error
+	if strings.Contains(name, "://") {
+		db, err = dburl.Open(name)
+		// Keep any password in the URL out of the messages below.
+		if u, perr := url.Parse(name); perr == nil {
+			name = u.Redacted()
+		}
+	} else {
+		if err := selectTarget(name); err != nil {
+			cli.Exitf(1, "select target %s: %s", name, err)
+		}
+		db, err = openDB()
+	}
+	if err != nil {
+		cli.Exitf(1, "open database connection for %s: %s", name, err)
+	}
+	defer db.Close()
+
+	s, err := drift.Inspect(ctx, db)
+	if err != nil {
+		cli.Exitf(1, "inspect %s: %s", name, err)
+	}
+	// Only databases migrated with --lock have the lock table, and only ones
+	// where a migration failed have the failures table.
+	m := newMigrator(cli)
+	return s.Without(m.LockTable()).Without(m.FailuresTable())
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+
+	"github.com/metagram-net/drift"
+)
+
+var errPgDumpAuth = errors.New("pg_dump can't use token authentication")
+
+// writeSchemaDump writes a structure dump of the database to the path. The
+// schema comes from pg_dump if it's installed and can connect, or else from
+// Drift's own catalog introspection.
+func writeSchemaDump(ctx context.Context, cli *CLI, db *sql.DB, m *drift.Migrator, path string) error {
+	var b bytes.Buffer
+	exclude := []string{drift.Postgres{}.Quote(m.LockTable()), drift.Postgres{}.Quote(m.FailuresTable())}
+	if err := pgDump(ctx, &b, exclude...); err != nil {
+		cli.Debugf("Dumping the schema without pg_dump: %s", err)
+		b.Reset()
+		if err := m.DumpSchema(ctx, db, &b); err != nil {
+			return err
+		}
+	} else if err := m.DumpRecords(ctx, db, &b); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil { //#nosec G301 // The schema isn't secret.
+		return err
+	}
+	return os.WriteFile(path, b.Bytes(), 0o644) //#nosec G306 -- The schema isn't secret.
+}
+
+// pgDump writes the schema of the configured database to b using pg_dump,
+// leaving out the quoted, schema-qualified tables. The password goes in the
+// environment rather than argv.
+func pgDump(ctx context.Context, b *bytes.Buffer, exclude ...string) error {
+	path, err := exec.LookPath("pg_dump")
+	if err != nil {
+		return err
+	}
+	if auth := viper.GetString("database-auth"); auth != "" && auth != "password" {
+		return fmt.Errorf("%w: %s", errPgDumpAuth, auth)
+	}
+	dbURL, _, err := connectionConfig(nil)
+	if err != nil {
+		return err
+	}
+	u, err := url.Parse(dbURL)
+	if err != nil {
+		return err
+	}
+
+	env := os.Environ()
+	password, hasPassword := u.User.Password()
+	switch file := viper.GetString("database-password-file"); {
+	case file != "":
+		if password, err = readSecret(file); err != nil {
+			return err
+		}
+		hasPassword = true
+	case viper.GetBool("prompt-password"):
+		if password, err = promptPassword(); err != nil {
+			return err
+		}
+		hasPassword = true
+	}
+	if hasPassword {
+		env = append(env, "PGPASSWORD="+password)
+	}
+	if u.User != nil {
+		u.User = url.User(u.User.Username())
+	}
+
+	args := []string{"--schema-only", "--no-owner", "--no-privileges", "--no-password"}
+	for _, t := range exclude {
+		args = append(args, "--exclude-table", t)
+	}
+	args = append(args, "--dbname", u.String())
+	cmd := exec.CommandContext(ctx, path, args...) //#nosec G204 -- The URL comes from the user's own config.
+	cmd.Env = env
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	b.WriteString(stripDumpVersions(string(out)))
+	return nil
+}
+
+// stripDumpVersions removes the server and pg_dump version comments from a
+// dump, so upgrading either doesn't change the committed file.
+func stripDumpVersions(dump string) string {
+	lines := strings.SplitAfter(dump, "\n")
+	kept := lines[:0]
+	for _, l := range lines {
+		if strings.HasPrefix(l, "-- Dumped from database version") || strings.HasPrefix(l, "-- Dumped by pg_dump version") {
+			continue
+		}
+		kept = append(kept, l)
+	}
+	return strings.Join(kept, "")
+}
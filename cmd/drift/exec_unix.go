@@ -0,0 +1,20 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// execCommand replaces the Drift process with the command, so the command gets
+// Drift's PID and receives signals directly. It only returns on error.
+func execCommand(args []string) error {
+	path, err := exec.LookPath(args[0])
+	if err != nil {
+		return err
+	}
+	//#nosec G204 // The command comes from the user running Drift.
+	return syscall.Exec(path, args, os.Environ())
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+
+	"github.com/metagram-net/drift"
+)
+
+var errInvalidExportFormat = errors.New("invalid export format")
+
+const exportLong string = `Export migrations to another migration tool's format.
+
+This writes copies of the migrations to the output directory and prints a
+report mapping each migration to the files written for it. The migration files
+themselves aren't changed. Supported formats:
+
+  golang-migrate   0001_slug.up.sql and 0001_slug.down.sql
+  goose            0001_slug.sql with Up and Down sections
+
+A down.sql file next to a migration (from a template directory) becomes its
+down migration. The init migration is left out, since it only sets up Drift's
+own table and functions. The report warns about Drift features (like
+no-transaction migrations that claim themselves) that the other tool won't
+understand.`
+
+func exportCmd(cli *CLI) *cobra.Command {
+	var (
+		to  string
+		out string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "export --to <format> --out <dir>",
+		Short: "Export migrations to another migration tool's format",
+		Long:  exportLong,
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, _ []string) {
+			format, err := parseExportFormat(to)
+			if err != nil {
+				cli.Exitf(1, "parse --to: %s", err)
+			}
+			es, err := newMigrator(cli).Export(migrationsDir(), format, out)
+			if err != nil {
+				cli.Exitf(1, "export: %s", err)
+			}
+
+			var b bytes.Buffer
+			t := tablewriter.NewWriter(&b)
+			t.SetAutoFormatHeaders(false)
+			t.SetAutoWrapText(false)
+			t.SetHeader([]string{"ID", "Migration", "Exported", "Warnings"})
+			for _, e := range es {
+				t.Append([]string{e.ID.String(), e.From, strings.Join(e.Files, "\n"), strings.Join(e.Warnings, "\n")})
+			}
+			t.Render()
+			cli.Printf("%s", strings.TrimSuffix(b.String(), "\n"))
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&to, "to", "", "The format to export to (golang-migrate, goose)")
+	flags.StringVar(&out, "out", "", "The directory to write the exported files to")
+	_ = cmd.MarkFlagRequired("to")
+	_ = cmd.MarkFlagRequired("out")
+	_ = cmd.RegisterFlagCompletionFunc("to", func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+		var formats []string
+		for _, f := range drift.ExportFormats {
+			formats = append(formats, string(f))
+		}
+		return formats, cobra.ShellCompDirectiveNoFileComp
+	})
+	return cmd
+}
+
+// parseExportFormat parses a --to value.
+func parseExportFormat(s string) (drift.ExportFormat, error) {
+	for _, f := range drift.ExportFormats {
+		if string(f) == s {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %q (want one of %v)", errInvalidExportFormat, s, drift.ExportFormats)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/metagram-net/drift"
+)
+
+var errFixturesProtected = errors.New("refusing to truncate tables in a protected environment")
+
+func fixturesCmd(cli *CLI) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "fixtures",
+		Short: "Load test fixtures",
+		Args:  cobra.NoArgs,
+	}
+	cmd.AddCommand(fixturesLoadCmd(cli))
+	return cmd
+}
+
+const fixturesLoadLong string = `Reset tables to the contents of fixture files, for integration tests.
+
+Each .csv or .sql file in the fixtures directory holds rows for the table it's
+named after, like users.csv or auth.users.sql. CSV files need a header row of
+column names and are loaded with COPY. SQL files are run as is.
+
+In one transaction, this truncates the fixture tables and the tables listed in
+fixtures.tables (restarting their sequences), then loads the files with
+referenced tables first. It refuses to run in a protected environment.`
+
+func fixturesLoadCmd(cli *CLI) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "load",
+		Short: "Truncate tables and load fixture files",
+		Long:  fixturesLoadLong,
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, _ []string) {
+			if viper.GetBool("protected") {
+				cli.Exitf(1, "load fixtures: %s", errFixturesProtected)
+			}
+			db, err := openDB()
+			if err != nil {
+				cli.Exitf(1, "open database connection: %s", err)
+			}
+			defer db.Close()
+
+			dir := viper.GetString("fixtures.dir")
+			loaded, err := drift.LoadFixtures(cmd.Context(), db, os.DirFS(dir), viper.GetStringSlice("fixtures.tables")...)
+			if err != nil {
+				cli.Exitf(1, "load fixtures: %s", err)
+			}
+			for _, f := range loaded {
+				cli.Infof("Loaded %d rows into %s from %s", f.Rows, f.Table, f.Path)
+			}
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.String("dir", defaultFixturesDir, "Directory containing fixture files")
+	viper.BindPFlag("fixtures.dir", flags.Lookup("dir"))
+	return cmd
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+
+	"github.com/metagram-net/drift"
+)
+
+const historyLong string = `Show the applied migrations.
+
+For each migration, this shows when it was applied, how long it took, and who
+applied it. Durations and appliers are only known for migrations applied since
+the migrations table gained the duration_ms and applied_by columns.
+
+Use --format=json for machine-readable output.`
+
+type historyRow struct {
+	ID         drift.MigrationID `json:"id"`
+	Slug       string            `json:"slug"`
+	RunAt      time.Time         `json:"run_at"`
+	DurationMS *int64            `json:"duration_ms"`
+	AppliedBy  *string           `json:"applied_by"`
+	Faked      bool              `json:"faked"`
+}
+
+func historyCmd(cli *CLI) *cobra.Command {
+	var format string
+
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "Show the applied migrations",
+		Long:  historyLong,
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, _ []string) {
+			if format != "table" && format != "json" {
+				cli.Exitf(1, "unknown format %q: expected table or json", format)
+			}
+
+			db, err := openDB()
+			if err != nil {
+				cli.Exitf(1, "open database connection: %s", err)
+			}
+			defer db.Close()
+
+			hs, err := newMigrator(cli).History(cmd.Context(), db)
+			if err != nil {
+				cli.Exitf(1, "history: %s", err)
+			}
+
+			if format == "json" {
+				rows := make([]historyRow, 0, len(hs))
+				for _, h := range hs {
+					r := historyRow{ID: h.ID, Slug: h.Slug, RunAt: h.RunAt, Faked: h.Faked}
+					if h.Duration != nil {
+						ms := h.Duration.Milliseconds()
+						r.DurationMS = &ms
+					}
+					if h.AppliedBy != "" {
+						r.AppliedBy = &h.AppliedBy
+					}
+					rows = append(rows, r)
+				}
+				b, err := json.MarshalIndent(rows, "", "  ")
+				if err != nil {
+					cli.Exitf(1, "encode history: %s", err)
+				}
+				cli.Printf("%s", b)
+				return
+			}
+
+			var b bytes.Buffer
+			t := tablewriter.NewWriter(&b)
+			t.SetAutoFormatHeaders(false)
+			t.SetAutoWrapText(false)
+			t.SetHeader([]string{"ID", "Slug", "Applied at", "Duration", "Applied by", "Faked"})
+			for _, h := range hs {
+				duration := ""
+				if h.Duration != nil {
+					duration = h.Duration.String()
+				}
+				faked := ""
+				if h.Faked {
+					faked = "yes"
+				}
+				t.Append([]string{
+					strconv.FormatInt(int64(h.ID), 10),
+					h.Slug,
+					h.RunAt.Format(time.RFC3339),
+					duration,
+					h.AppliedBy,
+					faked,
+				})
+			}
+			t.Render()
+			cli.Printf("%s", b.String())
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&format, "format", "table", "Output format (table or json)")
+	return cmd
+}
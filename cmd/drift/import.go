@@ -0,0 +1,130 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/metagram-net/drift"
+)
+
+var errInvalidImportSource = errors.New("invalid import source")
+
+const importLong string = `Import applied migrations from another migration tool.
+
+This reads the other tool's tracking table and records the migrations it
+applied in Drift's migrations table, so switching tools doesn't run them
+again. Slugs come from the tool's migration files in the migrations directory.
+Supported tools:
+
+  goose            goose_db_version, 00001_slug.sql
+  golang-migrate   schema_migrations, 1_slug.up.sql and 1_slug.down.sql
+  flyway           flyway_schema_history, V1__slug.sql and U1__slug.sql
+  rails            schema_migrations, 20240101000000_slug.rb
+
+Drift's migrations table has to exist first, so create and apply the init
+migration before importing:
+
+  drift setup
+  drift migrate --upto 0
+
+golang-migrate and Rails use schema_migrations, which is also Drift's default
+table name. Configure a different migrations-table for Drift, or rename the
+other tool's table and pass --source-table.
+
+With --convert-files, the tool's SQL files are also renamed to Drift's
+convention. Down migrations become down.sql in the migration's companion
+directory, and goose files keep only their Up section.
+
+Without --write, this only prints what it would do.`
+
+func importCmd(cli *CLI) *cobra.Command {
+	var (
+		from         string
+		sourceTable  string
+		convertFiles bool
+		write        bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "import --from <tool>",
+		Short: "Import applied migrations from another migration tool",
+		Long:  importLong,
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, _ []string) {
+			source, err := parseImportSource(from)
+			if err != nil {
+				cli.Exitf(1, "parse --from: %s", err)
+			}
+			dir := migrationsDir()
+
+			db, err := openDB()
+			if err != nil {
+				cli.Exitf(1, "open database connection: %s", err)
+			}
+			defer db.Close()
+
+			m := newMigrator(cli)
+			ims, err := m.ImportHistory(cmd.Context(), db, source, sourceTable, dir, write)
+			if errors.Is(err, drift.ErrNoMigrationsTable) {
+				cli.Exitf(1, "import: %s (drift setup && drift migrate --upto 0)", err)
+			}
+			if err != nil {
+				cli.Exitf(1, "import: %s", err)
+			}
+			if len(ims) == 0 {
+				cli.Infof("No applied migrations to import.")
+			}
+			for _, im := range ims {
+				applied := "unknown"
+				if !im.AppliedAt.IsZero() {
+					applied = im.AppliedAt.Format("2006-01-02 15:04:05")
+				}
+				cli.Infof("  %s-%s (applied %s)", im.ID.String(), im.Slug, applied)
+			}
+
+			if convertFiles {
+				cs, err := m.ConvertFiles(source, dir, write)
+				if err != nil {
+					cli.Exitf(1, "convert files: %s", err)
+				}
+				for _, c := range cs {
+					if c.To == "" {
+						cli.Infof("Can't convert %s; rewrite it as SQL by hand", c.From)
+						continue
+					}
+					cli.Infof("  %s -> %s", c.From, c.To)
+				}
+			}
+			if !write {
+				cli.Infof("Not importing because --write is off")
+			}
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&from, "from", "", "The tool to import from (goose, golang-migrate, flyway, rails)")
+	flags.StringVar(&sourceTable, "source-table", "", "The other tool's tracking table (default: the tool's default)")
+	flags.BoolVar(&convertFiles, "convert-files", false, "Also rename the tool's SQL files to Drift's convention")
+	flags.BoolVarP(&write, "write", "w", false, "Import (and convert) instead of just printing what would change")
+	_ = cmd.MarkFlagRequired("from")
+	_ = cmd.RegisterFlagCompletionFunc("from", func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+		var sources []string
+		for _, s := range drift.ImportSources {
+			sources = append(sources, string(s))
+		}
+		return sources, cobra.ShellCompDirectiveNoFileComp
+	})
+	return cmd
+}
+
+// parseImportSource parses an --from value.
+func parseImportSource(s string) (drift.ImportSource, error) {
+	for _, src := range drift.ImportSources {
+		if string(src) == s {
+			return src, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %q (want one of %v)", errInvalidImportSource, s, drift.ImportSources)
+}
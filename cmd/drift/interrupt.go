@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"sync/atomic"
+)
+
+// interrupts tracks how the running command wants to handle interrupts.
+type interrupts struct {
+	// stop is closed on the first interrupt if the command stops gracefully.
+	stop chan struct{}
+	// graceful is set by commands that can finish their current work and then
+	// stop when stop is closed.
+	graceful atomic.Bool
+}
+
+// StopGracefully makes the first interrupt close the returned channel instead
+// of cancelling the command's context. A second interrupt still cancels it.
+func (cli CLI) StopGracefully() <-chan struct{} {
+	cli.interrupts.graceful.Store(true)
+	return cli.interrupts.stop
+}
+
+// handleInterrupts cancels the context on interrupt. For commands that stop
+// gracefully, the first interrupt only asks them to stop and the second one
+// cancels the context. After that, interrupts get the default behavior
+// (killing the process).
+func handleInterrupts(intr *interrupts, cancel context.CancelFunc) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt)
+	go func() {
+		<-sigs
+		if intr.graceful.Load() {
+			log.Print("Interrupt received, finishing the current migration before stopping. Interrupt again to cancel it.")
+			close(intr.stop)
+			<-sigs
+		}
+		signal.Stop(sigs)
+		cancel()
+		log.Print("Interrupt received, cleaning up before quitting. Interrupt again to force-quit.")
+	}()
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/metagram-net/drift"
+)
+
+const lintLong string = `Check the migration files for dangerous patterns.
+
+The rules (and their default severities) are:
+
+- index-concurrently (warning): create index without concurrently
+- concurrently-in-transaction (error): concurrent index changes without
+  --drift:no-transaction
+- set-not-null (warning): set not null without first validating a check
+  constraint on the same table (and, if the file adds it, the same column)
+- column-type-change (warning): alter column type, which usually rewrites the
+  table
+- drop-if-exists (warning): drop without if exists
+- destructive (warning): drop table, drop column, truncate, and so on
+
+Disable rules or change their severities in the [lint] section of the config
+file. Exits with a non-zero status if there are any errors.
+
+Use --format to choose how findings are printed:
+
+- text (default): path:line: severity: message [rule]
+- github: GitHub Actions workflow commands, which show as annotations on pull
+  requests
+- sarif: a SARIF 2.1.0 log for code scanning tools`
+
+func lintCmd(cli *CLI) *cobra.Command {
+	var format string
+
+	cmd := &cobra.Command{
+		Use:   "lint",
+		Short: "Check the migration files for dangerous patterns",
+		Long:  lintLong,
+		Args:  cobra.NoArgs,
+		Run: func(_ *cobra.Command, _ []string) {
+			if format != "text" && format != "github" && format != "sarif" {
+				cli.Exitf(1, "unknown format %q: expected text, github, or sarif", format)
+			}
+
+			cfg := lintConfig()
+			fs, err := newMigrator(cli).Lint(migrationsDir(), cfg)
+			if err != nil {
+				cli.Exitf(1, "lint: %s", err)
+			}
+
+			if format == "sarif" {
+				b, err := sarif(fs, cfg)
+				if err != nil {
+					cli.Exitf(1, "encode SARIF: %s", err)
+				}
+				cli.Printf("%s", b)
+			}
+			var errs int
+			for _, f := range fs {
+				switch format {
+				case "text":
+					cli.Printf("%s", f)
+				case "github":
+					cli.Printf("::%s file=%s,line=%d,title=%s::%s", f.Severity, f.Path, f.Line, f.Rule, f.Message)
+				}
+				if f.Severity == drift.SeverityError {
+					errs++
+				}
+			}
+			if errs > 0 {
+				cli.Exitf(1, "Found %d errors", errs)
+			}
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&format, "format", "text", "Output format (text, github, or sarif)")
+	return cmd
+}
+
+// lintConfig reads the [lint] section of the config file.
+func lintConfig() drift.LintConfig {
+	cfg := drift.LintConfig{
+		Disabled: viper.GetStringSlice("lint.disable"),
+		Severity: make(map[string]drift.Severity),
+	}
+	for id, sev := range viper.GetStringMapString("lint.severity") {
+		cfg.Severity[id] = drift.Severity(sev)
+	}
+	return cfg
+}
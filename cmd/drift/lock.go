@@ -0,0 +1,130 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/metagram-net/drift"
+)
+
+var errUnlockNotInteractive = errors.New("refusing to force-unlock without --force when stdin is not a terminal")
+
+const lockStatusLong string = `Show who holds the migration lock (taken by migrate --lock).
+
+This prints the holding session's server PID, the host that Drift runs on,
+and when it took the lock, from the lock metadata row Drift writes, along with
+how many other runs are waiting for it.`
+
+const forceUnlockLong string = `Release the migration lock held by another Drift process.
+
+A Drift process that crashed or hung can leave its connection (and so the
+lock) open, so every other run waits forever. This terminates the holding
+session on the server, which releases the lock.
+
+Only do this if the holder is really gone: if it's still applying a
+migration, that migration is rolled back, or left half-done if it runs outside
+of a transaction. Check with "drift lock-status" first. This asks for
+confirmation unless --force is set.`
+
+func lockStatusCmd(cli *CLI) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "lock-status",
+		Short: "Show who holds the migration lock",
+		Long:  lockStatusLong,
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, _ []string) {
+			db, err := openDB()
+			if err != nil {
+				cli.Exitf(1, "open database connection: %s", err)
+			}
+			defer db.Close()
+
+			info, err := newMigrator(cli).LockStatus(cmd.Context(), db)
+			if errors.Is(err, drift.ErrNotLocked) {
+				cli.Printf("The migration lock isn't held.")
+				return
+			}
+			if err != nil {
+				cli.Exitf(1, "lock status: %s", err)
+			}
+			printLockInfo(cli, info)
+		},
+	}
+	return cmd
+}
+
+func forceUnlockCmd(cli *CLI) *cobra.Command {
+	var force bool
+
+	cmd := &cobra.Command{
+		Use:   "force-unlock",
+		Short: "Release the migration lock held by another Drift process",
+		Long:  forceUnlockLong,
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, _ []string) {
+			ctx := cmd.Context()
+			db, err := openDB()
+			if err != nil {
+				cli.Exitf(1, "open database connection: %s", err)
+			}
+			defer db.Close()
+
+			m := newMigrator(cli)
+			info, err := m.LockStatus(ctx, db)
+			if errors.Is(err, drift.ErrNotLocked) {
+				cli.Infof("The migration lock isn't held.")
+				return
+			}
+			if err != nil {
+				cli.Exitf(1, "lock status: %s", err)
+			}
+			printLockInfo(cli, info)
+			if info.State == "active" {
+				cli.Warnf("The holder is running a query right now, so it might still be applying a migration.")
+			}
+			if !force {
+				if !isTerminal(os.Stdin) {
+					cli.Exitf(1, "force-unlock: %s", errUnlockNotInteractive)
+				}
+				ok, err := askYesNo(cli, fmt.Sprintf("Terminate pid %d to release the lock?", info.PID))
+				if err != nil {
+					cli.Exitf(1, "%s", err)
+				}
+				if !ok {
+					cli.Exitf(1, "Not releasing the lock.")
+				}
+			}
+
+			info, err = m.ForceUnlock(ctx, db)
+			if errors.Is(err, drift.ErrNotLocked) {
+				cli.Infof("The lock was released in the meantime.")
+				return
+			}
+			if err != nil {
+				cli.Exitf(1, "force-unlock: %s", err)
+			}
+			cli.Infof("Terminated pid %d and released the migration lock.", info.PID)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.BoolVarP(&force, "force", "f", false, "Release the lock without asking for confirmation")
+	return cmd
+}
+
+func printLockInfo(cli *CLI, info *drift.LockInfo) {
+	cli.Printf("Lock key:   %d", info.Key)
+	cli.Printf("Held by:    pid %d (%s)", info.PID, info.State)
+	if info.Host != "" {
+		cli.Printf("Host:       %s", info.Host)
+	}
+	if info.Client != "" {
+		cli.Printf("Client:     %s", info.Client)
+	}
+	cli.Printf("Since:      %s (%s ago)", info.StartedAt.Format(time.RFC3339), time.Since(info.StartedAt).Round(time.Second))
+	cli.Printf("Waiting:    %d", info.Waiting)
+}
@@ -3,16 +3,20 @@ package main
 import (
 	"context"
 	"errors"
-	"log"
 	"os"
-	"os/signal"
 	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+
+	"github.com/metagram-net/drift"
 )
 
-const defaultMigrationsDir = "migrations"
+const (
+	defaultMigrationsDir = "migrations"
+	defaultSeedsDir      = "seeds"
+	defaultFixturesDir   = "fixtures"
+)
 
 func init() {
 	viper.SetConfigName("drift")
@@ -23,61 +27,186 @@ func init() {
 	viper.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
 	viper.AutomaticEnv()
 
+	viper.SetDefault("database-url", "")
+	viper.SetDefault("database-url-file", "")
+	viper.SetDefault("database-auth", "")
+	viper.SetDefault("database-password-file", "")
+	viper.SetDefault("maintenance-database", "postgres")
+	viper.SetDefault("query-timeout", "30s")
+	viper.SetDefault("lock-timeout", "0")
+	viper.SetDefault("statement-timeout", "0")
+	viper.SetDefault("blocking-report", "10s")
+	viper.SetDefault("claim-strategy", "")
+	viper.SetDefault("claim-sql", "")
+	viper.SetDefault("driver", "")
+	viper.SetDefault("simple-protocol", false)
 	viper.SetDefault("migrations-dir", defaultMigrationsDir)
+	viper.SetDefault("migrations-schema", drift.DefaultSchema)
+	viper.SetDefault("migrations-table", drift.DefaultTable)
+	viper.SetDefault("seeds-dir", defaultSeedsDir)
+	viper.SetDefault("seeds-table", drift.DefaultSeedTable)
+	viper.SetDefault("failures-table", drift.DefaultFailuresTable)
+	viper.SetDefault("seed-mode", string(drift.SeedOnce))
+	viper.SetDefault("fixtures.dir", defaultFixturesDir)
+	viper.SetDefault("fixtures.tables", []string{})
 	viper.SetDefault("verbosity", 1)
+	viper.SetDefault("quiet", false)
+	viper.SetDefault("show-sql", false)
 	viper.SetDefault("template-file", "")
+	viper.SetDefault("module", "")
+	viper.SetDefault("target", "")
+	viper.SetDefault("protected", false)
+	viper.SetDefault("author", "")
+	viper.SetDefault("layout", string(drift.LayoutFile))
+	viper.SetDefault("dump-schema", "")
+	viper.SetDefault("environment", "")
+	viper.SetDefault("pushgateway.url", "")
+	viper.SetDefault("pushgateway.job", "drift")
+	viper.SetDefault("notify.webhook", "")
+	viper.SetDefault("tenants.query", "")
+	viper.SetDefault("telemetry", false)
+	viper.SetDefault("telemetry-file", "")
+	viper.SetDefault("telemetry-exporter", "")
 }
 
 func main() {
-	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
-	go func() {
-		<-ctx.Done()
-		stop()
-		log.Print("Interrupt received, cleaning up before quitting. Interrupt again to force-quit.")
-	}()
-
-	err := rootCmd().ExecuteContext(ctx)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cli := &CLI{
+		stdout:     os.Stdout,
+		stderr:     os.Stderr,
+		verbosity:  InfoLevel,
+		colorOut:   colorEnabled(os.Stdout),
+		colorErr:   colorEnabled(os.Stderr),
+		interrupts: &interrupts{stop: make(chan struct{})},
+	}
+	handleInterrupts(cli.interrupts, cancel)
+
+	err := rootCmd(cli).ExecuteContext(ctx)
 	if err != nil {
+		cli.usage.finish(1)
+		cli.tracer.flush()
 		os.Exit(1)
 	}
+	cli.usage.finish(0)
+	cli.tracer.flush()
 }
 
-func rootCmd() *cobra.Command {
-	cli := &CLI{
-		stdout:    os.Stdout,
-		stderr:    os.Stderr,
-		verbosity: InfoLevel,
+// loadConfig reads the config file, if there is one, and selects the target
+// and module.
+func loadConfig() error {
+	err := viper.ReadInConfig()
+	var notFound viper.ConfigFileNotFoundError
+	if errors.As(err, &notFound) {
+		// The config file is optional, so use the defaults.
+	} else if err != nil {
+		return err
+	}
+	if err := interpolateConfig(); err != nil {
+		return err
 	}
+	if err := selectTarget(viper.GetString("target")); err != nil {
+		return err
+	}
+	return selectModule(viper.GetString("module"))
+}
 
+var errQuietVerbose = errors.New("--quiet and --verbosity can't be used together")
+
+func rootCmd(cli *CLI) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:     "drift",
 		Short:   "Manage SQL migrations",
-		Version: "0.1.1",
-		PersistentPreRunE: func(_ *cobra.Command, _ []string) error {
-			err := viper.ReadInConfig()
-			var notFound viper.ConfigFileNotFoundError
-			if errors.As(err, &notFound) {
-				// The config file is optional, so use the defaults.
-			} else if err != nil {
+		Version: version,
+		PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+			if err := loadConfig(); err != nil {
 				return err
 			}
-
+			if viper.GetBool("quiet") && cmd.Flags().Changed("verbosity") {
+				return errQuietVerbose
+			}
 			cli.SetVerbosity(Verbosity(viper.GetInt("verbosity")))
+			cli.quiet = viper.GetBool("quiet")
+			cli.usage = startUsage(cli, cmd.CommandPath())
+			cli.tracer = startTracing(cli)
 			return nil
 		},
 	}
 
 	flags := cmd.PersistentFlags()
-	flags.String("migrations-dir", defaultMigrationsDir, "Directory containing migration files")
+	flags.StringSlice("migrations-dir", []string{defaultMigrationsDir}, "Directory containing migration files (repeat to merge several)")
+	flags.String("migrations-schema", drift.DefaultSchema, "Schema containing the migrations table")
+	flags.String("migrations-table", drift.DefaultTable, "Table that records applied migrations")
 	flags.CountP("verbosity", "v", "Log verbosity")
+	flags.BoolP("quiet", "q", false, "Only log errors")
+	flags.Bool("show-sql", false, "Log each statement before running it, and the rows it affected")
+	flags.String("module", "", "Use the migrations directory and table of this module from the config file")
+	flags.String("target", "", "Use the database and migrations settings of this target from the config file")
+	flags.String("driver", "", "Database driver and dialect (default: the database URL's scheme, or postgres)")
+	flags.Bool("simple-protocol", false, "Don't prepare statements or rely on session state, for PgBouncer in transaction pooling mode")
+	flags.Bool("prompt-password", false, "Ask for the database password instead of taking it from the database URL")
 	viper.BindPFlags(flags)
 
-	cmd.AddCommand(
+	// These commands read the migration files, so they fetch the remote
+	// sources first. The others work without the network.
+	readers := []*cobra.Command{
 		migrateCmd(cli),
+		applyCmd(cli),
+		skipCmd(cli),
+		pruneCmd(cli),
+		exportCmd(cli),
+		planCmd(cli),
+		resetCmd(cli),
+		watchCmd(cli),
+		verifyCmd(cli),
+		pendingCmd(cli),
+		statusCmd(cli),
+		retryCmd(cli),
+		showCmd(cli),
+		lintCmd(cli),
+		repairCmd(cli),
+		sealCmd(cli),
+		testCmd(cli),
+	}
+	for _, c := range readers {
+		c.PreRunE = func(cmd *cobra.Command, _ []string) error {
+			return fetchSources(cmd.Context(), cli)
+		}
+	}
+	cmd.AddCommand(readers...)
+
+	// These commands write migration files, which only works in a local
+	// directory, so they don't fetch anything.
+	writers := []*cobra.Command{
+		importCmd(cli),
 		newCmd(cli),
 		setupCmd(cli),
 		renumberCmd(cli),
+	}
+	for _, c := range writers {
+		c.PreRunE = func(*cobra.Command, []string) error {
+			return checkWritable()
+		}
+	}
+	cmd.AddCommand(writers...)
+	cmd.AddCommand(
+		unmarkCmd(cli),
+		dbCmd(cli),
+		seedCmd(cli),
+		fixturesCmd(cli),
 		migrationTemplateCmd(cli),
+		initTemplateCmd(cli),
+		diffCmd(cli),
+		historyCmd(cli),
+		appliedCmd(cli),
+		currentCmd(cli),
+		statsCmd(cli),
+		pingCmd(cli),
+		lockStatusCmd(cli),
+		forceUnlockCmd(cli),
+		configCmd(cli),
+		versionCmd(cli),
 	)
 	return cmd
 }
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/metagram-net/drift"
+)
+
+const skipLong string = `Record a migration as applied without running it.
+
+Use this when the change was already made by hand. The record is marked as
+faked (if the migrations table has a faked column) so it stands out in the
+history.`
+
+func skipCmd(cli *CLI) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:               "skip <id>",
+		Aliases:           []string{"mark-applied"},
+		Short:             "Record a migration as applied without running it",
+		Long:              skipLong,
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeIDArg(cli),
+		Run: func(cmd *cobra.Command, args []string) {
+			id := parseID(cli, args[0])
+
+			db, err := openDB()
+			if err != nil {
+				cli.Exitf(1, "open database connection: %s", err)
+			}
+			defer db.Close()
+
+			if err := newMigrator(cli).MarkApplied(cmd.Context(), db, migrationsDir(), id); err != nil {
+				cli.Exitf(1, "mark migration as applied: %s", err)
+			}
+		},
+	}
+	return cmd
+}
+
+const unmarkLong string = `Remove the record of an applied migration.
+
+The next migrate will run it again. This doesn't undo any changes the
+migration made.`
+
+func unmarkCmd(cli *CLI) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:               "unmark <id>",
+		Short:             "Remove the record of an applied migration",
+		Long:              unmarkLong,
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeIDArg(cli),
+		Run: func(cmd *cobra.Command, args []string) {
+			id := parseID(cli, args[0])
+
+			db, err := openDB()
+			if err != nil {
+				cli.Exitf(1, "open database connection: %s", err)
+			}
+			defer db.Close()
+
+			if err := newMigrator(cli).Unmark(cmd.Context(), db, id); err != nil {
+				cli.Exitf(1, "unmark migration: %s", err)
+			}
+		},
+	}
+	return cmd
+}
+
+// parseID parses a migration ID argument or exits.
+func parseID(cli *CLI, s string) drift.MigrationID {
+	var id drift.MigrationID
+	if err := id.Set(s); err != nil {
+		cli.Exitf(1, "invalid migration ID: %s", err)
+	}
+	return id
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/metagram-net/drift"
+)
+
+var errPushFailed = errors.New("pushgateway rejected the metrics")
+
+// pushMetrics sends the run's metrics to the configured Prometheus
+// Pushgateway, if there is one. The result may be nil if the run failed early.
+func pushMetrics(cli *CLI, res *drift.Result, runErr error) {
+	gateway := viper.GetString("pushgateway.url")
+	if gateway == "" {
+		return
+	}
+	if err := pushResult(gateway, res, runErr); err != nil {
+		cli.Infof("Push metrics to %s: %s", gateway, err)
+	}
+}
+
+func pushResult(gateway string, res *drift.Result, runErr error) error {
+	// Group by environment and module so runs against different databases
+	// don't replace each other's metrics.
+	target := strings.TrimSuffix(gateway, "/") + "/metrics/job/" + url.PathEscape(viper.GetString("pushgateway.job"))
+	for _, key := range []string{"environment", "module"} {
+		if v := viper.GetString(key); v != "" {
+			target += "/" + key + "/" + url.PathEscape(v)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, target, bytes.NewReader(metricsText(res, runErr, time.Now())))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%w: %s", errPushFailed, resp.Status)
+	}
+	return nil
+}
+
+// metricsText renders the run in the Prometheus text exposition format.
+func metricsText(res *drift.Result, runErr error, now time.Time) []byte {
+	if res == nil {
+		res = &drift.Result{}
+	}
+	var b bytes.Buffer
+	metric := func(name, typ, help string) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	}
+
+	metric("drift_migrations_applied_total", "counter", "Migrations applied by the last run.")
+	fmt.Fprintf(&b, "drift_migrations_applied_total %d\n", len(res.Applied))
+
+	metric("drift_migration_duration_seconds", "gauge", "How long each migration in the last run took to apply.")
+	for _, a := range res.Applied {
+		fmt.Fprintf(&b, "drift_migration_duration_seconds{id=%q,slug=%q} %g\n", a.ID.String(), a.Slug, a.Duration.Seconds())
+	}
+
+	metric("drift_pending_migrations", "gauge", "Migrations left unapplied after the last run.")
+	fmt.Fprintf(&b, "drift_pending_migrations %d\n", res.Pending)
+
+	failed := 0
+	if runErr != nil {
+		failed = 1
+	}
+	metric("drift_last_run_failed", "gauge", "Whether the last run failed (1) or succeeded (0).")
+	fmt.Fprintf(&b, "drift_last_run_failed %d\n", failed)
+
+	metric("drift_last_run_timestamp_seconds", "gauge", "When the last run finished, in Unix time.")
+	fmt.Fprintf(&b, "drift_last_run_timestamp_seconds %d\n", now.Unix())
+	return b.Bytes()
+}
@@ -1,9 +1,16 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
 
-	_ "github.com/jackc/pgx/v4/stdlib" // database/sql driver: pgx
+	"github.com/olekukonko/tablewriter"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
@@ -13,34 +20,392 @@ import (
 func migrateCmd(cli *CLI) *cobra.Command {
 	// Set the default ID out of range to distinguish explicit zero.
 	uptoID := drift.MigrationID(-1)
+	var (
+		lock         bool
+		only         []int64
+		progressFile string
+		stopAfter    time.Duration
+		timeout      time.Duration
+		chaos        string
+		sealFile     string
+		sealRef      string
+		auditContent bool
+		parallel     int
+		yes          bool
+		destructive  bool
+		lint         bool
+		steps        int
+		waitTimeout  time.Duration
+		then         bool
+		tenants      bool
+		schemas      []string
+		keepGoing    bool
+		continueOn   bool
+		allTargets   bool
+		vars         map[string]string
+		txMode       string
+		debugKeep    bool
+		dryRun       bool
+		explain      bool
+	)
 
-	cmd := &cobra.Command{
-		Use:   "migrate",
-		Short: "Run migrations",
-		Args:  cobra.NoArgs,
-		Run: func(cmd *cobra.Command, _ []string) {
-			ctx := cmd.Context()
-			dir := viper.GetString("migrations-dir")
+	// migrate runs the migrations for the selected target.
+	migrate := func(ctx context.Context) {
+		dir := migrationsDir()
+
+		db, err := openDB()
+		if err != nil {
+			cli.Exitf(1, "open database connection: %s", err)
+		}
+		defer db.Close()
+
+		var upto *drift.MigrationID
+		if uptoID >= 0 {
+			upto = &uptoID
+		}
 
-			db, err := sql.Open("pgx", viper.GetString("database-url"))
+		opts := []drift.Option{
+			drift.WithStopSignal(cli.StopGracefully()),
+		}
+		if then && dryRun {
+			cli.Exitf(1, "--then doesn't work with --dry-run, which doesn't apply the migrations the command needs")
+		}
+		// Replicas that start together must take turns, so --then always
+		// locks.
+		if lock || then {
+			opts = append(opts, drift.WithLock())
+		}
+		if len(vars) > 0 {
+			opts = append(opts, drift.WithVars(vars))
+		}
+		if txMode != "" {
+			mode, err := parseTransactionMode(txMode)
+			if err != nil {
+				cli.Exitf(1, "parse --transaction-mode: %s", err)
+			}
+			opts = append(opts, drift.WithTransactionMode(mode))
+		}
+		if debugKeep {
+			opts = append(opts, drift.WithDebugKeep())
+		}
+		if explain && !dryRun {
+			cli.Exitf(1, "--explain only works with --dry-run")
+		}
+		if dryRun {
+			opts = append(opts, drift.WithDryRun())
+		}
+		if explain {
+			opts = append(opts, drift.WithExplain())
+		}
+		if auditContent {
+			opts = append(opts, drift.WithAuditContent())
+		}
+		if viper.GetBool("protected") {
+			opts = append(opts, drift.WithConfirm(confirmProtected(cli, yes, destructive)))
+		}
+		if lint || viper.GetBool("lint.on-migrate") {
+			opts = append(opts, drift.WithLint(lintConfig()))
+		}
+		if waitTimeout > 0 {
+			opts = append(opts, drift.WithWaitTimeout(waitTimeout))
+		}
+		if steps > 0 {
+			opts = append(opts, drift.WithSteps(steps))
+		}
+		tenantMode := tenants || len(schemas) > 0
+		switch {
+		case tenantMode && parallel > 1:
+			// In tenant mode, parallelism is across tenants.
+			opts = append(opts, drift.WithTenantWorkers(parallel))
+		case parallel > 1:
+			opts = append(opts, drift.WithParallel(parallel))
+		}
+		if keepGoing && !tenantMode {
+			cli.Exitf(1, "--keep-going only applies with --tenants (use --continue-on-error to keep applying migrations after one fails)")
+		}
+		if keepGoing {
+			opts = append(opts, drift.WithKeepGoing())
+		}
+		if continueOn {
+			opts = append(opts, drift.WithContinueOnError())
+		}
+		seal, err := readSeal(sealFile, sealRef)
+		if err != nil {
+			cli.Exitf(1, "%s", err)
+		}
+		if seal != nil {
+			opts = append(opts, drift.WithSeal(seal))
+		}
+		if chaos != "" {
+			point, id, err := parseChaos(chaos)
+			if err != nil {
+				cli.Exitf(1, "parse --chaos: %s", err)
+			}
+			cli.Infof("Chaos mode: injecting a failure at %s. Only use this on a disposable database!", point)
+			opts = append(opts, drift.WithChaos(point, id))
+		}
+		if timeout > 0 {
+			opts = append(opts, drift.WithTimeout(timeout))
+		}
+		if stopAfter > 0 {
+			opts = append(opts, drift.WithStopAfter(stopAfter))
+		}
+		if progressFile != "" {
+			// The file describes one run, which tenants and targets would
+			// overwrite in turn.
+			if tenantMode || allTargets {
+				cli.Exitf(1, "--progress-file doesn't work with --tenants or --all-targets")
+			}
+			opts = append(opts, drift.WithProgressFile(progressFile))
+		}
+		if len(only) > 0 {
+			ids, err := migrationIDs(only)
 			if err != nil {
-				cli.Exitf(1, "open database connection: %s", err)
+				cli.Exitf(1, "parse --only: %s", err)
 			}
-			defer db.Close()
+			opts = append(opts, drift.WithOnly(ids...))
+		}
+		switch {
+		case tenantMode && explain:
+			cli.Exitf(1, "--explain doesn't work with --tenants")
+		case dryRun && !tenantMode:
+			dryRunOne(ctx, cli, db, newMigrator(cli, opts...), dir, upto)
+			return
+		}
+		if tenantMode {
+			migrateTenants(ctx, cli, db, newMigrator(cli, opts...), dir, upto, schemas)
+		} else {
+			migrateOne(ctx, cli, db, newMigrator(cli, opts...), dir, upto)
+		}
+	}
 
-			var upto *drift.MigrationID
-			if uptoID >= 0 {
-				upto = &uptoID
+	cmd := &cobra.Command{
+		Use:   "migrate [--then -- <command> [args...]]",
+		Short: "Run migrations",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if then {
+				return cobra.MinimumNArgs(1)(cmd, args)
+			}
+			return cobra.NoArgs(cmd, args)
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			if allTargets {
+				names, err := targetNames()
+				if err != nil {
+					cli.Exitf(1, "%s", err)
+				}
+				for _, name := range names {
+					if err := selectTarget(name); err != nil {
+						cli.Exitf(1, "select target %s: %s", name, err)
+					}
+					// A target can have its own remote migrations.
+					if err := fetchSources(cmd.Context(), cli); err != nil {
+						cli.Exitf(1, "%s", err)
+					}
+					cli.Infof("Migrating target: %s", name)
+					migrate(cmd.Context())
+				}
+			} else {
+				migrate(cmd.Context())
 			}
 
-			err = drift.Migrate(ctx, cli, db, dir, upto)
-			if err != nil {
-				cli.Exitf(1, "run migrations: %s", err)
+			if then {
+				cli.usage.finish(0)
+				cli.tracer.flush()
+				cli.Infof("Starting: %s", strings.Join(args, " "))
+				if err := execCommand(args); err != nil {
+					cli.Exitf(1, "start %s: %s", args[0], err)
+				}
 			}
 		},
 	}
 
 	flags := cmd.Flags()
 	flags.Var(&uptoID, "upto", "Maximum migration ID to run (default: run all migrations)")
+	flags.IntVar(&steps, "steps", 0, "Apply at most this many pending migrations (default: no limit)")
+	flags.Int64SliceVar(&only, "only", nil, "Apply only these pending migration IDs (comma-separated)")
+	flags.StringVar(&progressFile, "progress-file", "", "Write the plan and progress as JSON to this file during the run")
+	flags.DurationVar(&stopAfter, "stop-after", 0, "Don't start new migrations after this much time (e.g. 20m)")
+	flags.DurationVar(&waitTimeout, "wait-timeout", 0, "Retry connecting until the database is reachable or this much time passes")
+	flags.DurationVar(&timeout, "timeout", 0, "Cancel the run if applying migrations takes longer than this")
+	flags.StringVar(&sealFile, "seal-file", "", "Refuse to apply migrations that don't match this seal lockfile")
+	flags.StringVar(&sealRef, "seal-ref", "", "Refuse to apply migrations that don't match the files in this git ref")
+	flags.BoolVar(&lock, "lock", false, "Hold an advisory lock so concurrent runs wait for each other")
+	flags.IntVar(&parallel, "parallel", 1, "Apply up to this many concurrent-safe migrations (or tenants, with --tenants) at once")
+	flags.StringToStringVar(&vars, "var", nil, "Set a ${DRIFT_VAR_name} placeholder value, like --var owner=app_owner (repeatable)")
+	flags.StringVar(&txMode, "transaction-mode", string(drift.TransactionEach), "Transactions to use: each (one per migration), all (one for the whole run), or none")
+	flags.BoolVar(&dryRun, "dry-run", false, "Check and list the migrations that would be applied, without applying them")
+	flags.BoolVar(&explain, "explain", false, "With --dry-run, show the query plans of the DML statements in the pending migrations")
+	flags.BoolVar(&debugKeep, "debug-keep", false, "When a migration fails, keep the statements before the failing one for debugging (development only)")
+	flags.BoolVar(&auditContent, "audit-content", false, "Record the SQL text of each applied migration in the migrations table")
+	flags.BoolVar(&then, "then", false, "After migrating, replace Drift with the command given after -- (implies --lock)")
+	flags.BoolVar(&lint, "lint", false, "Refuse to apply migrations with lint errors")
+	flags.BoolVarP(&yes, "yes", "y", false, "Apply without asking for confirmation in a protected environment")
+	flags.BoolVar(&destructive, "allow-destructive", false, "Allow destructive migrations (like drop table) in a protected environment")
+	flags.BoolVar(&tenants, "tenants", false, "Migrate each tenant schema listed by the tenants config")
+	flags.StringSliceVar(&schemas, "schemas", nil, "Migrate each of these tenant schemas (comma-separated)")
+	flags.BoolVar(&allTargets, "all-targets", false, "Migrate every target in the config file, in order, stopping at the first failure")
+	flags.BoolVar(&keepGoing, "keep-going", false, "With --tenants, keep migrating other tenants after one fails")
+	flags.BoolVar(&continueOn, "continue-on-error", false, "After a migration fails, keep applying the later ones that declare their dependencies and don't depend on it")
+	// Chaos mode is for rehearsing recovery procedures, so keep it out of the
+	// normal help output.
+	flags.StringVar(&chaos, "chaos", "", "Inject a failure at a failpoint[:migration_id] (after-claim, mid-statement, before-commit)")
+	_ = flags.MarkHidden("chaos")
+	_ = cmd.RegisterFlagCompletionFunc("transaction-mode", func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+		var modes []string
+		for _, m := range drift.TransactionModes {
+			modes = append(modes, string(m))
+		}
+		return modes, cobra.ShellCompDirectiveNoFileComp
+	})
+	_ = cmd.RegisterFlagCompletionFunc("upto", completeIDs(cli))
+	_ = cmd.RegisterFlagCompletionFunc("only", completeIDs(cli))
 	return cmd
 }
+
+// migrateOne migrates the configured database and exits if that fails.
+func migrateOne(ctx context.Context, cli *CLI, db *sql.DB, m *drift.Migrator, dir string, upto *drift.MigrationID) {
+	res, err := m.Run(ctx, db, dir, upto)
+	pushMetrics(cli, res, err)
+	notify(cli, res, err)
+	if errors.Is(err, drift.ErrBudgetExhausted) {
+		cli.Exitf(exitBudgetExhausted, "Stopped early (%s). Remaining migrations: %s", err, skippedIDs(res, drift.SkipStopAfter))
+	}
+	if errors.Is(err, drift.ErrStopped) {
+		cli.Infof("Applied before stopping: %s", appliedIDs(res))
+		for _, f := range res.Failed {
+			cli.Infof("Partly applied (outside a transaction): %s", f.Err)
+		}
+		cli.Exitf(1, "Stopped by interrupt. Remaining migrations: %s", skippedIDs(res, drift.SkipStopped))
+	}
+	if errors.Is(err, drift.ErrNotConfirmed) {
+		cli.Exitf(1, "Not applying migrations.")
+	}
+	if errors.Is(err, drift.ErrRolledBack) {
+		cli.Infof("Applied: %s", appliedIDs(res))
+		if rolledBack := skippedIDs(res, drift.SkipRolledBack); rolledBack != "" {
+			cli.Infof("Rolled back: %s", rolledBack)
+		}
+		cli.Exitf(1, "run migrations: %s", err)
+	}
+	if errors.Is(err, drift.ErrMigrationsFailed) {
+		cli.Printf("%s", runSummary(cli, res))
+		cli.Exitf(1, "run migrations: %s", err)
+	}
+	if err != nil {
+		cli.Exitf(1, "run migrations: %s", err)
+	}
+	if remaining := skippedIDs(res, drift.SkipSteps); remaining != "" {
+		cli.Infof("Remaining migrations: %s", cli.errColor(colorYellow, remaining))
+	}
+	if path := viper.GetString("dump-schema"); path != "" {
+		if err := writeSchemaDump(ctx, cli, db, m, path); err != nil {
+			cli.Exitf(1, "dump schema: %s", err)
+		}
+		cli.Infof("Wrote schema dump: %s", path)
+	}
+}
+
+// dryRunOne lists the migrations that a run would apply, with the query plans
+// of their DML statements for --explain, and exits if planning fails.
+func dryRunOne(ctx context.Context, cli *CLI, db *sql.DB, m *drift.Migrator, dir string, upto *drift.MigrationID) {
+	res, err := m.Run(ctx, db, dir, upto)
+	if err != nil {
+		cli.Exitf(1, "plan migrations: %s", err)
+	}
+	for _, e := range res.Explained {
+		cli.Printf("%s-%s, statement %d (line %d):", e.ID, e.Slug, e.Index, e.Line)
+		cli.Printf("%s", indent(e.Statement))
+		if e.Err != nil {
+			cli.Printf("%s", cli.outColor(colorYellow, indent("Can't explain: "+e.Err.Error())))
+		} else {
+			cli.Printf("%s", indent(e.Plan))
+		}
+		cli.Printf("")
+	}
+}
+
+// indent indents each line of s.
+func indent(s string) string {
+	return "    " + strings.ReplaceAll(s, "\n", "\n    ")
+}
+
+var errInvalidTransactionMode = errors.New("invalid transaction mode")
+
+// parseTransactionMode parses a --transaction-mode value.
+func parseTransactionMode(s string) (drift.TransactionMode, error) {
+	for _, m := range drift.TransactionModes {
+		if string(m) == s {
+			return m, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %q (want one of %v)", errInvalidTransactionMode, s, drift.TransactionModes)
+}
+
+// exitBudgetExhausted is the exit code when --stop-after stops a run early.
+const exitBudgetExhausted = 3
+
+// runSummary renders a table of what happened to each migration in the run.
+func runSummary(cli *CLI, res *drift.Result) string {
+	type row struct {
+		id    drift.MigrationID
+		cells []string
+	}
+	var rows []row
+	for _, a := range res.Applied {
+		rows = append(rows, row{a.ID, []string{a.ID.String(), a.Slug, cli.outColor(colorGreen, "applied"), a.Duration.Round(time.Millisecond).String()}})
+	}
+	for _, f := range res.Failed {
+		rows = append(rows, row{f.ID, []string{f.ID.String(), f.Slug, cli.outColor(colorRed, "failed"), f.Err.Error()}})
+	}
+	for _, s := range res.Skipped {
+		rows = append(rows, row{s.ID, []string{s.ID.String(), s.Slug, cli.outColor(colorYellow, "skipped"), s.Reason}})
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].id < rows[j].id })
+
+	var b bytes.Buffer
+	t := tablewriter.NewWriter(&b)
+	t.SetAutoFormatHeaders(false)
+	t.SetAutoWrapText(false)
+	t.SetHeader([]string{"ID", "Slug", "Status", "Detail"})
+	for _, r := range rows {
+		t.Append(r.cells)
+	}
+	t.Render()
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// appliedIDs lists the IDs of the migrations applied during the run.
+func appliedIDs(res *drift.Result) string {
+	var ids []string
+	for _, a := range res.Applied {
+		ids = append(ids, a.ID.String())
+	}
+	if len(ids) == 0 {
+		return "(none)"
+	}
+	return strings.Join(ids, ", ")
+}
+
+// skippedIDs lists the IDs of the migrations skipped for the reason.
+func skippedIDs(res *drift.Result, reason string) string {
+	var ids []string
+	for _, s := range res.Skipped {
+		if s.Reason == reason {
+			ids = append(ids, s.ID.String())
+		}
+	}
+	return strings.Join(ids, ", ")
+}
+
+func migrationIDs(is []int64) ([]drift.MigrationID, error) {
+	ids := make([]drift.MigrationID, 0, len(is))
+	for _, i := range is {
+		id, err := drift.NewMigrationID(i)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
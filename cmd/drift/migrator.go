@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+
+	"github.com/metagram-net/drift"
+	"github.com/metagram-net/drift/internal/remote"
+)
+
+// newMigrator creates a Migrator from the configuration. The options are
+// applied after the configured ones.
+func newMigrator(cli *CLI, opts ...drift.Option) *drift.Migrator {
+	d, err := dialect()
+	if err != nil {
+		cli.Exitf(1, "%s", err)
+	}
+	base := []drift.Option{
+		drift.WithLogger(cli),
+		drift.WithDialect(d),
+		drift.WithSchema(viper.GetString("migrations-schema")),
+		drift.WithTable(viper.GetString("migrations-table")),
+		drift.WithSeedTable(viper.GetString("seeds-table")),
+		drift.WithFailuresTable(viper.GetString("failures-table")),
+		drift.WithQueryTimeout(viper.GetDuration("query-timeout")),
+		drift.WithLockTimeout(viper.GetDuration("lock-timeout")),
+		drift.WithStatementTimeout(viper.GetDuration("statement-timeout")),
+		drift.WithBlockingReport(viper.GetDuration("blocking-report")),
+	}
+	if query := viper.GetString("claim-sql"); query != "" {
+		base = append(base, drift.WithClaimSQL(query))
+	} else if s := viper.GetString("claim-strategy"); s != "" {
+		strategy, err := parseClaimStrategy(s)
+		if err != nil {
+			cli.Exitf(1, "%s", err)
+		}
+		base = append(base, drift.WithClaimStrategy(strategy))
+	}
+	if viper.GetBool("simple-protocol") {
+		base = append(base, drift.WithTransactionPooling())
+	}
+	if viper.GetBool("show-sql") {
+		base = append(base, drift.WithShowSQL())
+	}
+	if module := viper.GetString("module"); module != "" {
+		base = append(base, drift.WithModule(module))
+	}
+	if dirs := migrationsDirs(); len(dirs) > 1 {
+		base = append(base, drift.WithDirs(dirs[1:]...))
+	}
+	if vars := migrationVars(); len(vars) > 0 {
+		base = append(base, drift.WithVars(vars))
+	}
+	if cli.tracer != nil {
+		base = append(base, drift.WithTracer(cli.tracer))
+	}
+	return drift.New(append(base, opts...)...)
+}
+
+var errInvalidClaimStrategy = errors.New("invalid claim strategy")
+
+// parseClaimStrategy parses a claim-strategy setting.
+func parseClaimStrategy(s string) (drift.ClaimStrategy, error) {
+	for _, c := range drift.ClaimStrategies {
+		if string(c) == s {
+			return c, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %q (want one of %v)", errInvalidClaimStrategy, s, drift.ClaimStrategies)
+}
+
+// migrationVars returns the values for ${DRIFT_VAR_name} placeholders from the
+// [vars] config section and DRIFT_VAR_name environment variables, which take
+// precedence. The --var flag is applied on top of these.
+func migrationVars() map[string]string {
+	vars := viper.GetStringMapString("vars")
+	for _, kv := range os.Environ() {
+		k, v, _ := strings.Cut(kv, "=")
+		if name := strings.TrimPrefix(k, "DRIFT_VAR_"); name != k && name != "" {
+			vars[strings.ToLower(name)] = v
+		}
+	}
+	return vars
+}
+
+var (
+	errUnknownModule    = errors.New("no such module in the config file")
+	errIncompleteModule = errors.New("module config needs both dir and table")
+	errRemoteDir        = errors.New("the migrations directory is a remote source, which is read-only")
+)
+
+// selectModule replaces the migrations settings with the ones from the named
+// module's config section, like:
+//
+//	[modules.auth]
+//	dir = "auth/migrations"
+//	table = "auth_schema_migrations"
+func selectModule(name string) error {
+	if name == "" {
+		return nil
+	}
+	key := "modules." + name
+	if !viper.IsSet(key) {
+		return fmt.Errorf("%w: %s", errUnknownModule, name)
+	}
+	dir := viper.GetString(key + ".dir")
+	table := viper.GetString(key + ".table")
+	if dir == "" || table == "" {
+		return fmt.Errorf("%w: %s", errIncompleteModule, name)
+	}
+	viper.Set("migrations-dir", []string{dir})
+	viper.Set("migrations-table", table)
+	if schema := viper.GetString(key + ".schema"); schema != "" {
+		viper.Set("migrations-schema", schema)
+	}
+	return nil
+}
+
+// migrationsDir returns the first configured migration directory, where new
+// files are written. The others are passed to the Migrator with
+// drift.WithDirs.
+func migrationsDir() string {
+	if dirs := migrationsDirs(); len(dirs) > 0 {
+		return dirs[0]
+	}
+	return ""
+}
+
+// migrationsDirs returns the configured migration directories, which the drift
+// package merges into one ID-ordered plan. Repeated directories are only
+// listed once (cobra parses the flags twice when completing, which doubles
+// slice flags). Remote sources are replaced with their local copies.
+func migrationsDirs() []string {
+	var dirs []string
+	seen := make(map[string]bool)
+	for _, dir := range viper.GetStringSlice("migrations-dir") {
+		if !seen[dir] {
+			seen[dir] = true
+			if local, ok := remoteDirs[dir]; ok {
+				dir = local
+			}
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// remoteDirs maps the remote migration sources in migrations-dir to the local
+// copies fetchSources downloaded.
+var remoteDirs = make(map[string]string)
+
+// checkWritable refuses to write to a remote migrations directory. Files
+// written to its local copy would be replaced by the next fetch.
+func checkWritable() error {
+	if dirs := viper.GetStringSlice("migrations-dir"); len(dirs) > 0 && remote.IsRemote(dirs[0]) {
+		name := dirs[0]
+		if u, err := url.Parse(name); err == nil {
+			name = u.Redacted()
+		}
+		return fmt.Errorf("%w: %s", errRemoteDir, name)
+	}
+	return nil
+}
+
+// fetchSources downloads the remote migration sources in migrations-dir to
+// the user's cache directory, replacing any earlier copies.
+func fetchSources(ctx context.Context, cli *CLI) error {
+	for _, dir := range viper.GetStringSlice("migrations-dir") {
+		if !remote.IsRemote(dir) || remoteDirs[dir] != "" {
+			continue
+		}
+		name := dir
+		if u, err := url.Parse(dir); err == nil {
+			name = u.Redacted()
+		}
+		cache, err := os.UserCacheDir()
+		if err != nil {
+			return fmt.Errorf("fetch migrations from %s: %w", name, err)
+		}
+		sum := sha256.Sum256([]byte(dir))
+		dest := filepath.Join(cache, "drift", "sources", hex.EncodeToString(sum[:8]))
+		cli.Debugf("Fetching migrations from %s to %s", name, dest)
+		if err := remote.Fetch(ctx, dir, dest); err != nil {
+			return fmt.Errorf("fetch migrations from %s: %w", name, err)
+		}
+		remoteDirs[dir] = dest
+	}
+	return nil
+}
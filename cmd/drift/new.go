@@ -1,7 +1,14 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"io"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
 	"text/template"
 
 	"github.com/spf13/cobra"
@@ -13,8 +20,11 @@ import (
 func newCmd(cli *CLI) *cobra.Command {
 	var (
 		// Set the default ID out of range to distinguish explicit zero.
-		id   drift.MigrationID = -1
-		slug string
+		id      drift.MigrationID = -1
+		slug    string
+		connect bool
+		from    string
+		stdin   bool
 	)
 
 	cmd := &cobra.Command{
@@ -22,21 +32,68 @@ func newCmd(cli *CLI) *cobra.Command {
 		Short: "Create a new migration file",
 		Args:  cobra.NoArgs,
 		Run: func(cmd *cobra.Command, args []string) {
-			dir := viper.GetString("migrations-dir")
+			dir := migrationsDir()
 			templateFile := viper.GetString("template-file")
 
-			tmpl, err := migrationTemplate(templateFile)
+			var db *sql.DB
+			if connect {
+				var err error
+				db, err = openDB()
+				if err != nil {
+					cli.Exitf(1, "open database connection: %s", err)
+				}
+				defer db.Close()
+			}
+
+			tmpls, err := migrationTemplates(templateFile, drift.TemplateFuncs(cmd.Context(), db))
 			if err != nil {
 				cli.Exitf(1, "apply migration template: %s", err)
 			}
 
-			path, err := drift.NewFile(cli, dir, id, slug, tmpl)
-			if err != nil {
-				cli.Exitf(1, "write migration file: %s", err)
+			opts := []drift.Option{
+				drift.WithAuthor(templateAuthor()),
+				drift.WithEnvironment(viper.GetString("environment")),
+				drift.WithLayout(drift.Layout(viper.GetString("layout"))),
+			}
+			if stdin && from != "" {
+				cli.Exitf(1, "--stdin and --from can't be used together")
+			}
+			if stdin {
+				body, err := io.ReadAll(cmd.InOrStdin())
+				if err != nil {
+					cli.Exitf(1, "read migration from stdin: %s", err)
+				}
+				opts = append(opts, drift.WithBody(strings.TrimRight(string(body), "\n")))
+			}
+			m := newMigrator(cli, opts...)
+			if from != "" {
+				up, err := cloneTemplate(cmd.Context(), m, dir, from)
+				if err != nil {
+					cli.Exitf(1, "copy migration %s: %s", from, err)
+				}
+				if tmpls == nil {
+					tmpls = make(map[string]*template.Template)
+				}
+				tmpls[drift.UpTemplate] = up
+			}
+			if tmpls == nil {
+				path, err := m.NewFile(dir, id, slug, nil)
+				if err != nil {
+					cli.Exitf(1, "write migration file: %s", err)
+				}
+				cli.Infof("Created new migration file: %s", path)
+				cli.Printf("%s", path)
+				return
 			}
 
-			cli.Infof("Created new migration file: %s", path)
-			cli.Printf(path)
+			paths, err := m.NewScaffold(dir, id, slug, tmpls)
+			if err != nil {
+				cli.Exitf(1, "write migration files: %s", err)
+			}
+			for _, path := range paths {
+				cli.Infof("Created new migration file: %s", path)
+				cli.Printf("%s", path)
+			}
 		},
 	}
 	flags := cmd.Flags()
@@ -44,19 +101,126 @@ func newCmd(cli *CLI) *cobra.Command {
 	flags.StringVar(&slug, "slug", "", "Short text used to name the migration")
 	cmd.MarkFlagRequired("slug")
 	flags.String("template", "", "Template file for the migration")
+	flags.BoolVar(&connect, "connect", false, "Connect to the database so the template can inspect the schema")
+	flags.StringVar(&from, "from", "", "Copy an existing migration (by ID, slug, or path) instead of using the template")
+	_ = cmd.RegisterFlagCompletionFunc("from", completeKeys(cli))
+	flags.BoolVar(&stdin, "stdin", false, "Read the migration SQL from stdin (available to templates as {{.Body}}, or appended if they don't use it)")
 	viper.BindPFlag("template-file", flags.Lookup("template"))
 	return cmd
 }
 
-func migrationTemplate(path string) (*template.Template, error) {
+// cloneTemplate returns a template that copies an existing migration, with
+// its slug replaced by the new migration's wherever it appears as a whole word
+// (like in a partition table name), and its ID replaced where it refers to the
+// migration (see replaceID).
+func cloneTemplate(ctx context.Context, m *drift.Migrator, dir, from string) (*template.Template, error) {
+	info, err := m.Show(ctx, nil, dir, from)
+	if err != nil {
+		return nil, err
+	}
+	clone := func(d drift.TemplateData) string {
+		content := replaceWord(info.Content, info.Slug, d.Slug)
+		return replaceID(content, info.ID.String(), d.ID.String())
+	}
+	return template.New("migration").Funcs(template.FuncMap{"clone": clone}).Parse("{{clone .}}")
+}
+
+// reIDCall finds calls to Drift's functions (or a module's suffixed versions of
+// them) that refer to a migration by a literal ID.
+var reIDCall = regexp.MustCompile(`(_drift_(?:claim|require)_migration(?:_\w+)?\s*\(\s*)(\d+)\b`)
+
+// reIdentifier finds words that could be identifiers. Plain numbers are left
+// to the caller to skip.
+var reIdentifier = regexp.MustCompile(`[\w$]+`)
+
+// replaceID replaces the old migration ID in calls to Drift's functions and in
+// identifiers that carry it as an underscore-separated part, like
+// events_1234 or part_1234_old. Other numbers that happen to equal the ID (like
+// the scale in numeric(10,2)) are left alone.
+func replaceID(s, old, repl string) string {
+	s = reIDCall.ReplaceAllStringFunc(s, func(call string) string {
+		sm := reIDCall.FindStringSubmatch(call)
+		if sm[2] != old {
+			return call
+		}
+		return sm[1] + repl
+	})
+	return reIdentifier.ReplaceAllStringFunc(s, func(word string) string {
+		if !strings.Contains(word, "_") {
+			return word
+		}
+		parts := strings.Split(word, "_")
+		for i, p := range parts {
+			if p == old {
+				parts[i] = repl
+			}
+		}
+		return strings.Join(parts, "_")
+	})
+}
+
+func replaceWord(s, old, repl string) string {
+	if old == "" {
+		return s
+	}
+	re := regexp.MustCompile(`\b` + regexp.QuoteMeta(old) + `\b`)
+	return re.ReplaceAllLiteralString(s, repl)
+}
+
+// templateAuthor returns the author for migration templates: the author
+// setting if there is one, then the git user name, then the OS user name.
+func templateAuthor() string {
+	if author := viper.GetString("author"); author != "" {
+		return author
+	}
+	if out, err := exec.Command("git", "config", "user.name").Output(); err == nil {
+		if name := strings.TrimSpace(string(out)); name != "" {
+			return name
+		}
+	}
+	return os.Getenv("USER")
+}
+
+// migrationTemplates loads the template file, or every *.tmpl file if the path
+// is a directory. The templates are keyed by the name of the file they create,
+// so a directory needs an up.sql.tmpl for the migration itself.
+func migrationTemplates(path string, funcs template.FuncMap) (map[string]*template.Template, error) {
 	if path == "" {
 		// Drift uses a sensible default template in case of nil.
 		return nil, nil
 	}
 
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		tmpl, err := migrationTemplate(path, funcs)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]*template.Template{drift.UpTemplate: tmpl}, nil
+	}
+
+	paths, err := filepath.Glob(filepath.Join(path, "*.tmpl"))
+	if err != nil {
+		return nil, err
+	}
+	tmpls := make(map[string]*template.Template)
+	for _, p := range paths {
+		tmpl, err := migrationTemplate(p, funcs)
+		if err != nil {
+			return nil, err
+		}
+		tmpls[strings.TrimSuffix(filepath.Base(p), ".tmpl")] = tmpl
+	}
+	return tmpls, nil
+}
+
+func migrationTemplate(path string, funcs template.FuncMap) (*template.Template, error) {
 	b, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
-	return template.New("migration").Parse(string(b))
+	return template.New("migration").Funcs(funcs).Parse(string(b))
 }
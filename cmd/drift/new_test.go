@@ -0,0 +1,71 @@
+package main
+
+import "testing"
+
+func TestReplaceWord(t *testing.T) {
+	tests := map[string]struct {
+		in, old, want string
+	}{
+		"whole words": {
+			in:   "create table orders (like events);\ncomment on table orders is 'events';",
+			old:  "events",
+			want: "create table orders (like invoices);\ncomment on table orders is 'invoices';",
+		},
+		"parts of words": {
+			in:   "create table events_archive (like all_events);",
+			old:  "events",
+			want: "create table events_archive (like all_events);",
+		},
+		"literal": {
+			in:   "select 'a.b', 'axb';",
+			old:  "a.b",
+			want: "select 'invoices', 'axb';",
+		},
+		"empty": {
+			in:   "select 1;",
+			old:  "",
+			want: "select 1;",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := replaceWord(tt.in, tt.old, "invoices"); got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplaceID(t *testing.T) {
+	tests := map[string]struct {
+		in, want string
+	}{
+		"claim call": {
+			in:   "select _drift_claim_migration(2, 'events');",
+			want: "select _drift_claim_migration(7, 'events');",
+		},
+		"module require call": {
+			in:   "select _drift_require_migration_auth( 2 );",
+			want: "select _drift_require_migration_auth( 7 );",
+		},
+		"identifier": {
+			in:   "create table events_2 (like events);\nalter index part_2_old rename to p2;",
+			want: "create table events_7 (like events);\nalter index part_7_old rename to p2;",
+		},
+		"numeric literals": {
+			in:   "alter table t add column n numeric(10,2) default 2;\nselect * from t limit 2;\ninsert into t values (2);",
+			want: "alter table t add column n numeric(10,2) default 2;\nselect * from t limit 2;\ninsert into t values (2);",
+		},
+		"other IDs": {
+			in:   "select _drift_require_migration(22);\ncreate table events_22 ();",
+			want: "select _drift_require_migration(22);\ncreate table events_22 ();",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := replaceID(tt.in, "2", "7"); got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
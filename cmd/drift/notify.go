@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/metagram-net/drift"
+)
+
+var errNotifyFailed = errors.New("webhook rejected the notification")
+
+// notification is the JSON payload posted to the webhook. Text makes it a
+// valid Slack incoming webhook message; the other fields are for everyone else.
+type notification struct {
+	Text        string              `json:"text"`
+	Status      string              `json:"status"`
+	Environment string              `json:"environment,omitempty"`
+	Module      string              `json:"module,omitempty"`
+	Applied     []notifiedMigration `json:"applied"`
+	Failed      []notifiedMigration `json:"failed,omitempty"`
+	Version     *drift.MigrationID  `json:"version,omitempty"`
+	Error       string              `json:"error,omitempty"`
+}
+
+type notifiedMigration struct {
+	ID         drift.MigrationID `json:"id"`
+	Slug       string            `json:"slug"`
+	DurationMS int64             `json:"duration_ms,omitempty"`
+	Error      string            `json:"error,omitempty"`
+}
+
+// notify posts the outcome of a migrate run to the configured webhook, if
+// there is one. Runs that neither applied anything nor failed aren't worth a
+// message. Notifications are best-effort, so errors are only logged.
+func notify(cli *CLI, res *drift.Result, runErr error) {
+	webhook := viper.GetString("notify.webhook")
+	if webhook == "" {
+		return
+	}
+	if runErr == nil && (res == nil || len(res.Applied) == 0) {
+		return
+	}
+	if err := postNotification(webhook, newNotification(res, runErr)); err != nil {
+		cli.Infof("Send notification: %s", err)
+	}
+}
+
+func newNotification(res *drift.Result, runErr error) notification {
+	n := notification{
+		Status:      "success",
+		Environment: viper.GetString("environment"),
+		Module:      viper.GetString("module"),
+		Applied:     []notifiedMigration{},
+	}
+	if res != nil {
+		for _, a := range res.Applied {
+			n.Applied = append(n.Applied, notifiedMigration{ID: a.ID, Slug: a.Slug, DurationMS: a.Duration.Milliseconds()})
+		}
+		for _, f := range res.Failed {
+			n.Failed = append(n.Failed, notifiedMigration{ID: f.ID, Slug: f.Slug, Error: f.Err.Error()})
+		}
+		if res.Version >= 0 {
+			v := res.Version
+			n.Version = &v
+		}
+	}
+	if runErr != nil {
+		n.Status = "failure"
+		n.Error = runErr.Error()
+	}
+	n.Text = n.summary()
+	return n
+}
+
+// summary is the human-readable message, like:
+//
+//	Drift applied 2 migrations to prod: 12-add_users (1.2s), 13-add_orgs (80ms)
+func (n notification) summary() string {
+	var b strings.Builder
+	where := ""
+	if n.Environment != "" {
+		where = " to " + n.Environment
+	}
+	if n.Module != "" {
+		where += " (module " + n.Module + ")"
+	}
+
+	var applied []string
+	for _, a := range n.Applied {
+		d := time.Duration(a.DurationMS) * time.Millisecond
+		applied = append(applied, fmt.Sprintf("%s-%s (%s)", a.ID.String(), a.Slug, d))
+	}
+	if n.Status == "failure" {
+		fmt.Fprintf(&b, "Drift failed to migrate%s: %s", where, n.Error)
+		if len(applied) > 0 {
+			fmt.Fprintf(&b, "\nApplied before the failure: %s", strings.Join(applied, ", "))
+		}
+		return b.String()
+	}
+	fmt.Fprintf(&b, "Drift applied %d migration(s)%s: %s", len(applied), where, strings.Join(applied, ", "))
+	return b.String()
+}
+
+func postNotification(webhook string, n notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%w: %s", errNotifyFailed, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/metagram-net/drift"
+)
+
+var errExportFailed = errors.New("trace export failed")
+
+// otlpTracer is a small OTLP/HTTP (JSON) trace exporter configured with the
+// standard OTEL_* environment variables. Spans are buffered in memory and sent
+// in one request when the command finishes. A nil *otlpTracer sends nothing.
+type otlpTracer struct {
+	cli      *CLI
+	endpoint string
+	headers  map[string]string
+	service  string
+	traceID  string
+	parentID string
+
+	mu    sync.Mutex
+	spans []otlpSpan
+	once  sync.Once
+}
+
+// startTracing returns a tracer if an OTLP endpoint is configured.
+func startTracing(cli *CLI) *otlpTracer {
+	if os.Getenv("OTEL_TRACES_EXPORTER") == "none" || os.Getenv("OTEL_SDK_DISABLED") == "true" {
+		return nil
+	}
+	protocol := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL")
+	if protocol == "" {
+		protocol = os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL")
+	}
+	endpoint := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	if endpoint == "" {
+		base := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+		if base == "" {
+			return nil
+		}
+		endpoint = strings.TrimSuffix(base, "/") + "/v1/traces"
+	}
+	if protocol != "" && protocol != "http/json" {
+		cli.Warnf("Not sending traces: OTLP protocol %q isn't supported (only http/json is)", protocol)
+		return nil
+	}
+	service := os.Getenv("OTEL_SERVICE_NAME")
+	if service == "" {
+		service = "drift"
+	}
+	t := &otlpTracer{
+		cli:      cli,
+		endpoint: endpoint,
+		headers:  otelHeaders(os.Getenv("OTEL_EXPORTER_OTLP_TRACES_HEADERS") + "," + os.Getenv("OTEL_EXPORTER_OTLP_HEADERS")),
+		service:  service,
+	}
+	// Join the caller's trace (e.g. a deploy pipeline) if there is one.
+	if tid, pid, ok := parseTraceparent(os.Getenv("TRACEPARENT")); ok {
+		t.traceID, t.parentID = tid, pid
+	} else {
+		t.traceID = randomID(16)
+	}
+	return t
+}
+
+// otelHeaders parses the key=value,key=value header list format.
+func otelHeaders(s string) map[string]string {
+	h := make(map[string]string)
+	for _, kv := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok {
+			continue
+		}
+		k, _ = url.QueryUnescape(strings.TrimSpace(k))
+		v, _ = url.QueryUnescape(strings.TrimSpace(v))
+		if k != "" {
+			h[k] = v
+		}
+	}
+	return h
+}
+
+// parseTraceparent parses a W3C traceparent: 00-<trace-id>-<span-id>-<flags>.
+func parseTraceparent(s string) (traceID, spanID string, ok bool) {
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return "", "", false
+	}
+	return parts[1], parts[2], true
+}
+
+func randomID(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+type spanKey struct{}
+
+func (t *otlpTracer) Start(ctx context.Context, name string, attrs ...slog.Attr) (context.Context, drift.Span) {
+	parent := t.parentID
+	if p, ok := ctx.Value(spanKey{}).(*otlpSpanRecorder); ok {
+		parent = p.span.SpanID
+	}
+	s := &otlpSpanRecorder{
+		tracer: t,
+		span: otlpSpan{
+			TraceID:      t.traceID,
+			SpanID:       randomID(8),
+			ParentSpanID: parent,
+			Name:         name,
+			Kind:         1, // SPAN_KIND_INTERNAL
+			StartTime:    unixNano(time.Now()),
+		},
+	}
+	s.SetAttributes(attrs...)
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// flush sends the finished spans. Only the first call has any effect. Tracing
+// is best-effort, so errors are only logged at debug level.
+func (t *otlpTracer) flush() {
+	if t == nil {
+		return
+	}
+	t.once.Do(func() {
+		t.mu.Lock()
+		spans := t.spans
+		t.mu.Unlock()
+		if len(spans) == 0 {
+			return
+		}
+		if err := t.send(spans); err != nil {
+			t.cli.Debugf("Export traces: %s", err)
+		}
+	})
+}
+
+func (t *otlpTracer) send(spans []otlpSpan) error {
+	body, err := json.Marshal(otlpRequest{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource: otlpResource{Attributes: []otlpAttr{
+				otlpAttribute(slog.String("service.name", t.service)),
+			}},
+			ScopeSpans: []otlpScopeSpans{{
+				Scope: otlpScope{Name: "github.com/metagram-net/drift"},
+				Spans: spans,
+			}},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		return fmt.Errorf("%w: %s", errExportFailed, res.Status)
+	}
+	return nil
+}
+
+// otlpSpanRecorder implements drift.Span.
+type otlpSpanRecorder struct {
+	tracer *otlpTracer
+	mu     sync.Mutex
+	span   otlpSpan
+}
+
+func (s *otlpSpanRecorder) SetAttributes(attrs ...slog.Attr) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, a := range attrs {
+		s.span.Attributes = append(s.span.Attributes, otlpAttribute(a))
+	}
+}
+
+func (s *otlpSpanRecorder) RecordError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.span.Status = &otlpStatus{Code: 2, Message: err.Error()} // STATUS_CODE_ERROR
+	s.span.Events = append(s.span.Events, otlpEvent{
+		Name:       "exception",
+		TimeUnix:   unixNano(time.Now()),
+		Attributes: []otlpAttr{otlpAttribute(slog.String("exception.message", err.Error()))},
+	})
+}
+
+func (s *otlpSpanRecorder) End() {
+	s.mu.Lock()
+	s.span.EndTime = unixNano(time.Now())
+	span := s.span
+	s.mu.Unlock()
+
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	s.tracer.spans = append(s.tracer.spans, span)
+}
+
+// The OTLP JSON encoding: https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding
+// It uses lowerCamelCase field names and encodes 64-bit integers as strings.
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"` //nolint:tagliatelle
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"` //nolint:tagliatelle
+}
+
+type otlpResource struct {
+	Attributes []otlpAttr `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID      string      `json:"traceId"`                //nolint:tagliatelle
+	SpanID       string      `json:"spanId"`                 //nolint:tagliatelle
+	ParentSpanID string      `json:"parentSpanId,omitempty"` //nolint:tagliatelle
+	Name         string      `json:"name"`
+	Kind         int         `json:"kind"`
+	StartTime    string      `json:"startTimeUnixNano"` //nolint:tagliatelle
+	EndTime      string      `json:"endTimeUnixNano"`   //nolint:tagliatelle
+	Attributes   []otlpAttr  `json:"attributes,omitempty"`
+	Events       []otlpEvent `json:"events,omitempty"`
+	Status       *otlpStatus `json:"status,omitempty"`
+}
+
+type otlpEvent struct {
+	Name       string     `json:"name"`
+	TimeUnix   string     `json:"timeUnixNano"` //nolint:tagliatelle
+	Attributes []otlpAttr `json:"attributes,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpAttr struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	String *string  `json:"stringValue,omitempty"` //nolint:tagliatelle
+	Int    *string  `json:"intValue,omitempty"`    //nolint:tagliatelle
+	Double *float64 `json:"doubleValue,omitempty"` //nolint:tagliatelle
+	Bool   *bool    `json:"boolValue,omitempty"`   //nolint:tagliatelle
+}
+
+func otlpAttribute(a slog.Attr) otlpAttr {
+	var v otlpValue
+	switch val := a.Value.Resolve(); val.Kind() {
+	case slog.KindInt64:
+		s := strconv.FormatInt(val.Int64(), 10)
+		v.Int = &s
+	case slog.KindUint64:
+		s := strconv.FormatUint(val.Uint64(), 10)
+		v.Int = &s
+	case slog.KindFloat64:
+		f := val.Float64()
+		v.Double = &f
+	case slog.KindBool:
+		b := val.Bool()
+		v.Bool = &b
+	default:
+		s := val.String()
+		v.String = &s
+	}
+	return otlpAttr{Key: a.Key, Value: v}
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
@@ -0,0 +1,43 @@
+//go:build !windows
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+var errNoTerminal = errors.New("--prompt-password needs stdin to be a terminal")
+
+// readPassword prompts on stderr and reads a line from the terminal with
+// echo turned off.
+func readPassword(prompt string) (string, error) {
+	if !isTerminal(os.Stdin) {
+		return "", errNoTerminal
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	if err := stty("-echo"); err != nil {
+		fmt.Fprintln(os.Stderr)
+		return "", fmt.Errorf("turn off terminal echo: %w", err)
+	}
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	serr := stty("echo")
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	if serr != nil {
+		return "", serr
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func stty(arg string) error {
+	cmd := exec.Command("stty", arg)
+	cmd.Stdin = os.Stdin
+	return cmd.Run()
+}
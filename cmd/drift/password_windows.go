@@ -0,0 +1,11 @@
+//go:build windows
+
+package main
+
+import "errors"
+
+var errPromptUnsupported = errors.New("--prompt-password is not supported on Windows; use database-password-file")
+
+func readPassword(string) (string, error) {
+	return "", errPromptUnsupported
+}
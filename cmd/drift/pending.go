@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+
+	"github.com/metagram-net/drift"
+)
+
+const pendingLong string = `List the migrations that haven't been applied.
+
+Use --format=ids to print one ID per line for scripts, like a deploy gate:
+
+    test "$(drift pending --format ids | wc -l)" -eq 0
+
+Use --format=json for machine-readable output.`
+
+const appliedLong string = `List the migrations that have been applied.
+
+This is a lighter version of "drift history" for scripts: use --format=ids to
+print one ID per line, or --format=json for machine-readable output.`
+
+// A listFormat is how pending and applied print their migrations.
+type listFormat string
+
+const (
+	listTable listFormat = "table"
+	listJSON  listFormat = "json"
+	listIDs   listFormat = "ids"
+)
+
+var listFormats = []listFormat{listTable, listJSON, listIDs}
+
+var errInvalidListFormat = errors.New("invalid list format")
+
+func parseListFormat(s string) (listFormat, error) {
+	for _, f := range listFormats {
+		if s == string(f) {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %q (want one of %v)", errInvalidListFormat, s, listFormats)
+}
+
+type listRow struct {
+	ID    drift.MigrationID `json:"id"`
+	Slug  string            `json:"slug"`
+	Path  string            `json:"path,omitempty"`
+	RunAt *time.Time        `json:"run_at,omitempty"`
+}
+
+func pendingCmd(cli *CLI) *cobra.Command {
+	var format string
+
+	cmd := &cobra.Command{
+		Use:   "pending",
+		Short: "List the migrations that haven't been applied",
+		Long:  pendingLong,
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, _ []string) {
+			f, err := parseListFormat(format)
+			if err != nil {
+				cli.Exitf(1, "%s", err)
+			}
+
+			db, err := openDB()
+			if err != nil {
+				cli.Exitf(1, "open database connection: %s", err)
+			}
+			defer db.Close()
+
+			ps, err := newMigrator(cli).Pending(cmd.Context(), db, migrationsDir())
+			if err != nil {
+				cli.Exitf(1, "pending: %s", err)
+			}
+			rows := make([]listRow, 0, len(ps))
+			for _, p := range ps {
+				rows = append(rows, listRow{ID: p.ID, Slug: p.Slug, Path: p.Path})
+			}
+			printList(cli, f, rows, []string{"ID", "Slug", "Path"}, func(r listRow) []string {
+				return []string{strconv.FormatInt(int64(r.ID), 10), r.Slug, r.Path}
+			})
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&format, "format", string(listTable), "Output format (table, json, or ids)")
+	return cmd
+}
+
+func appliedCmd(cli *CLI) *cobra.Command {
+	var format string
+
+	cmd := &cobra.Command{
+		Use:   "applied",
+		Short: "List the migrations that have been applied",
+		Long:  appliedLong,
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, _ []string) {
+			f, err := parseListFormat(format)
+			if err != nil {
+				cli.Exitf(1, "%s", err)
+			}
+
+			db, err := openDB()
+			if err != nil {
+				cli.Exitf(1, "open database connection: %s", err)
+			}
+			defer db.Close()
+
+			hs, err := newMigrator(cli).History(cmd.Context(), db)
+			if err != nil {
+				cli.Exitf(1, "applied: %s", err)
+			}
+			rows := make([]listRow, 0, len(hs))
+			for _, h := range hs {
+				runAt := h.RunAt
+				rows = append(rows, listRow{ID: h.ID, Slug: h.Slug, RunAt: &runAt})
+			}
+			printList(cli, f, rows, []string{"ID", "Slug", "Applied at"}, func(r listRow) []string {
+				return []string{strconv.FormatInt(int64(r.ID), 10), r.Slug, r.RunAt.Format(time.RFC3339)}
+			})
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&format, "format", string(listTable), "Output format (table, json, or ids)")
+	return cmd
+}
+
+// printList prints the rows in the format, using the header and cells for
+// tables.
+func printList(cli *CLI, format listFormat, rows []listRow, header []string, cells func(listRow) []string) {
+	switch format {
+	case listIDs:
+		for _, r := range rows {
+			cli.Printf("%d", r.ID)
+		}
+	case listJSON:
+		b, err := json.MarshalIndent(rows, "", "  ")
+		if err != nil {
+			cli.Exitf(1, "encode migrations: %s", err)
+		}
+		cli.Printf("%s", b)
+	default:
+		var b bytes.Buffer
+		t := tablewriter.NewWriter(&b)
+		t.SetAutoFormatHeaders(false)
+		t.SetAutoWrapText(false)
+		t.SetHeader(header)
+		for _, r := range rows {
+			t.Append(cells(r))
+		}
+		t.Render()
+		cli.Printf("%s", b.String())
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/metagram-net/drift"
+)
+
+const pingLong string = `Check the database connection and Drift's setup.
+
+This connects with the configured credentials, checks that the migrations table
+and the _drift_claim_migration function exist, and prints the server version
+and the current migration version.
+
+Exits with a non-zero status if the database isn't reachable or Drift isn't set
+up, so it works as a readiness or startup probe.`
+
+func pingCmd(cli *CLI) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "ping",
+		Short: "Check the database connection and Drift's setup",
+		Long:  pingLong,
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, _ []string) {
+			db, err := openDB()
+			if err != nil {
+				cli.Exitf(1, "open database connection: %s", err)
+			}
+			defer db.Close()
+
+			h, err := newMigrator(cli).Ping(cmd.Context(), db)
+			if err != nil {
+				cli.Exitf(1, "ping: %s", err)
+			}
+
+			cli.Printf("Server version:    %s", h.ServerVersion)
+			cli.Printf("Migrations table:  %s", found(h.Table))
+			if h.ClaimStrategy == drift.ClaimFunction {
+				cli.Printf("Claim function:    %s", found(h.ClaimFunction))
+			} else {
+				cli.Printf("Claim function:    not used (claim-strategy %q)", h.ClaimStrategy)
+			}
+			if h.Version >= 0 {
+				cli.Printf("Migration version: %d", h.Version)
+			} else {
+				cli.Printf("Migration version: (none)")
+			}
+			if !h.Ready() {
+				cli.Exitf(1, "Drift is not set up in this database. Run the init migration first.")
+			}
+		},
+	}
+	return cmd
+}
+
+func found(ok bool) string {
+	if ok {
+		return "found"
+	}
+	return "missing"
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/metagram-net/drift"
+)
+
+const planLong string = `Draft a migration from a declarative schema file.
+
+The schema file is the SQL that creates the whole desired schema from nothing,
+like a structure dump. Drift loads it into a scratch database on the same
+server (created and dropped for the purpose, so the database user needs the
+CREATEDB privilege), compares it with the configured database, and writes a
+new migration with the ALTER statements that would make the database match.
+
+The migration is only a draft: renames show up as a drop and an add, and type
+changes may need a better USING clause. Review and edit it before applying it.`
+
+func planCmd(cli *CLI) *cobra.Command {
+	var (
+		// Set the default ID out of range to distinguish explicit zero.
+		id         drift.MigrationID = -1
+		slug       string
+		schemaFile string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "plan --schema <file> --slug <slug>",
+		Short: "Draft a migration from a declarative schema file",
+		Long:  planLong,
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, _ []string) {
+			ctx := cmd.Context()
+			dir := migrationsDir()
+
+			schemaSQL, err := os.ReadFile(schemaFile) //#nosec G304 // The user chose the path.
+			if err != nil {
+				cli.Exitf(1, "read schema file: %s", err)
+			}
+
+			cs, err := planSchema(ctx, cli, string(schemaSQL))
+			if err != nil {
+				cli.Exitf(1, "plan schema changes: %s", err)
+			}
+			if len(cs) == 0 {
+				cli.Infof("The database already matches %s.", schemaFile)
+				return
+			}
+
+			body := fmt.Sprintf("-- Drafted by drift plan from %s. Review before applying!\n\n%s",
+				schemaFile, strings.TrimSuffix(drift.SchemaChangesSQL(cs), "\n\n"))
+			m := newMigrator(cli,
+				drift.WithAuthor(templateAuthor()),
+				drift.WithEnvironment(viper.GetString("environment")),
+				drift.WithLayout(drift.Layout(viper.GetString("layout"))),
+				drift.WithBody(body),
+			)
+			path, err := m.NewFile(dir, id, slug, nil)
+			if err != nil {
+				cli.Exitf(1, "write migration file: %s", err)
+			}
+			cli.Infof("Created new migration file with %d changes: %s", len(cs), path)
+			cli.Printf("%s", path)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&schemaFile, "schema", "", "SQL file that creates the desired schema")
+	cmd.MarkFlagRequired("schema")
+	flags.Var(&id, "id", "Migration ID override (default: Unix timestamp in seconds)")
+	flags.StringVar(&slug, "slug", "", "Short text used to name the migration")
+	cmd.MarkFlagRequired("slug")
+	return cmd
+}
+
+// planSchema compares the configured database with the schema SQL, loaded
+// into a scratch database that's dropped afterward.
+func planSchema(ctx context.Context, cli *CLI, schemaSQL string) ([]drift.SchemaChange, error) {
+	db, err := openDB()
+	if err != nil {
+		return nil, fmt.Errorf("open database connection: %w", err)
+	}
+	defer db.Close()
+
+	name := fmt.Sprintf("drift_plan_%d", time.Now().UnixNano())
+	if err := drift.CreateDatabase(ctx, db, name); err != nil {
+		return nil, fmt.Errorf("create scratch database: %w", err)
+	}
+	cli.Debugf("Created scratch database: %s", name)
+	defer func() {
+		// Clean up even after an interrupt.
+		if err := drift.DropDatabase(context.WithoutCancel(ctx), db, name); err != nil {
+			cli.Infof("Could not drop scratch database %s: %s", name, err)
+		}
+	}()
+
+	scratch, err := openNamedDB(name)
+	if err != nil {
+		return nil, fmt.Errorf("open scratch database connection: %w", err)
+	}
+	// Postgres won't drop the scratch database while it has connections.
+	defer scratch.Close()
+
+	return newMigrator(cli).PlanSchema(ctx, db, scratch, schemaSQL)
+}
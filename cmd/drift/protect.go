@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/metagram-net/drift"
+)
+
+var (
+	errDestructive    = errors.New("refusing to apply destructive migrations in a protected environment without --allow-destructive")
+	errNotInteractive = errors.New("refusing to apply migrations in a protected environment without --yes when stdin is not a terminal")
+)
+
+// confirmProtected returns the confirmation step for protected environments.
+// It prints the plan, refuses destructive migrations unless they're allowed,
+// and then asks for confirmation unless yes is set.
+func confirmProtected(cli *CLI, yes, allowDestructive bool) func([]drift.PlannedMigration) (bool, error) {
+	return func(plan []drift.PlannedMigration) (bool, error) {
+		cli.Infof("This is a protected environment. Migrations to apply:")
+		var destructive bool
+		for _, p := range plan {
+			if len(p.Destructive) == 0 {
+				cli.Infof("  %s", p.Path)
+				continue
+			}
+			destructive = true
+			cli.Infof("  %s (destructive: %s)", p.Path, strings.Join(p.Destructive, ", "))
+		}
+		if destructive && !allowDestructive {
+			return false, errDestructive
+		}
+		if yes {
+			return true, nil
+		}
+		if !isTerminal(os.Stdin) {
+			return false, errNotInteractive
+		}
+		return askYesNo(cli, "Apply these migrations?")
+	}
+}
+
+// askYesNo asks the question on the terminal and reports whether the answer
+// was yes. Anything else, including no answer, is no.
+func askYesNo(cli *CLI, question string) (bool, error) {
+	fmt.Fprintf(cli.stderr, "%s [y/N] ", question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false, err
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+// isTerminal reports whether f is an interactive terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/metagram-net/drift"
+)
+
+var errPruneNotInteractive = errors.New("refusing to prune without --yes when stdin is not a terminal")
+
+const pruneLong string = `Remove records of applied migrations that have no file.
+
+After squashing migrations, or moving some to another module, the migrations
+table still has records for files that no longer exist. This lists them, and
+with --write, deletes them after asking for confirmation (or with --yes).
+
+This only removes the records. It doesn't undo any changes the migrations
+made.`
+
+func pruneCmd(cli *CLI) *cobra.Command {
+	var (
+		write bool
+		yes   bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Remove records of applied migrations that have no file",
+		Long:  pruneLong,
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, _ []string) {
+			db, err := openDB()
+			if err != nil {
+				cli.Exitf(1, "open database connection: %s", err)
+			}
+			defer db.Close()
+
+			m := newMigrator(cli)
+			stale, err := m.StaleRecords(cmd.Context(), db, migrationsDir())
+			if err != nil {
+				cli.Exitf(1, "find stale records: %s", err)
+			}
+			if len(stale) == 0 {
+				cli.Infof("Every applied migration has a file. Nothing to prune.")
+				return
+			}
+
+			cli.Infof("Applied migrations with no file:")
+			ids := make([]drift.MigrationID, 0, len(stale))
+			for _, h := range stale {
+				cli.Infof("  %s-%s (applied %s)", h.ID.String(), h.Slug, h.RunAt.Format("2006-01-02 15:04:05"))
+				ids = append(ids, h.ID)
+			}
+			if !write {
+				cli.Infof("Not deleting the records because --write is off")
+				return
+			}
+			ok, err := confirmPrune(cli, yes, len(ids))
+			if err != nil {
+				cli.Exitf(1, "%s", err)
+			}
+			if !ok {
+				cli.Exitf(1, "Not pruning.")
+			}
+			if err := m.Prune(cmd.Context(), db, ids); err != nil {
+				cli.Exitf(1, "prune: %s", err)
+			}
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.BoolVarP(&write, "write", "w", false, "Delete the records instead of just listing them")
+	flags.BoolVarP(&yes, "yes", "y", false, "Delete without asking for confirmation")
+	return cmd
+}
+
+// confirmPrune asks whether to delete the n records, unless yes is set.
+func confirmPrune(cli *CLI, yes bool, n int) (bool, error) {
+	if yes {
+		return true, nil
+	}
+	if !isTerminal(os.Stdin) {
+		return false, errPruneNotInteractive
+	}
+	return askYesNo(cli, fmt.Sprintf("Delete these %d records?", n))
+}
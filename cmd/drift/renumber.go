@@ -1,9 +1,11 @@
 package main
 
 import (
+	"database/sql"
+	"slices"
+
 	_ "github.com/jackc/pgx/v4/stdlib" // database/sql driver: pgx
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 
 	"github.com/metagram-net/drift"
 )
@@ -15,10 +17,29 @@ sorting of the IDs. This happens by adding or removing prefix zeroes on the IDs
 to make all the IDs the shortest width that fits them all.
 
 Other commands ignore zero prefixes when interpreting IDs as integers. This
-renumbering is never necessary for correctness.`
+renumbering is never necessary for correctness.
+
+With --sequential, this instead rewrites the IDs to 1, 2, 3, ... in file order
+(keeping ID 0 for the init migration), for switching from timestamp IDs to
+sequential ones. Literal IDs passed to _drift_claim_migration and
+_drift_require_migration are updated too. The migrations table still has the
+old IDs, so pass --update-db to update the configured database in the same
+step, or --all-targets to update the database of every config target that uses
+this migrations directory.
+
+Files are renamed in two phases, through temporary names, and a journal file
+(.drift-renumber.json) records the progress. If a renumber is interrupted,
+finish it with --resume or undo it with --rollback.`
 
 func renumberCmd(cli *CLI) *cobra.Command {
-	var write bool
+	var (
+		write      bool
+		sequential bool
+		updateDB   bool
+		allTargets bool
+		resume     bool
+		rollback   bool
+	)
 
 	cmd := &cobra.Command{
 		Use:   "renumber",
@@ -26,8 +47,44 @@ func renumberCmd(cli *CLI) *cobra.Command {
 		Long:  renumberLong,
 		Args:  cobra.NoArgs,
 		Run: func(cmd *cobra.Command, _ []string) {
-			dir := viper.GetString("migrations-dir")
-			err := drift.Renumber(cli, dir, write)
+			dir := migrationsDir()
+			switch {
+			case resume && rollback:
+				cli.Exitf(1, "--resume and --rollback can't be used together")
+			case resume:
+				if err := newMigrator(cli).RenumberResume(dir); err != nil {
+					cli.Exitf(1, "resume renumber: %s", err)
+				}
+				return
+			case rollback:
+				if err := newMigrator(cli).RenumberRollback(dir); err != nil {
+					cli.Exitf(1, "roll back renumber: %s", err)
+				}
+				return
+			}
+
+			var opts []drift.Option
+			if sequential {
+				opts = append(opts, drift.WithSequentialIDs())
+			}
+			m := newMigrator(cli, opts...)
+			var dbs []*sql.DB
+			switch {
+			case allTargets:
+				dbs = targetDBs(cli, migrationsDirs())
+			case updateDB:
+				db, err := openDB()
+				if err != nil {
+					cli.Exitf(1, "open database connection: %s", err)
+				}
+				dbs = append(dbs, db)
+			}
+			defer func() {
+				for _, db := range dbs {
+					db.Close()
+				}
+			}()
+			err := m.RenumberDB(cmd.Context(), dir, write, dbs...)
 			if err != nil {
 				cli.Exitf(1, "renumber: %s", err)
 			}
@@ -36,5 +93,36 @@ func renumberCmd(cli *CLI) *cobra.Command {
 
 	flags := cmd.Flags()
 	flags.BoolVarP(&write, "write", "w", false, "Execute renames instead of just printing them")
+	flags.BoolVar(&sequential, "sequential", false, "Rewrite IDs to 1, 2, 3, ... in file order")
+	flags.BoolVar(&updateDB, "update-db", false, "Also update the IDs in the configured database's migrations table")
+	flags.BoolVar(&allTargets, "all-targets", false, "Also update the IDs in the database of every config target with the same migrations directory")
+	flags.BoolVar(&resume, "resume", false, "Finish an interrupted renumber")
+	flags.BoolVar(&rollback, "rollback", false, "Undo the file changes of an interrupted renumber")
 	return cmd
 }
+
+// targetDBs opens the database of every config target that uses the
+// migrations directories.
+func targetDBs(cli *CLI, dirs []string) []*sql.DB {
+	names, err := targetNames()
+	if err != nil {
+		cli.Exitf(1, "%s", err)
+	}
+	var dbs []*sql.DB
+	for _, name := range names {
+		if err := selectTarget(name); err != nil {
+			cli.Exitf(1, "select target %s: %s", name, err)
+		}
+		if !slices.Equal(migrationsDirs(), dirs) {
+			cli.Infof("Skipping target %s, which uses other migrations", name)
+			continue
+		}
+		db, err := openDB()
+		if err != nil {
+			cli.Exitf(1, "open database connection for target %s: %s", name, err)
+		}
+		cli.Infof("Database %d: target %s", len(dbs)+1, name)
+		dbs = append(dbs, db)
+	}
+	return dbs
+}
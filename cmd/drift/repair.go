@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/spf13/cobra"
+
+	"github.com/metagram-net/drift"
+)
+
+const repairLong string = `Repair problems left behind by failed migrations.
+
+Choose which repairs to make with flags:
+
+--drop-invalid-indexes
+  A failed CREATE INDEX CONCURRENTLY (in a no-transaction migration) leaves
+  behind an invalid index. Since the invalid index still holds the name,
+  re-running the migration fails. This drops the invalid indexes in the
+  migrations schema so the migration can be retried. Indexes that are still
+  being built are left alone. Add --migration to only drop the indexes that
+  one migration creates, in whichever schemas it creates them. This needs
+  Postgres 12 or newer.`
+
+var errNoRepairs = errors.New("no repairs chosen (see --help for the options)")
+
+func repairCmd(cli *CLI) *cobra.Command {
+	var (
+		dropInvalidIndexes bool
+		migrationID        = drift.MigrationID(-1)
+	)
+
+	cmd := &cobra.Command{
+		Use:   "repair",
+		Short: "Repair problems left behind by failed migrations",
+		Long:  repairLong,
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, _ []string) {
+			ctx := cmd.Context()
+
+			if !dropInvalidIndexes {
+				cli.Exitf(1, "repair: %s", errNoRepairs)
+			}
+
+			db, err := openDB()
+			if err != nil {
+				cli.Exitf(1, "open database connection: %s", err)
+			}
+			defer db.Close()
+
+			if dropInvalidIndexes {
+				m := newMigrator(cli)
+				if migrationID >= 0 {
+					_, err = m.DropFailedIndexes(ctx, db, migrationsDir(), migrationID)
+				} else {
+					_, err = m.DropInvalidIndexes(ctx, db)
+				}
+				if err != nil {
+					cli.Exitf(1, "drop invalid indexes: %s", err)
+				}
+			}
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.BoolVar(&dropInvalidIndexes, "drop-invalid-indexes", false, "Drop invalid indexes left by failed concurrent builds")
+	flags.Var(&migrationID, "migration", "With --drop-invalid-indexes, only drop the indexes this migration creates")
+	_ = cmd.RegisterFlagCompletionFunc("migration", completeIDs(cli))
+	return cmd
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/metagram-net/drift"
+)
+
+var (
+	errResetProtected      = errors.New("refusing to reset the database in a protected environment")
+	errResetNotInteractive = errors.New("refusing to reset the database without --force when stdin is not a terminal")
+)
+
+const resetLong string = `Start over with a clean development database.
+
+This drops and recreates the database named in the database URL (connecting to
+the maintenance database to do it), then applies every migration. With
+--in-place, it drops and recreates the migrations schema instead, for when the
+database user can't create databases. Objects the migrations created in other
+schemas are left alone in that case.
+
+With --seed, it also loads the seed files afterward, as with drift seed.
+
+It asks for confirmation unless --force is set, and refuses to run in a
+protected environment.`
+
+func resetCmd(cli *CLI) *cobra.Command {
+	var (
+		inPlace bool
+		seed    bool
+		force   bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "reset",
+		Short: "Recreate the database and apply every migration",
+		Long:  resetLong,
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, _ []string) {
+			ctx := cmd.Context()
+			if viper.GetBool("protected") {
+				cli.Exitf(1, "reset: %s", errResetProtected)
+			}
+			name, err := databaseName()
+			if err != nil {
+				cli.Exitf(1, "reset: %s", err)
+			}
+			schema := viper.GetString("migrations-schema")
+
+			if !force {
+				if !isTerminal(os.Stdin) {
+					cli.Exitf(1, "reset: %s", errResetNotInteractive)
+				}
+				question := fmt.Sprintf("Drop database %s and everything in it?", name)
+				if inPlace {
+					question = fmt.Sprintf("Drop schema %s in database %s and everything in it?", schema, name)
+				}
+				ok, err := askYesNo(cli, question)
+				if err != nil {
+					cli.Exitf(1, "%s", err)
+				}
+				if !ok {
+					cli.Exitf(1, "Not resetting the database.")
+				}
+			}
+
+			if inPlace {
+				db, err := openDB()
+				if err != nil {
+					cli.Exitf(1, "open database connection: %s", err)
+				}
+				if err := drift.RecreateSchema(ctx, db, schema); err != nil {
+					cli.Exitf(1, "recreate schema: %s", err)
+				}
+				db.Close()
+				cli.Infof("Recreated schema: %s", schema)
+			} else {
+				admin, err := openNamedDB(viper.GetString("maintenance-database"))
+				if err != nil {
+					cli.Exitf(1, "open maintenance database connection: %s", err)
+				}
+				if err := drift.DropDatabase(ctx, admin, name); err != nil {
+					cli.Exitf(1, "drop database: %s", err)
+				}
+				if err := drift.CreateDatabase(ctx, admin, name); err != nil {
+					cli.Exitf(1, "create database: %s", err)
+				}
+				admin.Close()
+				cli.Infof("Recreated database: %s", name)
+			}
+
+			db, err := openDB()
+			if err != nil {
+				cli.Exitf(1, "open database connection: %s", err)
+			}
+			defer db.Close()
+			migrateOne(ctx, cli, db, newMigrator(cli), migrationsDir(), nil)
+			if seed {
+				if err := runSeeds(ctx, cli, db); err != nil {
+					cli.Exitf(1, "seed: %s", err)
+				}
+			}
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.BoolVar(&inPlace, "in-place", false, "Recreate the migrations schema instead of the whole database")
+	flags.BoolVar(&seed, "seed", false, "Load the seed files after migrating")
+	flags.BoolVarP(&force, "force", "f", false, "Reset without asking for confirmation")
+	return cmd
+}
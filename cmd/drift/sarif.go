@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+
+	"github.com/metagram-net/drift"
+)
+
+// SARIF is the Static Analysis Results Interchange Format that code review
+// tools use to show findings inline. Its field names are camelCase.
+//
+//nolint:tagliatelle // SARIF defines these names.
+type (
+	sarifLog struct {
+		Version string     `json:"version"`
+		Schema  string     `json:"$schema"`
+		Runs    []sarifRun `json:"runs"`
+	}
+	sarifRun struct {
+		Tool    sarifTool     `json:"tool"`
+		Results []sarifResult `json:"results"`
+	}
+	sarifTool struct {
+		Driver sarifDriver `json:"driver"`
+	}
+	sarifDriver struct {
+		Name           string      `json:"name"`
+		InformationURI string      `json:"informationUri"`
+		Rules          []sarifRule `json:"rules"`
+	}
+	sarifRule struct {
+		ID                   string       `json:"id"`
+		ShortDescription     sarifMessage `json:"shortDescription"`
+		DefaultConfiguration struct {
+			Level string `json:"level"`
+		} `json:"defaultConfiguration"`
+	}
+	sarifResult struct {
+		RuleID    string          `json:"ruleId"`
+		Level     string          `json:"level"`
+		Message   sarifMessage    `json:"message"`
+		Locations []sarifLocation `json:"locations"`
+	}
+	sarifMessage struct {
+		Text string `json:"text"`
+	}
+	sarifLocation struct {
+		PhysicalLocation struct {
+			ArtifactLocation struct {
+				URI string `json:"uri"`
+			} `json:"artifactLocation"`
+			Region struct {
+				StartLine int `json:"startLine"`
+			} `json:"region"`
+		} `json:"physicalLocation"`
+	}
+)
+
+// sarif renders lint findings as a SARIF 2.1.0 log.
+func sarif(fs []drift.Finding, cfg drift.LintConfig) ([]byte, error) {
+	driver := sarifDriver{
+		Name:           "drift",
+		InformationURI: "https://github.com/metagram-net/drift",
+	}
+	for _, r := range drift.LintRules() {
+		sr := sarifRule{ID: r.ID, ShortDescription: sarifMessage{Text: r.Description}}
+		sr.DefaultConfiguration.Level = sarifLevel(r.Severity)
+		if s, ok := cfg.Severity[r.ID]; ok {
+			sr.DefaultConfiguration.Level = sarifLevel(s)
+		}
+		driver.Rules = append(driver.Rules, sr)
+	}
+
+	results := make([]sarifResult, 0, len(fs))
+	for _, f := range fs {
+		var loc sarifLocation
+		loc.PhysicalLocation.ArtifactLocation.URI = filepath.ToSlash(f.Path)
+		loc.PhysicalLocation.Region.StartLine = f.Line
+		results = append(results, sarifResult{
+			RuleID:    f.Rule,
+			Level:     sarifLevel(f.Severity),
+			Message:   sarifMessage{Text: f.Message},
+			Locations: []sarifLocation{loc},
+		})
+	}
+
+	return json.MarshalIndent(sarifLog{
+		Version: "2.1.0",
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs:    []sarifRun{{Tool: sarifTool{Driver: driver}, Results: results}},
+	}, "", "  ")
+}
+
+func sarifLevel(s drift.Severity) string {
+	if s == drift.SeverityError {
+		return "error"
+	}
+	return "warning"
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/spf13/cobra"
+
+	"github.com/metagram-net/drift"
+)
+
+const sealLong string = `Print a seal of the current migration files.
+
+A seal lists the git blob hash of every migration file. Commit the output as a
+lockfile and pass it to migrate with --seal-file to make sure that only the
+reviewed versions of the migrations can be applied:
+
+    drift seal > drift.lock
+    drift migrate --seal-file drift.lock
+
+Alternatively, use --seal-ref to seal against the files in a git tag or commit
+without a lockfile.`
+
+func sealCmd(cli *CLI) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "seal",
+		Short: "Print a seal of the current migration files",
+		Long:  sealLong,
+		Args:  cobra.NoArgs,
+		Run: func(_ *cobra.Command, _ []string) {
+			var more []string
+			if dirs := migrationsDirs(); len(dirs) > 1 {
+				more = dirs[1:]
+			}
+			s, err := drift.SealDir(cli, migrationsDir(), more...)
+			if err != nil {
+				cli.Exitf(1, "seal migrations: %s", err)
+			}
+			if _, err := s.WriteTo(cli.stdout); err != nil {
+				cli.Exitf(1, "write seal: %s", err)
+			}
+		},
+	}
+	return cmd
+}
+
+// readSeal reads the seal from the --seal-file lockfile or the --seal-ref git
+// ref, whichever is set. It returns nil if neither is.
+func readSeal(sealFile, sealRef string) (drift.Seal, error) {
+	switch {
+	case sealFile != "":
+		s, err := readSealFile(sealFile)
+		if err != nil {
+			return nil, fmt.Errorf("read seal file: %w", err)
+		}
+		return s, nil
+	case sealRef != "":
+		s, err := readSealRef(migrationsDirs(), sealRef)
+		if err != nil {
+			return nil, fmt.Errorf("read seal from git: %w", err)
+		}
+		return s, nil
+	}
+	return nil, nil
+}
+
+// readSealFile reads a seal from a lockfile.
+func readSealFile(path string) (drift.Seal, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return drift.ReadSeal(f)
+}
+
+// readSealRef reads a seal from the migration files in a git tree, in each of
+// the directories.
+func readSealRef(dirs []string, ref string) (drift.Seal, error) {
+	seal := make(drift.Seal)
+	for _, d := range dirs {
+		//#nosec G204 // The ref comes from the user running the command.
+		out, err := exec.Command("git", "-C", d, "ls-tree", "-r", ref, "--", ".").Output()
+		if err != nil {
+			return nil, err
+		}
+		s, err := drift.ReadSeal(bytes.NewReader(out))
+		if err != nil {
+			return nil, err
+		}
+		for name, hash := range s {
+			seal[name] = hash
+		}
+	}
+	return seal, nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/metagram-net/drift"
+)
+
+const seedLong string = `Load development or staging data from seed files.
+
+Seed files are the .sql files in the seeds directory, run in name order (like
+01-users.sql before 02-posts.sql). Each runs in its own transaction.
+
+With seed-mode "once" (the default), each file is recorded in the seeds table
+and skipped on later runs, so add new files for new data. Files that changed
+after they ran are skipped and reported. With seed-mode "always", every file
+runs every time, so write them to be idempotent (like INSERT ... ON CONFLICT DO
+NOTHING).`
+
+func seedCmd(cli *CLI) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "seed",
+		Short: "Load seed data",
+		Long:  seedLong,
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, _ []string) {
+			db, err := openDB()
+			if err != nil {
+				cli.Exitf(1, "open database connection: %s", err)
+			}
+			defer db.Close()
+
+			if err := runSeeds(cmd.Context(), cli, db); err != nil {
+				cli.Exitf(1, "seed: %s", err)
+			}
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.String("seeds-dir", defaultSeedsDir, "Directory containing seed files")
+	flags.String("mode", string(drift.SeedOnce), "Which seeds to run: once (each file once) or always (every file every time)")
+	viper.BindPFlag("seeds-dir", flags.Lookup("seeds-dir"))
+	viper.BindPFlag("seed-mode", flags.Lookup("mode"))
+	_ = cmd.RegisterFlagCompletionFunc("mode", func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+		var modes []string
+		for _, m := range drift.SeedModes {
+			modes = append(modes, string(m))
+		}
+		return modes, cobra.ShellCompDirectiveNoFileComp
+	})
+	return cmd
+}
+
+// runSeeds runs the configured seed files and reports what happened.
+func runSeeds(ctx context.Context, cli *CLI, db *sql.DB) error {
+	mode, err := parseSeedMode(viper.GetString("seed-mode"))
+	if err != nil {
+		return err
+	}
+	dir := viper.GetString("seeds-dir")
+	res, err := newMigrator(cli).Seed(ctx, db, dir, mode)
+	if err != nil {
+		return err
+	}
+	var ran, changed int
+	for _, r := range res {
+		if r.Ran {
+			ran++
+		}
+		if r.Changed {
+			changed++
+		}
+	}
+	switch {
+	case len(res) == 0:
+		cli.Infof("No seed files in %s", dir)
+	case changed > 0:
+		cli.Infof("Ran %d of %d seed files. %d changed after they ran and were skipped.", ran, len(res), changed)
+	default:
+		cli.Infof("Ran %d of %d seed files.", ran, len(res))
+	}
+	return nil
+}
+
+var errInvalidSeedMode = errors.New("invalid seed mode")
+
+// parseSeedMode parses a seed-mode setting.
+func parseSeedMode(s string) (drift.SeedMode, error) {
+	for _, m := range drift.SeedModes {
+		if string(m) == s {
+			return m, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %q (want one of %v)", errInvalidSeedMode, s, drift.SeedModes)
+}
@@ -1,26 +1,90 @@
 package main
 
 import (
-	"github.com/metagram-net/drift"
+	"errors"
+	"os"
+
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
+
+	"github.com/metagram-net/drift"
 )
 
+const setupLong string = `Set up the migrations directory by writing the init migration (0-init.sql),
+which creates the migrations table and Drift's functions.
+
+Running setup again is safe: if the init migration is already there and
+matches what setup would write, nothing changes. If it differs (because it was
+edited, or the migrations table or schema settings changed), setup says so and
+exits with a non-zero status without touching it.
+
+Use --template to write the init migration from your own template, like one
+that adds columns to the migrations table. Run "drift init-template" to print
+the default one to start from.
+
+Use --apply to run the init migration right away.`
+
 func setupCmd(cli *CLI) *cobra.Command {
+	var (
+		templateFile string
+		apply        bool
+	)
+
 	cmd := &cobra.Command{
 		Use:     "setup",
 		Aliases: []string{"init"},
 		Short:   "Set up the migrations directory",
+		Long:    setupLong,
 		Args:    cobra.NoArgs,
-		Run: func(_ *cobra.Command, _ []string) {
-			path, err := drift.Setup(viper.GetString("migrations-dir"))
-			if err != nil {
+		Run: func(cmd *cobra.Command, _ []string) {
+			var opts []drift.Option
+			if templateFile != "" {
+				text, err := os.ReadFile(templateFile)
+				if err != nil {
+					cli.Exitf(1, "read init template: %s", err)
+				}
+				tmpl, err := drift.ParseInitTemplate(string(text))
+				if err != nil {
+					cli.Exitf(1, "parse init template: %s", err)
+				}
+				opts = append(opts, drift.WithInitTemplate(tmpl))
+			}
+			m := newMigrator(cli, opts...)
+			dir := migrationsDir()
+
+			path, err := m.Setup(dir)
+			switch {
+			case errors.Is(err, drift.ErrAlreadySetUp):
+				cli.Infof("The init migration is already set up: %s", path)
+			case errors.Is(err, drift.ErrInitMismatch):
+				cli.Exitf(1, "The init migration doesn't match the one setup would write (it was edited, or the template or migrations table changed): %s", path)
+			case err != nil:
 				cli.Exitf(1, "set up migrations: %s", err)
+			default:
+				cli.Infof("Created the first migration file: %s", path)
 			}
 
-			cli.Infof("Created the first migration file: %s", path)
-			cli.Infof("Run the migrate command to apply it.")
+			if !apply {
+				if err == nil {
+					cli.Infof("Run the migrate command to apply it.")
+				}
+				return
+			}
+
+			db, err := openDB()
+			if err != nil {
+				cli.Exitf(1, "open database connection: %s", err)
+			}
+			defer db.Close()
+
+			upto := drift.MigrationID(0)
+			if _, err := m.Run(cmd.Context(), db, dir, &upto); err != nil {
+				cli.Exitf(1, "apply init migration: %s", err)
+			}
 		},
 	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&templateFile, "template", "", "Write the init migration from this template file")
+	flags.BoolVar(&apply, "apply", false, "Apply the init migration after writing it")
 	return cmd
 }
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const showLong string = `Show a single migration, found by its ID or slug.
+
+This prints the file path, the directives the file declares, and the SQL
+content. If a database URL is configured, it also shows whether (and when) the
+migration was applied.`
+
+func showCmd(cli *CLI) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:               "show <id|slug>",
+		Short:             "Show a single migration",
+		Long:              showLong,
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeKeys(cli),
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx := cmd.Context()
+			dir := migrationsDir()
+
+			var db *sql.DB
+			if viper.GetString("database-url") != "" {
+				var err error
+				db, err = openDB()
+				if err != nil {
+					cli.Exitf(1, "open database connection: %s", err)
+				}
+				defer db.Close()
+			}
+
+			info, err := newMigrator(cli).Show(ctx, db, dir, args[0])
+			if err != nil {
+				cli.Exitf(1, "show: %s", err)
+			}
+
+			cli.Printf("ID:          %d", info.ID)
+			cli.Printf("Slug:        %s", info.Slug)
+			cli.Printf("Path:        %s", info.Path)
+			cli.Printf("Transaction: %t", info.Transaction)
+			if info.Timeout > 0 {
+				cli.Printf("Timeout:     %s", info.Timeout)
+			}
+			for _, d := range info.Directives {
+				cli.Printf("Directive:   %s", strings.TrimPrefix(d.String(), "--drift:"))
+			}
+			switch {
+			case db == nil:
+				cli.Printf("Applied:     unknown (no database URL)")
+			case info.Applied == nil:
+				cli.Printf("Applied:     %s", cli.outColor(colorYellow, "no"))
+			default:
+				cli.Printf("Applied:     %s", cli.outColor(colorGreen, info.Applied.RunAt.Format(time.RFC3339)))
+				if info.Applied.Duration != nil {
+					cli.Printf("Duration:    %s", *info.Applied.Duration)
+				}
+				if info.Applied.AppliedBy != "" {
+					cli.Printf("Applied by:  %s", info.Applied.AppliedBy)
+				}
+			}
+			cli.Printf("\n%s", strings.TrimSuffix(info.Content, "\n"))
+		},
+	}
+	return cmd
+}
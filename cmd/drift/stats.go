@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+const statsLong string = `Show statistics about using Drift.
+
+With --usage, this shows the local usage stats recorded when telemetry is
+enabled (telemetry = true in the config file): how often each command ran, how
+often it failed, and how long it took. The stats never leave this machine
+unless you configure a telemetry-exporter command.`
+
+func statsCmd(cli *CLI) *cobra.Command {
+	var showUsage bool
+
+	cmd := &cobra.Command{
+		Use:   "stats",
+		Short: "Show statistics about using Drift",
+		Long:  statsLong,
+		Args:  cobra.NoArgs,
+		Run: func(_ *cobra.Command, _ []string) {
+			if !showUsage {
+				cli.Exitf(1, "Nothing to show: pass --usage")
+			}
+
+			path := usageFile()
+			stats, err := readUsage(path)
+			if err != nil {
+				cli.Exitf(1, "read usage stats: %s", err)
+			}
+			if len(stats.Commands) == 0 {
+				cli.Infof("No usage recorded in %s. Set telemetry = true in the config file to start recording.", path)
+				return
+			}
+
+			names := make([]string, 0, len(stats.Commands))
+			for name := range stats.Commands {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+
+			var b bytes.Buffer
+			t := tablewriter.NewWriter(&b)
+			t.SetAutoFormatHeaders(false)
+			t.SetAutoWrapText(false)
+			t.SetHeader([]string{"Command", "Runs", "Failures", "Average", "Max", "Last run"})
+			for _, name := range names {
+				cs := stats.Commands[name]
+				avg := time.Duration(0)
+				if cs.Runs > 0 {
+					avg = time.Duration(cs.TotalMS/int64(cs.Runs)) * time.Millisecond
+				}
+				t.Append([]string{
+					name,
+					strconv.Itoa(cs.Runs),
+					strconv.Itoa(cs.Failures),
+					avg.String(),
+					(time.Duration(cs.MaxMS) * time.Millisecond).String(),
+					cs.LastRunAt.Local().Format(time.RFC3339),
+				})
+			}
+			t.Render()
+			cli.Printf("%s", b.String())
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.BoolVar(&showUsage, "usage", false, "Show local usage stats")
+	return cmd
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+
+	"github.com/metagram-net/drift"
+)
+
+const statusLong string = `Summarize the state of the database's migrations.
+
+This prints the latest applied migration, how many are pending, and any failed
+migration attempts that haven't been resolved by applying the migration since
+(see the failures-table setting). Run "drift retry" to retry the latest one.`
+
+func statusCmd(cli *CLI) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Summarize the state of the database's migrations",
+		Long:  statusLong,
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, _ []string) {
+			ctx := cmd.Context()
+			db, err := openDB()
+			if err != nil {
+				cli.Exitf(1, "open database connection: %s", err)
+			}
+			defer db.Close()
+
+			m := newMigrator(cli)
+			h, err := m.Current(ctx, db)
+			switch {
+			case errors.Is(err, drift.ErrNoMigrationsTable):
+				cli.Printf("Version:  (not set up)")
+			case err != nil:
+				cli.Exitf(1, "status: %s", err)
+			case h == nil:
+				cli.Printf("Version:  (none)")
+			default:
+				cli.Printf("Version:  %d (%s)", h.ID, h.Slug)
+			}
+
+			ps, err := m.Pending(ctx, db, migrationsDir())
+			if err != nil {
+				cli.Exitf(1, "status: %s", err)
+			}
+			cli.Printf("Pending:  %d", len(ps))
+
+			fs, err := m.Failures(ctx, db)
+			if err != nil {
+				cli.Exitf(1, "status: %s", err)
+			}
+			cli.Printf("Failures: %d", len(fs))
+			if len(fs) == 0 {
+				return
+			}
+			var b bytes.Buffer
+			t := tablewriter.NewWriter(&b)
+			t.SetAutoFormatHeaders(false)
+			t.SetAutoWrapText(false)
+			t.SetHeader([]string{"ID", "Slug", "Failed at", "Error"})
+			for _, f := range fs {
+				t.Append([]string{
+					strconv.FormatInt(int64(f.MigrationID), 10),
+					f.Slug,
+					f.FailedAt.Format(time.RFC3339),
+					strings.Join(strings.Fields(f.Error), " "),
+				})
+			}
+			t.Render()
+			cli.Printf("%s", b.String())
+		},
+	}
+	return cmd
+}
+
+const retryLong string = `Apply the migration that failed most recently, once the problem is fixed.
+
+Failed attempts are recorded in the failures table (see the failures-table
+setting). Like "drift apply", this refuses to apply the migration while
+earlier ones are still pending.`
+
+func retryCmd(cli *CLI) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "retry",
+		Short: "Apply the migration that failed most recently",
+		Long:  retryLong,
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, _ []string) {
+			db, err := openDB()
+			if err != nil {
+				cli.Exitf(1, "open database connection: %s", err)
+			}
+			defer db.Close()
+
+			_, err = newMigrator(cli).Retry(cmd.Context(), db, migrationsDir())
+			if errors.Is(err, drift.ErrNoFailures) {
+				cli.Infof("No failed migrations to retry.")
+				return
+			}
+			if err != nil {
+				cli.Exitf(1, "retry: %s", err)
+			}
+		},
+	}
+	return cmd
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+var (
+	errNoTargets       = errors.New("no targets in the config file")
+	errUnknownTarget   = errors.New("no such target in the config file")
+	errUnnamedTarget   = errors.New("target needs a name")
+	errTargetSetting   = errors.New("setting can't be set per target")
+	errDuplicateTarget = errors.New("duplicate target name")
+)
+
+// targetSettings are the settings a target can override. Settings it leaves
+// out keep their top-level values.
+var targetSettings = []string{
+	"database-url",
+	"database-url-file",
+	"database-password-file",
+	"database-auth",
+	"driver",
+	"migrations-dir",
+	"migrations-schema",
+	"migrations-table",
+	"environment",
+	"protected",
+}
+
+// targetBase holds the top-level values of the target settings, so that
+// selecting one target after another doesn't leak settings between them.
+var targetBase map[string]interface{}
+
+// targets returns the [[targets]] tables from the config file, in order, like:
+//
+//	[[targets]]
+//	name = "analytics"
+//	database-url = "postgres://analytics-db/analytics"
+//	migrations-dir = "analytics/migrations"
+func targets() ([]map[string]interface{}, error) {
+	raw, ok := viper.Get("targets").([]interface{})
+	if !ok || len(raw) == 0 {
+		// Viper decodes arrays of tables as []map[string]interface{}.
+		if ts, ok := viper.Get("targets").([]map[string]interface{}); ok && len(ts) > 0 {
+			return ts, nil
+		}
+		return nil, errNoTargets
+	}
+	ts := make([]map[string]interface{}, 0, len(raw))
+	for _, r := range raw {
+		t, ok := r.(map[string]interface{})
+		if !ok {
+			return nil, errUnnamedTarget
+		}
+		ts = append(ts, t)
+	}
+	return ts, nil
+}
+
+// targetNames lists the targets in config file order.
+func targetNames() ([]string, error) {
+	ts, err := targets()
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	names := make([]string, 0, len(ts))
+	for _, t := range ts {
+		name, _ := t["name"].(string)
+		if name == "" {
+			return nil, errUnnamedTarget
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("%w: %s", errDuplicateTarget, name)
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// targetSets reports whether the named target sets the key.
+func targetSets(name, key string) bool {
+	ts, err := targets()
+	if err != nil {
+		return false
+	}
+	for _, t := range ts {
+		if t["name"] == name {
+			_, ok := t[key]
+			return ok
+		}
+	}
+	return false
+}
+
+// selectTarget replaces the connection and migrations settings with the ones
+// from the named target.
+func selectTarget(name string) error {
+	if name == "" {
+		return nil
+	}
+	ts, err := targets()
+	if err != nil {
+		return err
+	}
+	var target map[string]interface{}
+	for _, t := range ts {
+		if t["name"] == name {
+			target = t
+			break
+		}
+	}
+	if target == nil {
+		return fmt.Errorf("%w: %s", errUnknownTarget, name)
+	}
+
+	if targetBase == nil {
+		targetBase = make(map[string]interface{})
+		for _, key := range targetSettings {
+			targetBase[key] = viper.Get(key)
+		}
+	}
+	for key, v := range targetBase {
+		viper.Set(key, v)
+	}
+
+	keys := make([]string, 0, len(target))
+	for key := range target {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if key == "name" {
+			continue
+		}
+		if _, ok := targetBase[strings.ToLower(key)]; !ok {
+			return fmt.Errorf("%w: %s", errTargetSetting, key)
+		}
+		v := target[key]
+		if s, ok := v.(string); ok {
+			if s, err = interpolate(s); err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			v = s
+			if strings.EqualFold(key, "migrations-dir") {
+				// One directory, which GetStringSlice would split on spaces.
+				v = []string{s}
+			}
+		}
+		viper.Set(key, v)
+	}
+	return nil
+}
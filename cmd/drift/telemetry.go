@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// usageStats are the anonymous usage statistics kept when telemetry is
+// enabled. They never include arguments, paths, hostnames, or SQL.
+type usageStats struct {
+	Commands map[string]*commandStats `json:"commands"`
+}
+
+type commandStats struct {
+	Runs      int       `json:"runs"`
+	Failures  int       `json:"failures"`
+	TotalMS   int64     `json:"total_ms"`
+	MaxMS     int64     `json:"max_ms"`
+	LastRunAt time.Time `json:"last_run_at"`
+}
+
+// usageEvent is what the exporter command receives on stdin.
+type usageEvent struct {
+	Command    string    `json:"command"`
+	StartedAt  time.Time `json:"started_at"`
+	DurationMS int64     `json:"duration_ms"`
+	ExitCode   int       `json:"exit_code"`
+}
+
+// usage records a single command run. A nil *usage records nothing, so
+// telemetry can be left disabled without checks at every call site.
+type usage struct {
+	cli     *CLI
+	command string
+	start   time.Time
+	once    sync.Once
+}
+
+// startUsage starts recording a run of the command, if telemetry is enabled.
+func startUsage(cli *CLI, command string) *usage {
+	if !viper.GetBool("telemetry") {
+		return nil
+	}
+	return &usage{cli: cli, command: command, start: time.Now()}
+}
+
+// finish records the run's outcome. Only the first call has any effect.
+// Telemetry is best-effort, so errors are only logged at debug level.
+func (u *usage) finish(exitCode int) {
+	if u == nil {
+		return
+	}
+	u.once.Do(func() {
+		ev := usageEvent{
+			Command:    u.command,
+			StartedAt:  u.start.UTC(),
+			DurationMS: time.Since(u.start).Milliseconds(),
+			ExitCode:   exitCode,
+		}
+		if err := recordUsage(usageFile(), ev); err != nil {
+			u.cli.Debugf("record usage stats: %s", err)
+		}
+		if exporter := viper.GetString("telemetry-exporter"); exporter != "" {
+			if err := exportUsage(exporter, ev); err != nil {
+				u.cli.Debugf("export usage stats: %s", err)
+			}
+		}
+	})
+}
+
+// usageFile returns the path of the local usage stats file.
+func usageFile() string {
+	if path := viper.GetString("telemetry-file"); path != "" {
+		return path
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "drift", "usage.json")
+}
+
+func readUsage(path string) (usageStats, error) {
+	stats := usageStats{Commands: make(map[string]*commandStats)}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return stats, nil
+	}
+	if err != nil {
+		return stats, err
+	}
+	if err := json.Unmarshal(b, &stats); err != nil {
+		return stats, err
+	}
+	if stats.Commands == nil {
+		stats.Commands = make(map[string]*commandStats)
+	}
+	return stats, nil
+}
+
+func recordUsage(path string, ev usageEvent) error {
+	stats, err := readUsage(path)
+	if err != nil {
+		return err
+	}
+	cs := stats.Commands[ev.Command]
+	if cs == nil {
+		cs = &commandStats{}
+		stats.Commands[ev.Command] = cs
+	}
+	cs.Runs++
+	if ev.ExitCode != 0 {
+		cs.Failures++
+	}
+	cs.TotalMS += ev.DurationMS
+	if ev.DurationMS > cs.MaxMS {
+		cs.MaxMS = ev.DurationMS
+	}
+	cs.LastRunAt = ev.StartedAt
+
+	b, err := json.MarshalIndent(stats, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil { //#nosec G301 // Usage stats aren't secret.
+		return err
+	}
+	// Write to a temporary file and rename it so a concurrent run never reads
+	// a partial file. Each run gets its own temporary file, so concurrent runs
+	// don't write over each other's.
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// exportUsage runs the exporter command with the event as JSON on stdin. This
+// is the hook for teams that want to collect usage centrally. An exporter
+// that's only whitespace is treated as unset.
+func exportUsage(exporter string, ev usageEvent) error {
+	args := strings.Fields(exporter)
+	if len(args) == 0 {
+		return nil
+	}
+	b, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(args[0], args[1:]...) //#nosec G204 // The exporter comes from the user's own config.
+	cmd.Stdin = bytes.NewReader(b)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"strings"
+
 	_ "github.com/jackc/pgx/v4/stdlib" // database/sql driver: pgx
 	"github.com/spf13/cobra"
 
@@ -18,3 +20,15 @@ func migrationTemplateCmd(cli *CLI) *cobra.Command {
 	}
 	return cmd
 }
+
+func initTemplateCmd(cli *CLI) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "init-template",
+		Short: "Print the embedded default init migration template",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, _ []string) {
+			cli.Printf("%s", strings.TrimSpace(drift.DefaultInitTemplate()))
+		},
+	}
+	return cmd
+}
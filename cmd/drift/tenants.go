@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/viper"
+
+	"github.com/metagram-net/drift"
+	"github.com/metagram-net/drift/internal/dburl"
+)
+
+var errNoTenants = errors.New("no tenant schemas (set tenants.schemas or tenants.query, or pass --schemas)")
+
+// migrateTenants migrates each tenant schema and exits if any of them fails.
+func migrateTenants(ctx context.Context, cli *CLI, db *sql.DB, m *drift.Migrator, dir string, upto *drift.MigrationID, schemas []string) {
+	schemas, err := tenantSchemas(ctx, db, schemas)
+	if err != nil {
+		cli.Exitf(1, "list tenants: %s", err)
+	}
+	results, err := m.RunTenants(ctx, openTenantDB, dir, schemas, upto)
+	cli.Printf("%s", tenantSummary(cli, results))
+	if len(results) < len(schemas) {
+		cli.Infof("Not started: %d tenants (use --keep-going to continue past failures)", len(schemas)-len(results))
+	}
+	if err != nil {
+		cli.Exitf(1, "run migrations: %s", err)
+	}
+}
+
+// tenantSummary renders a table of what happened to each tenant.
+func tenantSummary(cli *CLI, results []drift.TenantResult) string {
+	var b bytes.Buffer
+	t := tablewriter.NewWriter(&b)
+	t.SetAutoFormatHeaders(false)
+	t.SetAutoWrapText(false)
+	t.SetHeader([]string{"Tenant", "Status", "Applied", "Version", "Error"})
+	for _, r := range results {
+		status, applied, version, msg := cli.outColor(colorGreen, "ok"), "", "", ""
+		if r.Result != nil {
+			applied = appliedIDs(r.Result)
+			if r.Result.Version >= 0 {
+				version = r.Result.Version.String()
+			}
+		}
+		if r.Err != nil {
+			status, msg = cli.outColor(colorRed, "failed"), r.Err.Error()
+		}
+		t.Append([]string{r.Schema, status, applied, version, msg})
+	}
+	t.Render()
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// tenantSchemas returns the tenant schemas from the flag, or else from the
+// tenants config: a list of schemas, or a query that returns them.
+func tenantSchemas(ctx context.Context, db *sql.DB, flag []string) ([]string, error) {
+	if len(flag) > 0 {
+		return flag, nil
+	}
+	if schemas := viper.GetStringSlice("tenants.schemas"); len(schemas) > 0 {
+		return schemas, nil
+	}
+	query := viper.GetString("tenants.query")
+	if query == "" {
+		return nil, errNoTenants
+	}
+
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var schemas []string
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return nil, err
+		}
+		schemas = append(schemas, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(schemas) == 0 {
+		return nil, errNoTenants
+	}
+	return schemas, nil
+}
+
+// openTenantDB opens a connection pool whose search_path is the tenant's
+// schema.
+func openTenantDB(schema string) (*sql.DB, error) {
+	url, opts, err := connectionConfig(map[string]string{
+		"search_path": `"` + strings.ReplaceAll(schema, `"`, `""`) + `"`,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return dburl.Open(url, opts...)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/metagram-net/drift"
+)
+
+const testLong string = `Apply all migrations to a throwaway database.
+
+This creates a uniquely named temporary database on the configured server, runs
+every migration from zero, and then drops the database. The database URL only
+needs enough privileges to create and drop databases.
+
+Exits with a non-zero status if any step fails, which makes this a useful
+continuous integration check for a migrations directory.
+
+Only applying migrations is tested. Drift doesn't run down migrations, so
+down.sql files next to migrations aren't run or checked.`
+
+func testCmd(cli *CLI) *cobra.Command {
+	var keep bool
+
+	cmd := &cobra.Command{
+		Use:   "test",
+		Short: "Apply all migrations to a throwaway database",
+		Long:  testLong,
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, _ []string) {
+			ctx := cmd.Context()
+			dir := migrationsDir()
+
+			admin, err := openDB()
+			if err != nil {
+				cli.Exitf(1, "open database connection: %s", err)
+			}
+			defer admin.Close()
+
+			name, err := tempDatabaseName()
+			if err != nil {
+				cli.Exitf(1, "generate database name: %s", err)
+			}
+
+			cli.Infof("Creating temporary database: %s", name)
+			if err := drift.CreateDatabase(ctx, admin, name); err != nil {
+				cli.Exitf(1, "create temporary database: %s", err)
+			}
+
+			err = migrateTemp(ctx, cli, name, dir)
+
+			if keep {
+				cli.Infof("Keeping temporary database: %s", name)
+			} else {
+				cli.Infof("Dropping temporary database: %s", name)
+				// Clean up even if the run was interrupted, but don't hang on
+				// an unreachable server.
+				dctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+				if derr := drift.DropDatabase(dctx, admin, name); derr != nil {
+					cli.Warnf("Could not drop temporary database %s: %s", name, derr)
+				}
+				cancel()
+			}
+
+			if err != nil {
+				cli.Exitf(1, "run migrations: %s", err)
+			}
+			cli.Infof("All migrations applied cleanly.")
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.BoolVar(&keep, "keep", false, "Keep the temporary database instead of dropping it")
+	return cmd
+}
+
+func migrateTemp(ctx context.Context, cli *CLI, name, dir string) error {
+	db, err := openNamedDB(name)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return newMigrator(cli).Migrate(ctx, db, dir, nil)
+}
+
+func tempDatabaseName() (string, error) {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("drift_test_%s", hex.EncodeToString(b)), nil
+}
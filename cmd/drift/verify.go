@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/metagram-net/drift"
+)
+
+const verifyLong string = `Verify that the database matches the migration files.
+
+By default, this checks that every applied migration still has a matching file
+in the migrations directory, and that the file hasn't changed since it was
+applied:
+
+- With --seal-file or --seal-ref, every applied file matches the seal.
+- Where the migrations table recorded the applied SQL (see migrate
+  --audit-content), the file still produces the same SQL.
+
+With --deep, this also checks the database catalog:
+
+- Every index listed in verify.indexes exists and is valid.
+- Every constraint listed in verify.constraints exists and is validated.
+- No invalid indexes were left behind in any schema (except the system ones)
+  by failed CREATE INDEX CONCURRENTLY builds. Indexes still being built don't
+  count.
+
+Exits with a non-zero status if any discrepancies are found.`
+
+func verifyCmd(cli *CLI) *cobra.Command {
+	var (
+		deep     bool
+		sealFile string
+		sealRef  string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Verify that the database matches the migration files",
+		Long:  verifyLong,
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, _ []string) {
+			ctx := cmd.Context()
+			dir := migrationsDir()
+
+			db, err := openDB()
+			if err != nil {
+				cli.Exitf(1, "open database connection: %s", err)
+			}
+			defer db.Close()
+
+			seal, err := readSeal(sealFile, sealRef)
+			if err != nil {
+				cli.Exitf(1, "%s", err)
+			}
+			var opts []drift.Option
+			if seal != nil {
+				opts = append(opts, drift.WithSeal(seal))
+			}
+
+			inv := drift.Invariants{
+				Indexes:     viper.GetStringSlice("verify.indexes"),
+				Constraints: viper.GetStringSlice("verify.constraints"),
+			}
+			ds, err := newMigrator(cli, opts...).Verify(ctx, db, dir, deep, inv)
+			if err != nil {
+				cli.Exitf(1, "verify: %s", err)
+			}
+
+			if len(ds) == 0 {
+				cli.Infof("No discrepancies found.")
+				return
+			}
+
+			var b bytes.Buffer
+			t := tablewriter.NewWriter(&b)
+			t.SetAutoFormatHeaders(false)
+			t.SetAutoWrapText(false)
+			t.SetHeader([]string{"Object", "Problem", "Fix"})
+			for _, d := range ds {
+				t.Append([]string{d.Object, d.Problem, d.Fix})
+			}
+			t.Render()
+			cli.Printf("%s", b.String())
+			cli.Exitf(1, "Found %d discrepancies", len(ds))
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.BoolVar(&deep, "deep", false, "Also check catalog invariants and invalid indexes")
+	flags.StringVar(&sealFile, "seal-file", "", "Check applied migrations against this seal lockfile")
+	flags.StringVar(&sealRef, "seal-ref", "", "Check applied migrations against the files in this git ref")
+	return cmd
+}
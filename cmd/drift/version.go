@@ -0,0 +1,90 @@
+package main
+
+import (
+	"runtime"
+	"runtime/debug"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// Build metadata, set by the linker:
+//
+//	go build -ldflags "-X main.version=1.2.3 -X main.commit=$(git rev-parse HEAD) -X main.date=$(date -u +%FT%TZ)"
+//
+// If they're not set, commit and date (of the commit) come from the VCS info
+// that go build embeds.
+var (
+	version = "0.1.1"
+	commit  = ""
+	date    = ""
+)
+
+const versionLong string = `Print Drift's version and build metadata.
+
+Include this output in bug reports. With --schema, it also prints the
+migrations table that this version of Drift expects, as the init migration
+creates it.`
+
+func versionCmd(cli *CLI) *cobra.Command {
+	var schema bool
+
+	cmd := &cobra.Command{
+		Use:   "version",
+		Short: "Print the version and build metadata",
+		Long:  versionLong,
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, _ []string) {
+			commit, date := buildMetadata()
+			cli.Printf("Version: %s", version)
+			cli.Printf("Commit:  %s", commit)
+			cli.Printf("Date:    %s", date)
+			cli.Printf("Go:      %s %s/%s", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+
+			if schema {
+				s, err := newMigrator(cli).TableSchema()
+				if err != nil {
+					cli.Exitf(1, "render table schema: %s", err)
+				}
+				cli.Printf("\n%s", strings.TrimSuffix(s, "\n"))
+			}
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.BoolVar(&schema, "schema", false, "Also print the migrations table schema this version expects")
+	return cmd
+}
+
+// buildMetadata returns the commit and build date, falling back to the VCS info
+// embedded by go build.
+func buildMetadata() (string, string) {
+	c, d := commit, date
+	var modified bool
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if c == "" {
+					c = s.Value
+				}
+			case "vcs.time":
+				if d == "" {
+					d = s.Value
+				}
+			case "vcs.modified":
+				modified = s.Value == "true"
+			}
+		}
+	}
+	switch {
+	case c == "":
+		c = "unknown"
+	case modified && commit == "":
+		c += " (modified)"
+	}
+	if d == "" {
+		d = "unknown"
+	}
+	return c, d
+}
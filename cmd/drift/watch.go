@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/cobra"
+
+	"github.com/metagram-net/drift"
+)
+
+const watchLong string = `Apply new migrations as soon as they're written.
+
+This applies any pending migrations, then watches the migrations directory and
+applies pending migrations again whenever a file changes. It's meant for a
+development database, with drift new in another terminal.
+
+A pending migration with no SQL statements yet (like one drift new just wrote)
+waits until it has some, and so do the migrations after it. A migration that
+fails is tried again the next time a file changes. Editing a migration that
+was already applied doesn't apply it again.
+
+Press Ctrl-C to stop watching.`
+
+// watchDelay is how long to wait for more changes before applying, since
+// editors often write a file in several steps.
+const watchDelay = 300 * time.Millisecond
+
+func watchCmd(cli *CLI) *cobra.Command {
+	return &cobra.Command{
+		Use:   "watch",
+		Short: "Apply new migrations whenever the migration files change",
+		Long:  watchLong,
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, _ []string) {
+			ctx := cmd.Context()
+			dir := migrationsDir()
+
+			db, err := openDB()
+			if err != nil {
+				cli.Exitf(1, "open database connection: %s", err)
+			}
+			defer db.Close()
+
+			w, err := fsnotify.NewWatcher()
+			if err != nil {
+				cli.Exitf(1, "watch migrations: %s", err)
+			}
+			defer w.Close()
+			for _, d := range migrationsDirs() {
+				if err := watchTree(w, d); err != nil {
+					cli.Exitf(1, "watch %s: %s", d, err)
+				}
+			}
+
+			m := newMigrator(cli)
+			applyPending(ctx, cli, db, m, dir)
+			cli.Infof("Watching for changes in %s", strings.Join(migrationsDirs(), ", "))
+
+			timer := time.NewTimer(watchDelay)
+			timer.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case err := <-w.Errors:
+					cli.Infof("Watch error: %s", err)
+				case ev := <-w.Events:
+					if ev.Op&fsnotify.Create != 0 {
+						// Watch new migration directories too. It's fine if
+						// this isn't a directory.
+						_ = watchTree(w, ev.Name)
+					}
+					timer.Reset(watchDelay)
+				case <-timer.C:
+					applyPending(ctx, cli, db, m, dir)
+				}
+			}
+		},
+	}
+}
+
+// watchTree watches the directory and every directory in it.
+func watchTree(w *fsnotify.Watcher, root string) error {
+	return filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return err
+		}
+		return w.Add(path)
+	})
+}
+
+// applyPending applies the pending migrations up to the first one that has no
+// SQL yet. Failures are reported, not fatal.
+func applyPending(ctx context.Context, cli *CLI, db *sql.DB, m *drift.Migrator, dir string) {
+	infos, err := m.List(dir)
+	if err != nil {
+		cli.Errorf("List migrations: %s", err)
+		return
+	}
+	ps, err := m.Pending(ctx, db, dir)
+	if err != nil {
+		cli.Errorf("Get pending migrations: %s", err)
+		return
+	}
+	pending := make(map[drift.MigrationID]bool, len(ps))
+	for _, p := range ps {
+		pending[p.ID] = true
+	}
+
+	var upto *drift.MigrationID
+	var last *drift.MigrationID
+	for _, info := range infos {
+		if !pending[info.ID] {
+			continue
+		}
+		if info.Empty() {
+			cli.Infof("Waiting for SQL in %s", info.Path)
+			if last == nil {
+				return
+			}
+			upto = last
+			break
+		}
+		id := info.ID
+		last = &id
+	}
+	if last == nil {
+		return
+	}
+
+	if _, err := m.Run(ctx, db, dir, upto); err != nil {
+		cli.Errorf("Migration failed: %s", err)
+	}
+}
@@ -0,0 +1,87 @@
+package drift
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+var ErrNotConfirmed = errors.New("migration plan was not confirmed")
+
+// A PlannedMigration is a migration that a run is about to apply.
+type PlannedMigration struct {
+	ID   MigrationID
+	Slug string
+	Name string
+	Path string
+	// Destructive lists the statements in the file that drop or truncate
+	// data, like "drop table".
+	Destructive []string
+}
+
+// WithConfirm makes Migrate call confirm with the plan after checking every
+// planned file and before applying any of them. If confirm returns false, no
+// migrations are applied and Migrate returns ErrNotConfirmed. If confirm
+// returns an error, Migrate returns it.
+//
+// This isn't called if there's nothing to apply.
+func WithConfirm(confirm func([]PlannedMigration) (bool, error)) Option {
+	return func(m *Migrator) {
+		m.confirm = confirm
+	}
+}
+
+// reDestructive finds statements that drop or truncate data.
+var reDestructive = regexp.MustCompile(`(?i)\b(drop\s+(?:table|column|schema|database|materialized\s+view)|truncate)\b`)
+
+// reSQLComment finds SQL line and block comments, so commented-out statements
+// aren't reported.
+var reSQLComment = regexp.MustCompile(`(?s)--[^\n]*|/\*.*?\*/`)
+
+// destructive returns the destructive statements in the content, normalized to
+// lower case with single spaces.
+func destructive(content string) []string {
+	var found []string
+	seen := make(map[string]bool)
+	for _, m := range reDestructive.FindAllString(reSQLComment.ReplaceAllString(content, ""), -1) {
+		s := strings.ToLower(strings.Join(strings.Fields(m), " "))
+		if !seen[s] {
+			seen[s] = true
+			found = append(found, s)
+		}
+	}
+	return found
+}
+
+// destructiveStatements returns the file's destructive statements. Streamed
+// files found them as they were scanned.
+func (f migrationFile) destructiveStatements() []string {
+	if f.summary != nil {
+		return f.summary.destructive
+	}
+	return destructive(f.Content)
+}
+
+func (m *Migrator) confirmPlan(plan []migrationFile) error {
+	if m.confirm == nil || len(plan) == 0 {
+		return nil
+	}
+	ps := make([]PlannedMigration, len(plan))
+	for i, f := range plan {
+		ps[i] = PlannedMigration{
+			ID:          f.ID,
+			Slug:        f.Slug,
+			Name:        f.Name,
+			Path:        f.Path,
+			Destructive: f.destructiveStatements(),
+		}
+	}
+	ok, err := m.confirm(ps)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotConfirmed
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package drift
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+)
+
+// A conn is what migrations run on: a *sql.DB pool, or a *sql.Conn that the
+// caller manages.
+type conn interface {
+	Queryable
+	rowQueryable
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+	PingContext(ctx context.Context) error
+}
+
+var (
+	_ conn = (*sql.DB)(nil)
+	_ conn = (*sql.Conn)(nil)
+)
+
+// dedicatedConn returns a connection for session settings (like advisory locks
+// and timeouts) and a function to give it back. A pool lends one of its
+// connections. A *sql.Conn is used as is, and stays open.
+func dedicatedConn(ctx context.Context, db conn) (*sql.Conn, func(), error) {
+	if c, ok := db.(*sql.Conn); ok {
+		return c, func() {}, nil
+	}
+	c, err := db.(*sql.DB).Conn(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return c, func() { c.Close() }, nil
+}
+
+// RunConn is like Run, but it applies the migrations through a connection the
+// caller manages, like one from a wrapped or instrumented pool. The
+// connection stays open afterward. Migrations are applied one at a time, so
+// WithParallel has no effect.
+func (m *Migrator) RunConn(ctx context.Context, conn *sql.Conn, migrationsDir string, upto *MigrationID) (*Result, error) {
+	return m.runConn(ctx, conn, func() ([]migrationFile, error) {
+		return m.listFiles(migrationsDir)
+	}, upto)
+}
+
+// RunConnFS is like RunConn, but it reads the migration files from the root
+// of fsys instead of a directory on disk.
+func (m *Migrator) RunConnFS(ctx context.Context, conn *sql.Conn, fsys fs.FS, upto *MigrationID) (*Result, error) {
+	return m.runConn(ctx, conn, func() ([]migrationFile, error) {
+		return listFS(m.io, fsys, ".")
+	}, upto)
+}
+
+func (m *Migrator) runConn(ctx context.Context, conn *sql.Conn, load func() ([]migrationFile, error), upto *MigrationID) (*Result, error) {
+	// One connection can only run one migration at a time.
+	one := *m
+	one.parallel = 0
+	return one.run(ctx, conn, load, upto)
+}
+
+// RunTx is like Run, but it applies the migrations in a transaction the caller
+// manages, so they commit or roll back with the caller's other changes. RunTx
+// doesn't commit the transaction, and the Result lists migrations as applied
+// even though they aren't visible to others until it commits. After an error,
+// roll the transaction back.
+//
+// Every planned migration must be able to run in a transaction, like in
+// TransactionAll mode. The transaction mode, WithLock, WithWait, and
+// WithParallel don't apply; WithTimeout and the stop options do, but only
+// before the first migration.
+func (m *Migrator) RunTx(ctx context.Context, tx *sql.Tx, migrationsDir string, upto *MigrationID) (*Result, error) {
+	return m.runTx(ctx, tx, func() ([]migrationFile, error) {
+		return m.listFiles(migrationsDir)
+	}, upto)
+}
+
+// RunTxFS is like RunTx, but it reads the migration files from the root of
+// fsys instead of a directory on disk.
+func (m *Migrator) RunTxFS(ctx context.Context, tx *sql.Tx, fsys fs.FS, upto *MigrationID) (*Result, error) {
+	return m.runTx(ctx, tx, func() ([]migrationFile, error) {
+		return listFS(m.io, fsys, ".")
+	}, upto)
+}
+
+func (m *Migrator) runTx(ctx context.Context, tx *sql.Tx, load func() ([]migrationFile, error), upto *MigrationID) (*Result, error) {
+	// Check the plan like TransactionAll does, since it all runs in one
+	// transaction.
+	all := *m
+	all.txMode = TransactionAll
+	return all.traced(ctx, func(ctx context.Context) (*Result, error) {
+		if err := all.checkTimeouts(); err != nil {
+			return nil, err
+		}
+		res, plan, err := all.preparePlan(ctx, tx, load, upto)
+		if err != nil {
+			return res, err
+		}
+		for _, f := range plan {
+			if len(f.directives.copies) > 0 {
+				return res, fmt.Errorf("%w: %s", ErrCopyInCallerTx, f.Name)
+			}
+		}
+		if all.dryRun {
+			// The caller's transaction is no place for EXPLAIN's own.
+			return res, all.dryRunPlan(ctx, nil, plan, res)
+		}
+		if all.stopRequested() {
+			for _, f := range plan {
+				res.skipped(f, SkipStopped)
+			}
+			return res, ErrStopped
+		}
+		if all.timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, all.timeout)
+			defer cancel()
+		}
+
+		prog := all.newProgress(plan, res)
+		all.io.Infof("Applying %d migrations in the caller's transaction", len(plan))
+		ds, err := all.applyAllTx(ctx, tx, nil, plan, prog, res)
+		if err != nil {
+			return res, err
+		}
+		for i, f := range plan {
+			prog.finish(f.ID)
+			res.applied(f, ds[i])
+		}
+		prog.end(nil)
+		all.logFinished(res)
+		return res, nil
+	})
+}
+
+// MigrateConn is like Migrate, but it applies the migrations through a
+// connection the caller manages. See Migrator.RunConn for details.
+func MigrateConn(ctx context.Context, io IO, conn *sql.Conn, migrationsDir string, upto *MigrationID) error {
+	_, err := New(WithLogger(io)).RunConn(ctx, conn, migrationsDir, upto)
+	return err
+}
+
+// MigrateTx is like Migrate, but it applies the migrations in a transaction
+// the caller manages and commits. See Migrator.RunTx for details.
+func MigrateTx(ctx context.Context, io IO, tx *sql.Tx, migrationsDir string, upto *MigrationID) error {
+	_, err := New(WithLogger(io)).RunTx(ctx, tx, migrationsDir, upto)
+	return err
+}
@@ -0,0 +1,64 @@
+package drift
+
+// WithContinueOnError makes a run carry on after a migration fails, instead
+// of stopping. Only the later migrations that declare their dependencies
+// (with _drift_require_migration or depends-on directives) are still applied,
+// and only if none of those dependencies failed or were skipped. The others
+// are skipped: with SkipFailedDependency if they depend on a failed
+// migration, or with SkipUndeclaredDependencies if they don't say what they
+// depend on, since they might depend on the failed one. The run returns
+// ErrMigrationsFailed if any migration failed.
+//
+// It has no effect with TransactionAll, which applies all of the migrations or
+// none.
+func WithContinueOnError() Option {
+	return func(m *Migrator) {
+		m.continueOnError = true
+	}
+}
+
+// notStarted returns the migrations in the batch that a failure kept from
+// starting, which have no result yet.
+func notStarted(batch []migrationFile, res *Result) []migrationFile {
+	done := make(map[MigrationID]bool)
+	for _, a := range res.Applied {
+		done[a.ID] = true
+	}
+	for _, f := range res.Failed {
+		done[f.ID] = true
+	}
+	var rest []migrationFile
+	for _, f := range batch {
+		if !done[f.ID] {
+			rest = append(rest, f)
+		}
+	}
+	return rest
+}
+
+// skipAfterFailure marks the planned migrations that can't safely be applied
+// after a failure as skipped, and returns the rest: the ones that declare
+// their dependencies, none of which failed or were skipped.
+func skipAfterFailure(plan []migrationFile, res *Result) []migrationFile {
+	blocked := make(map[MigrationID]bool)
+	for _, f := range res.Failed {
+		blocked[f.ID] = true
+	}
+	for _, s := range res.Skipped {
+		blocked[s.ID] = true
+	}
+	var rest []migrationFile
+	for _, f := range plan {
+		switch {
+		case requiresAny(f, blocked):
+			res.skipped(f, SkipFailedDependency)
+			blocked[f.ID] = true
+		case len(dependencies(f)) == 0:
+			res.skipped(f, SkipUndeclaredDependencies)
+			blocked[f.ID] = true
+		default:
+			rest = append(rest, f)
+		}
+	}
+	return rest
+}
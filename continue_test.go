@@ -0,0 +1,42 @@
+package drift
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestSkipAfterFailure(t *testing.T) {
+	file := func(id MigrationID, content string) migrationFile {
+		return migrationFile{ID: id, Name: fmt.Sprintf("%d-m.sql", id), Content: content}
+	}
+	plan := []migrationFile{
+		file(2, "--drift:depends-on=1\n"),
+		file(3, "--drift:depends-on=2\n"),
+		file(4, "select 1;\n"),
+		file(5, "--drift:depends-on=4\n"),
+		file(6, "--drift:depends-on=0\n"),
+	}
+	res := &Result{Failed: []FailedMigration{{ID: 1}}}
+
+	var rest []MigrationID
+	for _, f := range skipAfterFailure(plan, res) {
+		rest = append(rest, f.ID)
+	}
+	if want := []MigrationID{6}; !reflect.DeepEqual(rest, want) {
+		t.Errorf("got rest %v, want %v", rest, want)
+	}
+	skipped := make(map[MigrationID]string)
+	for _, s := range res.Skipped {
+		skipped[s.ID] = s.Reason
+	}
+	want := map[MigrationID]string{
+		2: SkipFailedDependency,
+		3: SkipFailedDependency,
+		4: SkipUndeclaredDependencies,
+		5: SkipFailedDependency,
+	}
+	if !reflect.DeepEqual(skipped, want) {
+		t.Errorf("got skipped %v, want %v", skipped, want)
+	}
+}
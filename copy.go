@@ -0,0 +1,215 @@
+package drift
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+	pgxv5 "github.com/jackc/pgx/v5"
+)
+
+var (
+	// ErrCopyUnsupported means a migration has a copy directive, but the
+	// database connection can't run COPY FROM STDIN. Drift needs a pgx
+	// driver for that, either pgx v4's or pgx v5's (like driftpool uses).
+	ErrCopyUnsupported = errors.New("copy directive needs a pgx connection")
+	// ErrCopyInCallerTx means a migration with a copy directive was planned
+	// in a transaction that the caller manages (with RunTx), where Drift
+	// can't reach the connection.
+	ErrCopyInCallerTx = errors.New("copy directive can't run in the caller's transaction")
+)
+
+// A copySpec is what a copy directive loads: the data file, relative to the
+// migration file, the table to copy it into, and the file's format.
+type copySpec struct {
+	table  string
+	format string
+	file   string
+}
+
+// parseCopy parses a copy directive, either the short `copy=users.csv` form
+// (with the table named after the file) or the `copy table=users format=csv
+// file=users.csv` form.
+func parseCopy(value, args string) (copySpec, error) {
+	if value != "" {
+		if args != "" {
+			return copySpec{}, fmt.Errorf("%w: copy takes a file or key=value arguments, not both: %q", ErrInvalidDirective, value+" "+args)
+		}
+		if path.Ext(value) != ".csv" {
+			return copySpec{}, fmt.Errorf("%w: copy needs a .csv file named after its table, like users.csv: %q", ErrInvalidDirective, value)
+		}
+		return copySpec{
+			table:  strings.TrimSuffix(path.Base(value), path.Ext(value)),
+			format: "csv",
+			file:   value,
+		}, nil
+	}
+	c := copySpec{format: "csv"}
+	for _, arg := range strings.Fields(args) {
+		k, v, _ := strings.Cut(arg, "=")
+		switch k {
+		case "table":
+			c.table = v
+		case "format":
+			c.format = v
+		case "file":
+			c.file = v
+		default:
+			return c, fmt.Errorf("%w: copy doesn't take %q (want table, format, and file)", ErrInvalidDirective, k)
+		}
+	}
+	if c.table == "" || c.file == "" {
+		return c, fmt.Errorf("%w: copy needs a table and a file, like copy table=users file=users.csv: %q", ErrInvalidDirective, args)
+	}
+	if c.format != "csv" {
+		return c, fmt.Errorf("%w: copy only supports format=csv: %q", ErrInvalidDirective, c.format)
+	}
+	return c, nil
+}
+
+// copyPath returns the path in the migration's file system of a copy
+// directive's data file, which is relative to the migration file.
+func (f migrationFile) copyPath(name string) string {
+	return path.Join(path.Dir(f.Name), name)
+}
+
+// checkCopies returns an error if a copy directive's data file is missing.
+func checkCopies(f migrationFile) error {
+	for _, c := range f.directives.copies {
+		if _, err := fs.Stat(f.fsys, f.copyPath(c.file)); err != nil {
+			return fmt.Errorf("%s: copy %s: %w", f.Name, c.file, err)
+		}
+	}
+	return nil
+}
+
+// hasCopies reports whether any migration in the plan has a copy directive.
+func hasCopies(plan []migrationFile) bool {
+	for _, f := range plan {
+		if len(f.directives.copies) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// runCopies runs the migration's content with its copy directives. Each
+// directive's data is copied where the directive sits, after the statements
+// before it and before the ones after it. The raw connection is the one that q
+// runs on.
+func (m *Migrator) runCopies(ctx context.Context, q Queryable, raw *sql.Conn, f migrationFile) error {
+	q = m.shown(q)
+	content := f.Content
+	prev, k := 0, 0
+	for _, loc := range reDirective.FindAllStringSubmatchIndex(content, -1) {
+		if content[loc[2]:loc[3]] != "copy" {
+			continue
+		}
+		if err := runSegment(ctx, q, content, prev, loc[0]); err != nil {
+			return err
+		}
+		c := f.directives.copies[k]
+		k++
+		n, err := copyFrom(ctx, raw, f.fsys, f.copyPath(c.file), c)
+		if err != nil {
+			line, _ := lineColumn(content, loc[0])
+			return fmt.Errorf("copy %s (line %d): %w", c.file, line, err)
+		}
+		m.io.Debugf("Copied %d rows from %s into %s in %s", n, c.file, c.table, f.Name)
+		prev = loc[1]
+	}
+	return runSegment(ctx, q, content, prev, len(content))
+}
+
+// runSegment runs the statements in content[start:end]. Earlier lines are
+// kept as blank lines so that error locations still match the file.
+func runSegment(ctx context.Context, q Queryable, content string, start, end int) error {
+	if len(splitSQL(content[start:end])) == 0 {
+		return nil
+	}
+	text := strings.Repeat("\n", strings.Count(content[:start], "\n")) + content[start:end]
+	return statementError(text, run(ctx, q, text))
+}
+
+// copyFrom copies the CSV file at name into the spec's table, using its header
+// row as the column names.
+func copyFrom(ctx context.Context, raw *sql.Conn, fsys fs.FS, name string, c copySpec) (int64, error) {
+	header, err := csvHeader(fsys, name)
+	if err != nil {
+		return 0, err
+	}
+	cols := make([]string, 0, len(header))
+	for _, h := range header {
+		cols = append(cols, Postgres{}.Quote(strings.TrimSpace(h)))
+	}
+	query := fmt.Sprintf("copy %s (%s) from stdin with (format csv, header true)",
+		quoteTable(c.table), strings.Join(cols, ", "))
+
+	r, err := fsys.Open(name)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+
+	var n int64
+	err = raw.Raw(func(driverConn any) error {
+		copyIn, ok := copyFromStdin(driverConn)
+		if !ok {
+			return fmt.Errorf("%w, not %T", ErrCopyUnsupported, driverConn)
+		}
+		n, err = copyIn(ctx, r, query)
+		return err
+	})
+	return n, err
+}
+
+// csvHeader reads the header row of the CSV file.
+func csvHeader(fsys fs.FS, name string) ([]string, error) {
+	r, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	header, err := csv.NewReader(r).Read()
+	if err != nil {
+		return nil, fmt.Errorf("could not read the CSV header: %w", err)
+	}
+	return header, nil
+}
+
+// pgxConn is a pgx v4 stdlib driver connection. Matching the method instead of
+// the type keeps the stdlib package, which registers the driver, out of the
+// imports.
+type pgxConn interface {
+	Conn() *pgx.Conn
+}
+
+// pgxConnV5 is a pgx v5 stdlib driver connection.
+type pgxConnV5 interface {
+	Conn() *pgxv5.Conn
+}
+
+// copyFromStdin returns a function that runs COPY FROM STDIN on the pgx
+// connection behind a database/sql driver connection, if it has one.
+func copyFromStdin(driverConn any) (func(ctx context.Context, r io.Reader, query string) (int64, error), bool) {
+	switch c := driverConn.(type) {
+	case pgxConn:
+		return func(ctx context.Context, r io.Reader, query string) (int64, error) {
+			tag, err := c.Conn().PgConn().CopyFrom(ctx, r, query)
+			return tag.RowsAffected(), err
+		}, true
+	case pgxConnV5:
+		return func(ctx context.Context, r io.Reader, query string) (int64, error) {
+			tag, err := c.Conn().PgConn().CopyFrom(ctx, r, query)
+			return tag.RowsAffected(), err
+		}, true
+	}
+	return nil, false
+}
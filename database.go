@@ -0,0 +1,63 @@
+package drift
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4"
+)
+
+// CreateDatabase creates a new, empty database with the given name.
+//
+// The connection can be to any database on the server, since Postgres doesn't
+// allow creating a database from inside a transaction or from the database
+// itself.
+func CreateDatabase(ctx context.Context, db *sql.DB, name string) error {
+	_, err := db.ExecContext(ctx, "create database "+pgx.Identifier{name}.Sanitize())
+	return err
+}
+
+// CreateDatabaseFrom creates a new database as a copy of the template database.
+//
+// Postgres refuses to copy a database while anything else is connected to it.
+func CreateDatabaseFrom(ctx context.Context, db *sql.DB, name, template string) error {
+	query := fmt.Sprintf("create database %s template %s",
+		pgx.Identifier{name}.Sanitize(),
+		pgx.Identifier{template}.Sanitize(),
+	)
+	_, err := db.ExecContext(ctx, query)
+	return err
+}
+
+// DatabaseExists reports whether a database with the given name exists on the
+// server.
+func DatabaseExists(ctx context.Context, db *sql.DB, name string) (bool, error) {
+	query, args, err := pq.
+		Select("count(*) > 0").
+		From("pg_database").
+		Where(sq.Eq{"datname": name}).
+		ToSql()
+	if err != nil {
+		return false, err
+	}
+	var exists bool
+	return exists, db.QueryRowContext(ctx, query, args...).Scan(&exists)
+}
+
+// DropDatabase drops the database with the given name, if it exists.
+//
+// The connection must be to a different database on the same server.
+func DropDatabase(ctx context.Context, db *sql.DB, name string) error {
+	_, err := db.ExecContext(ctx, "drop database if exists "+pgx.Identifier{name}.Sanitize())
+	return err
+}
+
+// RecreateSchema drops the schema with everything in it, if it exists, and
+// creates it again empty.
+func RecreateSchema(ctx context.Context, db *sql.DB, name string) error {
+	schema := pgx.Identifier{name}.Sanitize()
+	_, err := db.ExecContext(ctx, fmt.Sprintf("drop schema if exists %s cascade; create schema %s", schema, schema))
+	return err
+}
@@ -0,0 +1,280 @@
+package drift
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	pgconnv5 "github.com/jackc/pgx/v5/pgconn"
+)
+
+// A Dialect adapts Drift's own queries to a specific database.
+type Dialect interface {
+	// Placeholders formats query parameters.
+	Placeholders() sq.PlaceholderFormat
+	// Quote quotes a (possibly qualified) identifier.
+	Quote(parts ...string) string
+	// IsUndefinedTable reports whether the error means the queried table
+	// doesn't exist.
+	IsUndefinedTable(err error) bool
+	// Lock blocks until it acquires the session-level lock identified by key.
+	Lock(ctx context.Context, conn *sql.Conn, key int64) error
+	// Unlock releases a lock acquired by Lock.
+	Unlock(ctx context.Context, conn *sql.Conn, key int64) error
+}
+
+// Postgres is the default dialect.
+type Postgres struct{}
+
+var _ Dialect = Postgres{}
+
+func (Postgres) Placeholders() sq.PlaceholderFormat {
+	return sq.Dollar
+}
+
+func (Postgres) Quote(parts ...string) string {
+	return pgx.Identifier(parts).Sanitize()
+}
+
+func (Postgres) IsUndefinedTable(err error) bool {
+	code, _, ok := pgError(err)
+	return ok && code == "42P01" // undefined_table
+}
+
+// pgError returns the SQLSTATE code and 1-based position of a Postgres error
+// from either pgx v4 or pgx v5 (see driftpool). The position is 0 if the server
+// didn't report one.
+func pgError(err error) (code string, position int32, ok bool) {
+	var v4 *pgconn.PgError
+	if errors.As(err, &v4) {
+		return v4.Code, v4.Position, true
+	}
+	var v5 *pgconnv5.PgError
+	if errors.As(err, &v5) {
+		return v5.Code, v5.Position, true
+	}
+	return "", 0, false
+}
+
+func (Postgres) Lock(ctx context.Context, conn *sql.Conn, key int64) error {
+	_, err := conn.ExecContext(ctx, "select pg_advisory_lock($1)", key)
+	return err
+}
+
+func (Postgres) Unlock(ctx context.Context, conn *sql.Conn, key int64) error {
+	_, err := conn.ExecContext(ctx, "select pg_advisory_unlock($1)", key)
+	return err
+}
+
+// A RetryDialect is a Dialect whose transactions can fail with errors that
+// only mean "try again", like serialization failures. Drift retries a
+// migration's transaction when it fails with one of them.
+type RetryDialect interface {
+	Dialect
+	// IsRetryable reports whether the transaction failed with an error that
+	// it might not fail with next time.
+	IsRetryable(err error) bool
+}
+
+// txRetries is how many times a migration's transaction is retried after a
+// retryable error, and txRetryDelay is how long to wait before the first retry.
+// The delay doubles each time.
+const (
+	txRetries    = 5
+	txRetryDelay = 50 * time.Millisecond
+)
+
+// retry calls fn, which applies the migration in a transaction, again when it
+// fails with an error that the dialect says is retryable.
+func (m *Migrator) retry(ctx context.Context, f migrationFile, fn func() error) error {
+	rd, ok := m.dialect.(RetryDialect)
+	if !ok {
+		return fn()
+	}
+	delay := txRetryDelay
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt > txRetries || !rd.IsRetryable(err) {
+			return err
+		}
+		warnf(m.io, "Retrying migration %d after a retryable error (attempt %d of %d): %s", f.ID, attempt, txRetries, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// An InitDialect is a Dialect with its own template for the init migration,
+// for databases that can't run the default one. The template is parsed with
+// ParseInitTemplate.
+type InitDialect interface {
+	Dialect
+	InitTemplate() string
+}
+
+// ErrLockUnsupported means WithLock was used with a dialect that has no
+// session-level locks.
+var ErrLockUnsupported = errors.New("the database doesn't support migration locks")
+
+// ErrTimeoutUnsupported means WithLockTimeout or WithStatementTimeout was used
+// with a dialect other than Postgres or CockroachDB.
+var ErrTimeoutUnsupported = errors.New("lock and statement timeouts are only supported for Postgres and CockroachDB")
+
+// Cockroach is the dialect for CockroachDB. It's like Postgres, but it
+// retries transactions that fail with serialization errors (which CockroachDB
+// returns much more often), and its init migration avoids features CockroachDB
+// doesn't have. It needs CockroachDB 23.1 or later, and it doesn't support
+// WithLock.
+type Cockroach struct {
+	Postgres
+}
+
+var (
+	_ RetryDialect = Cockroach{}
+	_ InitDialect  = Cockroach{}
+)
+
+func (Cockroach) IsRetryable(err error) bool {
+	code, _, ok := pgError(err)
+	return ok && code == "40001" // serialization_failure
+}
+
+func (Cockroach) InitTemplate() string {
+	return cockroachInitContent
+}
+
+func (Cockroach) Lock(context.Context, *sql.Conn, int64) error {
+	return ErrLockUnsupported
+}
+
+func (Cockroach) Unlock(context.Context, *sql.Conn, int64) error {
+	return ErrLockUnsupported
+}
+
+//go:embed templates/init_cockroach.sql
+var cockroachInitContent string
+
+// A BatchDialect is a Dialect whose migration files can hold several batches
+// that have to be sent to the database separately, like SQL Server's GO
+// separators. Each batch keeps its place in the file (earlier lines are
+// blank), so that error locations still match.
+type BatchDialect interface {
+	Dialect
+	SplitBatches(content string) []string
+}
+
+// A ProcedureDialect is a Dialect where Drift's functions are stored
+// procedures. Call returns the statement that calls the named procedure with
+// the number of arguments, using ? placeholders. ProcedureExistsQuery takes
+// the schema and procedure names as its parameters and returns a row if the
+// procedure exists.
+type ProcedureDialect interface {
+	Dialect
+	Call(name string, args int) string
+	ProcedureExistsQuery() string
+}
+
+// A VersionDialect is a Dialect with its own query for the server version,
+// which Ping reports. The query returns one row with the version as text.
+type VersionDialect interface {
+	Dialect
+	ServerVersionQuery() string
+}
+
+// A TableDialect is a Dialect that checks whether the migrations table exists
+// before reading it, rather than relying on IsUndefinedTable. The query takes
+// the schema and table names as its parameters and returns a row if the table
+// exists.
+type TableDialect interface {
+	Dialect
+	TableExistsQuery() string
+}
+
+// A ClaimDialect is a Dialect with a different default claim strategy than
+// ClaimFunction, for databases without functions. WithClaimStrategy still
+// overrides it.
+type ClaimDialect interface {
+	Dialect
+	ClaimStrategy() ClaimStrategy
+}
+
+// A NonTransactionalDialect is a Dialect for databases without transactions.
+// Drift applies every migration as if in TransactionNone mode, whatever
+// WithTransactionMode says.
+type NonTransactionalDialect interface {
+	Dialect
+	NonTransactional()
+}
+
+var (
+	dialectsMu sync.RWMutex
+	dialects   = map[string]Dialect{
+		"postgres":    Postgres{},
+		"postgresql":  Postgres{},
+		"pgx":         Postgres{},
+		"cockroachdb": Cockroach{},
+		"crdb":        Cockroach{},
+		"sqlserver":   SQLServer{},
+		"clickhouse":  ClickHouse{},
+	}
+)
+
+// RegisterDialect makes a dialect available by name, for databases that Drift
+// doesn't support itself. The drift command looks dialects up by the database
+// URL's scheme or the --driver flag, and opens the database with the
+// database/sql driver of the same name, so use that name (like "duckdb").
+//
+// Like sql.Register, it's meant to be called from an init function, and it
+// panics if the name is already registered or the dialect is nil.
+func RegisterDialect(name string, d Dialect) {
+	dialectsMu.Lock()
+	defer dialectsMu.Unlock()
+	if d == nil {
+		panic("drift: RegisterDialect dialect is nil")
+	}
+	if _, dup := dialects[name]; dup {
+		panic("drift: RegisterDialect called twice for dialect " + name)
+	}
+	dialects[name] = d
+}
+
+// LookupDialect returns the dialect registered with the name.
+func LookupDialect(name string) (Dialect, bool) {
+	dialectsMu.RLock()
+	defer dialectsMu.RUnlock()
+	d, ok := dialects[name]
+	return d, ok
+}
+
+// Dialects returns the sorted names of the registered dialects, including
+// Drift's own.
+func Dialects() []string {
+	dialectsMu.RLock()
+	defer dialectsMu.RUnlock()
+	names := make([]string, 0, len(dialects))
+	for name := range dialects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// speaksPostgres reports whether the dialect is for Postgres or a database
+// compatible enough to share its SQL.
+func speaksPostgres(d Dialect) bool {
+	switch d.(type) {
+	case Postgres, Cockroach:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,25 @@
+package drift
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgconn"
+	pgconnv5 "github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestPgErrorVersions(t *testing.T) {
+	errs := map[string]error{
+		"v4": &pgconn.PgError{Code: "42P01", Position: 8},
+		"v5": &pgconnv5.PgError{Code: "42P01", Position: 8},
+	}
+	for name, err := range errs {
+		err = fmt.Errorf("wrapped: %w", err)
+		if !(Postgres{}).IsUndefinedTable(err) {
+			t.Errorf("%s: IsUndefinedTable = false, want true", name)
+		}
+		if _, pos, ok := pgError(err); !ok || pos != 8 {
+			t.Errorf("%s: got position %d (ok %v), want 8", name, pos, ok)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package drift
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var ErrInvalidDirective = errors.New("invalid directive")
+
+// reDirective finds `--drift:name`, `--drift:name=value`, and `--drift:name
+// key=value ...` directives as one-line SQL comments, optionally followed by
+// another comment explaining them. Older documentation spelled them with two
+// colons (`--drift::name`), so that's accepted too.
+var reDirective = regexp.MustCompile(`(?m)^--drift::?([a-z-]+)(?:=(\S*))?((?:[ \t]+[a-z]+=\S*)*)[ \t]*(?:--[^\r\n]*)?\r?$`)
+
+// reDirectiveLine finds every line that looks like a directive, so that ones
+// reDirective can't parse are reported instead of silently ignored.
+var reDirectiveLine = regexp.MustCompile(`(?m)^--drift:[^\r\n]*`)
+
+// reNoTxText finds a no-transaction directive line followed by free text
+// that isn't a comment. Older versions of Drift only looked at the start of
+// the line, so this still counts as no-transaction.
+var reNoTxText = regexp.MustCompile(`^--drift::?no-transaction[ \t]+\S`)
+
+// knownDirectives are the directive names Drift understands.
+var knownDirectives = map[string]bool{
+	"no-transaction":    true,
+	"timeout":           true,
+	"concurrent-safe":   true,
+	"strip-transaction": true,
+	"copy":              true,
+	"batched":           true,
+	"depends-on":        true,
+}
+
+// directives are the settings a migration file declares for itself.
+type directives struct {
+	// noTransaction runs the migration outside of a transaction. The
+	// migration has to claim itself.
+	noTransaction bool
+	// timeout limits how long the migration can run.
+	timeout time.Duration
+	// concurrentSafe allows applying the migration at the same time as other
+	// concurrent-safe migrations (see WithParallel).
+	concurrentSafe bool
+	// stripTransaction removes the file's own transaction control statements
+	// (like BEGIN and COMMIT) so Drift's transaction wraps it instead.
+	stripTransaction bool
+	// copies are the data files to COPY into tables, in the order of their
+	// directives.
+	copies []copySpec
+	// batched repeats the migration's one statement, each time in its own
+	// transaction, until it affects no rows, pausing for batchPause between
+	// runs.
+	batched    bool
+	batchPause time.Duration
+	// dependsOn are the migrations that must be applied first.
+	dependsOn []MigrationID
+}
+
+func parseDirectives(content string) (directives, error) {
+	var d directives
+	for _, line := range reDirectiveLine.FindAllString(content, -1) {
+		if !reDirective.MatchString(line) && !reNoTxText.MatchString(line) {
+			return d, fmt.Errorf("%w: can't parse %q (want --drift:name, --drift:name=value, or --drift:name key=value ...)", ErrInvalidDirective, line)
+		}
+	}
+	for _, m := range directiveMatches(content) {
+		name, value, args := m[1], m[2], strings.TrimSpace(m[3])
+		if args != "" && name != "copy" && knownDirectives[name] {
+			return d, fmt.Errorf("%w: %s doesn't take key=value arguments: %q", ErrInvalidDirective, name, args)
+		}
+		switch name {
+		case "no-transaction":
+			if value != "" {
+				return d, fmt.Errorf("%w: no-transaction doesn't take a value: %q", ErrInvalidDirective, value)
+			}
+			d.noTransaction = true
+		case "timeout":
+			t, err := time.ParseDuration(value)
+			if err != nil || t <= 0 {
+				return d, fmt.Errorf("%w: timeout must be a positive duration like 30s: %q", ErrInvalidDirective, value)
+			}
+			d.timeout = t
+		case "concurrent-safe":
+			if value != "" {
+				return d, fmt.Errorf("%w: concurrent-safe doesn't take a value: %q", ErrInvalidDirective, value)
+			}
+			d.concurrentSafe = true
+		case "strip-transaction":
+			if value != "" {
+				return d, fmt.Errorf("%w: strip-transaction doesn't take a value: %q", ErrInvalidDirective, value)
+			}
+			d.stripTransaction = true
+		case "copy":
+			c, err := parseCopy(value, args)
+			if err != nil {
+				return d, err
+			}
+			d.copies = append(d.copies, c)
+		case "batched":
+			if value != "" {
+				t, err := time.ParseDuration(value)
+				if err != nil || t < 0 {
+					return d, fmt.Errorf("%w: batched takes a pause between batches like 500ms: %q", ErrInvalidDirective, value)
+				}
+				d.batchPause = t
+			}
+			d.batched = true
+		case "depends-on":
+			var id MigrationID
+			if err := id.Set(value); err != nil {
+				return d, fmt.Errorf("%w: depends-on needs a migration ID: %q", ErrInvalidDirective, value)
+			}
+			d.dependsOn = append(d.dependsOn, id)
+		}
+	}
+	return d, nil
+}
+
+// skipTx reports whether the content has a no-transaction directive.
+func skipTx(content string) bool {
+	for _, d := range listDirectives(content) {
+		if d.Name == "no-transaction" {
+			return true
+		}
+	}
+	return false
+}
+
+// directiveMatches returns the reDirective submatches of each directive line
+// in the content, counting a reNoTxText line as a plain no-transaction.
+func directiveMatches(content string) [][]string {
+	var ms [][]string
+	for _, line := range reDirectiveLine.FindAllString(content, -1) {
+		if sm := reDirective.FindStringSubmatch(line); sm != nil {
+			ms = append(ms, sm)
+		} else if reNoTxText.MatchString(line) {
+			ms = append(ms, []string{line, "no-transaction", "", ""})
+		}
+	}
+	return ms
+}
+
+// listDirectives returns the directives in the content, in order.
+func listDirectives(content string) []Directive {
+	var ds []Directive
+	for _, sm := range directiveMatches(content) {
+		ds = append(ds, Directive{Name: sm[1], Value: sm[2], Args: strings.TrimSpace(sm[3])})
+	}
+	return ds
+}
+
+// directiveText returns the text to find the file's directives in: its
+// content, or only its directive lines if it's streamed.
+func (f migrationFile) directiveText() string {
+	if f.summary != nil {
+		return f.summary.directives
+	}
+	return f.Content
+}
+
+// logDirectives logs each directive in the file, so users can check that
+// Drift recognized it.
+func (m *Migrator) logDirectives(f migrationFile) {
+	for _, line := range reDirectiveLine.FindAllString(f.directiveText(), -1) {
+		if !reDirective.MatchString(line) && reNoTxText.MatchString(line) {
+			warnf(m.io, "Treating %q in %s as --drift:no-transaction; start the text after it with -- to mark it as a comment", line, f.Name)
+		}
+	}
+	for _, d := range listDirectives(f.directiveText()) {
+		if knownDirectives[d.Name] {
+			m.io.Debugf("Found directive in %s: %s", f.Name, d)
+		} else {
+			warnf(m.io, "Ignoring unknown directive in %s: %s", f.Name, d)
+		}
+	}
+}
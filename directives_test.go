@@ -0,0 +1,163 @@
+package drift
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseDirectives(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    directives
+	}{
+		{
+			name:    "none",
+			content: "create table users ();\n",
+		},
+		{
+			name:    "flag",
+			content: "--drift:no-transaction\ncreate index concurrently on users (email);\n",
+			want:    directives{noTransaction: true},
+		},
+		{
+			name:    "old spelling",
+			content: "--drift::no-transaction\ncreate index concurrently on users (email);\n",
+			want:    directives{noTransaction: true},
+		},
+		{
+			name:    "value",
+			content: "--drift:timeout=30s\nupdate users set email = lower(email);\n",
+			want:    directives{timeout: 30 * time.Second},
+		},
+		{
+			name:    "old spelling with value",
+			content: "--drift::timeout=1m\n",
+			want:    directives{timeout: time.Minute},
+		},
+		{
+			name:    "CRLF",
+			content: "--drift:no-transaction\r\n--drift:timeout=30s\r\nselect 1;\r\n",
+			want:    directives{noTransaction: true, timeout: 30 * time.Second},
+		},
+		{
+			name:    "trailing spaces",
+			content: "--drift:concurrent-safe \t\n",
+			want:    directives{concurrentSafe: true},
+		},
+		{
+			name:    "trailing comment",
+			content: "--drift:no-transaction -- needed for CONCURRENTLY\n",
+			want:    directives{noTransaction: true},
+		},
+		{
+			name:    "no-transaction with trailing text",
+			content: "--drift:no-transaction because of CONCURRENTLY\r\ncreate index concurrently on users (email);\n",
+			want:    directives{noTransaction: true},
+		},
+		{
+			name:    "trailing comment after value",
+			content: "--drift:timeout=30s -- the table is big\r\n",
+			want:    directives{timeout: 30 * time.Second},
+		},
+		{
+			name:    "repeated values",
+			content: "--drift:depends-on=1\n--drift:depends-on=2\n--drift:copy=users.csv\n",
+			want:    directives{dependsOn: []MigrationID{1, 2}, copies: []copySpec{{table: "users", format: "csv", file: "users.csv"}}},
+		},
+		{
+			name:    "copy with arguments",
+			content: "--drift:copy table=auth.users format=csv file=data/users.csv -- seed\n",
+			want:    directives{copies: []copySpec{{table: "auth.users", format: "csv", file: "data/users.csv"}}},
+		},
+		{
+			name:    "copy with arguments and default format",
+			content: "--drift:copy file=users.csv\ttable=users\r\n",
+			want:    directives{copies: []copySpec{{table: "users", format: "csv", file: "users.csv"}}},
+		},
+		{
+			name:    "batched without pause",
+			content: "--drift:batched\n",
+			want:    directives{batched: true},
+		},
+		{
+			name:    "batched with pause",
+			content: "--drift:batched=500ms\n",
+			want:    directives{batched: true, batchPause: 500 * time.Millisecond},
+		},
+		{
+			name:    "indented lines aren't directives",
+			content: "  --drift:no-transaction\n",
+		},
+		{
+			name:    "unknown directives are ignored",
+			content: "--drift:frobnicate\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseDirectives(tt.content)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDirectivesInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"flag with value", "--drift:no-transaction=yes\n"},
+		{"bad timeout", "--drift:timeout=soon\n"},
+		{"negative timeout", "--drift:timeout=-1s\n"},
+		{"copy without csv", "--drift:copy=users.txt\n"},
+		{"copy without table", "--drift:copy file=users.csv\n"},
+		{"copy without file", "--drift:copy table=users\n"},
+		{"copy with unknown argument", "--drift:copy table=users file=users.csv header=false\n"},
+		{"copy with unknown format", "--drift:copy table=users format=binary file=users.bin\n"},
+		{"copy with file and arguments", "--drift:copy=users.csv table=users\n"},
+		{"arguments to a flag", "--drift:no-transaction reason=concurrently\n"},
+		{"bad depends-on", "--drift:depends-on=abc\n"},
+		{"unparsable line", "--drift:no transaction\n"},
+		{"value with spaces", "--drift:timeout=30 s\n"},
+		{"uppercase name", "--drift:No-Transaction\n"},
+		{"trailing text after another flag", "--drift:concurrent-safe because it is\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseDirectives(tt.content)
+			if !errors.Is(err, ErrInvalidDirective) {
+				t.Errorf("got error %v, want %v", err, ErrInvalidDirective)
+			}
+		})
+	}
+}
+
+func TestListDirectives(t *testing.T) {
+	content := "--drift:no-transaction -- for CONCURRENTLY\r\n--drift::timeout=5m\n--drift:copy table=users file=users.csv\nselect 1;\n"
+	want := []Directive{
+		{Name: "no-transaction"},
+		{Name: "timeout", Value: "5m"},
+		{Name: "copy", Args: "table=users file=users.csv"},
+	}
+	got := listDirectives(content)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if s, want := got[2].String(), "--drift:copy table=users file=users.csv"; s != want {
+		t.Errorf("got %q, want %q", s, want)
+	}
+	if !skipTx(content) {
+		t.Error("skipTx didn't find the no-transaction directive")
+	}
+	if !skipTx("--drift:no-transaction for CONCURRENTLY\nselect 1;\n") {
+		t.Error("skipTx didn't find the no-transaction directive with trailing text")
+	}
+}
@@ -7,7 +7,9 @@ import (
 	_ "embed"
 	"errors"
 	"fmt"
-	"os"
+	"io/fs"
+	"log/slog"
+	"path"
 	"path/filepath"
 	"regexp"
 	"sort"
@@ -18,13 +20,31 @@ import (
 
 	sq "github.com/Masterminds/squirrel"
 	"github.com/blockloop/scan"
-	"github.com/jackc/pgconn"
-	"github.com/olekukonko/tablewriter"
 )
 
 var (
-	ErrNegativeID  = errors.New("migration ID must not be negative")
-	ErrDuplicateID = errors.New("duplicate migration ID")
+	ErrNegativeID   = errors.New("migration ID must not be negative")
+	ErrDuplicateID  = errors.New("duplicate migration ID")
+	ErrNoUpTemplate = errors.New("template set has no migration template")
+
+	// ErrBudgetExhausted means the run stopped early because of WithStopAfter.
+	// The Result lists the remaining migrations as skipped.
+	ErrBudgetExhausted = errors.New("time budget exhausted")
+	// ErrStopped means the run stopped early because of WithStopSignal. The
+	// Result lists the remaining migrations as skipped.
+	ErrStopped = errors.New("stopped by request")
+	// ErrMigrationsFailed means some migrations failed in a run with
+	// WithContinueOnError. The Result lists them as failed, and the ones that
+	// depend on them as skipped.
+	ErrMigrationsFailed = errors.New("some migrations failed")
+
+	// ErrAlreadySetUp means Setup found an init migration identical to the
+	// one it would have written, so there was nothing to do.
+	ErrAlreadySetUp = errors.New("init migration already exists")
+	// ErrInitMismatch means Setup found an init migration that differs from
+	// the one it would have written, because it was edited or written with
+	// other settings.
+	ErrInitMismatch = errors.New("existing init migration doesn't match")
 )
 
 type IO interface {
@@ -32,6 +52,14 @@ type IO interface {
 	Debugf(format string, args ...interface{}) (n int, err error)
 }
 
+// A LevelIO is an IO that can also log warnings and errors. Drift uses Warnf
+// and Errorf if the IO has them, and Infof otherwise.
+type LevelIO interface {
+	IO
+	Warnf(format string, args ...interface{}) (n int, err error)
+	Errorf(format string, args ...interface{}) (n int, err error)
+}
+
 // A MigrationID is a nonnegative integer that will be used to sort migrations.
 //
 // This will often be a Unix timestamp in seconds, so it's represented as as an
@@ -85,48 +113,348 @@ func mustID(s string) MigrationID {
 //
 // If upto is non-nil, this will also skip any migrations with IDs greater than
 // that value.
-func Migrate(ctx context.Context, io IO, db *sql.DB, migrationsDir string, upto *MigrationID) error {
+func (m *Migrator) Migrate(ctx context.Context, db *sql.DB, migrationsDir string, upto *MigrationID) error {
+	_, err := m.Run(ctx, db, migrationsDir, upto)
+	return err
+}
+
+// MigrateFS is like Migrate, but it reads the migration files from the root of
+// fsys instead of a directory on disk.
+func (m *Migrator) MigrateFS(ctx context.Context, db *sql.DB, fsys fs.FS, upto *MigrationID) error {
+	_, err := m.RunFS(ctx, db, fsys, upto)
+	return err
+}
+
+// Run is like Migrate, but it also returns a Result describing what it did.
+// If a migration fails, the Result still lists the ones applied before it.
+//
+// To merge the migrations in several directories into one plan, see WithDirs.
+func (m *Migrator) Run(ctx context.Context, db *sql.DB, migrationsDir string, upto *MigrationID) (*Result, error) {
+	return m.run(ctx, db, func() ([]migrationFile, error) {
+		return m.listFiles(migrationsDir)
+	}, upto)
+}
+
+// RunFS is like Run, but it reads the migration files from the root of fsys
+// instead of a directory on disk.
+func (m *Migrator) RunFS(ctx context.Context, db *sql.DB, fsys fs.FS, upto *MigrationID) (*Result, error) {
+	return m.run(ctx, db, func() ([]migrationFile, error) {
+		return listFS(m.io, fsys, ".")
+	}, upto)
+}
+
+func (m *Migrator) run(ctx context.Context, db conn, load func() ([]migrationFile, error), upto *MigrationID) (*Result, error) {
+	return m.traced(ctx, func(ctx context.Context) (*Result, error) {
+		return m.runPlan(ctx, db, load, upto)
+	})
+}
+
+// traced runs the migrations in a span.
+func (m *Migrator) traced(ctx context.Context, migrate func(context.Context) (*Result, error)) (*Result, error) {
+	ctx, span := startSpan(ctx, m.tracer, "drift.migrate")
+	defer span.End()
+	res, err := migrate(ctx)
+	if err != nil {
+		span.RecordError(err)
+	}
+	if res != nil {
+		span.SetAttributes(slog.Int("drift.applied", len(res.Applied)), slog.Int64("drift.version", int64(res.Version)))
+	}
+	return res, err
+}
+
+func (m *Migrator) runPlan(ctx context.Context, db conn, load func() ([]migrationFile, error), upto *MigrationID) (*Result, error) {
+	if m.waitTimeout > 0 {
+		if err := m.waitForDB(ctx, db); err != nil {
+			return nil, err
+		}
+	}
+	if err := m.checkTimeouts(); err != nil {
+		return nil, err
+	}
+	if m.lock && m.pooled && !m.dryRun {
+		return nil, fmt.Errorf("could not acquire migration lock: %w with transaction pooling", ErrLockUnsupported)
+	}
+	if m.lock && !m.dryRun {
+		unlock, err := m.acquireLock(ctx, db)
+		if err != nil {
+			return nil, fmt.Errorf("could not acquire migration lock: %w", err)
+		}
+		defer unlock()
+	}
+
+	res, plan, err := m.preparePlan(ctx, db, load, upto)
+	if err != nil {
+		return res, err
+	}
+	if m.dryRun {
+		return res, m.dryRunPlan(ctx, db, plan, res)
+	}
+	defer m.trackFailures(ctx, db, res)
+
+	if m.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.timeout)
+		defer cancel()
+	}
+
+	prog := m.newProgress(plan, res)
+	if m.txMode == TransactionAll && len(plan) > 0 {
+		return res, m.applyAll(ctx, db, plan, prog, res)
+	}
+
+	// The init migration creates the table, so keep checking until the
+	// history columns show up.
+	var cols historyColumns
+	begin := m.clock()
+	for i := 0; i < len(plan); {
+		if m.stopAfter > 0 && m.clock().Sub(begin) >= m.stopAfter {
+			for _, rest := range plan[i:] {
+				res.skipped(rest, SkipStopAfter)
+			}
+			err := fmt.Errorf("%w: stopped after %s", ErrBudgetExhausted, m.stopAfter)
+			prog.end(err)
+			return res, err
+		}
+		if m.stopRequested() {
+			for _, rest := range plan[i:] {
+				res.skipped(rest, SkipStopped)
+			}
+			prog.end(ErrStopped)
+			return res, ErrStopped
+		}
+
+		cols, err = m.refreshColumns(ctx, db, cols)
+		if err != nil {
+			err = fmt.Errorf("could not inspect the migrations table: %w", err)
+			prog.end(err)
+			return res, err
+		}
+		batch := m.nextBatch(plan[i:])
+		// A failed migration only ends the progress report once no more
+		// migrations will run.
+		if err := m.applyBatch(ctx, db, batch, cols, prog, res); err != nil {
+			if errors.Is(err, ErrStopped) {
+				for _, rest := range notStarted(plan[i:], res) {
+					res.skipped(rest, SkipStopped)
+				}
+				prog.end(err)
+				return res, err
+			}
+			if !m.continueOnError || ctx.Err() != nil {
+				prog.end(err)
+				return res, err
+			}
+			rest := append(notStarted(batch, res), plan[i+len(batch):]...)
+			plan = append(plan[:i], skipAfterFailure(rest, res)...)
+			continue
+		}
+		i += len(batch)
+	}
+	if len(res.Failed) > 0 {
+		err := fmt.Errorf("%w: %d of %d", ErrMigrationsFailed, len(res.Failed), len(res.Applied)+len(res.Failed))
+		prog.end(err)
+		return res, err
+	}
+	prog.end(nil)
+	m.logFinished(res)
+	return res, nil
+}
+
+// preparePlan decides which migrations to apply and checks every one of them
+// before any are applied.
+func (m *Migrator) preparePlan(ctx context.Context, db rowQueryable, load func() ([]migrationFile, error), upto *MigrationID) (*Result, []migrationFile, error) {
 	// 1. select * from schema_migrations
-	records, err := applied(db)
+	records, err := m.applied(ctx, db)
 	if err != nil {
-		return fmt.Errorf("could not get applied migrations: %w", err)
+		return nil, nil, fmt.Errorf("could not get applied migrations: %w", err)
 	}
+	res := newResult(records)
 
 	// 2. ls migrations_dir
-	files, err := available(io, migrationsDir)
+	files, err := load()
 	if err != nil {
-		return fmt.Errorf("could not get available migrations: %w", err)
+		return res, nil, fmt.Errorf("could not get available migrations: %w", err)
 	}
 
 	// 3. diff IDs
 	needed := diff(records, files)
-	for _, f := range needed {
+	res.Pending = len(needed)
+	plan := needed
+	if m.only != nil {
+		plan, err = selectOnly(m.io, needed, records, m.only, m.readPlanned)
+		if err != nil {
+			return res, nil, err
+		}
+	}
+	plan = m.planUpto(res, needed, plan, upto)
+	if m.steps > 0 && len(plan) > m.steps {
+		for _, f := range plan[m.steps:] {
+			m.io.Debugf("Skipping migration because of steps=%d: %s", m.steps, f.Name)
+			res.skipped(f, SkipSteps)
+		}
+		plan = plan[:m.steps]
+	}
+
+	if err := m.checkClaim(); err != nil {
+		return res, nil, err
+	}
+
+	// Only the planned files are read, and only now.
+	for i := range plan {
+		if err := m.readPlanned(&plan[i]); err != nil {
+			return res, nil, fmt.Errorf("could not read migration files: %w", err)
+		}
+	}
+
+	// Check every planned file before applying any of them.
+	for i, f := range plan {
+		if m.seal != nil {
+			if err := m.seal.check(f); err != nil {
+				return res, nil, err
+			}
+		}
+		d, err := parseDirectives(f.directiveText())
+		if err != nil {
+			return res, nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+		plan[i].directives = d
+		m.logDirectives(f)
+		if err := checkCopies(plan[i]); err != nil {
+			return res, nil, err
+		}
+		if err := checkBatched(plan[i]); err != nil {
+			return res, nil, err
+		}
+		if err := m.checkTransactionControl(plan[i]); err != nil {
+			return res, nil, err
+		}
+		if err := m.checkVars(f); err != nil {
+			return res, nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+	}
+	if err := checkDependencies(plan, records); err != nil {
+		return res, nil, err
+	}
+	if err := m.checkTransactionMode(plan); err != nil {
+		return res, nil, err
+	}
+	if err := m.lintPlan(plan); err != nil {
+		return res, nil, err
+	}
+	if !m.dryRun {
+		if err := m.confirmPlan(plan); err != nil {
+			return res, nil, err
+		}
+	}
+	for i, f := range plan {
+		if f.stream {
+			continue
+		}
+		// These were all checked above.
+		plan[i].Content, _ = m.substituteVars(f.Content)
+		if f.directives.stripTransaction {
+			m.io.Debugf("Stripping transaction control statements: %s", f.Name)
+			plan[i].Content = stripTransactionControl(plan[i].Content)
+		}
+	}
+	return res, plan, nil
+}
+
+// applyOne applies a single migration and reports its progress.
+func (m *Migrator) applyOne(ctx context.Context, db conn, f migrationFile, cols historyColumns, prog *progress) (time.Duration, error) {
+	m.logMigration(slog.LevelInfo, f, 0, "Applying migration: %s", f.Path)
+	ctx, span := startSpan(ctx, m.tracer, "drift.migration",
+		slog.Int64("migration.id", int64(f.ID)),
+		slog.String("migration.slug", f.Slug),
+	)
+	defer span.End()
+	prog.start(f.ID)
+	start := m.clock()
+	if err := m.apply(ctx, db, f, cols); err != nil {
+		span.RecordError(err)
+		prog.fail(f.ID, err)
+		return 0, migrationError(f, err)
+	}
+	d := m.clock().Sub(start)
+	prog.finish(f.ID)
+	m.logMigration(slog.LevelDebug, f, d, "Applied migration in %s: %s", d, f.Name)
+	return d, nil
+}
+
+// planUpto removes migrations after upto from the plan, and records every
+// needed migration that didn't make it into the plan as skipped.
+func (m *Migrator) planUpto(res *Result, needed, plan []migrationFile, upto *MigrationID) []migrationFile {
+	planned := make(map[MigrationID]bool)
+	var kept []migrationFile
+	for _, f := range plan {
 		if upto != nil && f.ID > *upto {
-			io.Debugf("Skipping migration because of upto=%d: %s", upto, f.Name)
 			continue
 		}
+		planned[f.ID] = true
+		kept = append(kept, f)
+	}
 
-		io.Infof("Applying migration: %s", f.Name)
-		if err := apply(ctx, db, f); err != nil {
-			return err
+	for _, f := range needed {
+		if planned[f.ID] {
+			continue
+		}
+		if upto != nil && f.ID > *upto {
+			m.io.Debugf("Skipping migration because of upto=%d: %s", *upto, f.Name)
+			res.skipped(f, SkipUpto)
+		} else {
+			res.skipped(f, SkipOnly)
 		}
 	}
-	io.Infof("All migrations applied!")
-	return nil
+	return kept
 }
 
 type migrationRecord struct {
 	ID    MigrationID `db:"id"`
 	Slug  string      `db:"slug"`
 	RunAt time.Time   `db:"run_at"`
+
+	// These columns were added later, so older tables don't have them.
+	DurationMS sql.NullInt64  `db:"duration_ms"`
+	AppliedBy  sql.NullString `db:"applied_by"`
+	Content    sql.NullString `db:"content"`
+	Faked      sql.NullBool   `db:"faked"`
 }
 
-var qApplied, _ = pq.Select("*").From("schema_migrations").OrderBy("id asc").MustSql()
+func (m *Migrator) applied(ctx context.Context, db rowQueryable) ([]migrationRecord, error) {
+	return m.records(ctx, db, false)
+}
 
-func applied(db *sql.DB) ([]migrationRecord, error) {
-	rows, err := db.Query(qApplied)
-	var pgerr *pgconn.PgError
-	if errors.As(err, &pgerr) && pgerr.Code == "42P01" { // undefined_table
+// records reads the migrations table. It only reads the content column if
+// content is true, since that can hold a lot of text.
+func (m *Migrator) records(ctx context.Context, db rowQueryable, content bool) ([]migrationRecord, error) {
+	ctx, cancel := m.queryContext(ctx)
+	defer cancel()
+	if _, ok := m.dialect.(TableDialect); ok {
+		exists, err := m.tableExists(ctx, db)
+		if err != nil || !exists {
+			return nil, err
+		}
+	}
+	cols, err := m.historyColumns(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	selected := []string{"id", "slug", "run_at"}
+	for _, c := range []string{"duration_ms", "applied_by", "faked"} {
+		if cols[c] {
+			selected = append(selected, c)
+		}
+	}
+	if content && cols["content"] {
+		selected = append(selected, "content")
+	}
+	query, args, err := m.sb().Select(selected...).From(m.tableName()).OrderBy("id asc").ToSql()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.QueryContext(ctx, query, args...)
+	if m.dialect.IsUndefinedTable(err) {
 		// The expected table doesn't exist. This is almost certainly because
 		// we haven't run the first migration that will create this table.
 		return nil, nil
@@ -135,7 +463,50 @@ func applied(db *sql.DB) ([]migrationRecord, error) {
 		return nil, err
 	}
 	var ms []migrationRecord
-	return ms, scan.RowsStrict(&ms, rows)
+	if err := scan.RowsStrict(&ms, rows); err != nil {
+		return nil, err
+	}
+	return dedupRecords(ms), nil
+}
+
+// tableExists reports whether the migrations table exists, using the
+// TableDialect's query or else Postgres's to_regclass.
+func (m *Migrator) tableExists(ctx context.Context, db rowQueryable) (bool, error) {
+	if td, ok := m.dialect.(TableDialect); ok {
+		return exists(ctx, db, td.TableExistsQuery(), m.schema, m.table)
+	}
+	query, args, err := m.sb().Select("1").Where("to_regclass(?) is not null", m.tableName()).ToSql()
+	if err != nil {
+		return false, err
+	}
+	return exists(ctx, db, query, args...)
+}
+
+// exists reports whether the query returns any rows.
+func exists(ctx context.Context, db rowQueryable, query string, args ...interface{}) (bool, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return false, err
+	}
+	found := rows.Next()
+	if err := rows.Close(); err != nil {
+		return false, err
+	}
+	return found, rows.Err()
+}
+
+// dedupRecords drops records with the same ID as the one before them. Tables
+// without a primary key (like ClickHouse's ReplacingMergeTree) can have
+// duplicates, which are sorted next to each other by ID.
+func dedupRecords(ms []migrationRecord) []migrationRecord {
+	out := ms[:0]
+	for _, r := range ms {
+		if len(out) > 0 && r.ID == out[len(out)-1].ID {
+			continue
+		}
+		out = append(out, r)
+	}
+	return out
 }
 
 // reFilename matches the migration filename convention.
@@ -147,6 +518,20 @@ func applied(db *sql.DB) ([]migrationRecord, error) {
 //
 var reFilename = regexp.MustCompile(`^(?P<id>\d+)-(?P<slug>.*)\.sql$`)
 
+// reDirname matches the migration directory convention of the directory
+// layout, like 1234567890-create_users/up.sql.
+var reDirname = regexp.MustCompile(`^(?P<id>\d+)-(?P<slug>.*)$`)
+
+// looksLikeMigration reports whether a file that doesn't match reFilename was
+// probably meant to, like a .sql file with no ID. Names starting with an
+// underscore or a dot (like _template.sql) are left alone on purpose.
+func looksLikeMigration(name string) bool {
+	return strings.HasSuffix(name, ".sql") && !strings.HasPrefix(name, "_") && !strings.HasPrefix(name, ".")
+}
+
+// upFile is the migration file within a migration directory.
+const upFile = "up.sql"
+
 type migrationFile struct {
 	Path    string
 	Name    string
@@ -155,13 +540,133 @@ type migrationFile struct {
 	ID   MigrationID
 	Slug string
 
+	directives directives
+
 	idRaw string
+	// inDir is true for the directory layout, where Name is like
+	// 1234-create_users/up.sql.
+	inDir bool
+
+	// fsys holds the file at Name. Content is only read from it once loaded
+	// is set (see load).
+	fsys   fs.FS
+	size   int64
+	loaded bool
+	// stream is set for planned files larger than the stream size. Their
+	// Content is never loaded: they're checked in one pass, which fills in
+	// summary, and read again a chunk at a time to apply them.
+	stream  bool
+	summary *streamSummary
+}
+
+// load reads the file's content, if it hasn't been read yet.
+func (f *migrationFile) load() error {
+	if f.loaded {
+		return nil
+	}
+	content, err := fs.ReadFile(f.fsys, f.Name)
+	if err != nil {
+		return err
+	}
+	f.Content = string(content)
+	f.loaded = true
+	return nil
+}
+
+// loadFiles reads the content of every file that hasn't been read yet.
+func loadFiles(files []migrationFile) error {
+	for i := range files {
+		if err := files[i].load(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// entryPath returns the path of the file, or of its directory in the directory
+// layout.
+func (f migrationFile) entryPath() string {
+	if f.inDir {
+		return filepath.Dir(f.Path)
+	}
+	return f.Path
+}
+
+// renamed returns the name the file (or its directory) would have with a
+// different ID width, ID, or slug.
+func (f migrationFile) renamed(width int, id MigrationID, slug string) string {
+	name := Filename(width, id, slug)
+	if f.inDir {
+		return strings.TrimSuffix(name, ".sql")
+	}
+	return name
+}
+
+// available reads the migration files from dir, and the directories added
+// with WithDirs, in the Migrator's file system.
+func (m *Migrator) available(dir string) ([]migrationFile, error) {
+	return available(m.io, m.files, m.allDirs(dir))
+}
+
+// available reads the migration files from the directories. Their files are
+// merged, and the file paths show which directory each came from.
+func available(io IO, files FileSystem, dirs []string) ([]migrationFile, error) {
+	ms, err := listFiles(io, files, dirs)
+	if err != nil {
+		return nil, err
+	}
+	return ms, loadFiles(ms)
+}
+
+// listFiles lists the migration files in dir, and the directories added with
+// WithDirs, in the Migrator's file system.
+func (m *Migrator) listFiles(dir string) ([]migrationFile, error) {
+	return listFiles(m.io, m.files, m.allDirs(dir))
+}
+
+// allDirs returns dir followed by the directories added with WithDirs.
+func (m *Migrator) allDirs(dir string) []string {
+	return append([]string{dir}, m.dirs...)
+}
+
+// listFiles is like available, but it doesn't read the files' content.
+func listFiles(io IO, files FileSystem, dirs []string) ([]migrationFile, error) {
+	if len(dirs) == 1 {
+		return listFS(io, files.DirFS(dirs[0]), dirs[0])
+	}
+
+	var all []migrationFile
+	seen := make(map[MigrationID]migrationFile)
+	for _, d := range dirs {
+		ms, err := listFS(io, files.DirFS(d), d)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", d, err)
+		}
+		for _, m := range ms {
+			if other, ok := seen[m.ID]; ok {
+				return nil, fmt.Errorf("%w: %s, %s", ErrDuplicateID, other.Path, m.Path)
+			}
+			seen[m.ID] = m
+		}
+		all = append(all, ms...)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].ID < all[j].ID })
+	return all, nil
 }
 
-// TODO: Use an afero.Fs to make this easier to test.
+// availableFS reads the migration files from the root of fsys. The dir is only
+// used to build the file paths shown to users.
+func availableFS(io IO, fsys fs.FS, dir string) ([]migrationFile, error) {
+	ms, err := listFS(io, fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	return ms, loadFiles(ms)
+}
 
-func available(io IO, dir string) ([]migrationFile, error) {
-	files, err := os.ReadDir(dir)
+// listFS is like availableFS, but it doesn't read the files' content.
+func listFS(io IO, fsys fs.FS, dir string) ([]migrationFile, error) {
+	files, err := fs.ReadDir(fsys, ".")
 	if err != nil {
 		return nil, fmt.Errorf("could not list migration files: %w", err)
 	}
@@ -169,28 +674,48 @@ func available(io IO, dir string) ([]migrationFile, error) {
 	var ms []migrationFile
 	for _, f := range files {
 		name := f.Name()
-		m := reFilename.FindStringSubmatch(name)
+		re := reFilename
+		if f.IsDir() {
+			re = reDirname
+		}
+		m := re.FindStringSubmatch(name)
 		if m == nil {
-			io.Debugf("Ignoring non-migration file: %s", name)
+			if looksLikeMigration(name) {
+				warnf(io, "Ignoring SQL file that isn't named like a migration: %s", name)
+			} else {
+				io.Debugf("Ignoring non-migration file: %s", name)
+			}
 			continue
 		}
-		path := filepath.Join(dir, name)
-		content, err := os.ReadFile(path)
-		if err != nil {
-			return nil, err
-		}
-		ms = append(ms, migrationFile{
-			Path:    path,
-			Name:    name,
-			Content: string(content),
+		mf := migrationFile{
+			Path: filepath.Join(dir, name),
+			Name: name,
 
 			// The subexpression cannot match negative integers, so this can
 			// only fail if the ID doesn't fit into an int64.
-			ID:   mustID(m[reFilename.SubexpIndex("id")]),
-			Slug: m[reFilename.SubexpIndex("slug")],
+			ID:   mustID(m[re.SubexpIndex("id")]),
+			Slug: m[re.SubexpIndex("slug")],
 
-			idRaw: m[reFilename.SubexpIndex("id")],
-		})
+			idRaw: m[re.SubexpIndex("id")],
+			fsys:  fsys,
+		}
+		if f.IsDir() {
+			// Use the forward slash for the name, like fs.FS does.
+			mf.Name = path.Join(name, upFile)
+			mf.Path = filepath.Join(dir, name, upFile)
+			mf.inDir = true
+		}
+		info, err := fs.Stat(fsys, mf.Name)
+		if f.IsDir() && errors.Is(err, fs.ErrNotExist) {
+			// Probably a companion directory of a single-file migration.
+			io.Debugf("Ignoring directory without %s: %s", upFile, name)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		mf.size = info.Size()
+		ms = append(ms, mf)
 	}
 
 	seen := make(map[MigrationID]migrationFile)
@@ -221,30 +746,165 @@ func diff(applied []migrationRecord, files []migrationFile) []migrationFile {
 	return needed
 }
 
-func apply(ctx context.Context, db *sql.DB, f migrationFile) error {
-	if skipTx(f.Content) {
-		return run(ctx, db, f.Content)
+func (m *Migrator) apply(ctx context.Context, db conn, f migrationFile, cols historyColumns) error {
+	start := m.clock()
+	noTx := f.directives.noTransaction
+	// Each batch commits on its own, so batched migrations are claimed after
+	// they finish, like in TransactionNone mode.
+	if noTx || f.directives.batched || m.txMode == TransactionNone {
+		timeout := f.directives.timeout
+		if timeout > 0 {
+			// Cancelling the context makes the driver send a cancel request
+			// to the server, and the statement timeout covers anything it
+			// misses.
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		if m.pooled || !speaksPostgres(m.dialect) {
+			// The session setting would outlive the migration on a pooled
+			// connection, and other databases don't have it. The context's
+			// deadline still applies.
+			timeout = 0
+		}
+		if err := m.chaos.at(FailAfterClaim, f); err != nil {
+			return err
+		}
+		runCtx, injected := m.chaos.midStatement(ctx, f)
+		runContent := m.runContent
+		if noTx && m.stop != nil {
+			runContent = m.runUntilStopped
+		}
+		var err error
+		switch {
+		case f.directives.batched:
+			err = onConn(runCtx, db, timeout, func(c *sql.Conn) error {
+				return m.runBatched(runCtx, c, f)
+			})
+		case len(f.directives.copies) > 0:
+			err = onConn(runCtx, db, timeout, func(c *sql.Conn) error {
+				return m.runCopies(runCtx, c, c, f)
+			})
+		case f.stream:
+			err = m.runStream(runCtx, db, f, timeout)
+		case timeout > 0:
+			err = onConn(runCtx, db, timeout, func(c *sql.Conn) error {
+				return runContent(runCtx, c, f.Content)
+			})
+		case m.reportsBlocking(db):
+			// Run on a known connection, to know which session to check on.
+			err = onConn(runCtx, db, 0, func(c *sql.Conn) error {
+				defer m.watchBlockers(runCtx, db, c, f)()
+				return runContent(runCtx, c, f.Content)
+			})
+		default:
+			err = runContent(runCtx, db, f.Content)
+		}
+		if err := injected(err); err != nil {
+			return err
+		}
+		if err := m.chaos.at(FailBeforeCommit, f); err != nil {
+			return err
+		}
+		// A no-transaction migration claims itself, so the record should
+		// exist already. Otherwise, claim it now that it has run.
+		if !noTx {
+			if err := m.claim(ctx, db, f.ID, f.Slug); err != nil {
+				return err
+			}
+		}
+		return m.recordHistory(ctx, db, f, m.clock().Sub(start), cols)
 	}
+	return m.retry(ctx, f, func() error {
+		return m.applyInTx(ctx, db, f, cols, start)
+	})
+}
 
+// applyInTx applies the migration in a transaction of its own.
+func (m *Migrator) applyInTx(ctx context.Context, db conn, f migrationFile, cols historyColumns, start time.Time) error {
+	pool := db
+	// COPY needs the connection under the transaction.
+	var raw *sql.Conn
+	if len(f.directives.copies) > 0 {
+		c, release, err := dedicatedConn(ctx, db)
+		if err != nil {
+			return err
+		}
+		defer release()
+		raw, db = c, c
+	}
 	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
-	if err := claim(ctx, tx, f.ID, f.Slug); err != nil {
-		return err
-	}
-	if err := run(ctx, tx, f.Content); err != nil {
+	// This is a no-op after a successful commit.
+	defer tx.Rollback() //nolint:errcheck
+	defer m.watchBlockers(ctx, pool, tx, f)()
+
+	if err := m.applyTx(ctx, tx, raw, f, cols, start); err != nil {
+		var kept *keptError
+		if errors.As(err, &kept) {
+			if kerr := m.keepFailed(ctx, tx, f, kept); kerr != nil {
+				errorf(m.io, "Could not keep the statements that ran before the failure: %s", kerr)
+			}
+		}
 		return err
 	}
 	return tx.Commit()
 }
 
-// reNoTxComment finds the `--drift::no-transaction` directive as a one-line
-// SQL comment.
-var reNoTxComment = regexp.MustCompile(`(?m)^--drift:no-transaction`)
-
-func skipTx(content string) bool {
-	return reNoTxComment.MatchString(content)
+// applyTx claims, runs, and records the migration in the transaction. The raw
+// connection is the one under the transaction, for copy directives, if Drift
+// opened it.
+func (m *Migrator) applyTx(ctx context.Context, tx *sql.Tx, raw *sql.Conn, f migrationFile, cols historyColumns, start time.Time) error {
+	timeout := f.directives.timeout
+	if timeout > 0 {
+		// Cancelling the context makes the driver send a cancel request to
+		// the server, and the statement timeout covers anything it misses.
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	if err := m.setLocalTimeouts(ctx, tx, timeout); err != nil {
+		return err
+	}
+	if err := m.claim(ctx, tx, f.ID, f.Slug); err != nil {
+		return err
+	}
+	if err := m.chaos.at(FailAfterClaim, f); err != nil {
+		return err
+	}
+	runCtx, injected := m.chaos.midStatement(ctx, f)
+	var err error
+	switch {
+	case len(f.directives.copies) > 0:
+		err = m.runCopies(runCtx, tx, raw, f)
+	case f.stream:
+		err = m.streamStatements(f, func(text string) error {
+			return run(runCtx, m.shown(tx), text)
+		})
+	case m.debugKeep && m.txMode != TransactionAll:
+		err = m.runStatements(runCtx, tx, f.Content)
+	default:
+		err = m.runContent(runCtx, tx, f.Content)
+	}
+	if err := injected(err); err != nil {
+		return err
+	}
+	if err := m.chaos.at(FailBeforeCommit, f); err != nil {
+		return err
+	}
+	if err := m.recordHistory(ctx, tx, f, m.clock().Sub(start), cols); err != nil {
+		return err
+	}
+	if timeout > 0 && speaksPostgres(m.dialect) {
+		// Later migrations in the same transaction shouldn't inherit the
+		// limit.
+		if _, err := tx.ExecContext(ctx, "set local statement_timeout to default"); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 type Queryable interface {
@@ -253,75 +913,290 @@ type Queryable interface {
 
 var pq = sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
 
-func claim(ctx context.Context, tx Queryable, id MigrationID, slug string) error {
-	query, args, err := pq.Select().
-		Column("_drift_claim_migration("+sq.Placeholders(2)+")", id, slug).
-		ToSql()
+func run(ctx context.Context, tx Queryable, content string) error {
+	ctx, span := startSpan(ctx, tracerFrom(ctx), "drift.statement")
+	defer span.End()
+	res, err := tx.ExecContext(ctx, content)
 	if err != nil {
+		span.RecordError(err)
 		return err
 	}
-	_, err = tx.ExecContext(ctx, query, args...)
-	return err
+	if n, err := res.RowsAffected(); err == nil {
+		span.SetAttributes(slog.Int64("db.rows_affected", n))
+	}
+	return nil
 }
 
-func run(ctx context.Context, tx Queryable, content string) error {
-	_, err := tx.ExecContext(ctx, content)
+// runContent runs a migration's content, one batch at a time if the dialect
+// splits it into batches.
+func (m *Migrator) runContent(ctx context.Context, q Queryable, content string) error {
+	bd, ok := m.dialect.(BatchDialect)
+	if !ok {
+		if m.showSQL || m.tracer != nil {
+			return m.runEach(ctx, q, content)
+		}
+		return statementError(content, run(ctx, q, content))
+	}
+	// Statements in a batch can depend on each other, so show whole batches.
+	q = m.shown(q)
+	for _, batch := range bd.SplitBatches(content) {
+		if len(splitSQL(batch)) == 0 {
+			continue
+		}
+		if err := run(ctx, q, batch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runUntilStopped is like runContent, but it checks for a stop signal (see
+// WithStopSignal) between statements. A migration outside a transaction can't
+// be rolled back, so stopping after the current statement is as graceful as
+// stopping it gets. The stopped migration is left partly applied.
+func (m *Migrator) runUntilStopped(ctx context.Context, q Queryable, content string) error {
+	if _, ok := m.dialect.(BatchDialect); ok {
+		return m.runContent(ctx, q, content)
+	}
+	q = m.shown(q)
+	sts := splitSQL(content)
+	for i, st := range sts {
+		if i > 0 && m.stopRequested() {
+			return fmt.Errorf("%w after statement %d of %d", ErrStopped, i, len(sts))
+		}
+		if err := run(ctx, q, st.text); err != nil {
+			return locateError(content, sts, i, st.start, err)
+		}
+	}
+	return nil
+}
+
+// onConn calls fn with a dedicated connection, with a session statement
+// timeout if timeout is positive. The dedicated connection keeps the setting
+// from leaking into other uses of the pool.
+func onConn(ctx context.Context, db conn, timeout time.Duration, fn func(*sql.Conn) error) error {
+	conn, release, err := dedicatedConn(ctx, db)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if timeout <= 0 {
+		return fn(conn)
+	}
+	if _, err := conn.ExecContext(ctx, statementTimeout("set", timeout)); err != nil {
+		return err
+	}
+	err = fn(conn)
+	// Reset even after a failure, since the connection goes back to the pool.
+	cleanup, cancel := cleanupContext(ctx)
+	defer cancel()
+	if _, rerr := conn.ExecContext(cleanup, "reset statement_timeout"); rerr != nil && err == nil {
+		err = rerr
+	}
 	return err
 }
 
+// checkTimeouts rejects configured lock and statement timeouts if the dialect
+// has no way to set them.
+func (m *Migrator) checkTimeouts() error {
+	if (m.lockTimeout > 0 || m.statementTimeout > 0) && !speaksPostgres(m.dialect) {
+		return ErrTimeoutUnsupported
+	}
+	return nil
+}
+
+// setLocalTimeouts sets the lock and statement timeouts for the rest of the
+// transaction. The migration's own timeout, if it has one, takes the place of
+// the configured statement timeout. Other databases than Postgres have no such
+// settings, so only the context's deadline limits a migration's timeout there.
+func (m *Migrator) setLocalTimeouts(ctx context.Context, tx Queryable, timeout time.Duration) error {
+	if !speaksPostgres(m.dialect) {
+		return nil
+	}
+	if m.lockTimeout > 0 {
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("set local lock_timeout = %d", m.lockTimeout.Milliseconds())); err != nil {
+			return err
+		}
+	}
+	if timeout <= 0 {
+		timeout = m.statementTimeout
+	}
+	if timeout > 0 {
+		if _, err := tx.ExecContext(ctx, statementTimeout("set local", timeout)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func statementTimeout(set string, d time.Duration) string {
+	return fmt.Sprintf("%s statement_timeout = %d", set, d.Milliseconds())
+}
+
 // Setup creates the "init" migration that will prepare the database for
 // migrations. This will create the migrations directory if needed.
-func Setup(migrationsDir string) (string, error) {
-	if err := os.MkdirAll(migrationsDir, 0o755); err != nil {
+//
+// If the init migration already exists, Setup leaves it alone and returns its
+// path with ErrAlreadySetUp if it's what Setup would have written, or
+// ErrInitMismatch if it isn't.
+func (m *Migrator) Setup(migrationsDir string) (string, error) {
+	if err := m.files.MkdirAll(migrationsDir, 0o755); err != nil {
 		return "", fmt.Errorf("could not create migrations directory: %w", err)
 	}
+	tmpl, err := m.initTmpl()
+	if err != nil {
+		return "", err
+	}
+	var content bytes.Buffer
+	if err := tmpl.Execute(&content, m.initData()); err != nil {
+		return "", fmt.Errorf("could not render init migration: %w", err)
+	}
 	name := fmt.Sprintf("%d-%s.sql", 0, "init")
 	path := filepath.Join(migrationsDir, name)
-	if err := safeWriteFile(path, []byte(initContent), 0o644); err != nil {
+	err = m.files.CreateFile(path, content.Bytes(), 0o644)
+	if errors.Is(err, fs.ErrExist) {
+		existing, rerr := m.files.ReadFile(path)
+		if rerr != nil {
+			return path, fmt.Errorf("could not read existing init migration: %w", rerr)
+		}
+		if !bytes.Equal(existing, content.Bytes()) {
+			return path, fmt.Errorf("%w: %s", ErrInitMismatch, path)
+		}
+		return path, fmt.Errorf("%w: %s", ErrAlreadySetUp, path)
+	}
+	if err != nil {
 		return "", fmt.Errorf("could not create migration file: %w", err)
 	}
 	return path, nil
 }
 
-// NewFile creates a new migration file with a placeholder comment in it.
-func NewFile(io IO, migrationsDir string, id MigrationID, slug string, tmpl *template.Template) (string, error) {
+// NewFile creates a new migration file with a placeholder comment in it. The
+// file goes in migrationsDir, even with WithDirs, but its ID must be unique
+// across all of the directories.
+func (m *Migrator) NewFile(migrationsDir string, id MigrationID, slug string, tmpl *template.Template) (string, error) {
 	if tmpl == nil {
 		tmpl = defaultTemplate
 	}
+	paths, err := m.NewScaffold(migrationsDir, id, slug, map[string]*template.Template{
+		UpTemplate: tmpl,
+	})
+	if len(paths) == 0 {
+		return "", err
+	}
+	return paths[0], err
+}
+
+// UpTemplate is the name of the template for the migration file itself in a
+// NewScaffold template set.
+const UpTemplate = "up.sql"
 
+// NewScaffold creates a new migration from a set of templates keyed by file
+// name. The UpTemplate becomes the migration file, and each of the others
+// (like "down.sql" or "notes.md") becomes a file of that name in a companion
+// directory named after the migration:
+//
+//	1234-create_users.sql
+//	1234-create_users/down.sql
+//	1234-create_users/notes.md
+//
+// With LayoutDirectory, the migration file is up.sql in that directory
+// instead.
+//
+// It returns the paths of the files it created, starting with the migration
+// file.
+func (m *Migrator) NewScaffold(migrationsDir string, id MigrationID, slug string, tmpls map[string]*template.Template) ([]string, error) {
+	tmpl, ok := tmpls[UpTemplate]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNoUpTemplate, UpTemplate)
+	}
+
+	now := m.clock()
 	if id == -1 {
 		var err error
-		ts := time.Now().Unix()
+		ts := now.Unix()
 		id, err = NewMigrationID(ts)
 		if err != nil {
-			return "", fmt.Errorf("invalid migration ID: %w", err)
+			return nil, fmt.Errorf("invalid migration ID: %w", err)
 		}
 	}
 
-	files, err := available(io, migrationsDir)
+	files, err := m.available(migrationsDir)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	for _, f := range files {
 		if f.ID == id {
-			return "", fmt.Errorf("%w: %d: %s", ErrDuplicateID, id, f.Name)
+			return nil, fmt.Errorf("%w: %d: %s", ErrDuplicateID, id, f.Name)
 		}
 	}
 
-	slug = slugify(slug)
-	name := filename(idWidth(files), id, slug)
-	path := filepath.Join(migrationsDir, name)
+	slug = Slugify(slug)
+	name := Filename(idWidth(files), id, slug)
+	dir := migrationsDir
+	path := filepath.Join(dir, name)
 	data := TemplateData{
-		ID:   id,
-		Slug: slug,
+		ID:          id,
+		Slug:        slug,
+		Timestamp:   now,
+		Author:      m.author,
+		Directory:   dir,
+		Environment: m.environment,
+		Body:        m.body,
+	}
+
+	var names []string
+	for name := range tmpls {
+		if name != UpTemplate {
+			names = append(names, name)
+		}
 	}
+	sort.Strings(names)
 
+	companion := strings.TrimSuffix(path, ".sql")
+	if m.layout == LayoutDirectory || len(names) > 0 {
+		//#nosec G301 // Normal permissions for non-sensitive files.
+		if err := m.files.MkdirAll(companion, 0o755); err != nil {
+			return nil, err
+		}
+	}
+	if m.layout == LayoutDirectory {
+		path = filepath.Join(companion, upFile)
+	}
+	var up bytes.Buffer
+	if err := tmpl.Execute(&up, data); err != nil {
+		return nil, err
+	}
+	// Custom templates might not use the body, but it shouldn't be lost.
+	if m.body != "" && !strings.Contains(up.String(), m.body) {
+		warnf(m.io, "The template doesn't use {{.Body}}, so the SQL is appended to the migration.")
+		if up.Len() > 0 && !bytes.HasSuffix(up.Bytes(), []byte("\n")) {
+			up.WriteString("\n")
+		}
+		up.WriteString(m.body + "\n")
+	}
 	//#nosec G306 // Normal permissions for non-sensitive files.
-	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
-	if err != nil {
-		return "", err
+	if err := m.files.WriteFile(path, up.Bytes(), 0o644); err != nil {
+		return nil, err
+	}
+	paths := []string{path}
+	for _, name := range names {
+		p := filepath.Join(companion, name)
+		if err := executeFile(m.files, p, tmpls[name], data); err != nil {
+			return paths, err
+		}
+		paths = append(paths, p)
+	}
+	return paths, nil
+}
+
+func executeFile(files FileSystem, path string, tmpl *template.Template, data TemplateData) error {
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, data); err != nil {
+		return err
 	}
-	return path, tmpl.Execute(f, data)
+	//#nosec G306 // Normal permissions for non-sensitive files.
+	return files.WriteFile(path, b.Bytes(), 0o644)
 }
 
 //go:embed templates/new.sql
@@ -333,98 +1208,141 @@ func DefaultTemplate() string {
 	return strings.TrimSpace(newContent)
 }
 
+// TemplateData is the data available to migration templates.
 type TemplateData struct {
 	ID   MigrationID
 	Slug string
+	// Timestamp is when the file was created.
+	Timestamp time.Time
+	// Author is set with WithAuthor.
+	Author string
+	// Directory is the directory the file is written to.
+	Directory string
+	// Environment is set with WithEnvironment.
+	Environment string
+	// Body is the SQL set with WithBody, if any.
+	Body string
 }
 
 // reSeparator matches runs of common characters types as separators in
 // interactive command-line usage.
 var reSeparator = regexp.MustCompile(`[\-\s._/]+`)
 
-func slugify(s string) string {
+// Slugify converts s into a migration slug by replacing runs of separator
+// characters (whitespace, dashes, dots, underscores, and slashes) with a single
+// underscore. NewFile applies this to every slug.
+func Slugify(s string) string {
 	return reSeparator.ReplaceAllString(s, "_")
 }
 
-// safeWriteFile is like os.WriteFile but it fails if the file already exists.
-func safeWriteFile(path string, data []byte, perm os.FileMode) error {
-	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL|os.O_TRUNC, perm)
+// TableSchema returns the create table statement for the migrations table, as
+// the init migration written by Setup creates it.
+func (m *Migrator) TableSchema() (string, error) {
+	tmpl, err := m.initTmpl()
 	if err != nil {
-		return err
+		return "", err
 	}
-	// Prefer the write error over the close error.
-	_, werr := f.Write(data)
-	cerr := f.Close()
-	if werr != nil {
-		return werr
+	var content bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&content, "table", m.initData()); err != nil {
+		return "", fmt.Errorf("could not render table schema: %w", err)
 	}
-	return cerr
+	return content.String(), nil
 }
 
 //go:embed templates/init.sql
 var initContent string
 
-func Renumber(io IO, dir string, write bool) error {
-	files, err := available(io, dir)
-	if err != nil {
-		return err
-	}
-	width := idWidth(files)
+//go:embed templates/table.sql
+var tableContent string
 
-	type rename struct {
-		from string
-		to   string
+var initTemplate = template.Must(ParseInitTemplate(initContent))
+
+// DefaultInitTemplate returns the template that Setup uses for the init
+// migration.
+func DefaultInitTemplate() string {
+	return initContent
+}
+
+// ParseInitTemplate parses a custom template for the init migration, to pass
+// to WithInitTemplate. Like the default one, it can use {{template "table" .}}
+// for the migrations table's create statement, and these fields:
+//
+//   - .Schema: the schema, as configured with WithSchema
+//   - .Table: the quoted, schema-qualified migrations table
+//   - .ClaimFunc, .UnclaimFunc, .RequireFunc: the quoted, schema-qualified
+//     names of Drift's functions
+//
+// Drift still needs the table's id and slug columns and, with the default
+// claim strategy, the claim function; other columns are up to you.
+func ParseInitTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("init").Parse(text)
+	if err != nil {
+		return nil, err
 	}
-	var renames []rename
-	for _, f := range files {
-		id := f.idRaw
-		if len(id) != width {
-			renames = append(renames, rename{
-				from: f.Name,
-				to:   filename(width, f.ID, f.Slug),
-			})
+	if tmpl.Lookup("table") == nil {
+		if _, err := tmpl.New("table").Parse(tableContent); err != nil {
+			return nil, err
 		}
 	}
+	return tmpl, nil
+}
 
-	if len(renames) == 0 {
-		io.Infof("Nothing to do.")
-		return nil
+// WithInitTemplate sets the template that Setup uses for the init migration.
+// Create it with ParseInitTemplate.
+func WithInitTemplate(tmpl *template.Template) Option {
+	return func(m *Migrator) {
+		m.initTemplate = tmpl
 	}
+}
 
-	io.Infof("Renames:")
-	{
-		var b bytes.Buffer
-		t := tablewriter.NewWriter(&b)
-		t.SetAutoFormatHeaders(false)
-		t.SetHeader([]string{"Old", "->", "New"})
-		for _, r := range renames {
-			t.Append([]string{r.from, "->", r.to})
+// initTmpl returns the template for the init migration: the one set with
+// WithInitTemplate, or else the dialect's, or else the default.
+func (m *Migrator) initTmpl() (*template.Template, error) {
+	if m.initTemplate != nil {
+		return m.initTemplate, nil
+	}
+	if d, ok := m.dialect.(InitDialect); ok {
+		tmpl, err := ParseInitTemplate(d.InitTemplate())
+		if err != nil {
+			return nil, fmt.Errorf("could not parse the dialect's init template: %w", err)
 		}
-		t.Render()
-		io.Infof(b.String())
+		return tmpl, nil
 	}
+	return initTemplate, nil
+}
 
-	if !write {
-		io.Infof("Skipping renames because write is off")
-		return nil
-	}
+type initData struct {
+	Schema      string
+	Table       string
+	ClaimFunc   string
+	UnclaimFunc string
+	RequireFunc string
+}
 
-	io.Infof("Renaming files")
-	for _, r := range renames {
-		old := filepath.Join(dir, r.from)
-		new := filepath.Join(dir, r.to)
-		if err := os.Rename(old, new); err != nil {
-			return err
-		}
+func (m *Migrator) initData() initData {
+	return initData{
+		Schema:      m.schema,
+		Table:       m.tableName(),
+		ClaimFunc:   m.funcName("_drift_claim_migration"),
+		UnclaimFunc: m.funcName("_drift_unclaim_migration"),
+		RequireFunc: m.funcName("_drift_require_migration"),
 	}
-	io.Infof("Done!")
-	return nil
 }
 
 func idWidth(files []migrationFile) int {
+	ids := make([]MigrationID, len(files))
+	for i, f := range files {
+		ids[i] = f.ID
+	}
+	return IDWidth(ids...)
+}
+
+// IDWidth returns the number of digits in the longest ID. Drift zero-pads new
+// and renumbered files to this width so they sort correctly.
+func IDWidth(ids ...MigrationID) int {
 	width := 0
-	for _, f := range files {
-		w := f.ID.Width()
+	for _, id := range ids {
+		w := id.Width()
 		if w > width {
 			width = w
 		}
@@ -432,6 +1350,12 @@ func idWidth(files []migrationFile) int {
 	return width
 }
 
-func filename(idWidth int, id MigrationID, slug string) string {
+// Filename returns the name of the migration file for id and slug, with the ID
+// zero-padded to idWidth digits. For example, Filename(3, 7, "add_users") is
+// "007-add_users.sql".
+//
+// The slug is used as-is, so pass it through Slugify first if it came from
+// user input.
+func Filename(idWidth int, id MigrationID, slug string) string {
 	return fmt.Sprintf("%0*d-%s.sql", idWidth, id, slug)
 }
@@ -0,0 +1,45 @@
+// Package driftpool applies Drift migrations through a pgx v5 pool, so
+// applications that use pgx directly don't have to open a second pool just for
+// migrations.
+//
+// It's a separate package so that importing drift doesn't register pgx v5's
+// database/sql driver.
+package driftpool
+
+import (
+	"context"
+	"io/fs"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+
+	"github.com/metagram-net/drift"
+)
+
+// Run is like Migrator.Run, but it applies the migrations through a pgx v5
+// pool. The migrator still talks database/sql: the pool is wrapped with pgx's
+// stdlib.OpenDBFromPool, which borrows a connection from the pool for each use
+// and returns it afterward, so Drift never keeps idle connections of its own.
+// Copy directives use pgx's COPY FROM on the borrowed connection. The pool
+// stays open afterward.
+func Run(ctx context.Context, m *drift.Migrator, pool *pgxpool.Pool, migrationsDir string, upto *drift.MigrationID) (*drift.Result, error) {
+	db := stdlib.OpenDBFromPool(pool)
+	// This doesn't close the pool.
+	defer db.Close()
+	return m.Run(ctx, db, migrationsDir, upto)
+}
+
+// RunFS is like Run, but it reads the migration files from the root of fsys
+// instead of a directory on disk.
+func RunFS(ctx context.Context, m *drift.Migrator, pool *pgxpool.Pool, fsys fs.FS, upto *drift.MigrationID) (*drift.Result, error) {
+	db := stdlib.OpenDBFromPool(pool)
+	defer db.Close()
+	return m.RunFS(ctx, db, fsys, upto)
+}
+
+// Migrate is like drift.Migrate, but it applies the migrations through a pgx v5
+// pool. See Run for details.
+func Migrate(ctx context.Context, io drift.IO, pool *pgxpool.Pool, migrationsDir string, upto *drift.MigrationID) error {
+	_, err := Run(ctx, drift.New(drift.WithLogger(io)), pool, migrationsDir, upto)
+	return err
+}
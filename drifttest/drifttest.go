@@ -0,0 +1,241 @@
+// Package drifttest provides helpers for testing code against a database with
+// Drift migrations applied.
+//
+// The helpers connect to the server in the DRIFT_TEST_DATABASE_URL environment
+// variable (falling back to DRIFT_DATABASE_URL). Tests that use them are
+// skipped if neither is set.
+package drifttest
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"os"
+	"testing"
+
+	"github.com/metagram-net/drift"
+	"github.com/metagram-net/drift/internal/dburl"
+)
+
+// URLEnv is the environment variable for the server connection string.
+const URLEnv = "DRIFT_TEST_DATABASE_URL"
+
+type config struct {
+	url      string
+	template bool
+}
+
+// An Option configures MigrateTemp.
+type Option func(*config)
+
+// WithURL sets the server connection string instead of reading it from the
+// environment.
+func WithURL(url string) Option {
+	return func(c *config) {
+		c.url = url
+	}
+}
+
+// WithTemplate speeds up repeated calls by applying the migrations once to a
+// template database and then copying it for each test.
+//
+// The template database is named after a hash of the migration files, so it
+// is reused across test runs until the migrations change. Old templates are
+// never dropped automatically.
+func WithTemplate() Option {
+	return func(c *config) {
+		c.template = true
+	}
+}
+
+// MigrateTemp creates a uniquely named database, applies every migration in
+// fsys to it, and returns a connection pool for it. The database is dropped
+// when the test finishes.
+func MigrateTemp(t testing.TB, fsys fs.FS, opts ...Option) *sql.DB {
+	t.Helper()
+
+	cfg := config{url: serverURL()}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.url == "" {
+		t.Skipf("%s is not set", URLEnv)
+	}
+
+	ctx := context.Background()
+	admin, err := dburl.Open(cfg.url)
+	if err != nil {
+		t.Fatalf("open database connection: %s", err)
+	}
+	t.Cleanup(func() { admin.Close() })
+
+	name := "drift_test_" + randomSuffix(t)
+	if cfg.template {
+		tmpl, err := ensureTemplate(ctx, t, admin, cfg.url, fsys)
+		if err != nil {
+			t.Fatalf("prepare template database: %s", err)
+		}
+		if err := drift.CreateDatabaseFrom(ctx, admin, name, tmpl); err != nil {
+			t.Fatalf("create database from template: %s", err)
+		}
+	} else if err := drift.CreateDatabase(ctx, admin, name); err != nil {
+		t.Fatalf("create database: %s", err)
+	}
+
+	db, err := dburl.OpenDatabase(cfg.url, name)
+	if err != nil {
+		t.Fatalf("open database connection: %s", err)
+	}
+	// Cleanups run last-in-first-out, so this runs before the admin
+	// connection is closed.
+	t.Cleanup(func() {
+		db.Close()
+		if err := drift.DropDatabase(ctx, admin, name); err != nil {
+			t.Errorf("drop database %s: %s", name, err)
+		}
+	})
+
+	if !cfg.template {
+		if err := drift.MigrateFS(ctx, testIO{t}, db, fsys, nil); err != nil {
+			t.Fatalf("run migrations: %s", err)
+		}
+	}
+	return db
+}
+
+// LoadFixtures truncates the fixture tables in fsys and the other tables, and
+// loads the fixture files into them (see drift.LoadFixtures). Call it at the
+// start of each test that needs known rows.
+func LoadFixtures(t testing.TB, db *sql.DB, fsys fs.FS, tables ...string) {
+	t.Helper()
+	loaded, err := drift.LoadFixtures(context.Background(), db, fsys, tables...)
+	if err != nil {
+		t.Fatalf("load fixtures: %s", err)
+	}
+	for _, f := range loaded {
+		t.Logf("Loaded %d rows into %s from %s", f.Rows, f.Table, f.Path)
+	}
+}
+
+func serverURL() string {
+	if url := os.Getenv(URLEnv); url != "" {
+		return url
+	}
+	return os.Getenv("DRIFT_DATABASE_URL")
+}
+
+// ensureTemplate creates and migrates the template database for fsys if it
+// doesn't exist yet. It holds an advisory lock while doing so, because test
+// binaries for different packages often run in parallel.
+func ensureTemplate(ctx context.Context, t testing.TB, admin *sql.DB, url string, fsys fs.FS) (string, error) {
+	sum, err := hashFS(fsys)
+	if err != nil {
+		return "", err
+	}
+	name := "drift_template_" + sum[:16]
+
+	conn, err := admin.Conn(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	key := lockKey(name)
+	if _, err := conn.ExecContext(ctx, "select pg_advisory_lock($1)", key); err != nil {
+		return "", err
+	}
+	//nolint:errcheck // Closing the connection releases the lock anyway.
+	defer conn.ExecContext(ctx, "select pg_advisory_unlock($1)", key)
+
+	exists, err := drift.DatabaseExists(ctx, admin, name)
+	if err != nil || exists {
+		return name, err
+	}
+
+	if err := drift.CreateDatabase(ctx, admin, name); err != nil {
+		return "", err
+	}
+	if err := migrateTemplate(ctx, t, url, name, fsys); err != nil {
+		// Don't leave a half-migrated template for the next run to find.
+		if derr := drift.DropDatabase(ctx, admin, name); derr != nil {
+			t.Logf("drop template database %s: %s", name, derr)
+		}
+		return "", err
+	}
+	return name, nil
+}
+
+func migrateTemplate(ctx context.Context, t testing.TB, url, name string, fsys fs.FS) error {
+	db, err := dburl.OpenDatabase(url, name)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return drift.MigrateFS(ctx, testIO{t}, db, fsys, nil)
+}
+
+// hashFS hashes the names and contents of every file in fsys, including the
+// ones in migration directories.
+func hashFS(fsys fs.FS) (string, error) {
+	h := sha256.New()
+	err := fs.WalkDir(fsys, ".", func(name string, e fs.DirEntry, err error) error {
+		if err != nil || e.IsDir() {
+			return err
+		}
+		b, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00", name, len(b))
+		h.Write(b)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func lockKey(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return int64(h.Sum64())
+}
+
+func randomSuffix(t testing.TB) string {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatalf("generate database name: %s", err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// testIO sends Drift's logs to the test log.
+type testIO struct {
+	t testing.TB
+}
+
+func (io testIO) Infof(format string, args ...interface{}) (int, error) {
+	io.t.Logf(format, args...)
+	return 0, nil
+}
+
+func (io testIO) Debugf(format string, args ...interface{}) (int, error) {
+	io.t.Logf(format, args...)
+	return 0, nil
+}
+
+func (io testIO) Warnf(format string, args ...interface{}) (int, error) {
+	io.t.Logf("Warning: "+format, args...)
+	return 0, nil
+}
+
+func (io testIO) Errorf(format string, args ...interface{}) (int, error) {
+	io.t.Logf("Error: "+format, args...)
+	return 0, nil
+}
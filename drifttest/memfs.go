@@ -0,0 +1,20 @@
+package drifttest
+
+import (
+	"github.com/metagram-net/drift"
+	"github.com/metagram-net/drift/internal/memfs"
+)
+
+// MemFileSystem is an in-memory drift.FileSystem, for fast tests that don't
+// touch the disk. Paths are cleaned and can use either separator, and a
+// leading separator is ignored, so "/migrations" and "migrations" are the same
+// directory. It's safe for concurrent use. Pass it to drift.WithFileSystem.
+type MemFileSystem = memfs.FileSystem
+
+var _ drift.FileSystem = (*MemFileSystem)(nil)
+
+// NewMemFileSystem returns a MemFileSystem with the files, keyed by path.
+// Their parent directories exist implicitly.
+func NewMemFileSystem(files map[string]string) *MemFileSystem {
+	return memfs.New(files)
+}
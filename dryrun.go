@@ -0,0 +1,137 @@
+package drift
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ErrExplainUnsupported means WithExplain was used with a dialect other than
+// Postgres or CockroachDB.
+var ErrExplainUnsupported = errors.New("explain is only supported for Postgres and CockroachDB")
+
+// WithDryRun makes a run plan and check the migrations as usual, but apply
+// none of them: each planned migration is skipped with SkipDryRun. It doesn't
+// take the lock or ask for confirmation, since nothing changes.
+func WithDryRun() Option {
+	return func(m *Migrator) {
+		m.dryRun = true
+	}
+}
+
+// WithExplain makes a dry run (see WithDryRun) explain the DML statements
+// (INSERT, UPDATE, DELETE, MERGE, and WITH queries) of each planned migration
+// against the database, and list the plans in Result.Explained. The
+// statements are never executed: each EXPLAIN (without ANALYZE) runs in its
+// own read-only transaction, which is rolled back.
+//
+// A statement that uses a table created by an earlier pending migration can't
+// be explained; its ExplainedStatement has the error instead. Streamed
+// migrations aren't explained.
+func WithExplain() Option {
+	return func(m *Migrator) {
+		m.explain = true
+	}
+}
+
+// An ExplainedStatement is the query plan of one DML statement in a pending
+// migration.
+type ExplainedStatement struct {
+	ID   MigrationID
+	Slug string
+	// Index is the 1-based position of the statement in the file, and Line is
+	// the line it starts on.
+	Index     int
+	Line      int
+	Statement string
+	// Plan is the EXPLAIN output, one line per plan node, or empty if Err is
+	// set.
+	Plan string
+	Err  error
+}
+
+// reDML matches statements that change rows.
+var reDML = regexp.MustCompile(`(?i)^(insert|update|delete|merge|with)\b`)
+
+// dryRunPlan reports what the run would apply, and explains the DML
+// statements with WithExplain. Without a connection to begin transactions on,
+// the statements aren't explained.
+func (m *Migrator) dryRunPlan(ctx context.Context, db conn, plan []migrationFile, res *Result) error {
+	if m.explain && !speaksPostgres(m.dialect) {
+		return ErrExplainUnsupported
+	}
+	for _, f := range plan {
+		m.io.Infof("Would apply migration: %s", f.Path)
+		res.skipped(f, SkipDryRun)
+		if !m.explain || db == nil {
+			continue
+		}
+		if f.stream {
+			m.io.Debugf("Not explaining streamed migration: %s", f.Name)
+			continue
+		}
+		es, err := m.explainFile(ctx, db, f)
+		if err != nil {
+			return err
+		}
+		res.Explained = append(res.Explained, es...)
+	}
+	m.io.Infof("Dry run: would apply %d migrations", len(plan))
+	return nil
+}
+
+// explainFile explains each DML statement in the migration.
+func (m *Migrator) explainFile(ctx context.Context, db conn, f migrationFile) ([]ExplainedStatement, error) {
+	var es []ExplainedStatement
+	for i, st := range splitSQL(f.Content) {
+		if !reDML.MatchString(st.text) {
+			continue
+		}
+		line, _ := lineColumn(f.Content, st.start)
+		e := ExplainedStatement{
+			ID:        f.ID,
+			Slug:      f.Slug,
+			Index:     i + 1,
+			Line:      line,
+			Statement: strings.TrimSuffix(strings.TrimSpace(st.text), ";"),
+		}
+		if err := ctx.Err(); err != nil {
+			return es, err
+		}
+		e.Plan, e.Err = explainStatement(ctx, db, e.Statement)
+		es = append(es, e)
+	}
+	return es, nil
+}
+
+// explainStatement returns the query plan of the statement, without running
+// it.
+func explainStatement(ctx context.Context, db conn, statement string) (string, error) {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return "", err
+	}
+	// Nothing is meant to change, so never commit.
+	defer tx.Rollback() //nolint:errcheck
+
+	rows, err := tx.QueryContext(ctx, "explain "+statement)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	var lines []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return "", err
+		}
+		lines = append(lines, line)
+	}
+	if err := rows.Err(); err != nil {
+		return "", fmt.Errorf("explain: %w", err)
+	}
+	return strings.Join(lines, "\n"), nil
+}
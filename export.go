@@ -0,0 +1,138 @@
+package drift
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// An ExportFormat is another migration tool's file format.
+type ExportFormat string
+
+const (
+	// ExportGolangMigrate writes 0001_slug.up.sql and 0001_slug.down.sql
+	// files for golang-migrate.
+	ExportGolangMigrate ExportFormat = "golang-migrate"
+	// ExportGoose writes 0001_slug.sql files with Up and Down sections for
+	// goose.
+	ExportGoose ExportFormat = "goose"
+)
+
+// ExportFormats lists every valid ExportFormat.
+var ExportFormats = []ExportFormat{ExportGolangMigrate, ExportGoose}
+
+// An ExportedMigration maps a migration file to the files written for it.
+type ExportedMigration struct {
+	ID   MigrationID
+	Slug string
+	// From is the path of the migration file.
+	From string
+	// Files are the paths of the exported files. It's empty if the migration
+	// was left out.
+	Files []string
+	// Warnings describe Drift features the migration uses that the other
+	// tool won't understand.
+	Warnings []string
+}
+
+// Export writes copies of the migrations in migrationsDir to outDir in
+// another tool's format. A down.sql file next to a migration (see
+// NewScaffold) becomes its down migration. The init migration is left out,
+// since it only sets up Drift's own table and functions.
+//
+// The migration files aren't changed, so Export can be used to evaluate
+// another tool or to hand migrations to a project that uses one.
+func (m *Migrator) Export(migrationsDir string, format ExportFormat, outDir string) ([]ExportedMigration, error) {
+	files, err := m.available(migrationsDir)
+	if err != nil {
+		return nil, fmt.Errorf("could not get available migrations: %w", err)
+	}
+	if err := m.files.MkdirAll(outDir, 0o755); err != nil {
+		return nil, fmt.Errorf("could not create output directory: %w", err)
+	}
+	width := idWidth(files)
+
+	var es []ExportedMigration
+	for _, f := range files {
+		e := ExportedMigration{ID: f.ID, Slug: f.Slug, From: f.Path}
+		if f.ID == 0 && f.Slug == "init" {
+			e.Warnings = append(e.Warnings, "left out: the init migration only sets up Drift's table and functions")
+			es = append(es, e)
+			continue
+		}
+		e.Warnings = exportWarnings(f)
+
+		down, err := m.files.ReadFile(filepath.Join(strings.TrimSuffix(f.entryPath(), ".sql"), "down.sql"))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		base := fmt.Sprintf("%0*d_%s", width, f.ID, f.Slug)
+		outputs := map[string]string{}
+		switch format {
+		case ExportGolangMigrate:
+			outputs[base+".up.sql"] = f.Content
+			if down != nil {
+				outputs[base+".down.sql"] = string(down)
+			}
+		case ExportGoose:
+			var b strings.Builder
+			if skipTx(f.Content) {
+				b.WriteString("-- +goose NO TRANSACTION\n")
+			}
+			// Like Drift, send each section as one batch instead of letting
+			// goose split it at semicolons.
+			b.WriteString("-- +goose Up\n-- +goose StatementBegin\n")
+			b.WriteString(ensureNewline(f.Content))
+			b.WriteString("-- +goose StatementEnd\n")
+			if down != nil {
+				b.WriteString("\n-- +goose Down\n-- +goose StatementBegin\n")
+				b.WriteString(ensureNewline(string(down)))
+				b.WriteString("-- +goose StatementEnd\n")
+			}
+			outputs[base+".sql"] = b.String()
+		default:
+			return nil, fmt.Errorf("unknown export format: %q", format)
+		}
+		for name, content := range outputs {
+			path := filepath.Join(outDir, name)
+			if err := m.files.CreateFile(path, []byte(content), 0o644); err != nil {
+				return nil, fmt.Errorf("could not write %s: %w", path, err)
+			}
+			e.Files = append(e.Files, path)
+		}
+		sort.Strings(e.Files)
+		es = append(es, e)
+	}
+	return es, nil
+}
+
+// exportWarnings describes the Drift features in the migration that other
+// tools won't understand.
+func exportWarnings(f migrationFile) []string {
+	var ws []string
+	if skipTx(f.Content) {
+		ws = append(ws, "claims itself with _drift_claim_migration, which the other tool won't have")
+	}
+	if len(requires(f.Content)) > 0 {
+		ws = append(ws, "calls _drift_require_migration, which the other tool won't have")
+	}
+	if reVar.MatchString(f.Content) {
+		ws = append(ws, "uses ${DRIFT_VAR_...} placeholders, which won't be filled in")
+	}
+	for _, d := range listDirectives(f.Content) {
+		if d.Name != "no-transaction" {
+			ws = append(ws, fmt.Sprintf("has a %s directive, which will be ignored", d))
+		}
+	}
+	return ws
+}
+
+func ensureNewline(s string) string {
+	if strings.HasSuffix(s, "\n") {
+		return s
+	}
+	return s + "\n"
+}
@@ -0,0 +1,164 @@
+package drift
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/blockloop/scan"
+)
+
+// DefaultFailuresTable is the default name of the table that records failed
+// migration attempts.
+const DefaultFailuresTable = "schema_migration_failures"
+
+// ErrNoFailures means there's no failed migration to retry.
+var ErrNoFailures = errors.New("no failed migrations")
+
+// WithFailuresTable records each failed migration attempt (with its error) in
+// the named table, in the same schema as the migrations table, so failures
+// leave a trace in the database. The table is created when the first failure
+// is recorded. Once a migration is applied, its failures are marked resolved.
+//
+// Failures aren't recorded by default. They need Postgres or CockroachDB.
+func WithFailuresTable(name string) Option {
+	return func(m *Migrator) {
+		m.failuresTable = name
+	}
+}
+
+// A Failure is a failed attempt to apply a migration.
+type Failure struct {
+	MigrationID MigrationID `db:"migration_id"`
+	Slug        string      `db:"slug"`
+	Error       string      `db:"error"`
+	FailedAt    time.Time   `db:"failed_at"`
+}
+
+// FailuresTable returns the schema and name of the failures table (see
+// WithFailuresTable). It's created on the first failure, so only databases
+// where a migration once failed have it. Schema dumps, diffs, and plans leave
+// it out.
+func (m *Migrator) FailuresTable() (schema, table string) {
+	name := m.failuresTable
+	if name == "" {
+		name = DefaultFailuresTable
+	}
+	return m.schema, name
+}
+
+func (m *Migrator) failuresTableName() string {
+	return m.dialect.Quote(m.FailuresTable())
+}
+
+// trackFailures records the run's failed migrations and resolves the earlier
+// failures of the ones it applied. The migrations are already done either
+// way, so problems are only logged.
+func (m *Migrator) trackFailures(ctx context.Context, db Queryable, res *Result) {
+	if m.failuresTable == "" || !speaksPostgres(m.dialect) || res == nil {
+		return
+	}
+	// Record even if the run was cancelled.
+	ctx, cancel := cleanupContext(ctx)
+	defer cancel()
+	if len(res.Failed) > 0 {
+		if err := m.recordFailures(ctx, db, res.Failed); err != nil {
+			warnf(m.io, "Could not record the failed migrations: %s", err)
+		}
+	}
+	if len(res.Applied) > 0 {
+		if err := m.resolveFailures(ctx, db, res.Applied); err != nil && !m.dialect.IsUndefinedTable(err) {
+			warnf(m.io, "Could not mark earlier failures as resolved: %s", err)
+		}
+	}
+}
+
+func (m *Migrator) recordFailures(ctx context.Context, db Queryable, failed []FailedMigration) error {
+	create := fmt.Sprintf(`create table if not exists %s (
+	id bigserial primary key,
+	migration_id bigint not null,
+	slug text not null,
+	error text not null,
+	failed_at timestamptz not null default now(),
+	resolved_at timestamptz
+)`, m.failuresTableName())
+	if _, err := db.ExecContext(ctx, create); err != nil {
+		return err
+	}
+	q := m.sb().Insert(m.failuresTableName()).Columns("migration_id", "slug", "error")
+	for _, f := range failed {
+		q = q.Values(f.ID, f.Slug, f.Err.Error())
+	}
+	query, args, err := q.ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, query, args...)
+	return err
+}
+
+func (m *Migrator) resolveFailures(ctx context.Context, db Queryable, applied []AppliedMigration) error {
+	ids := make([]MigrationID, len(applied))
+	for i, a := range applied {
+		ids[i] = a.ID
+	}
+	query, args, err := m.sb().
+		Update(m.failuresTableName()).
+		Set("resolved_at", sq.Expr("now()")).
+		Where(sq.Eq{"migration_id": ids, "resolved_at": nil}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, query, args...)
+	return err
+}
+
+// Failures returns the unresolved failed attempts to apply migrations, most
+// recent first. They're recorded with WithFailuresTable.
+func (m *Migrator) Failures(ctx context.Context, db *sql.DB) ([]Failure, error) {
+	ctx, cancel := m.queryContext(ctx)
+	defer cancel()
+	query, args, err := m.sb().
+		Select("migration_id", "slug", "error", "failed_at").
+		From(m.failuresTableName()).
+		Where(sq.Eq{"resolved_at": nil}).
+		OrderBy("failed_at desc", "id desc").
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.QueryContext(ctx, query, args...)
+	if m.dialect.IsUndefinedTable(err) {
+		// Nothing has failed yet.
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var fs []Failure
+	if err := scan.RowsStrict(&fs, rows); err != nil {
+		return nil, err
+	}
+	return fs, nil
+}
+
+// Retry applies the migration that failed most recently, once whatever made
+// it fail is fixed. Like ApplyOne, it refuses if earlier migrations are still
+// pending. It returns ErrNoFailures if there's no unresolved failure.
+func (m *Migrator) Retry(ctx context.Context, db *sql.DB, migrationsDir string) (*Result, error) {
+	fs, err := m.Failures(ctx, db)
+	if err != nil {
+		return nil, fmt.Errorf("could not get failed migrations: %w", err)
+	}
+	if len(fs) == 0 {
+		return nil, ErrNoFailures
+	}
+	last := fs[0]
+	m.io.Infof("Retrying migration %d (%s), which failed at %s: %s",
+		last.MigrationID, last.Slug, last.FailedAt.Format(time.RFC3339), last.Error)
+	return m.ApplyOne(ctx, db, migrationsDir, last.MigrationID, false)
+}
@@ -0,0 +1,128 @@
+package drift
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+)
+
+// fakeDriver is a database/sql driver for tests that don't need a real
+// database. Every statement succeeds with no rows, except that statements
+// containing one of the (lowercase) hang substrings block until their context is done,
+// and statements containing one of the fail substrings fail.
+type fakeDriver struct {
+	hang []string
+	fail []string
+
+	mu    sync.Mutex
+	execs []string
+}
+
+func (d *fakeDriver) Open(string) (driver.Conn, error) {
+	return &fakeConn{d: d}, nil
+}
+
+// db opens a pool of fake connections.
+func (d *fakeDriver) db() *sql.DB {
+	return sql.OpenDB(fakeConnector{d: d})
+}
+
+// executed returns the statements executed so far, with "commit" and
+// "rollback" for the ends of transactions.
+func (d *fakeDriver) executed() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.execs...)
+}
+
+type fakeConnector struct {
+	d *fakeDriver
+}
+
+func (c fakeConnector) Connect(context.Context) (driver.Conn, error) {
+	return &fakeConn{d: c.d}, nil
+}
+
+func (c fakeConnector) Driver() driver.Driver {
+	return c.d
+}
+
+type fakeConn struct {
+	d *fakeDriver
+}
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("fake driver: prepared statements aren't supported")
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return fakeTx{d: c.d}, nil
+}
+
+// wait blocks until the context is done if the statement should hang.
+func (c *fakeConn) wait(ctx context.Context, query string) error {
+	for _, h := range c.d.hang {
+		if strings.Contains(strings.ToLower(query), h) {
+			<-ctx.Done()
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	if err := c.wait(ctx, query); err != nil {
+		return nil, err
+	}
+	for _, f := range c.d.fail {
+		if strings.Contains(strings.ToLower(query), f) {
+			return nil, errFakeFailure
+		}
+	}
+	c.d.record(query)
+	return driver.RowsAffected(0), nil
+}
+
+// errFakeFailure is the error from statements that match a fail substring.
+var errFakeFailure = errors.New("fake driver: statement failed")
+
+func (d *fakeDriver) record(query string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.execs = append(d.execs, query)
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	if err := c.wait(ctx, query); err != nil {
+		return nil, err
+	}
+	return fakeRows{}, nil
+}
+
+type fakeTx struct {
+	d *fakeDriver
+}
+
+func (tx fakeTx) Commit() error {
+	tx.d.record("commit")
+	return nil
+}
+
+func (tx fakeTx) Rollback() error {
+	tx.d.record("rollback")
+	return nil
+}
+
+type fakeRows struct{}
+
+func (fakeRows) Columns() []string         { return nil }
+func (fakeRows) Close() error              { return nil }
+func (fakeRows) Next([]driver.Value) error { return io.EOF }
@@ -0,0 +1,114 @@
+package drift
+
+import (
+	"io/fs"
+	"os"
+)
+
+// A FileSystem is where a Migrator reads and writes migration files. Paths
+// are like the ones given to Migrate, with the operating system's
+// separators. Methods behave like the os functions of the same name.
+//
+// The default is OSFileSystem. Set another one with WithFileSystem, like
+// drifttest.MemFileSystem for tests.
+type FileSystem interface {
+	// DirFS returns the directory as an fs.FS, like os.DirFS.
+	DirFS(dir string) fs.FS
+	ReadDir(name string) ([]fs.DirEntry, error)
+	ReadFile(name string) ([]byte, error)
+	Stat(name string) (fs.FileInfo, error)
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+	// CreateFile is like WriteFile, but it fails with fs.ErrExist if the
+	// file already exists.
+	CreateFile(name string, data []byte, perm fs.FileMode) error
+	// CreateTemp creates a new file with a unique name in dir, like
+	// os.CreateTemp, writes data to it, and returns its name.
+	CreateTemp(dir, pattern string, data []byte) (string, error)
+	MkdirAll(path string, perm fs.FileMode) error
+	Rename(oldpath, newpath string) error
+	Remove(name string) error
+}
+
+// WithFileSystem sets where migration files are read from and written to.
+func WithFileSystem(fsys FileSystem) Option {
+	return func(m *Migrator) {
+		m.files = fsys
+	}
+}
+
+// WithDirs adds more directories of migration files. Their files are merged
+// with the ones in migrationsDir into one plan in ID order, so IDs must be
+// unique across all of them. New files are still written to migrationsDir.
+func WithDirs(dirs ...string) Option {
+	return func(m *Migrator) {
+		m.dirs = dirs
+	}
+}
+
+// OSFileSystem is the operating system's file system.
+type OSFileSystem struct{}
+
+var _ FileSystem = OSFileSystem{}
+
+func (OSFileSystem) DirFS(dir string) fs.FS {
+	return os.DirFS(dir)
+}
+
+func (OSFileSystem) ReadDir(name string) ([]fs.DirEntry, error) {
+	return os.ReadDir(name)
+}
+
+func (OSFileSystem) ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(name) //#nosec G304 // Migration files are trusted input.
+}
+
+func (OSFileSystem) Stat(name string) (fs.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (OSFileSystem) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return os.WriteFile(name, data, perm)
+}
+
+func (OSFileSystem) CreateFile(name string, data []byte, perm fs.FileMode) error {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	// Prefer the write error over the close error.
+	_, werr := f.Write(data)
+	cerr := f.Close()
+	if werr != nil {
+		return werr
+	}
+	return cerr
+}
+
+func (OSFileSystem) CreateTemp(dir, pattern string, data []byte) (string, error) {
+	f, err := os.CreateTemp(dir, pattern)
+	if err != nil {
+		return "", err
+	}
+	_, werr := f.Write(data)
+	cerr := f.Close()
+	if werr == nil {
+		werr = cerr
+	}
+	if werr != nil {
+		os.Remove(f.Name()) //nolint:errcheck
+		return "", werr
+	}
+	return f.Name(), nil
+}
+
+func (OSFileSystem) MkdirAll(path string, perm fs.FileMode) error {
+	return os.MkdirAll(path, perm)
+}
+
+func (OSFileSystem) Rename(oldpath, newpath string) error {
+	return os.Rename(oldpath, newpath)
+}
+
+func (OSFileSystem) Remove(name string) error {
+	return os.Remove(name)
+}
@@ -0,0 +1,431 @@
+package drift
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/metagram-net/drift/internal/memfs"
+)
+
+func TestAvailableWithDirs(t *testing.T) {
+	fsys := memfs.New(map[string]string{
+		"app:v2/1-a.sql": "select 1;",
+		"auth/2-b.sql":   "select 2;",
+		"app:v2/3-c.sql": "select 3;",
+	})
+	m := New(WithFileSystem(fsys))
+	files, err := m.available("app:v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("got %d files from a directory with a colon, want 2", len(files))
+	}
+
+	m = New(WithFileSystem(fsys), WithDirs("auth"))
+	files, err = m.available("app:v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, f := range files {
+		got = append(got, f.Path)
+	}
+	want := []string{"app:v2/1-a.sql", "auth/2-b.sql", "app:v2/3-c.sql"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestAvailableWithDirsDuplicateID(t *testing.T) {
+	fsys := memfs.New(map[string]string{
+		"app/1-a.sql": "select 1;",
+		"dup/1-d.sql": "select 4;",
+	})
+	m := New(WithFileSystem(fsys), WithDirs("dup"))
+	if _, err := m.available("app"); !errors.Is(err, ErrDuplicateID) {
+		t.Errorf("got error %v, want %v", err, ErrDuplicateID)
+	}
+}
+
+func TestNewFileBody(t *testing.T) {
+	fsys := memfs.New(map[string]string{"migrations/0-init.sql": "-- init"})
+	m := New(WithFileSystem(fsys), WithBody("create table users (id bigint);"))
+	path, err := m.NewFile("migrations", 1700000000, "add_users", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := fsys.Files()[path]
+	if !strings.Contains(content, "\ncreate table users (id bigint);") {
+		t.Errorf("content doesn't have the body:\n%s", content)
+	}
+	if strings.Contains(content, "TODO") {
+		t.Errorf("content has the placeholder comment as well as the body:\n%s", content)
+	}
+}
+
+func TestRenumberSequential(t *testing.T) {
+	tests := map[string]struct {
+		files map[string]string
+		want  map[string]string
+	}{
+		"gaps": {
+			files: map[string]string{
+				"migrations/0-init.sql":       "-- init",
+				"migrations/3-add_teams.sql":  "create table teams ();",
+				"migrations/70-add_users.sql": "create table users ();",
+				"migrations/900-add_orgs.sql": "create table orgs ();",
+			},
+			want: map[string]string{
+				"migrations/0-init.sql":      "-- init",
+				"migrations/1-add_teams.sql": "create table teams ();",
+				"migrations/2-add_users.sql": "create table users ();",
+				"migrations/3-add_orgs.sql":  "create table orgs ();",
+			},
+		},
+		"new IDs reuse old ones": {
+			files: map[string]string{
+				"migrations/2-add_teams.sql": "create table teams ();",
+				"migrations/3-add_users.sql": "select _drift_require_migration(2);",
+				"migrations/5-add_orgs.sql":  "select _drift_require_migration(3);",
+			},
+			want: map[string]string{
+				"migrations/1-add_teams.sql": "create table teams ();",
+				"migrations/2-add_users.sql": "select _drift_require_migration(1);",
+				"migrations/3-add_orgs.sql":  "select _drift_require_migration(2);",
+			},
+		},
+		"timestamps": {
+			files: map[string]string{
+				"migrations/1645673864-add_teams.sql":   "create table teams ();",
+				"migrations/1645680000-add_users.sql":   "create table users ();",
+				"migrations/1700000000-add_orgs/up.sql": "create table orgs ();",
+			},
+			want: map[string]string{
+				"migrations/1-add_teams.sql":   "create table teams ();",
+				"migrations/2-add_users.sql":   "create table users ();",
+				"migrations/3-add_orgs/up.sql": "create table orgs ();",
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			fsys := memfs.New(tt.files)
+			m := New(WithFileSystem(fsys), WithSequentialIDs())
+			if err := m.Renumber("migrations", true); err != nil {
+				t.Fatal(err)
+			}
+			if got := fsys.Files(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got files %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenumberSequentialCollision(t *testing.T) {
+	files := map[string]string{
+		"migrations/5-add_teams.sql":      "create table teams ();",
+		"migrations/5-add_teams/down.sql": "drop table teams;",
+		"migrations/9-add_users.sql":      "create table users ();",
+		// Not a migration, but the companion directory would take its name.
+		"migrations/1-add_teams": "not a migration",
+	}
+	fsys := memfs.New(files)
+	m := New(WithFileSystem(fsys), WithSequentialIDs())
+	if err := m.Renumber("migrations", true); !errors.Is(err, ErrRenameCollision) {
+		t.Errorf("got error %v, want %v", err, ErrRenameCollision)
+	}
+	if got := fsys.Files(); !reflect.DeepEqual(got, files) {
+		t.Errorf("got files %v, want them unchanged", got)
+	}
+}
+
+// renumberFiles is a directory that WithSequentialIDs renumbers with renames,
+// a companion directory, and an ID reference to update.
+func renumberFiles() map[string]string {
+	return map[string]string{
+		"migrations/0-init.sql":            "-- init",
+		"migrations/10-add_teams.sql":      "create table teams ();",
+		"migrations/10-add_teams/down.sql": "drop table teams;",
+		"migrations/20-add_users.sql":      "select _drift_require_migration(10);\ncreate table users ();",
+	}
+}
+
+func TestRenumberResume(t *testing.T) {
+	want := map[string]string{
+		"migrations/0-init.sql":           "-- init",
+		"migrations/1-add_teams.sql":      "create table teams ();",
+		"migrations/1-add_teams/down.sql": "drop table teams;",
+		"migrations/2-add_users.sql":      "select _drift_require_migration(1);\ncreate table users ();",
+	}
+	// Each renumber renames the journal into place three times, and makes
+	// six renames of its own.
+	for n := 0; n < 9; n++ {
+		fsys := &interruptedFS{FileSystem: memfs.New(renumberFiles()), renames: n}
+		m := New(WithFileSystem(fsys), WithSequentialIDs())
+		if err := m.Renumber("migrations", true); !errors.Is(err, errInterrupted) {
+			t.Fatalf("interrupted after %d renames: got error %v, want %v", n, err, errInterrupted)
+		}
+		fsys.renames = -1
+
+		if _, err := fsys.Stat("migrations/.drift-renumber.json"); err != nil {
+			// The journal was never written, so nothing was renamed.
+			if got := fsys.Files(); !reflect.DeepEqual(got, renumberFiles()) {
+				t.Errorf("interrupted after %d renames without a journal: got files %v", n, got)
+			}
+			continue
+		}
+		if err := m.Renumber("migrations", true); !errors.Is(err, ErrRenumberInterrupted) {
+			t.Errorf("interrupted after %d renames: renumber again got error %v, want %v", n, err, ErrRenumberInterrupted)
+		}
+		if err := m.RenumberResume("migrations"); err != nil {
+			t.Fatalf("interrupted after %d renames: %v", n, err)
+		}
+		if got := fsys.Files(); !reflect.DeepEqual(got, want) {
+			t.Errorf("interrupted after %d renames: got files %v, want %v", n, got, want)
+		}
+	}
+}
+
+func TestRenumberRollback(t *testing.T) {
+	for n := 1; n < 9; n++ {
+		fsys := &interruptedFS{FileSystem: memfs.New(renumberFiles()), renames: n}
+		m := New(WithFileSystem(fsys), WithSequentialIDs())
+		if err := m.Renumber("migrations", true); !errors.Is(err, errInterrupted) {
+			t.Fatalf("interrupted after %d renames: got error %v, want %v", n, err, errInterrupted)
+		}
+		fsys.renames = -1
+
+		if err := m.RenumberRollback("migrations"); err != nil {
+			t.Fatalf("interrupted after %d renames: %v", n, err)
+		}
+		if got := fsys.Files(); !reflect.DeepEqual(got, renumberFiles()) {
+			t.Errorf("interrupted after %d renames: got files %v, want %v", n, got, renumberFiles())
+		}
+	}
+
+	m := New(WithFileSystem(memfs.New(renumberFiles())))
+	if err := m.RenumberRollback("migrations"); !errors.Is(err, ErrNoRenumberJournal) {
+		t.Errorf("got error %v, want %v", err, ErrNoRenumberJournal)
+	}
+}
+
+func TestRenumberDBRollsBack(t *testing.T) {
+	ok := &fakeDriver{}
+	failing := &fakeDriver{fail: []string{"update"}}
+	dbs := []*sql.DB{ok.db(), failing.db()}
+	for _, db := range dbs {
+		defer db.Close()
+	}
+
+	fsys := memfs.New(renumberFiles())
+	m := New(WithFileSystem(fsys), WithSequentialIDs())
+	if err := m.RenumberDB(context.Background(), "migrations", true, dbs...); !errors.Is(err, errFakeFailure) {
+		t.Fatalf("got error %v, want %v", err, errFakeFailure)
+	}
+	// The first database was updated, but its transaction wasn't committed.
+	if got := ok.executed(); len(got) == 0 || got[len(got)-1] != "rollback" {
+		t.Errorf("first database executed %q, want a rollback at the end", got)
+	}
+	if got := fsys.Files(); !reflect.DeepEqual(got, renumberFiles()) {
+		t.Errorf("files were renamed after the database update failed: %v", got)
+	}
+}
+
+func TestAvailable(t *testing.T) {
+	fsys := memfs.New(map[string]string{
+		"migrations/0-init.sql":            "-- init",
+		"migrations/2-add_users.sql":       "create table users ();",
+		"migrations/1-add_teams/up.sql":    "create table teams ();",
+		"migrations/2-add_users/down.sql":  "drop table users;",
+		"migrations/README.md":             "not a migration",
+		"migrations/not_a_migration.sql":   "select 1;",
+		"other/3-not_in_the_directory.sql": "select 1;",
+	})
+
+	files, err := available(nopIO{}, fsys, []string{"migrations"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	type file struct {
+		ID      MigrationID
+		Slug    string
+		Content string
+	}
+	var got []file
+	for _, f := range files {
+		got = append(got, file{f.ID, f.Slug, f.Content})
+	}
+	want := []file{
+		{0, "init", "-- init"},
+		{1, "add_teams", "create table teams ();"},
+		{2, "add_users", "create table users ();"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestAvailableDuplicateID(t *testing.T) {
+	fsys := memfs.New(map[string]string{
+		"migrations/1-a.sql":  "",
+		"migrations/01-b.sql": "",
+	})
+	if _, err := available(nopIO{}, fsys, []string{"migrations"}); !errors.Is(err, ErrDuplicateID) {
+		t.Errorf("got error %v, want %v", err, ErrDuplicateID)
+	}
+}
+
+func TestNewFile(t *testing.T) {
+	fsys := memfs.New(map[string]string{
+		"migrations/0-init.sql": "-- init",
+	})
+	m := New(WithFileSystem(fsys))
+
+	path, err := m.NewFile("migrations", 1700000000, "add_users", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "migrations/1700000000-add_users.sql"; path != want {
+		t.Errorf("got path %q, want %q", path, want)
+	}
+	content, ok := fsys.Files()["migrations/1700000000-add_users.sql"]
+	if !ok {
+		t.Fatalf("file wasn't created; files: %v", fsys.Files())
+	}
+	if !strings.Contains(content, "add_users") {
+		t.Errorf("content doesn't mention the slug:\n%s", content)
+	}
+
+	if _, err := m.NewFile("migrations", 1700000000, "add_users", nil); err == nil {
+		t.Error("creating the same file twice succeeded")
+	}
+}
+
+func TestNewScaffoldBodyWithoutTemplateField(t *testing.T) {
+	fsys := memfs.New(map[string]string{
+		"migrations/0-init.sql": "-- init",
+	})
+	m := New(WithFileSystem(fsys), WithBody("create table users (id bigint);"))
+	tmpls := map[string]*template.Template{
+		UpTemplate: template.Must(template.New(UpTemplate).Parse("-- Migration: {{.Slug}}")),
+	}
+
+	paths, err := m.NewScaffold("migrations", 1700000000, "add_users", tmpls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := fsys.Files()[paths[0]]
+	if want := "-- Migration: add_users\ncreate table users (id bigint);\n"; content != want {
+		t.Errorf("got content %q, want %q", content, want)
+	}
+}
+
+func TestSetup(t *testing.T) {
+	fsys := memfs.New(nil)
+	m := New(WithFileSystem(fsys))
+
+	path, err := m.Setup("db/migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "db/migrations/0-init.sql"; path != want {
+		t.Errorf("got path %q, want %q", path, want)
+	}
+	if !strings.Contains(fsys.Files()["db/migrations/0-init.sql"], DefaultTable) {
+		t.Errorf("init migration doesn't create the migrations table:\n%s", fsys.Files()["db/migrations/0-init.sql"])
+	}
+
+	if _, err := m.Setup("db/migrations"); !errors.Is(err, ErrAlreadySetUp) {
+		t.Errorf("second Setup: got error %v, want %v", err, ErrAlreadySetUp)
+	}
+	if err := fsys.WriteFile("db/migrations/0-init.sql", []byte("-- edited"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Setup("db/migrations"); !errors.Is(err, ErrInitMismatch) {
+		t.Errorf("Setup after an edit: got error %v, want %v", err, ErrInitMismatch)
+	}
+}
+
+func TestRenumber(t *testing.T) {
+	fsys := memfs.New(map[string]string{
+		"migrations/0-init.sql":            "-- init",
+		"migrations/5-add_teams.sql":       "create table teams ();",
+		"migrations/12-add_users.sql":      "create table users ();",
+		"migrations/12-add_users/down.sql": "drop table users;",
+	})
+	m := New(WithFileSystem(fsys))
+
+	if err := m.Renumber("migrations", false); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fsys.Files()["migrations/05-add_teams.sql"]; ok {
+		t.Fatal("Renumber renamed files without write")
+	}
+
+	if err := m.Renumber("migrations", true); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"migrations/00-init.sql":           "-- init",
+		"migrations/05-add_teams.sql":      "create table teams ();",
+		"migrations/12-add_users.sql":      "create table users ();",
+		"migrations/12-add_users/down.sql": "drop table users;",
+	}
+	if got := fsys.Files(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got files %v, want %v", got, want)
+	}
+}
+
+func TestRenumberCollision(t *testing.T) {
+	fsys := memfs.New(map[string]string{
+		"migrations/5-add_teams.sql":      "create table teams ();",
+		"migrations/5-add_teams/down.sql": "drop table teams;",
+		"migrations/12-add_users.sql":     "create table users ();",
+		"migrations/05-add_teams":         "not a migration",
+	})
+	m := New(WithFileSystem(fsys))
+	if err := m.Renumber("migrations", true); !errors.Is(err, ErrRenameCollision) {
+		t.Errorf("got error %v, want %v", err, ErrRenameCollision)
+	}
+}
+
+func TestWriteJSONAtomic(t *testing.T) {
+	fsys := memfs.New(map[string]string{"progress/.keep": ""})
+	for i := 0; i < 2; i++ {
+		if err := writeJSONAtomic(fsys, "progress/run.json", map[string]int{"n": i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := map[string]string{
+		"progress/.keep":    "",
+		"progress/run.json": "{\n  \"n\": 1\n}\n",
+	}
+	if got := fsys.Files(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got files %v, want %v", got, want)
+	}
+}
+
+var errInterrupted = errors.New("interrupted")
+
+// interruptedFS fails every rename after the first few, like a renumber that
+// was killed partway through.
+type interruptedFS struct {
+	*memfs.FileSystem
+	// renames is how many renames succeed before they start failing, or
+	// negative for no limit.
+	renames int
+}
+
+func (f *interruptedFS) Rename(oldpath, newpath string) error {
+	if f.renames == 0 {
+		return errInterrupted
+	}
+	f.renames--
+	return f.FileSystem.Rename(oldpath, newpath)
+}
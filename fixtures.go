@@ -0,0 +1,246 @@
+package drift
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// ErrFixtureCycle means the fixture tables' foreign keys form a cycle, so
+// there's no order to load them in.
+var ErrFixtureCycle = errors.New("fixture tables have a foreign key cycle")
+
+// A LoadedFixture is a fixture file that LoadFixtures loaded.
+type LoadedFixture struct {
+	Table string
+	Path  string
+	// Rows is the number of rows copied from a CSV file, or affected by the
+	// last statement of a SQL file.
+	Rows int64
+}
+
+type fixtureFile struct {
+	table string
+	path  string
+	oid   int64
+}
+
+// LoadFixtures resets tables to known contents for tests. Each .csv or .sql
+// file at the top of fsys holds rows for the table it's named after, like
+// users.csv or auth.users.sql. CSV files need a header row of column names and
+// are loaded with COPY. SQL files are run as is.
+//
+// In one transaction, LoadFixtures truncates the fixture tables and the other
+// given tables (restarting their sequences) and then loads the files, with
+// tables that others reference through foreign keys first.
+func LoadFixtures(ctx context.Context, db *sql.DB, fsys fs.FS, tables ...string) ([]LoadedFixture, error) {
+	files, err := fixtureFiles(fsys)
+	if err != nil {
+		return nil, fmt.Errorf("could not list fixture files: %w", err)
+	}
+	truncate := make([]string, 0, len(files)+len(tables))
+	for _, f := range files {
+		truncate = append(truncate, f.table)
+	}
+	truncate = append(truncate, tables...)
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var loaded []LoadedFixture
+	err = conn.Raw(func(driverConn any) error {
+		pc := driverConn.(pgxConn).Conn()
+		tx, err := pc.Begin(ctx)
+		if err != nil {
+			return err
+		}
+		// This is a no-op after a successful commit.
+		defer tx.Rollback(ctx) //nolint:errcheck
+
+		if err := resolveFixtures(ctx, tx, files); err != nil {
+			return err
+		}
+		if files, err = orderFixtures(ctx, tx, files); err != nil {
+			return err
+		}
+		if len(truncate) > 0 {
+			names := make([]string, 0, len(truncate))
+			for _, t := range truncate {
+				names = append(names, quoteTable(t))
+			}
+			if _, err := tx.Exec(ctx, "truncate "+strings.Join(names, ", ")+" restart identity"); err != nil {
+				return fmt.Errorf("could not truncate tables: %w", err)
+			}
+		}
+		for _, f := range files {
+			n, err := loadFixture(ctx, tx, fsys, f)
+			if err != nil {
+				return fmt.Errorf("fixture %s: %w", f.path, err)
+			}
+			loaded = append(loaded, LoadedFixture{Table: f.table, Path: f.path, Rows: n})
+		}
+		return tx.Commit(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return loaded, nil
+}
+
+// fixtureFiles lists the fixture files at the top of fsys, in name order.
+func fixtureFiles(fsys fs.FS) ([]fixtureFile, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	var files []fixtureFile
+	for _, e := range entries {
+		ext := path.Ext(e.Name())
+		if e.IsDir() || (ext != ".csv" && ext != ".sql") {
+			continue
+		}
+		files = append(files, fixtureFile{
+			table: strings.TrimSuffix(e.Name(), ext),
+			path:  e.Name(),
+		})
+	}
+	return files, nil
+}
+
+// quoteTable quotes a table name that may be schema-qualified with a dot.
+func quoteTable(name string) string {
+	return Postgres{}.Quote(strings.Split(name, ".")...)
+}
+
+// resolveFixtures looks up the OID of each fixture's table.
+func resolveFixtures(ctx context.Context, tx pgx.Tx, files []fixtureFile) error {
+	for i, f := range files {
+		var oid sql.NullInt64
+		err := tx.QueryRow(ctx, "select to_regclass($1)::oid::int8", quoteTable(f.table)).Scan(&oid)
+		if err != nil {
+			return err
+		}
+		if !oid.Valid {
+			return fmt.Errorf("%w: %s (from %s)", ErrUnknownTable, f.table, f.path)
+		}
+		files[i].oid = oid.Int64
+	}
+	return nil
+}
+
+// orderFixtures sorts the fixtures so that each table comes after the tables
+// it references. Otherwise, they stay in name order.
+func orderFixtures(ctx context.Context, tx pgx.Tx, files []fixtureFile) ([]fixtureFile, error) {
+	oids := make([]int64, 0, len(files))
+	for _, f := range files {
+		oids = append(oids, f.oid)
+	}
+	rows, err := tx.Query(ctx, `
+		select distinct conrelid::oid::int8, confrelid::oid::int8
+		from pg_constraint
+		where contype = 'f'
+			and conrelid::oid::int8 = any($1)
+			and confrelid::oid::int8 = any($1)
+			and conrelid <> confrelid`, oids)
+	if err != nil {
+		return nil, err
+	}
+	// deps maps each table to the tables it references.
+	deps := make(map[int64][]int64)
+	for rows.Next() {
+		var child, parent int64
+		if err := rows.Scan(&child, &parent); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		deps[child] = append(deps[child], parent)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// A table's fixtures are all placed before any that depend on it, so
+	// count the files left for each table.
+	left := make(map[int64]int, len(files))
+	for _, f := range files {
+		left[f.oid]++
+	}
+	ordered := make([]fixtureFile, 0, len(files))
+	placed := make([]bool, len(files))
+	for len(ordered) < len(files) {
+		progress := false
+		for i, f := range files {
+			if placed[i] || !allLoaded(deps[f.oid], left) {
+				continue
+			}
+			ordered = append(ordered, f)
+			placed[i] = true
+			left[f.oid]--
+			progress = true
+		}
+		if !progress {
+			var cycle []string
+			for i, f := range files {
+				if !placed[i] {
+					cycle = append(cycle, f.table)
+				}
+			}
+			sort.Strings(cycle)
+			return nil, fmt.Errorf("%w: %s", ErrFixtureCycle, strings.Join(cycle, ", "))
+		}
+	}
+	return ordered, nil
+}
+
+func allLoaded(oids []int64, left map[int64]int) bool {
+	for _, oid := range oids {
+		if left[oid] > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// loadFixture loads one fixture file into its table.
+func loadFixture(ctx context.Context, tx pgx.Tx, fsys fs.FS, f fixtureFile) (int64, error) {
+	b, err := fs.ReadFile(fsys, f.path)
+	if err != nil {
+		return 0, err
+	}
+	if path.Ext(f.path) == ".sql" {
+		tag, err := tx.Exec(ctx, string(b))
+		if err != nil {
+			return 0, statementError(string(b), err)
+		}
+		return tag.RowsAffected(), nil
+	}
+
+	header, err := csv.NewReader(bytes.NewReader(b)).Read()
+	if err != nil {
+		return 0, fmt.Errorf("could not read the CSV header: %w", err)
+	}
+	cols := make([]string, 0, len(header))
+	for _, h := range header {
+		cols = append(cols, Postgres{}.Quote(strings.TrimSpace(h)))
+	}
+	query := fmt.Sprintf("copy %s (%s) from stdin with (format csv, header true)",
+		quoteTable(f.table), strings.Join(cols, ", "))
+	tag, err := tx.Conn().PgConn().CopyFrom(ctx, bytes.NewReader(b), query)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,186 @@
+package drift
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"os/user"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/blockloop/scan"
+)
+
+// A HistoryEntry is one applied migration from the migrations table.
+type HistoryEntry struct {
+	ID    MigrationID
+	Slug  string
+	RunAt time.Time
+	// Duration is nil if the migration was applied before Drift started
+	// recording durations (or the table has no duration_ms column).
+	Duration *time.Duration
+	// AppliedBy is empty if it wasn't recorded.
+	AppliedBy string
+	// Content is the SQL that ran, if it was recorded with WithAuditContent.
+	Content string
+	// Faked is true if the migration was marked as applied with MarkApplied
+	// instead of being run.
+	Faked bool
+}
+
+// WithAppliedBy sets the identity recorded in the applied_by column. The
+// default is the current OS user and hostname, like "alice@build-1".
+func WithAppliedBy(s string) Option {
+	return func(m *Migrator) {
+		m.appliedBy = s
+	}
+}
+
+// History returns the applied migrations in ID order.
+func (m *Migrator) History(ctx context.Context, db *sql.DB) ([]HistoryEntry, error) {
+	records, err := m.records(ctx, db, true)
+	if err != nil {
+		return nil, err
+	}
+	hs := make([]HistoryEntry, 0, len(records))
+	for _, r := range records {
+		h := HistoryEntry{
+			ID:        r.ID,
+			Slug:      r.Slug,
+			RunAt:     r.RunAt,
+			AppliedBy: r.AppliedBy.String,
+			Content:   r.Content.String,
+			Faked:     r.Faked.Bool,
+		}
+		if r.DurationMS.Valid {
+			d := time.Duration(r.DurationMS.Int64) * time.Millisecond
+			h.Duration = &d
+		}
+		hs = append(hs, h)
+	}
+	return hs, nil
+}
+
+// WithAuditContent records the full SQL text of each migration in the content
+// column of the migrations table as it's applied. This keeps the exact DDL that
+// ran recoverable even if the files are later edited or squashed.
+//
+// Tables created by older init migrations need the column added first:
+//
+//	alter table schema_migrations add column content text;
+func WithAuditContent() Option {
+	return func(m *Migrator) {
+		m.auditContent = true
+	}
+}
+
+// historyColumns are the optional columns of the migrations table that are
+// present. Tables created by older init migrations don't have them. The id
+// column is only there if the table exists.
+type historyColumns map[string]bool
+
+// complete reports whether every column the migrator wants to fill is present.
+func (m *Migrator) complete(cols historyColumns) bool {
+	return cols["duration_ms"] && cols["applied_by"] && (!m.auditContent || cols["content"])
+}
+
+// contentMissing reports whether WithAuditContent can't record anything
+// because the migrations table exists without a content column.
+func (m *Migrator) contentMissing(cols historyColumns) bool {
+	return m.auditContent && cols["id"] && !cols["content"]
+}
+
+// refreshColumns looks for the migrations table's columns again if some that
+// the migrator wants to fill were missing, since the init migration may have
+// just created them. The first time it finds the content column missing, it
+// warns that WithAuditContent won't record anything.
+func (m *Migrator) refreshColumns(ctx context.Context, db rowQueryable, cols historyColumns) (historyColumns, error) {
+	if m.complete(cols) {
+		return cols, nil
+	}
+	warned := m.contentMissing(cols)
+	cols, err := m.historyColumns(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	if m.contentMissing(cols) && !warned {
+		warnf(m.io, "The migrations table has no content column, so the SQL that runs won't be recorded (add it with: alter table %s add column content text)", m.tableName())
+	}
+	return cols, nil
+}
+
+// A rowQueryable is a database or transaction that can run queries.
+type rowQueryable interface {
+	QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error)
+}
+
+func (m *Migrator) historyColumns(ctx context.Context, db rowQueryable) (historyColumns, error) {
+	ctx, cancel := m.queryContext(ctx)
+	defer cancel()
+	query, args, err := m.sb().
+		Select("column_name").
+		From("information_schema.columns").
+		Where(sq.Eq{
+			"table_schema": m.schema,
+			"table_name":   m.table,
+			"column_name":  []string{"id", "duration_ms", "applied_by", "content", "faked"},
+		}).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	if err := scan.RowsStrict(&names, rows); err != nil {
+		return nil, err
+	}
+	cols := make(historyColumns)
+	for _, n := range names {
+		cols[n] = true
+	}
+	return cols, nil
+}
+
+func (m *Migrator) recordHistory(ctx context.Context, tx Queryable, f migrationFile, d time.Duration, cols historyColumns) error {
+	q := m.sb().Update(m.tableName()).Where(sq.Eq{"id": f.ID})
+	set := false
+	if cols["duration_ms"] {
+		q, set = q.Set("duration_ms", d.Milliseconds()), true
+	}
+	if cols["applied_by"] {
+		q, set = q.Set("applied_by", m.appliedByOrDefault()), true
+	}
+	if m.auditContent && cols["content"] && !f.stream {
+		q, set = q.Set("content", f.Content), true
+	}
+	if !set {
+		return nil
+	}
+	query, args, err := q.ToSql()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := m.queryContext(ctx)
+	defer cancel()
+	_, err = tx.ExecContext(ctx, query, args...)
+	return err
+}
+
+func (m *Migrator) appliedByOrDefault() string {
+	if m.appliedBy != "" {
+		return m.appliedBy
+	}
+	name := "unknown"
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		return name
+	}
+	return fmt.Sprintf("%s@%s", name, host)
+}
@@ -0,0 +1,437 @@
+package drift
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+var (
+	ErrNoMigrationsTable = errors.New("the migrations table doesn't exist yet; apply the init migration first")
+	ErrTableConflict     = errors.New("the other tool's table has the same name as the migrations table")
+	ErrDirtySource       = errors.New("the other tool's last migration failed partway through")
+	ErrUnsupportedID     = errors.New("migration version can't be used as a Drift ID")
+)
+
+// An ImportSource is another migration tool that Drift can import history and
+// files from.
+type ImportSource string
+
+const (
+	// ImportGoose reads pressly/goose's goose_db_version table and
+	// 00001_slug.sql files.
+	ImportGoose ImportSource = "goose"
+	// ImportGolangMigrate reads golang-migrate's schema_migrations table and
+	// 1_slug.up.sql and 1_slug.down.sql files.
+	ImportGolangMigrate ImportSource = "golang-migrate"
+	// ImportFlyway reads Flyway's flyway_schema_history table and
+	// V1__slug.sql and U1__slug.sql files.
+	ImportFlyway ImportSource = "flyway"
+	// ImportRails reads Active Record's schema_migrations table and uses the
+	// names of the 20240101000000_slug.rb files for slugs. The Ruby files
+	// can't be converted.
+	ImportRails ImportSource = "rails"
+)
+
+// ImportSources lists every valid ImportSource.
+var ImportSources = []ImportSource{ImportGoose, ImportGolangMigrate, ImportFlyway, ImportRails}
+
+// DefaultTable returns the name of the table the tool records applied
+// migrations in by default.
+func (s ImportSource) DefaultTable() string {
+	switch s {
+	case ImportGoose:
+		return "goose_db_version"
+	case ImportFlyway:
+		return "flyway_schema_history"
+	default:
+		return "schema_migrations"
+	}
+}
+
+// sourceFile is one of the other tool's migration files.
+type sourceFile struct {
+	path string
+	id   MigrationID
+	slug string
+	// down is true for files that undo a migration.
+	down bool
+	// convertible is false for files Drift can't run, like Ruby or Go
+	// migrations.
+	convertible bool
+}
+
+// reSourceFile matches each tool's file names. The first group is the version
+// and the second is the description.
+var reSourceFile = map[ImportSource]*regexp.Regexp{
+	ImportGoose:         regexp.MustCompile(`^(\d+)_(.+)\.(sql|go)$`),
+	ImportGolangMigrate: regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`),
+	ImportFlyway:        regexp.MustCompile(`^([VU])(\d+)__(.+)\.sql$`),
+	ImportRails:         regexp.MustCompile(`^(\d+)_(.+)\.rb$`),
+}
+
+// sourceFiles reads the names of the other tool's migration files in dir.
+func sourceFiles(io IO, files FileSystem, source ImportSource, dir string) ([]sourceFile, error) {
+	entries, err := files.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not list migration files: %w", err)
+	}
+	re := reSourceFile[source]
+	var fs []sourceFile
+	for _, e := range entries {
+		sm := re.FindStringSubmatch(e.Name())
+		if e.IsDir() || sm == nil {
+			io.Debugf("Ignoring file that isn't a %s migration: %s", source, e.Name())
+			continue
+		}
+		f := sourceFile{path: filepath.Join(dir, e.Name()), convertible: true}
+		version, slug := sm[1], sm[2]
+		switch source {
+		case ImportGoose:
+			f.convertible = sm[3] == "sql"
+		case ImportGolangMigrate:
+			f.down = sm[3] == "down"
+		case ImportFlyway:
+			f.down = sm[1] == "U"
+			version, slug = sm[2], sm[3]
+		case ImportRails:
+			f.convertible = false
+		}
+		if err := f.id.Set(version); err != nil {
+			return nil, fmt.Errorf("%w: %s: %s", ErrUnsupportedID, e.Name(), err)
+		}
+		f.slug = Slugify(slug)
+		fs = append(fs, f)
+	}
+	sort.SliceStable(fs, func(i, j int) bool { return fs[i].id < fs[j].id })
+	return fs, nil
+}
+
+// An ImportedMigration is a migration that the other tool recorded as applied.
+type ImportedMigration struct {
+	ID   MigrationID
+	Slug string
+	// AppliedAt is zero if the tool doesn't record when migrations ran.
+	AppliedAt time.Time
+}
+
+// ImportHistory reads the migrations that another tool recorded as applied
+// from its table (the source's DefaultTable if table is empty) and records
+// them as applied in the migrations table. Slugs come from the tool's files in
+// migrationsDir, where there's one for the migration. Migrations that are
+// already recorded are left alone.
+//
+// The migrations table has to exist already, so apply the init migration
+// first. If write is false, this only returns what it would import.
+func (m *Migrator) ImportHistory(ctx context.Context, db *sql.DB, source ImportSource, table, migrationsDir string, write bool) ([]ImportedMigration, error) {
+	if table == "" {
+		table = source.DefaultTable()
+	}
+	if table == m.table {
+		return nil, fmt.Errorf("%w: %s", ErrTableConflict, table)
+	}
+	if _, err := db.ExecContext(ctx, "select 1 from "+m.tableName()+" limit 0"); err != nil {
+		if m.dialect.IsUndefinedTable(err) {
+			return nil, ErrNoMigrationsTable
+		}
+		return nil, err
+	}
+
+	files, err := sourceFiles(m.io, m.files, source, migrationsDir)
+	if err != nil {
+		return nil, err
+	}
+	slugs := make(map[MigrationID]string)
+	for _, f := range files {
+		slugs[f.id] = f.slug
+	}
+	imported, err := m.readSourceHistory(ctx, db, source, table, files)
+	if err != nil {
+		return nil, fmt.Errorf("could not read the %s history: %w", source, err)
+	}
+
+	records, err := m.applied(ctx, db)
+	if err != nil {
+		return nil, fmt.Errorf("could not get applied migrations: %w", err)
+	}
+	recorded := make(map[MigrationID]bool, len(records))
+	for _, r := range records {
+		recorded[r.ID] = true
+	}
+	var todo []ImportedMigration
+	for _, im := range imported {
+		if recorded[im.ID] {
+			m.io.Debugf("Already recorded: %d", im.ID)
+			continue
+		}
+		if im.Slug == "" {
+			im.Slug = slugs[im.ID]
+		}
+		if im.Slug == "" {
+			im.Slug = "imported"
+		}
+		todo = append(todo, im)
+	}
+	if !write || len(todo) == 0 {
+		return todo, nil
+	}
+
+	cols, err := m.historyColumns(ctx, db)
+	if err != nil {
+		return nil, fmt.Errorf("could not inspect the migrations table: %w", err)
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	// This is a no-op after a successful commit.
+	defer tx.Rollback() //nolint:errcheck
+	for _, im := range todo {
+		if err := m.claim(ctx, tx, im.ID, im.Slug); err != nil {
+			return nil, fmt.Errorf("could not record migration %d: %w", im.ID, err)
+		}
+		q := m.sb().Update(m.tableName()).Where(sq.Eq{"id": im.ID})
+		set := false
+		if !im.AppliedAt.IsZero() {
+			q, set = q.Set("run_at", im.AppliedAt), true
+		}
+		if cols["applied_by"] {
+			q, set = q.Set("applied_by", "imported from "+string(source)), true
+		}
+		if !set {
+			continue
+		}
+		query, args, err := q.ToSql()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	m.io.Infof("Imported %d migrations from %s", len(todo), source)
+	return todo, nil
+}
+
+// readSourceHistory reads the applied migrations from the other tool's table.
+func (m *Migrator) readSourceHistory(ctx context.Context, db *sql.DB, source ImportSource, table string, files []sourceFile) ([]ImportedMigration, error) {
+	quoted := m.dialect.Quote(table)
+	switch source {
+	case ImportGoose:
+		// Goose records every up and down, so the last row for each version
+		// says whether it's applied. Version 0 is goose's own starting point.
+		rows, err := db.QueryContext(ctx, "select version_id, is_applied, tstamp from "+quoted+" where version_id > 0 order by id")
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		byID := make(map[MigrationID]ImportedMigration)
+		applied := make(map[MigrationID]bool)
+		for rows.Next() {
+			var (
+				im ImportedMigration
+				ok bool
+			)
+			if err := rows.Scan(&im.ID, &ok, &im.AppliedAt); err != nil {
+				return nil, err
+			}
+			byID[im.ID] = im
+			applied[im.ID] = ok
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		var ims []ImportedMigration
+		for id, im := range byID {
+			if applied[id] {
+				ims = append(ims, im)
+			}
+		}
+		sort.Slice(ims, func(i, j int) bool { return ims[i].ID < ims[j].ID })
+		return ims, nil
+
+	case ImportGolangMigrate:
+		// golang-migrate only records the current version, so every file up
+		// to it has been applied.
+		var (
+			version int64
+			dirty   bool
+		)
+		err := db.QueryRowContext(ctx, "select version, dirty from "+quoted).Scan(&version, &dirty)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if dirty {
+			return nil, fmt.Errorf("%w: version %d", ErrDirtySource, version)
+		}
+		var ims []ImportedMigration
+		for _, f := range files {
+			if !f.down && int64(f.id) <= version {
+				ims = append(ims, ImportedMigration{ID: f.id})
+			}
+		}
+		return ims, nil
+
+	case ImportFlyway:
+		// Repeatable migrations have no version, and the baseline row isn't a
+		// migration.
+		rows, err := db.QueryContext(ctx, "select version, description, installed_on from "+quoted+
+			" where success and version is not null and type <> 'BASELINE' order by installed_rank")
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		var ims []ImportedMigration
+		for rows.Next() {
+			var (
+				im          ImportedMigration
+				version     string
+				description string
+			)
+			if err := rows.Scan(&version, &description, &im.AppliedAt); err != nil {
+				return nil, err
+			}
+			if err := im.ID.Set(version); err != nil {
+				return nil, fmt.Errorf("%w: %s", ErrUnsupportedID, version)
+			}
+			im.Slug = Slugify(description)
+			ims = append(ims, im)
+		}
+		return ims, rows.Err()
+
+	case ImportRails:
+		rows, err := db.QueryContext(ctx, "select version from "+quoted)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		var ims []ImportedMigration
+		for rows.Next() {
+			var version string
+			if err := rows.Scan(&version); err != nil {
+				return nil, err
+			}
+			var im ImportedMigration
+			if err := im.ID.Set(version); err != nil {
+				return nil, fmt.Errorf("%w: %s", ErrUnsupportedID, version)
+			}
+			ims = append(ims, im)
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		sort.Slice(ims, func(i, j int) bool { return ims[i].ID < ims[j].ID })
+		return ims, nil
+	}
+	return nil, fmt.Errorf("unknown import source: %q", source)
+}
+
+// A ConvertedFile is one of the other tool's migration files rewritten in
+// Drift's naming convention.
+type ConvertedFile struct {
+	From string
+	// To is empty if the file can't be converted, like a Go or Ruby
+	// migration.
+	To string
+}
+
+// ConvertFiles rewrites the other tool's migration files in migrationsDir with
+// Drift's naming convention. Down migrations go in the migration's companion
+// directory as down.sql, and goose files keep only their Up section. If write
+// is false, this only returns the conversions it would make.
+//
+// Files Drift can't run (like Go or Ruby migrations) are listed with an empty
+// To, and left alone.
+func (m *Migrator) ConvertFiles(source ImportSource, migrationsDir string, write bool) ([]ConvertedFile, error) {
+	files, err := sourceFiles(m.io, m.files, source, migrationsDir)
+	if err != nil {
+		return nil, err
+	}
+	var ids []MigrationID
+	for _, f := range files {
+		ids = append(ids, f.id)
+	}
+	width := IDWidth(ids...)
+
+	var cs []ConvertedFile
+	for _, f := range files {
+		c := ConvertedFile{From: f.path}
+		if f.convertible {
+			name := Filename(width, f.id, f.slug)
+			if f.down {
+				c.To = filepath.Join(migrationsDir, strings.TrimSuffix(name, ".sql"), "down.sql")
+			} else {
+				c.To = filepath.Join(migrationsDir, name)
+			}
+		}
+		cs = append(cs, c)
+		if !write || c.To == "" {
+			continue
+		}
+
+		b, err := m.files.ReadFile(f.path)
+		if err != nil {
+			return nil, err
+		}
+		content := string(b)
+		if source == ImportGoose {
+			content = gooseUp(content)
+		}
+		if err := m.files.MkdirAll(filepath.Dir(c.To), 0o755); err != nil {
+			return nil, err
+		}
+		if err := m.files.CreateFile(c.To, []byte(content), 0o644); err != nil {
+			return nil, err
+		}
+		if err := m.files.Remove(f.path); err != nil {
+			return nil, err
+		}
+		m.io.Debugf("Converted %s to %s", f.path, c.To)
+	}
+	return cs, nil
+}
+
+// reGooseAnnotation finds goose's -- +goose annotations.
+var reGooseAnnotation = regexp.MustCompile(`(?im)^--\s*\+goose\s+(\w+)(.*)$`)
+
+// gooseUp returns the Up section of a goose migration, without the
+// annotations. A NO TRANSACTION annotation becomes a comment pointing at
+// Drift's directive, since Drift's no-transaction migrations have to claim
+// themselves.
+func gooseUp(content string) string {
+	var b strings.Builder
+	up := true
+	last := 0
+	for _, loc := range reGooseAnnotation.FindAllStringSubmatchIndex(content, -1) {
+		if up {
+			b.WriteString(content[last:loc[0]])
+		}
+		last = loc[1]
+		switch strings.ToLower(content[loc[2]:loc[3]]) {
+		case "up":
+			up = true
+		case "down":
+			up = false
+		case "no":
+			b.WriteString("-- TODO: goose ran this without a transaction. See --drift:no-transaction.")
+		}
+	}
+	if up {
+		b.WriteString(content[last:])
+	}
+	return strings.TrimSpace(b.String()) + "\n"
+}
@@ -0,0 +1,108 @@
+package drift
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/metagram-net/drift/internal/memfs"
+)
+
+func TestConvertFiles(t *testing.T) {
+	tests := map[ImportSource]struct {
+		files map[string]string
+		want  map[string]string
+	}{
+		ImportGoose: {
+			files: map[string]string{
+				"db/00001_create_users.sql": "-- +goose Up\ncreate table users ();\n\n-- +goose Down\ndrop table users;\n",
+				"db/00002_backfill.go":      "package migrations",
+				"db/00010_add index.sql":    "-- +goose NO TRANSACTION\n-- +goose Up\ncreate index concurrently users_name on users (name);\n-- +goose Down\ndrop index users_name;\n",
+				"db/README.md":              "not a migration",
+			},
+			want: map[string]string{
+				"db/01-create_users.sql": "create table users ();\n",
+				"db/00002_backfill.go":   "package migrations",
+				"db/10-add_index.sql":    "-- TODO: goose ran this without a transaction. See --drift:no-transaction.\n\ncreate index concurrently users_name on users (name);\n",
+				"db/README.md":           "not a migration",
+			},
+		},
+		ImportGolangMigrate: {
+			files: map[string]string{
+				"db/1_create_users.up.sql":   "create table users ();",
+				"db/1_create_users.down.sql": "drop table users;",
+				"db/2_add_teams.up.sql":      "create table teams ();",
+			},
+			want: map[string]string{
+				"db/1-create_users.sql":      "create table users ();",
+				"db/1-create_users/down.sql": "drop table users;",
+				"db/2-add_teams.sql":         "create table teams ();",
+			},
+		},
+		ImportFlyway: {
+			files: map[string]string{
+				"db/V1__Create_users.sql": "create table users ();",
+				"db/U1__Create_users.sql": "drop table users;",
+				"db/V2__add.teams.sql":    "create table teams ();",
+				"db/R__refresh_view.sql":  "create or replace view v as select 1;",
+			},
+			want: map[string]string{
+				"db/1-Create_users.sql":      "create table users ();",
+				"db/1-Create_users/down.sql": "drop table users;",
+				"db/2-add_teams.sql":         "create table teams ();",
+				"db/R__refresh_view.sql":     "create or replace view v as select 1;",
+			},
+		},
+		ImportRails: {
+			files: map[string]string{
+				"db/20240101000000_create_users.rb": "class CreateUsers < ActiveRecord::Migration[7.1]; end",
+			},
+			want: map[string]string{
+				"db/20240101000000_create_users.rb": "class CreateUsers < ActiveRecord::Migration[7.1]; end",
+			},
+		},
+	}
+	for source, tt := range tests {
+		t.Run(string(source), func(t *testing.T) {
+			fsys := memfs.New(tt.files)
+			m := New(WithFileSystem(fsys))
+
+			if _, err := m.ConvertFiles(source, "db", false); err != nil {
+				t.Fatal(err)
+			}
+			if got := fsys.Files(); !reflect.DeepEqual(got, tt.files) {
+				t.Fatalf("ConvertFiles changed files without write: %v", got)
+			}
+
+			if _, err := m.ConvertFiles(source, "db", true); err != nil {
+				t.Fatal(err)
+			}
+			if got := fsys.Files(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got files %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSourceFiles(t *testing.T) {
+	fsys := memfs.New(map[string]string{
+		"db/20240101000000_create_users.rb": "",
+		"db/20230101000000_add-teams.rb":    "",
+	})
+	files, err := sourceFiles(nopIO{}, fsys, ImportRails, "db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []sourceFile{
+		{path: "db/20230101000000_add-teams.rb", id: 20230101000000, slug: "add_teams"},
+		{path: "db/20240101000000_create_users.rb", id: 20240101000000, slug: "create_users"},
+	}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("got %+v, want %+v", files, want)
+	}
+
+	fsys = memfs.New(map[string]string{"db/99999999999999999999_too_big.sql": ""})
+	if _, err := sourceFiles(nopIO{}, fsys, ImportGoose, "db"); !errors.Is(err, ErrUnsupportedID) {
+		t.Errorf("got error %v, want %v", err, ErrUnsupportedID)
+	}
+}
@@ -0,0 +1,79 @@
+package dburl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+)
+
+var ErrNoGCPToken = errors.New("no Google Cloud access token from GOOGLE_OAUTH_ACCESS_TOKEN, gcloud, or the metadata server")
+
+// CloudSQLIAMToken returns an OAuth2 access token to use as the password for
+// Cloud SQL IAM database authentication. Like RDSIAMToken, use it with
+// WithPasswordFunc, since tokens expire.
+//
+// The token comes from GOOGLE_OAUTH_ACCESS_TOKEN, or else `gcloud auth
+// print-access-token`, or else the GCE/GKE metadata server. This doesn't dial
+// through the Cloud SQL connector (that needs its SDK), so connect through the
+// Cloud SQL Auth Proxy or over a private IP with sslmode=require.
+func CloudSQLIAMToken(ctx context.Context, _ *pgx.ConnConfig) (string, error) {
+	return GCPAccessToken(ctx)
+}
+
+// GCPAccessToken returns a Google Cloud OAuth2 access token from the same
+// places as CloudSQLIAMToken, for other Google Cloud requests.
+func GCPAccessToken(ctx context.Context) (string, error) {
+	if token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); token != "" {
+		return token, nil
+	}
+	if out, err := exec.CommandContext(ctx, "gcloud", "auth", "print-access-token").Output(); err == nil {
+		if token := strings.TrimSpace(string(out)); token != "" {
+			return token, nil
+		}
+	}
+	token, err := metadataToken(ctx)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrNoGCPToken, err)
+	}
+	return token, nil
+}
+
+// metadataToken gets the default service account's token from the metadata
+// server.
+func metadataToken(ctx context.Context) (string, error) {
+	host := os.Getenv("GCE_METADATA_HOST")
+	if host == "" {
+		host = "metadata.google.internal"
+	}
+	url := "http://" + host + "/computeMetadata/v1/instance/service-accounts/default/token"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%w: metadata server: %s", ErrNoGCPToken, res.Status)
+	}
+	var body struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return "", err
+	}
+	if body.AccessToken == "" {
+		return "", ErrNoGCPToken
+	}
+	return body.AccessToken, nil
+}
@@ -0,0 +1,115 @@
+// Package dburl opens database connections from PostgreSQL connection strings.
+package dburl
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/stdlib" // database/sql driver: pgx
+)
+
+// An Option changes how connections are made, on top of the connection
+// string.
+type Option func(*config)
+
+type config struct {
+	conn *pgx.ConnConfig
+	open []stdlib.OptionOpenDB
+}
+
+// WithPassword overrides the password in the connection string.
+func WithPassword(password string) Option {
+	return func(c *config) {
+		c.conn.Password = password
+	}
+}
+
+// WithPasswordFunc gets a new password for every new connection, for
+// short-lived tokens.
+func WithPasswordFunc(password func(context.Context, *pgx.ConnConfig) (string, error)) Option {
+	return func(c *config) {
+		c.open = append(c.open, stdlib.OptionBeforeConnect(func(ctx context.Context, cfg *pgx.ConnConfig) error {
+			p, err := password(ctx, cfg)
+			if err != nil {
+				return err
+			}
+			cfg.Password = p
+			return nil
+		}))
+	}
+}
+
+// WithSimpleProtocol sends queries with the simple protocol instead of
+// preparing them, for connection poolers like PgBouncer in transaction pooling
+// mode.
+func WithSimpleProtocol() Option {
+	return func(c *config) {
+		c.conn.PreferSimpleProtocol = true
+	}
+}
+
+// WithSession sets session settings (like search_path, role, or lock_timeout)
+// on every new connection, as if by SET.
+func WithSession(settings map[string]string) Option {
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return func(c *config) {
+		c.open = append(c.open, stdlib.OptionAfterConnect(func(ctx context.Context, conn *pgx.Conn) error {
+			for _, name := range names {
+				if _, err := conn.Exec(ctx, "select set_config($1, $2, false)", name, settings[name]); err != nil {
+					return fmt.Errorf("could not set %s: %w", name, err)
+				}
+			}
+			return nil
+		}))
+	}
+}
+
+// Open opens a connection pool for the database URL.
+func Open(url string, opts ...Option) (*sql.DB, error) {
+	cfg, err := pgx.ParseConfig(pgURL(url))
+	if err != nil {
+		return nil, err
+	}
+	return open(cfg, opts), nil
+}
+
+// OpenDatabase opens a connection pool for a different database on the same
+// server as the URL, using the same connection settings otherwise.
+func OpenDatabase(url, name string, opts ...Option) (*sql.DB, error) {
+	cfg, err := pgx.ParseConfig(pgURL(url))
+	if err != nil {
+		return nil, err
+	}
+	cfg.Database = name
+	return open(cfg, opts), nil
+}
+
+// cockroachSchemes are the URL schemes for CockroachDB, which speaks the
+// PostgreSQL protocol.
+var cockroachSchemes = []string{"cockroachdb://", "crdb://"}
+
+// pgURL replaces a CockroachDB URL scheme with one that pgx understands.
+func pgURL(url string) string {
+	for _, s := range cockroachSchemes {
+		if rest, ok := strings.CutPrefix(url, s); ok {
+			return "postgres://" + rest
+		}
+	}
+	return url
+}
+
+func open(cfg *pgx.ConnConfig, opts []Option) *sql.DB {
+	c := &config{conn: cfg}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return stdlib.OpenDB(*c.conn, c.open...)
+}
@@ -0,0 +1,126 @@
+package dburl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+
+	"github.com/metagram-net/drift/internal/sigv4"
+)
+
+var (
+	ErrNoAWSCredentials = errors.New("no AWS credentials in AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY or from the aws CLI")
+	ErrNoAWSRegion      = errors.New("no AWS region in AWS_REGION or the RDS hostname")
+)
+
+type awsCredentials struct {
+	AccessKeyID     string `json:"AccessKeyId"`     //nolint:tagliatelle
+	SecretAccessKey string `json:"SecretAccessKey"` //nolint:tagliatelle
+	SessionToken    string `json:"SessionToken"`    //nolint:tagliatelle
+}
+
+// RDSIAMToken generates an RDS IAM authentication token to use as the
+// password for the connection. Tokens are valid for 15 minutes, so use it with
+// WithPasswordFunc to get a new one for every connection.
+//
+// This signs the token itself (with AWS Signature Version 4) rather than
+// pulling in the AWS SDK. Credentials come from the standard environment
+// variables, or else from `aws configure export-credentials`, which covers
+// profiles, SSO, and instance roles.
+func RDSIAMToken(ctx context.Context, cfg *pgx.ConnConfig) (string, error) {
+	creds, err := awsCreds(ctx)
+	if err != nil {
+		return "", err
+	}
+	region := awsRegion(cfg.Host)
+	if region == "" {
+		return "", ErrNoAWSRegion
+	}
+	return rdsToken(creds, region, fmt.Sprintf("%s:%d", cfg.Host, cfg.Port), cfg.User, time.Now().UTC()), nil
+}
+
+// AWSCredentials returns the AWS credentials RDSIAMToken would use, for
+// signing other AWS requests.
+func AWSCredentials(ctx context.Context) (accessKeyID, secretAccessKey, sessionToken string, err error) {
+	creds, err := awsCreds(ctx)
+	return creds.AccessKeyID, creds.SecretAccessKey, creds.SessionToken, err
+}
+
+func awsCreds(ctx context.Context) (awsCredentials, error) {
+	creds := awsCredentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if creds.AccessKeyID != "" && creds.SecretAccessKey != "" {
+		return creds, nil
+	}
+
+	out, err := exec.CommandContext(ctx, "aws", "configure", "export-credentials", "--format", "process").Output()
+	if err != nil {
+		return creds, fmt.Errorf("%w: %s", ErrNoAWSCredentials, err)
+	}
+	if err := json.Unmarshal(out, &creds); err != nil {
+		return creds, fmt.Errorf("%w: %s", ErrNoAWSCredentials, err)
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return creds, ErrNoAWSCredentials
+	}
+	return creds, nil
+}
+
+// awsRegion returns the configured region, or the one in an RDS endpoint like
+// mydb.abc123.us-east-1.rds.amazonaws.com.
+func awsRegion(host string) string {
+	for _, env := range []string{"AWS_REGION", "AWS_DEFAULT_REGION"} {
+		if r := os.Getenv(env); r != "" {
+			return r
+		}
+	}
+	parts := strings.Split(host, ".")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] == "rds" {
+			return parts[i-1]
+		}
+	}
+	return ""
+}
+
+// rdsToken presigns an rds-db:connect request, which is what an IAM
+// authentication token is.
+func rdsToken(creds awsCredentials, region, endpoint, user string, now time.Time) string {
+	const service = "rds-db"
+	query := url.Values{
+		"Action":              {"connect"},
+		"DBUser":              {user},
+		"X-Amz-Algorithm":     {sigv4.Algorithm},
+		"X-Amz-Credential":    {creds.AccessKeyID + "/" + sigv4.Scope(now, region, service)},
+		"X-Amz-Date":          {now.Format(sigv4.TimeFormat)},
+		"X-Amz-Expires":       {"900"},
+		"X-Amz-SignedHeaders": {"host"},
+	}
+	if creds.SessionToken != "" {
+		query.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+	canonicalQuery := sigv4.Query(query)
+
+	canonicalRequest := strings.Join([]string{
+		"GET",
+		"/",
+		canonicalQuery,
+		"host:" + endpoint + "\n",
+		"host",
+		sigv4.Hash(""),
+	}, "\n")
+	signature := sigv4.Sign(creds.SecretAccessKey, now, region, service, canonicalRequest)
+
+	return endpoint + "/?" + canonicalQuery + "&X-Amz-Signature=" + signature
+}
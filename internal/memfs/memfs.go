@@ -0,0 +1,248 @@
+// Package memfs is an in-memory drift.FileSystem. The drifttest package
+// exports it for tests; it lives here so that the drift package's own tests
+// can use it without the drift binary linking testing/fstest.
+package memfs
+
+import (
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing/fstest"
+	"time"
+)
+
+// FileSystem is an in-memory drift.FileSystem, for fast tests that don't
+// touch the disk. Paths are cleaned and can use either separator, and a leading
+// separator is ignored, so "/migrations" and "migrations" are the same
+// directory. It's safe for concurrent use.
+type FileSystem struct {
+	mu    sync.Mutex
+	files fstest.MapFS
+	temps int
+}
+
+// New returns a FileSystem with the files, keyed by path. Their parent
+// directories exist implicitly.
+func New(files map[string]string) *FileSystem {
+	m := &FileSystem{files: make(fstest.MapFS)}
+	for name, content := range files {
+		m.files[memPath(name)] = &fstest.MapFile{Data: []byte(content), Mode: 0o644}
+	}
+	return m
+}
+
+// Files returns the content of every file, keyed by slash-separated path.
+func (m *FileSystem) Files() map[string]string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make(map[string]string)
+	for name, f := range m.files {
+		if !f.Mode.IsDir() {
+			out[name] = string(f.Data)
+		}
+	}
+	return out
+}
+
+// memPath converts a drift.FileSystem path to a MapFS key.
+func memPath(name string) string {
+	p := path.Clean(filepath.ToSlash(name))
+	p = strings.TrimLeft(p, "/")
+	if p == "" {
+		return "."
+	}
+	return p
+}
+
+// snapshot copies the files, so that reads through an fs.FS don't race with
+// writes.
+func (m *FileSystem) snapshot() fstest.MapFS {
+	fsys := make(fstest.MapFS, len(m.files))
+	for name, f := range m.files {
+		c := *f
+		fsys[name] = &c
+	}
+	return fsys
+}
+
+// isDir reports whether the directory exists, explicitly or as the parent of
+// another file.
+func (m *FileSystem) isDir(p string) bool {
+	info, err := fs.Stat(m.files, p)
+	return err == nil && info.IsDir()
+}
+
+// DirFS returns a snapshot of the directory. Later changes don't show up in
+// it.
+func (m *FileSystem) DirFS(dir string) fs.FS {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sub, err := fs.Sub(m.snapshot(), memPath(dir))
+	if err != nil {
+		// Only invalid paths fail, and memPath cleans those up.
+		panic(err)
+	}
+	return sub
+}
+
+func (m *FileSystem) ReadDir(name string) ([]fs.DirEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return fs.ReadDir(m.snapshot(), memPath(name))
+}
+
+func (m *FileSystem) ReadFile(name string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return fs.ReadFile(m.files, memPath(name))
+}
+
+func (m *FileSystem) Stat(name string) (fs.FileInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return fs.Stat(m.files, memPath(name))
+}
+
+func (m *FileSystem) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.write("open", memPath(name), data, perm, false)
+}
+
+func (m *FileSystem) CreateFile(name string, data []byte, perm fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.write("open", memPath(name), data, perm, true)
+}
+
+// write sets the file's content, like os.WriteFile. With excl, it fails if the
+// file already exists.
+func (m *FileSystem) write(op, p string, data []byte, perm fs.FileMode, excl bool) error {
+	if !m.isDir(path.Dir(p)) {
+		return &fs.PathError{Op: op, Path: p, Err: fs.ErrNotExist}
+	}
+	if info, err := fs.Stat(m.files, p); err == nil {
+		if excl {
+			return &fs.PathError{Op: op, Path: p, Err: fs.ErrExist}
+		}
+		if info.IsDir() {
+			return &fs.PathError{Op: op, Path: p, Err: fs.ErrInvalid}
+		}
+	}
+	m.files[p] = &fstest.MapFile{
+		Data:    append([]byte(nil), data...),
+		Mode:    perm,
+		ModTime: time.Now(),
+	}
+	return nil
+}
+
+func (m *FileSystem) CreateTemp(dir, pattern string, data []byte) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	prefix, suffix := pattern, ""
+	if i := strings.LastIndex(pattern, "*"); i >= 0 {
+		prefix, suffix = pattern[:i], pattern[i+1:]
+	}
+	for {
+		m.temps++
+		name := filepath.Join(dir, prefix+strconv.Itoa(m.temps)+suffix)
+		err := m.write("createtemp", memPath(name), data, 0o600, true)
+		if pe, ok := err.(*fs.PathError); ok && pe.Err == fs.ErrExist {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return name, nil
+	}
+}
+
+func (m *FileSystem) MkdirAll(dir string, perm fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p := memPath(dir)
+	if info, err := fs.Stat(m.files, p); err == nil {
+		if !info.IsDir() {
+			return &fs.PathError{Op: "mkdir", Path: p, Err: fs.ErrExist}
+		}
+		return nil
+	}
+	// Walk up to the nearest existing ancestor to check it isn't a file.
+	for parent := path.Dir(p); parent != "."; parent = path.Dir(parent) {
+		if info, err := fs.Stat(m.files, parent); err == nil {
+			if !info.IsDir() {
+				return &fs.PathError{Op: "mkdir", Path: parent, Err: fs.ErrExist}
+			}
+			break
+		}
+	}
+	m.files[p] = &fstest.MapFile{Mode: fs.ModeDir | perm, ModTime: time.Now()}
+	return nil
+}
+
+// Rename moves a file, or a directory with everything in it.
+func (m *FileSystem) Rename(oldpath, newpath string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	from, to := memPath(oldpath), memPath(newpath)
+	info, err := fs.Stat(m.files, from)
+	if err != nil {
+		return &fs.PathError{Op: "rename", Path: from, Err: fs.ErrNotExist}
+	}
+	if !m.isDir(path.Dir(to)) {
+		return &fs.PathError{Op: "rename", Path: to, Err: fs.ErrNotExist}
+	}
+	if !info.IsDir() {
+		m.files[to] = m.files[from]
+		delete(m.files, from)
+		return nil
+	}
+	if m.isDir(to) && len(m.children(to)) > 0 {
+		return &fs.PathError{Op: "rename", Path: to, Err: fs.ErrExist}
+	}
+	for _, name := range m.children(from) {
+		m.files[to+strings.TrimPrefix(name, from)] = m.files[name]
+		delete(m.files, name)
+	}
+	// The directory itself may only exist implicitly.
+	if f, ok := m.files[from]; ok {
+		m.files[to] = f
+		delete(m.files, from)
+	} else {
+		m.files[to] = &fstest.MapFile{Mode: fs.ModeDir | 0o755, ModTime: time.Now()}
+	}
+	return nil
+}
+
+// children returns the paths of everything under the directory.
+func (m *FileSystem) children(dir string) []string {
+	var names []string
+	for name := range m.files {
+		if strings.HasPrefix(name, dir+"/") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Remove removes a file or an empty directory.
+func (m *FileSystem) Remove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p := memPath(name)
+	info, err := fs.Stat(m.files, p)
+	if err != nil {
+		return &fs.PathError{Op: "remove", Path: p, Err: fs.ErrNotExist}
+	}
+	if info.IsDir() && len(m.children(p)) > 0 {
+		return &fs.PathError{Op: "remove", Path: p, Err: fs.ErrExist}
+	}
+	delete(m.files, p)
+	return nil
+}
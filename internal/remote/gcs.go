@@ -0,0 +1,118 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5" //#nosec G501 // Cloud Storage reports MD5 checksums.
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+
+	"github.com/metagram-net/drift/internal/dburl"
+)
+
+// gcsBucket reads a Google Cloud Storage bucket through the JSON API, with
+// the same access token as Cloud SQL IAM authentication.
+type gcsBucket struct {
+	name string
+}
+
+type gcsObject struct {
+	Name    string `json:"name"`
+	MD5Hash string `json:"md5Hash"`
+}
+
+type gcsListResult struct {
+	Items         []gcsObject `json:"items"`
+	NextPageToken string      `json:"nextPageToken"`
+}
+
+const gcsAPI = "https://storage.googleapis.com/storage/v1/b/"
+
+func (b gcsBucket) list(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	token := ""
+	for {
+		q := url.Values{"prefix": {prefix}, "fields": {"items(name),nextPageToken"}}
+		if token != "" {
+			q.Set("pageToken", token)
+		}
+		req, err := b.request(ctx, gcsAPI+url.PathEscape(b.name)+"/o?"+q.Encode())
+		if err != nil {
+			return nil, err
+		}
+		data, _, err := download(http.DefaultClient, req)
+		if err != nil {
+			return nil, err
+		}
+		var res gcsListResult
+		if err := json.Unmarshal(data, &res); err != nil {
+			return nil, fmt.Errorf("could not read the Cloud Storage object list: %w", err)
+		}
+		for _, obj := range res.Items {
+			keys = append(keys, obj.Name)
+		}
+		if res.NextPageToken == "" {
+			sort.Strings(keys)
+			return keys, nil
+		}
+		token = res.NextPageToken
+	}
+}
+
+func (b gcsBucket) get(ctx context.Context, key string) ([]byte, func([]byte) error, error) {
+	object := gcsAPI + url.PathEscape(b.name) + "/o/" + url.PathEscape(key)
+	req, err := b.request(ctx, object+"?fields=md5Hash")
+	if err != nil {
+		return nil, nil, err
+	}
+	meta, _, err := download(http.DefaultClient, req)
+	if err != nil {
+		return nil, nil, err
+	}
+	var obj gcsObject
+	if err := json.Unmarshal(meta, &obj); err != nil {
+		return nil, nil, fmt.Errorf("could not read the Cloud Storage object metadata: %w", err)
+	}
+
+	req, err = b.request(ctx, object+"?alt=media")
+	if err != nil {
+		return nil, nil, err
+	}
+	data, _, err := download(http.DefaultClient, req)
+	if err != nil {
+		return nil, nil, err
+	}
+	check := func(data []byte) error {
+		// Composite objects have no MD5 hash.
+		if obj.MD5Hash == "" {
+			return nil
+		}
+		want, err := base64.StdEncoding.DecodeString(obj.MD5Hash)
+		if err != nil {
+			return err
+		}
+		sum := md5.Sum(data) //#nosec G401 // Only checks for corruption.
+		if !bytes.Equal(sum[:], want) {
+			return fmt.Errorf("%w: want MD5 %x, got %x", ErrChecksumMismatch, want, sum)
+		}
+		return nil
+	}
+	return data, check, nil
+}
+
+func (b gcsBucket) request(ctx context.Context, u string) (*http.Request, error) {
+	token, err := dburl.GCPAccessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req, nil
+}
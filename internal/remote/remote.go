@@ -0,0 +1,283 @@
+// Package remote fetches migrations from object storage and web servers into
+// a local directory.
+package remote
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/metagram-net/drift"
+)
+
+var (
+	ErrUnsupportedScheme = errors.New("unsupported migrations source")
+	ErrChecksumMismatch  = drift.ErrChecksumMismatch
+	ErrUnsafePath        = errors.New("file path leaves the migrations directory")
+	ErrNoFiles           = errors.New("no files found")
+)
+
+// maxSize limits how much is downloaded for one source.
+const maxSize = 1 << 30
+
+// IsRemote reports whether dir is a URL rather than a local directory.
+func IsRemote(dir string) bool {
+	for _, scheme := range []string{"s3://", "gs://", "https://", "http://"} {
+		if strings.HasPrefix(dir, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// Fetch downloads the migrations at the URL into dest, replacing anything
+// already there. The URL is one of:
+//
+//	https://example.com/migrations.zip
+//	s3://bucket/prefix/
+//	gs://bucket/prefix/
+//
+// An HTTPS URL is a .zip, .tar.gz, .tgz, or .tar archive, which is unpacked.
+// An s3:// or gs:// URL is either an archive object or a prefix, and every
+// object under the prefix is downloaded.
+//
+// Add #sha256=<hex> to an archive URL to check the archive's checksum before
+// unpacking it. Objects under a prefix are checked against the checksums the
+// storage service reports for them.
+func Fetch(ctx context.Context, rawURL, dest string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	want, err := fragmentChecksum(u.Fragment)
+	if err != nil {
+		return err
+	}
+	u.Fragment = ""
+
+	var b bucket
+	switch u.Scheme {
+	case "https":
+		if !isArchive(u.Path) {
+			return fmt.Errorf("%w: %s is not a .zip, .tar.gz, .tgz, or .tar file", ErrUnsupportedScheme, u.Redacted())
+		}
+	case "s3":
+		b = s3Bucket{name: u.Host}
+	case "gs":
+		b = gcsBucket{name: u.Host}
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedScheme, u.Redacted())
+	}
+
+	if err := os.RemoveAll(dest); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dest, 0o755); err != nil {
+		return err
+	}
+
+	key := strings.TrimPrefix(u.Path, "/")
+	if b == nil || isArchive(key) {
+		var data []byte
+		if b == nil {
+			data, _, err = download(http.DefaultClient, mustRequest(ctx, u.String()))
+		} else {
+			data, _, err = b.get(ctx, key)
+		}
+		if err != nil {
+			return err
+		}
+		if want != "" {
+			if got := sha256Hex(data); got != want {
+				return fmt.Errorf("%w: %s (want sha256 %s, got %s)", ErrChecksumMismatch, u.Redacted(), want, got)
+			}
+		}
+		return unpack(key, data, dest)
+	}
+
+	if want != "" {
+		return fmt.Errorf("%w: #sha256 only applies to archives, not %s", ErrUnsupportedScheme, u.Redacted())
+	}
+	if key != "" && !strings.HasSuffix(key, "/") {
+		key += "/"
+	}
+	objects, err := b.list(ctx, key)
+	if err != nil {
+		return err
+	}
+	if len(objects) == 0 {
+		return fmt.Errorf("%w: %s", ErrNoFiles, u.Redacted())
+	}
+	for _, obj := range objects {
+		name := strings.TrimPrefix(obj, key)
+		if name == "" || strings.HasSuffix(name, "/") {
+			// A "directory" placeholder object.
+			continue
+		}
+		data, check, err := b.get(ctx, obj)
+		if err != nil {
+			return err
+		}
+		if err := check(data); err != nil {
+			return fmt.Errorf("%s: %w", obj, err)
+		}
+		if err := writeFile(dest, name, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// A bucket is an object storage service.
+type bucket interface {
+	// list returns the keys of the objects under the prefix.
+	list(ctx context.Context, prefix string) ([]string, error)
+	// get downloads an object and returns a function that checks its content
+	// against the checksum the service reported.
+	get(ctx context.Context, key string) ([]byte, func([]byte) error, error)
+}
+
+func fragmentChecksum(fragment string) (string, error) {
+	if fragment == "" {
+		return "", nil
+	}
+	v, err := url.ParseQuery(fragment)
+	if err != nil {
+		return "", err
+	}
+	sum := strings.ToLower(v.Get("sha256"))
+	if len(sum) != sha256.Size*2 {
+		return "", fmt.Errorf("%w: #sha256 must be %d hex digits", ErrChecksumMismatch, sha256.Size*2)
+	}
+	return sum, nil
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func isArchive(name string) bool {
+	for _, ext := range []string{".zip", ".tar.gz", ".tgz", ".tar"} {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+func mustRequest(ctx context.Context, u string) *http.Request {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		// The URL was already parsed.
+		panic(err)
+	}
+	return req
+}
+
+// download sends the request and returns the response body and headers.
+func download(client *http.Client, req *http.Request) ([]byte, http.Header, error) {
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return nil, nil, fmt.Errorf("GET %s: %s: %s", req.URL.Redacted(), res.Status, strings.TrimSpace(string(body)))
+	}
+	data, err := io.ReadAll(io.LimitReader(res.Body, maxSize+1))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(data) > maxSize {
+		return nil, nil, fmt.Errorf("GET %s: larger than %d bytes", req.URL.Redacted(), maxSize)
+	}
+	return data, res.Header, nil
+}
+
+// unpack extracts the archive into dest.
+func unpack(name string, data []byte, dest string) error {
+	if strings.HasSuffix(name, ".zip") {
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return err
+		}
+		for _, f := range zr.File {
+			if f.FileInfo().IsDir() {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return err
+			}
+			b, err := io.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				return err
+			}
+			if err := writeFile(dest, f.Name, b); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var r io.Reader = bytes.NewReader(data)
+	if !strings.HasSuffix(name, ".tar") {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	tr := tar.NewReader(r)
+	for {
+		h, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if h.Typeflag != tar.TypeReg {
+			continue
+		}
+		b, err := io.ReadAll(tr)
+		if err != nil {
+			return err
+		}
+		if err := writeFile(dest, h.Name, b); err != nil {
+			return err
+		}
+	}
+}
+
+// writeFile writes the file at the slash-separated name under dest, refusing
+// names that would land outside of it.
+func writeFile(dest, name string, data []byte) error {
+	clean := path.Clean("/" + name)
+	if clean == "/" || clean != "/"+strings.TrimPrefix(name, "./") {
+		return fmt.Errorf("%w: %s", ErrUnsafePath, name)
+	}
+	p := filepath.Join(dest, filepath.FromSlash(clean))
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	//#nosec G306 // Normal permissions for non-sensitive files.
+	return os.WriteFile(p, data, 0o644)
+}
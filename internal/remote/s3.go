@@ -0,0 +1,164 @@
+package remote
+
+import (
+	"context"
+	"crypto/md5" //#nosec G501 // S3 reports MD5 checksums (as ETags).
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/metagram-net/drift/internal/dburl"
+	"github.com/metagram-net/drift/internal/sigv4"
+)
+
+// s3Bucket reads an S3 bucket, signing requests with the same credentials as
+// RDS IAM authentication. Set AWS_ENDPOINT_URL_S3 (or AWS_ENDPOINT_URL) to
+// use another S3-compatible service.
+type s3Bucket struct {
+	name string
+}
+
+type s3ListResult struct {
+	Contents []struct {
+		Key  string `xml:"Key"`
+		ETag string `xml:"ETag"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+func (b s3Bucket) list(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	token := ""
+	for {
+		q := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+		req, err := b.request(ctx, "", q)
+		if err != nil {
+			return nil, err
+		}
+		data, _, err := download(http.DefaultClient, req)
+		if err != nil {
+			return nil, err
+		}
+		var res s3ListResult
+		if err := xml.Unmarshal(data, &res); err != nil {
+			return nil, fmt.Errorf("could not read the S3 object list: %w", err)
+		}
+		for _, c := range res.Contents {
+			keys = append(keys, c.Key)
+		}
+		if !res.IsTruncated {
+			sort.Strings(keys)
+			return keys, nil
+		}
+		token = res.NextContinuationToken
+	}
+}
+
+func (b s3Bucket) get(ctx context.Context, key string) ([]byte, func([]byte) error, error) {
+	req, err := b.request(ctx, key, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, header, err := download(http.DefaultClient, req)
+	if err != nil {
+		return nil, nil, err
+	}
+	etag := strings.Trim(header.Get("ETag"), `"`)
+	check := func(data []byte) error {
+		// Multipart uploads have ETags like <hash>-<parts>, which aren't
+		// the MD5 of the content.
+		if len(etag) != md5.Size*2 {
+			return nil
+		}
+		sum := md5.Sum(data) //#nosec G401 // Only checks for corruption.
+		if got := hex.EncodeToString(sum[:]); got != etag {
+			return fmt.Errorf("%w: want MD5 %s, got %s", ErrChecksumMismatch, etag, got)
+		}
+		return nil
+	}
+	return data, check, nil
+}
+
+// request builds a signed GET request for the key (or the bucket, if the key
+// is empty).
+func (b s3Bucket) request(ctx context.Context, key string, query url.Values) (*http.Request, error) {
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	u := &url.URL{Scheme: "https", Host: b.name + ".s3." + region + ".amazonaws.com", Path: "/" + key}
+	endpoint := os.Getenv("AWS_ENDPOINT_URL_S3")
+	if endpoint == "" {
+		endpoint = os.Getenv("AWS_ENDPOINT_URL")
+	}
+	if endpoint != "" {
+		// Custom endpoints usually only support path-style URLs.
+		e, err := url.Parse(endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("invalid S3 endpoint: %w", err)
+		}
+		u = e.JoinPath(b.name, key)
+	}
+	u.RawQuery = sigv4.Query(query)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	id, secret, session, err := dburl.AWSCredentials(ctx)
+	if err != nil {
+		return nil, err
+	}
+	signS3(req, id, secret, session, region, time.Now().UTC())
+	return req, nil
+}
+
+// signS3 adds an AWS Signature Version 4 Authorization header to the GET
+// request.
+func signS3(req *http.Request, id, secret, session, region string, now time.Time) {
+	const service = "s3"
+	amzDate := now.Format(sigv4.TimeFormat)
+	payloadHash := sigv4.Hash("")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	headers := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	values := map[string]string{"host": req.URL.Host, "x-amz-content-sha256": payloadHash, "x-amz-date": amzDate}
+	if session != "" {
+		req.Header.Set("X-Amz-Security-Token", session)
+		headers = append(headers, "x-amz-security-token")
+		values["x-amz-security-token"] = session
+	}
+	var canonicalHeaders strings.Builder
+	for _, h := range headers {
+		canonicalHeaders.WriteString(h + ":" + values[h] + "\n")
+	}
+	signedHeaders := strings.Join(headers, ";")
+
+	canonicalRequest := strings.Join([]string{
+		http.MethodGet,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	signature := sigv4.Sign(secret, now, region, service, canonicalRequest)
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigv4.Algorithm, id, sigv4.Scope(now, region, service), signedHeaders, signature))
+}
@@ -0,0 +1,122 @@
+package drift
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/blockloop/scan"
+)
+
+var (
+	ErrNoDatabase   = errors.New("template function needs a database connection")
+	ErrUnknownTable = errors.New("table does not exist")
+)
+
+// A Column describes one column of a table in the database.
+type Column struct {
+	Name     string `db:"name"`
+	Type     string `db:"type"`
+	Nullable bool   `db:"nullable"`
+	// Default is the column's default expression, or empty if it has none.
+	Default string `db:"default"`
+}
+
+// TemplateFuncs returns the helpers available to migration templates:
+//
+//   - tables: the names of the tables in the current search path
+//   - columns "name": the columns of the named (optionally schema-qualified) table
+//   - upper "s": s in upper case
+//   - snake "s": s in snake_case, like "CreateUsers" to "create_users"
+//   - now: the current time
+//   - env "NAME": the value of an environment variable
+//
+// If db is nil, the helpers are still defined (so templates using them can be
+// parsed) but they fail with ErrNoDatabase when called.
+func TemplateFuncs(ctx context.Context, db *sql.DB) template.FuncMap {
+	return template.FuncMap{
+		"tables": func() ([]string, error) {
+			if db == nil {
+				return nil, fmt.Errorf("%w: tables", ErrNoDatabase)
+			}
+			return tables(ctx, db)
+		},
+		"columns": func(table string) ([]Column, error) {
+			if db == nil {
+				return nil, fmt.Errorf("%w: columns", ErrNoDatabase)
+			}
+			return columns(ctx, db, table)
+		},
+		"upper": strings.ToUpper,
+		"snake": snake,
+		"now":   time.Now,
+		"env":   os.Getenv,
+	}
+}
+
+// reWordBoundary finds lower-to-upper case changes, like the "eU" in
+// "CreateUsers".
+var reWordBoundary = regexp.MustCompile(`([a-z0-9])([A-Z])`)
+
+// snake converts s to snake_case.
+func snake(s string) string {
+	s = reWordBoundary.ReplaceAllString(s, "${1}_${2}")
+	return strings.ToLower(Slugify(strings.TrimSpace(s)))
+}
+
+var qTables, _ = pq.
+	Select("c.relname").
+	From("pg_class c").
+	Join("pg_namespace n on n.oid = c.relnamespace").
+	Where("c.relkind in ('r', 'p')").
+	Where("n.nspname = any(current_schemas(false))").
+	OrderBy("c.relname").
+	MustSql()
+
+func tables(ctx context.Context, db *sql.DB) ([]string, error) {
+	rows, err := db.QueryContext(ctx, qTables)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	return names, scan.RowsStrict(&names, rows)
+}
+
+func columns(ctx context.Context, db *sql.DB, table string) ([]Column, error) {
+	query, args, err := pq.
+		Select(
+			"a.attname as name",
+			"format_type(a.atttypid, a.atttypmod) as type",
+			"not a.attnotnull as nullable",
+			`coalesce(pg_get_expr(d.adbin, d.adrelid), '') as "default"`,
+		).
+		From("pg_attribute a").
+		LeftJoin("pg_attrdef d on d.adrelid = a.attrelid and d.adnum = a.attnum").
+		Where(sq.Expr("a.attrelid = to_regclass(?)", table)).
+		Where("a.attnum > 0").
+		Where("not a.attisdropped").
+		OrderBy("a.attnum").
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	var cs []Column
+	if err := scan.RowsStrict(&cs, rows); err != nil {
+		return nil, err
+	}
+	if len(cs) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownTable, table)
+	}
+	return cs, nil
+}
@@ -0,0 +1,315 @@
+package drift
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var ErrLintFailed = errors.New("migration has lint errors")
+
+// A Severity is how serious a lint finding is. Only errors make WithLint
+// refuse to apply a migration.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// A LintRule is a dangerous pattern that Lint looks for.
+type LintRule struct {
+	ID          string
+	Severity    Severity
+	Description string
+
+	// pattern matches the problem within a single statement. A match isn't
+	// reported if the unless pattern also matches the statement, if proven
+	// returns true for it given the statements before it, or if allowed
+	// returns true for the file.
+	pattern *regexp.Regexp
+	unless  *regexp.Regexp
+	proven  func(s *lintState, st string) bool
+	allowed func(f migrationFile) bool
+}
+
+// LintRules returns the built-in rules with their default severities. Use a
+// LintConfig to disable rules or change their severities.
+func LintRules() []LintRule {
+	return append([]LintRule(nil), lintRules...)
+}
+
+var lintRules = []LintRule{
+	{
+		ID:          "index-concurrently",
+		Severity:    SeverityWarning,
+		Description: "create index without concurrently blocks writes to the table while it builds",
+		pattern:     regexp.MustCompile(`(?is)\bcreate\s+(?:unique\s+)?index\b`),
+		unless:      regexp.MustCompile(`(?is)\bindex\s+concurrently\b`),
+	},
+	{
+		ID:          "concurrently-in-transaction",
+		Severity:    SeverityError,
+		Description: "concurrent index changes can't run in a transaction, so the migration needs --drift:no-transaction",
+		pattern:     regexp.MustCompile(`(?is)\b(?:create|drop)\s+(?:unique\s+)?index\s+concurrently\b`),
+		allowed:     func(f migrationFile) bool { return f.directives.noTransaction || skipTx(f.Content) },
+	},
+	{
+		ID:          "set-not-null",
+		Severity:    SeverityWarning,
+		Description: "set not null scans the whole table under an exclusive lock unless a validated check constraint already proves it",
+		pattern:     reSetNotNull,
+		proven:      (*lintState).provesNotNull,
+	},
+	{
+		ID:          "column-type-change",
+		Severity:    SeverityWarning,
+		Description: "changing a column type usually rewrites the whole table under an exclusive lock",
+		pattern:     regexp.MustCompile(`(?is)\balter\s+column\s+\S+\s+(?:set\s+data\s+)?type\b`),
+	},
+	{
+		ID:          "drop-if-exists",
+		Severity:    SeverityWarning,
+		Description: "drop without if exists fails if the object is already gone, which makes the migration hard to re-run",
+		pattern:     regexp.MustCompile(`(?is)\bdrop\s+(?:table|index|view|materialized\s+view|schema|sequence|type|function|trigger)\b(?:\s+concurrently)?`),
+		unless:      regexp.MustCompile(`(?is)\bdrop\s+[a-z ]*?\bif\s+exists\b`),
+	},
+	{
+		ID:          "destructive",
+		Severity:    SeverityWarning,
+		Description: "the statement drops or truncates data",
+		pattern:     reDestructive,
+	},
+}
+
+var (
+	reAlterTable     = regexp.MustCompile(`(?is)\balter\s+table\s+(?:if\s+exists\s+)?(?:only\s+)?([^\s,;]+)`)
+	reSetNotNull     = regexp.MustCompile(`(?is)\balter\s+column\s+([^\s,;]+)\s+set\s+not\s+null\b`)
+	reAddConstraint  = regexp.MustCompile(`(?is)\badd\s+constraint\s+([^\s,;]+)\s+([^,;]*)`)
+	reNotNullCheck   = regexp.MustCompile(`(?is)^check\s*\(+\s*([^\s()]+)\s+is\s+not\s+null\s*\)+`)
+	reValidateConstr = regexp.MustCompile(`(?is)\bvalidate\s+constraint\s+([^\s,;]+)`)
+)
+
+// lintState is what a file's earlier statements tell the rules about the
+// later ones.
+type lintState struct {
+	// checks maps the constraints added in the file (as "table.constraint")
+	// to the column they check is not null, or "" if they check something
+	// else.
+	checks map[string]string
+	// notNull are the columns (as "table.column") that a validated check
+	// constraint proves not null.
+	notNull map[string]bool
+	// validated are the tables with a validated constraint that was added
+	// before the file, so it isn't known which column it checks.
+	validated map[string]bool
+}
+
+// observe records what the statement adds and validates.
+func (s *lintState) observe(st string) {
+	sm := reAlterTable.FindStringSubmatch(st)
+	if sm == nil {
+		return
+	}
+	if s.checks == nil {
+		s.checks = make(map[string]string)
+		s.notNull = make(map[string]bool)
+		s.validated = make(map[string]bool)
+	}
+	table := sqlName(sm[1])
+	for _, add := range reAddConstraint.FindAllStringSubmatch(st, -1) {
+		col := ""
+		if check := reNotNullCheck.FindStringSubmatch(strings.TrimSpace(add[2])); check != nil {
+			col = sqlName(check[1])
+		}
+		s.checks[table+"."+sqlName(add[1])] = col
+	}
+	for _, v := range reValidateConstr.FindAllStringSubmatch(st, -1) {
+		col, ok := s.checks[table+"."+sqlName(v[1])]
+		switch {
+		case !ok:
+			s.validated[table] = true
+		case col != "":
+			s.notNull[table+"."+col] = true
+		}
+	}
+}
+
+// provesNotNull reports whether every column the statement sets not null was
+// proven not null by a constraint the earlier statements validated on the
+// same table. If the file added the constraint too, it has to check that
+// column.
+func (s *lintState) provesNotNull(st string) bool {
+	sm := reAlterTable.FindStringSubmatch(st)
+	if sm == nil {
+		return false
+	}
+	table := sqlName(sm[1])
+	for _, col := range reSetNotNull.FindAllStringSubmatch(st, -1) {
+		if !s.notNull[table+"."+sqlName(col[1])] && !s.validated[table] {
+			return false
+		}
+	}
+	return true
+}
+
+// sqlName normalizes an identifier for comparison, ignoring quotes and case.
+func sqlName(s string) string {
+	return strings.ToLower(strings.ReplaceAll(s, `"`, ""))
+}
+
+// LintConfig adjusts the built-in rules.
+type LintConfig struct {
+	// Disabled lists the IDs of rules to skip.
+	Disabled []string
+	// Severity overrides the default severity of rules by ID.
+	Severity map[string]Severity
+}
+
+// A Finding is a lint rule match in a migration file.
+type Finding struct {
+	Rule     string
+	Severity Severity
+	Path     string
+	Line     int
+	Message  string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s:%d: %s: %s [%s]", f.Path, f.Line, f.Severity, f.Message, f.Rule)
+}
+
+// WithLint makes Migrate lint every planned file before applying any of them,
+// and refuse to run if there are any error findings.
+func WithLint(cfg LintConfig) Option {
+	return func(m *Migrator) {
+		m.lint = &cfg
+	}
+}
+
+// Lint checks every migration file in migrationsDir for dangerous patterns.
+// Findings are sorted by file and line.
+func (m *Migrator) Lint(migrationsDir string, cfg LintConfig) ([]Finding, error) {
+	files, err := m.available(migrationsDir)
+	if err != nil {
+		return nil, fmt.Errorf("could not get available migrations: %w", err)
+	}
+	var fs []Finding
+	for _, f := range files {
+		fs = append(fs, cfg.lint(f)...)
+	}
+	return fs, nil
+}
+
+// lintPlan lints the planned files and returns an error if any findings are
+// errors. Warnings are logged.
+func (m *Migrator) lintPlan(plan []migrationFile) error {
+	if m.lint == nil {
+		return nil
+	}
+	var errs int
+	for _, f := range plan {
+		for _, finding := range m.lint.lint(f) {
+			if finding.Severity == SeverityError {
+				errorf(m.io, "%s", finding)
+				errs++
+			} else {
+				warnf(m.io, "%s", finding)
+			}
+		}
+	}
+	if errs > 0 {
+		return fmt.Errorf("%w: %d errors", ErrLintFailed, errs)
+	}
+	return nil
+}
+
+// lint checks the file. Streamed files were checked as they were scanned, so
+// their findings only need the rules' allowed checks.
+func (c LintConfig) lint(f migrationFile) []Finding {
+	var found []Finding
+	if f.summary != nil {
+		found = f.summary.findings
+	} else {
+		found = c.find(f.Path, f.Content, 1, &lintState{})
+	}
+	allowed := make(map[string]bool)
+	for _, r := range lintRules {
+		allowed[r.ID] = r.allowed != nil && r.allowed(f)
+	}
+	var kept []Finding
+	for _, finding := range found {
+		if !allowed[finding.Rule] {
+			kept = append(kept, finding)
+		}
+	}
+	sort.SliceStable(kept, func(i, j int) bool { return kept[i].Line < kept[j].Line })
+	return kept
+}
+
+// find matches the enabled rules in the content, which starts at the line of
+// the file, without their allowed checks. The state carries what earlier
+// statements in the file proved, and is updated with the content's.
+func (c LintConfig) find(path, content string, line int, state *lintState) []Finding {
+	disabled := make(map[string]bool)
+	for _, id := range c.Disabled {
+		disabled[id] = true
+	}
+
+	var fs []Finding
+	for _, st := range statements(stripComments(content)) {
+		for _, r := range lintRules {
+			if disabled[r.ID] {
+				continue
+			}
+			loc := r.pattern.FindStringIndex(st.text)
+			if loc == nil || (r.unless != nil && r.unless.MatchString(st.text)) {
+				continue
+			}
+			if r.proven != nil && r.proven(state, st.text) {
+				continue
+			}
+			sev := r.Severity
+			if s, ok := c.Severity[r.ID]; ok {
+				sev = s
+			}
+			fs = append(fs, Finding{
+				Rule:     r.ID,
+				Severity: sev,
+				Path:     path,
+				Line:     line - 1 + st.line + strings.Count(st.text[:loc[0]], "\n"),
+				Message:  r.Description,
+			})
+		}
+		state.observe(st.text)
+	}
+	return fs
+}
+
+// stripComments blanks out SQL comments, keeping the line breaks so that line
+// numbers still match the file.
+func stripComments(content string) string {
+	return reSQLComment.ReplaceAllStringFunc(content, func(c string) string {
+		return strings.Repeat("\n", strings.Count(c, "\n"))
+	})
+}
+
+type statement struct {
+	text string
+	// line is the line number the statement text starts on.
+	line int
+}
+
+// statements splits SQL at semicolons. It doesn't understand quoting, so it's
+// only good enough for finding patterns.
+func statements(content string) []statement {
+	var sts []statement
+	line := 1
+	for _, text := range strings.SplitAfter(content, ";") {
+		sts = append(sts, statement{text: text, line: line})
+		line += strings.Count(text, "\n")
+	}
+	return sts
+}
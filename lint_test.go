@@ -0,0 +1,117 @@
+package drift
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	f := migrationFile{
+		Path: "1-m.sql",
+		Content: "-- create index users_name on users (name);\n" +
+			"create index users_email on users (email);\n" +
+			"create index concurrently users_name on users (name);\n" +
+			"drop table teams;\n",
+	}
+	cfg := LintConfig{
+		Disabled: []string{"destructive"},
+		Severity: map[string]Severity{"drop-if-exists": SeverityError},
+	}
+	var got []string
+	for _, finding := range cfg.lint(f) {
+		got = append(got, fmt.Sprintf("%d: %s %s", finding.Line, finding.Severity, finding.Rule))
+	}
+	want := []string{
+		"2: warning index-concurrently",
+		"3: error concurrently-in-transaction",
+		"4: error drop-if-exists",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	f.Content = "--drift:no-transaction\ncreate index concurrently users_name on users (name);\n"
+	if got := cfg.lint(f); len(got) != 0 {
+		t.Errorf("got findings %v for a concurrent index without a transaction", got)
+	}
+}
+
+func TestLintSetNotNull(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    bool
+	}{
+		{
+			name:    "no constraint",
+			content: "alter table users alter column email set not null;",
+			want:    true,
+		},
+		{
+			name: "validated check on the column",
+			content: "alter table users add constraint users_email_nn check (email is not null) not valid;\n" +
+				"alter table users validate constraint users_email_nn;\n" +
+				"alter table users alter column email set not null;",
+		},
+		{
+			name: "quoted and uppercase names",
+			content: `ALTER TABLE "Users" ADD CONSTRAINT email_nn CHECK ((email IS NOT NULL)) NOT VALID;` + "\n" +
+				`ALTER TABLE users VALIDATE CONSTRAINT "email_nn";` + "\n" +
+				`ALTER TABLE ONLY users ALTER COLUMN "email" SET NOT NULL;`,
+		},
+		{
+			name: "constraint added earlier",
+			content: "alter table users validate constraint users_email_nn;\n" +
+				"alter table users alter column email set not null;",
+		},
+		{
+			name: "validated on another table",
+			content: "alter table teams validate constraint teams_name_nn;\n" +
+				"alter table users alter column email set not null;",
+			want: true,
+		},
+		{
+			name: "validated check on another column",
+			content: "alter table users add constraint users_name_nn check (name is not null) not valid;\n" +
+				"alter table users validate constraint users_name_nn;\n" +
+				"alter table users alter column email set not null;",
+			want: true,
+		},
+		{
+			name: "validated check on something else",
+			content: "alter table users add constraint users_email_lower check (email = lower(email)) not valid;\n" +
+				"alter table users validate constraint users_email_lower;\n" +
+				"alter table users alter column email set not null;",
+			want: true,
+		},
+		{
+			name: "validated after set not null",
+			content: "alter table users alter column email set not null;\n" +
+				"alter table users validate constraint users_email_nn;",
+			want: true,
+		},
+		{
+			name: "one of two columns unproven",
+			content: "alter table users add constraint users_email_nn check (email is not null) not valid;\n" +
+				"alter table users validate constraint users_email_nn;\n" +
+				"alter table users alter column email set not null, alter column name set not null;",
+			want: true,
+		},
+	}
+	cfg := LintConfig{Disabled: []string{"destructive"}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := migrationFile{Path: "1-m.sql", Content: tt.content}
+			var got bool
+			for _, finding := range cfg.lint(f) {
+				if finding.Rule == "set-not-null" {
+					got = true
+				}
+			}
+			if got != tt.want {
+				t.Errorf("got a set-not-null finding %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
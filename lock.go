@@ -0,0 +1,195 @@
+package drift
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/blockloop/scan"
+)
+
+// ErrNotLocked means nothing holds the migration lock.
+var ErrNotLocked = errors.New("the migration lock isn't held")
+
+// LockInfo describes the session holding the migration lock.
+type LockInfo struct {
+	// Key is the advisory lock key, which is derived from the migrations
+	// table name.
+	Key int64
+	// PID is the server process ID of the holding session.
+	PID int64
+	// Host is where the holding Drift process runs, from the lock metadata
+	// row it wrote. It's empty if the holder didn't write one.
+	Host string
+	// Client is the holding session's client address, if it's connected
+	// over the network.
+	Client string
+	// StartedAt is when the lock was acquired, from the lock metadata row,
+	// or else when the holding session connected.
+	StartedAt time.Time
+	// State is the holding session's state, like "idle" or "active".
+	State string
+	// Waiting is how many other sessions are waiting for the lock.
+	Waiting int
+}
+
+// LockTable returns the schema and name of the table with the lock metadata
+// row, which is named after the migrations table. Drift creates it the first
+// time it takes the lock, so it only exists in databases migrated with
+// WithLock. Schema dumps, diffs, and plans leave it out.
+func (m *Migrator) LockTable() (schema, table string) {
+	return m.schema, m.table + "_lock"
+}
+
+// lockTableName returns the quoted, schema-qualified name of the lock table.
+func (m *Migrator) lockTableName() string {
+	return m.dialect.Quote(m.LockTable())
+}
+
+// supportsLockInfo reports whether the dialect's locks show up in pg_locks.
+func (m *Migrator) supportsLockInfo() bool {
+	_, ok := m.dialect.(Postgres)
+	return ok
+}
+
+// recordLock writes the lock metadata row for the session that has just
+// acquired the lock, creating the table if needed. The init migration doesn't
+// create it, so databases set up before --lock existed can still use it.
+func (m *Migrator) recordLock(ctx context.Context, conn *sql.Conn, key int64) error {
+	ctx, cancel := m.queryContext(ctx)
+	defer cancel()
+	create := fmt.Sprintf(`create table if not exists %s (
+	key bigint primary key,
+	pid integer not null,
+	host text not null,
+	started_at timestamptz not null
+)`, m.lockTableName())
+	if _, err := conn.ExecContext(ctx, create); err != nil {
+		return err
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	query := fmt.Sprintf(`insert into %s (key, pid, host, started_at)
+values ($1, pg_backend_pid(), $2, now())
+on conflict (key) do update set pid = excluded.pid, host = excluded.host, started_at = excluded.started_at`, m.lockTableName())
+	_, err = conn.ExecContext(ctx, query, key, host)
+	return err
+}
+
+// clearLock deletes the lock metadata row, if the session wrote it.
+func (m *Migrator) clearLock(ctx context.Context, conn *sql.Conn, key int64) error {
+	query := fmt.Sprintf("delete from %s where key = $1 and pid = pg_backend_pid()", m.lockTableName())
+	_, err := conn.ExecContext(ctx, query, key)
+	return err
+}
+
+// An advisory lock on a bigint key shows up in pg_locks with the high half of
+// the key as classid and the low half as objid.
+const qLockHolder = `select
+    l.pid,
+    coalesce(host(a.client_addr), '') as client_addr,
+    coalesce(a.state, '') as state,
+    a.backend_start,
+    (select count(*) from pg_locks w
+        where w.locktype = 'advisory' and w.objsubid = 1 and not w.granted
+        and w.classid = l.classid and w.objid = l.objid) as waiting
+from pg_locks l
+join pg_stat_activity a on a.pid = l.pid
+where l.locktype = 'advisory' and l.objsubid = 1 and l.granted
+    and l.classid::bigint = $1 and l.objid::bigint = $2`
+
+type lockHolder struct {
+	PID          int64     `db:"pid"`
+	Client       string    `db:"client_addr"`
+	State        string    `db:"state"`
+	BackendStart time.Time `db:"backend_start"`
+	Waiting      int       `db:"waiting"`
+}
+
+type lockRow struct {
+	PID       int64     `db:"pid"`
+	Host      string    `db:"host"`
+	StartedAt time.Time `db:"started_at"`
+}
+
+// LockStatus returns who holds the migration lock (see WithLock), or
+// ErrNotLocked if nothing does. It needs Postgres.
+func (m *Migrator) LockStatus(ctx context.Context, db *sql.DB) (*LockInfo, error) {
+	if !m.supportsLockInfo() {
+		return nil, ErrLockUnsupported
+	}
+	ctx, cancel := m.queryContext(ctx)
+	defer cancel()
+
+	key := m.lockKey()
+	rows, err := db.QueryContext(ctx, qLockHolder, int64(uint64(key)>>32), int64(uint64(key)&0xffffffff))
+	if err != nil {
+		return nil, err
+	}
+	var h lockHolder
+	if err := scan.RowStrict(&h, rows); errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotLocked
+	} else if err != nil {
+		return nil, err
+	}
+	info := &LockInfo{
+		Key:       key,
+		PID:       h.PID,
+		Client:    h.Client,
+		StartedAt: h.BackendStart,
+		State:     h.State,
+		Waiting:   h.Waiting,
+	}
+
+	// The metadata row is only trustworthy if the holder wrote it.
+	query := fmt.Sprintf("select pid, host, started_at from %s where key = $1", m.lockTableName())
+	rows, err = db.QueryContext(ctx, query, key)
+	if m.dialect.IsUndefinedTable(err) {
+		return info, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var r lockRow
+	switch err := scan.RowStrict(&r, rows); {
+	case errors.Is(err, sql.ErrNoRows):
+	case err != nil:
+		return nil, err
+	case r.PID == h.PID:
+		info.Host = r.Host
+		info.StartedAt = r.StartedAt
+	}
+	return info, nil
+}
+
+// ForceUnlock releases the migration lock by terminating the session that
+// holds it, for when a crashed or hung Drift process left its connection open.
+// It returns the holder it terminated, or ErrNotLocked.
+//
+// If the holder is still applying a migration, that migration is rolled back
+// (or, outside of a transaction, left half-done), so check LockStatus first.
+func (m *Migrator) ForceUnlock(ctx context.Context, db *sql.DB) (*LockInfo, error) {
+	info, err := m.LockStatus(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := m.queryContext(ctx)
+	defer cancel()
+	var ok bool
+	if err := db.QueryRowContext(ctx, "select pg_terminate_backend($1)", info.PID).Scan(&ok); err != nil {
+		return info, err
+	}
+	if !ok {
+		return info, fmt.Errorf("could not terminate pid %d", info.PID)
+	}
+	query := fmt.Sprintf("delete from %s where key = $1", m.lockTableName())
+	if _, err := db.ExecContext(ctx, query, info.Key); err != nil && !m.dialect.IsUndefinedTable(err) {
+		return info, err
+	}
+	return info, nil
+}
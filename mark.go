@@ -0,0 +1,98 @@
+package drift
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+var ErrNotApplied = errors.New("migration has not been applied")
+
+// MarkApplied records the migration with the ID as applied without running it,
+// for changes that were made by hand. If the migrations table has a faked
+// column, the record is marked as faked.
+func (m *Migrator) MarkApplied(ctx context.Context, db *sql.DB, migrationsDir string, id MigrationID) error {
+	files, err := m.available(migrationsDir)
+	if err != nil {
+		return fmt.Errorf("could not get available migrations: %w", err)
+	}
+	f, err := findMigration(files, id.String())
+	if err != nil {
+		return err
+	}
+	records, err := m.applied(ctx, db)
+	if err != nil {
+		return fmt.Errorf("could not get applied migrations: %w", err)
+	}
+	for _, r := range records {
+		if r.ID == id {
+			return fmt.Errorf("%w: %d", ErrAlreadyApplied, id)
+		}
+	}
+	cols, err := m.historyColumns(ctx, db)
+	if err != nil {
+		return fmt.Errorf("could not inspect the migrations table: %w", err)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+	if err := m.claim(ctx, tx, f.ID, f.Slug); err != nil {
+		return err
+	}
+	if cols["faked"] {
+		query, args, err := m.sb().
+			Update(m.tableName()).
+			Set("faked", true).
+			Where(sq.Eq{"id": f.ID}).
+			ToSql()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return err
+		}
+	} else {
+		warnf(m.io, "The migrations table has no faked column, so the record won't be marked as faked")
+	}
+	if cols["applied_by"] {
+		if err := m.recordHistory(ctx, tx, f, 0, historyColumns{"applied_by": true}); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	m.io.Infof("Marked as applied: %s", f.Path)
+	return nil
+}
+
+// Unmark removes the record of the migration with the ID, so Migrate will run
+// it again. It doesn't undo any changes the migration made.
+func (m *Migrator) Unmark(ctx context.Context, db *sql.DB, id MigrationID) error {
+	query, args, err := m.sb().
+		Delete(m.tableName()).
+		Where(sq.Eq{"id": id}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	res, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("%w: %d", ErrNotApplied, id)
+	}
+	m.io.Infof("Unmarked migration: %d", id)
+	return nil
+}
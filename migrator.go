@@ -0,0 +1,460 @@
+package drift
+
+import (
+	"context"
+	"database/sql"
+	"hash/fnv"
+	"io/fs"
+	"text/template"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// DefaultTable is the name of the table that records applied migrations.
+const DefaultTable = "schema_migrations"
+
+// DefaultSchema is the schema containing the migrations table and Drift's
+// functions.
+const DefaultSchema = "public"
+
+// A Migrator runs and manages migrations. Use New to create one.
+//
+// The package-level functions (Migrate, NewFile, etc.) use a Migrator with the
+// default options.
+type Migrator struct {
+	schema  string
+	table   string
+	io      IO
+	lock    bool
+	pooled  bool
+	dialect Dialect
+	clock   func() time.Time
+	files   FileSystem
+	dirs    []string
+
+	only         []MigrationID
+	progressFile string
+	stopAfter    time.Duration
+	timeout      time.Duration
+	stop         <-chan struct{}
+	appliedBy    string
+	auditContent bool
+	parallel     int
+	module       string
+	confirm      func([]PlannedMigration) (bool, error)
+	lint         *LintConfig
+	layout       Layout
+	steps        int
+	waitTimeout  time.Duration
+	tracer       Tracer
+	body         string
+	author       string
+	environment  string
+	vars         map[string]string
+	txMode       TransactionMode
+	atomic       bool
+	showSQL      bool
+	dryRun       bool
+	explain      bool
+	debugKeep    bool
+	streamSize   int64
+	queryTimeout time.Duration
+
+	lockTimeout      time.Duration
+	statementTimeout time.Duration
+	blockingReport   time.Duration
+
+	failuresTable string
+
+	claimStrategy ClaimStrategy
+	claimSQL      string
+
+	initTemplate *template.Template
+
+	tenantWorkers int
+	keepGoing     bool
+	sequentialIDs bool
+	seedTable     string
+
+	continueOnError bool
+
+	seal  Seal
+	chaos *chaos
+}
+
+// An Option configures a Migrator.
+type Option func(*Migrator)
+
+// New creates a Migrator with the given options applied over the defaults.
+func New(opts ...Option) *Migrator {
+	m := &Migrator{
+		schema:  DefaultSchema,
+		table:   DefaultTable,
+		io:      nopIO{},
+		dialect: Postgres{},
+		clock:   time.Now,
+		files:   OSFileSystem{},
+
+		streamSize: DefaultStreamSize,
+		seedTable:  DefaultSeedTable,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	// These defaults depend on the dialect.
+	if m.claimStrategy == "" {
+		m.claimStrategy = ClaimFunction
+		if d, ok := m.dialect.(ClaimDialect); ok {
+			m.claimStrategy = d.ClaimStrategy()
+		}
+	}
+	if _, ok := m.dialect.(NonTransactionalDialect); ok {
+		m.txMode = TransactionNone
+	}
+	return m
+}
+
+// WithTable sets the name of the table that records applied migrations.
+//
+// The init migration written by Setup uses this name, so set the same table
+// for every Migrator that uses the same migrations.
+func WithTable(name string) Option {
+	return func(m *Migrator) {
+		m.table = name
+	}
+}
+
+// WithSchema sets the schema containing the migrations table and Drift's
+// functions. Drift always uses schema-qualified names, so the database's
+// search_path doesn't affect which table it uses.
+func WithSchema(name string) Option {
+	return func(m *Migrator) {
+		m.schema = name
+	}
+}
+
+// WithLogger sets where log messages are written. By default, they're
+// discarded.
+func WithLogger(io IO) Option {
+	return func(m *Migrator) {
+		m.io = io
+	}
+}
+
+// WithLock makes Migrate hold an advisory lock while it runs, so that several
+// processes (e.g. replicas of an application) can safely run migrations at
+// the same time. The lock is specific to the migrations table.
+func WithLock() Option {
+	return func(m *Migrator) {
+		m.lock = true
+	}
+}
+
+// WithTransactionPooling keeps Drift from relying on session state, for
+// connection poolers like PgBouncer in transaction pooling mode, where
+// consecutive transactions can run on different server connections. WithLock
+// fails, since a session-level lock could be held by (and released from) the
+// wrong connection, and migrations outside of transactions aren't given a
+// session statement timeout, which would leak to other clients. Their
+// timeout directives still cancel them.
+//
+// Open the database with the simple protocol too (like pgx's
+// PreferSimpleProtocol), since prepared statements have the same problem.
+func WithTransactionPooling() Option {
+	return func(m *Migrator) {
+		m.pooled = true
+	}
+}
+
+// WithDialect sets the database dialect. The default is Postgres.
+func WithDialect(d Dialect) Option {
+	return func(m *Migrator) {
+		m.dialect = d
+	}
+}
+
+// WithClock sets the function used to get the current time. It generates
+// default migration IDs, and it times migrations for the durations in the
+// Result, the migrations table, and progress reports, and for the WithStopAfter
+// budget.
+func WithClock(now func() time.Time) Option {
+	return func(m *Migrator) {
+		m.clock = now
+	}
+}
+
+// WithStopAfter sets a time budget for Migrate. Once the budget is used up,
+// Migrate lets the in-flight migration finish but doesn't start the next one,
+// and returns an error wrapping ErrBudgetExhausted.
+func WithStopAfter(d time.Duration) Option {
+	return func(m *Migrator) {
+		m.stopAfter = d
+	}
+}
+
+// tableName returns the quoted, schema-qualified migrations table name.
+func (m *Migrator) tableName() string {
+	return m.dialect.Quote(m.schema, m.table)
+}
+
+// funcName returns the quoted, schema-qualified name of one of Drift's
+// functions.
+func (m *Migrator) funcName(name string) string {
+	return m.dialect.Quote(m.schema, m.moduleName(name))
+}
+
+// moduleName returns the unquoted name of one of Drift's functions, with the
+// module suffix if there is one.
+func (m *Migrator) moduleName(name string) string {
+	if m.module != "" {
+		name += "_" + m.module
+	}
+	return name
+}
+
+// A Layout is how NewFile and NewScaffold arrange new migrations. Drift reads
+// migrations in either layout regardless of this setting.
+type Layout string
+
+const (
+	// LayoutFile writes each migration as a single file, like
+	// 1234-create_users.sql. This is the default.
+	LayoutFile Layout = "file"
+	// LayoutDirectory writes each migration as a directory with an up.sql
+	// file, like 1234-create_users/up.sql, so related files (data files,
+	// notes, or a down migration) can live next to it.
+	LayoutDirectory Layout = "directory"
+)
+
+// WithLayout sets the layout of new migrations.
+func WithLayout(l Layout) Option {
+	return func(m *Migrator) {
+		m.layout = l
+	}
+}
+
+// WithSteps limits Migrate to applying the first n pending migrations (after
+// upto and WithOnly). The rest are skipped with SkipSteps.
+func WithSteps(n int) Option {
+	return func(m *Migrator) {
+		m.steps = n
+	}
+}
+
+// WithAuthor sets the Author available to migration templates.
+func WithAuthor(name string) Option {
+	return func(m *Migrator) {
+		m.author = name
+	}
+}
+
+// WithBody sets the Body available to migration templates, for writing new
+// migrations with SQL generated elsewhere. The default template uses it in
+// place of its TODO comment.
+func WithBody(sql string) Option {
+	return func(m *Migrator) {
+		m.body = sql
+	}
+}
+
+// WithEnvironment sets the name of the environment (like "staging") that the
+// Migrator is used for. It's available to migration templates.
+func WithEnvironment(name string) Option {
+	return func(m *Migrator) {
+		m.environment = name
+	}
+}
+
+// WithModule scopes the Migrator to an independently-versioned module that
+// keeps its own migrations table (set with WithTable). Drift's functions get
+// the module name as a suffix, like _drift_claim_migration_auth, so several
+// modules can share a schema without sharing an ID space.
+//
+// Migrations that call the functions directly (no-transaction migrations, or
+// ones that require others) must use the suffixed names.
+func WithModule(name string) Option {
+	return func(m *Migrator) {
+		m.module = name
+	}
+}
+
+// WithTimeout limits how long Migrate can spend applying migrations. When the
+// time runs out, the running statement is cancelled and Migrate returns an
+// error.
+//
+// Migration files can set their own limits with a directive:
+//
+//	--drift:timeout=30s
+func WithTimeout(d time.Duration) Option {
+	return func(m *Migrator) {
+		m.timeout = d
+	}
+}
+
+// WithQueryTimeout limits how long each of Drift's own queries can take, like
+// reading the migrations table or recording a migration's history. A query
+// that waits longer (say, on a lock someone forgot to release) is cancelled
+// and its error is returned. It doesn't limit the migrations themselves (see
+// WithTimeout) or waiting for WithLock. The default is no limit.
+func WithQueryTimeout(d time.Duration) Option {
+	return func(m *Migrator) {
+		m.queryTimeout = d
+	}
+}
+
+// WithLockTimeout sets lock_timeout for each migration's transaction, so a
+// migration that's waiting for a lock (say, an alter table behind a long
+// query) fails instead of stalling the deploy and blocking everything queued
+// behind it. It doesn't apply to migrations outside of transactions. Only
+// Postgres and CockroachDB support it; other dialects fail with
+// ErrTimeoutUnsupported.
+func WithLockTimeout(d time.Duration) Option {
+	return func(m *Migrator) {
+		m.lockTimeout = d
+	}
+}
+
+// WithStatementTimeout sets statement_timeout for each migration's
+// transaction. A migration's timeout directive takes precedence. It doesn't
+// apply to migrations outside of transactions. Only Postgres and CockroachDB
+// support it; other dialects fail with ErrTimeoutUnsupported.
+func WithStatementTimeout(d time.Duration) Option {
+	return func(m *Migrator) {
+		m.statementTimeout = d
+	}
+}
+
+// queryContext bounds one of Drift's own queries by the query timeout.
+func (m *Migrator) queryContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if m.queryTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, m.queryTimeout)
+}
+
+// cleanupTimeout limits cleanup that runs after a failure or cancellation,
+// like releasing the lock. Tests shorten it.
+var cleanupTimeout = 10 * time.Second
+
+// cleanupContext returns a context for cleanup that isn't cancelled with ctx,
+// but doesn't wait forever either.
+func cleanupContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), cleanupTimeout)
+}
+
+// WithStopSignal lets the caller stop Migrate cooperatively: once the channel
+// is closed, Migrate lets the in-flight migration finish but doesn't start the
+// next one, and returns ErrStopped. A no-transaction migration only finishes
+// its current statement, and fails with ErrStopped partly applied. Cancel the
+// context instead to abort the in-flight migration too.
+func WithStopSignal(stop <-chan struct{}) Option {
+	return func(m *Migrator) {
+		m.stop = stop
+	}
+}
+
+func (m *Migrator) stopRequested() bool {
+	select {
+	case <-m.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+func (m *Migrator) sb() sq.StatementBuilderType {
+	return sq.StatementBuilder.PlaceholderFormat(m.dialect.Placeholders())
+}
+
+// lockKey derives the advisory lock key from the migrations table name.
+func (m *Migrator) lockKey() int64 {
+	h := fnv.New64a()
+	h.Write([]byte("drift:" + m.schema + "." + m.table))
+	return int64(h.Sum64())
+}
+
+func (m *Migrator) acquireLock(ctx context.Context, db conn) (unlock func(), err error) {
+	conn, release, err := dedicatedConn(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	key := m.lockKey()
+	m.io.Debugf("Waiting for migration lock: %d", key)
+	if err := m.dialect.Lock(ctx, conn, key); err != nil {
+		release()
+		return nil, err
+	}
+	m.io.Debugf("Acquired migration lock: %d", key)
+	if m.supportsLockInfo() {
+		// The metadata only helps people find the holder, so it's fine to
+		// go without.
+		if err := m.recordLock(ctx, conn, key); err != nil {
+			warnf(m.io, "Could not record the migration lock holder: %s", err)
+		}
+	}
+	return func() {
+		// Use a fresh context so the lock is released even after
+		// cancellation. Closing the connection would release it too.
+		ctx, cancel := cleanupContext(ctx)
+		defer cancel()
+		if m.supportsLockInfo() {
+			if err := m.clearLock(ctx, conn, key); err != nil {
+				m.io.Debugf("Could not clear the migration lock holder: %s", err)
+			}
+		}
+		if err := m.dialect.Unlock(ctx, conn, key); err != nil {
+			warnf(m.io, "Could not release migration lock: %s", err)
+		}
+		release()
+	}, nil
+}
+
+type nopIO struct{}
+
+func (nopIO) Infof(string, ...interface{}) (int, error)  { return 0, nil }
+func (nopIO) Debugf(string, ...interface{}) (int, error) { return 0, nil }
+func (nopIO) Warnf(string, ...interface{}) (int, error)  { return 0, nil }
+func (nopIO) Errorf(string, ...interface{}) (int, error) { return 0, nil }
+
+// Migrate runs all unapplied migrations in ID order, least to greatest. It
+// skips any migrations that have already been applied.
+//
+// If upto is non-nil, this will also skip any migrations with IDs greater than
+// that value.
+func Migrate(ctx context.Context, io IO, db *sql.DB, migrationsDir string, upto *MigrationID) error {
+	return New(WithLogger(io)).Migrate(ctx, db, migrationsDir, upto)
+}
+
+// MigrateFS is like Migrate, but it reads the migration files from the root of
+// fsys instead of a directory on disk.
+func MigrateFS(ctx context.Context, io IO, db *sql.DB, fsys fs.FS, upto *MigrationID) error {
+	return New(WithLogger(io)).MigrateFS(ctx, db, fsys, upto)
+}
+
+// Run is like Migrate, but it also returns a Result describing what it did.
+func Run(ctx context.Context, io IO, db *sql.DB, migrationsDir string, upto *MigrationID) (*Result, error) {
+	return New(WithLogger(io)).Run(ctx, db, migrationsDir, upto)
+}
+
+// Setup creates the "init" migration that will prepare the database for
+// migrations. This will create the migrations directory if needed.
+func Setup(migrationsDir string) (string, error) {
+	return New().Setup(migrationsDir)
+}
+
+// NewFile creates a new migration file with a placeholder comment in it.
+func NewFile(io IO, migrationsDir string, id MigrationID, slug string, tmpl *template.Template) (string, error) {
+	return New(WithLogger(io)).NewFile(migrationsDir, id, slug, tmpl)
+}
+
+// Renumber renames migration files so that their IDs all have the same width.
+func Renumber(io IO, dir string, write bool) error {
+	return New(WithLogger(io)).Renumber(dir, write)
+}
+
+// Verify compares the applied migration records to the migration files and
+// reports any differences. See Migrator.Verify for details.
+func Verify(ctx context.Context, io IO, db *sql.DB, migrationsDir string, deep bool, inv Invariants) ([]Discrepancy, error) {
+	return New(WithLogger(io)).Verify(ctx, db, migrationsDir, deep, inv)
+}
@@ -0,0 +1,238 @@
+package drift
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStopSignal(t *testing.T) {
+	if New().stopRequested() {
+		t.Error("stop requested without a stop signal")
+	}
+	stop := make(chan struct{})
+	m := New(WithStopSignal(stop))
+	if m.stopRequested() {
+		t.Error("stop requested before the signal")
+	}
+	close(stop)
+	if !m.stopRequested() {
+		t.Error("stop not requested after the signal")
+	}
+}
+
+// prompt is how soon a cancelled call has to return.
+const prompt = 2 * time.Second
+
+// cancelSoon returns a context that's cancelled shortly after the call starts.
+func cancelSoon(t *testing.T) context.Context {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	time.AfterFunc(20*time.Millisecond, cancel)
+	return ctx
+}
+
+func TestQueriesCancel(t *testing.T) {
+	queries := map[string]func(context.Context, *Migrator, rowQueryable) error{
+		"applied": func(ctx context.Context, m *Migrator, db rowQueryable) error {
+			_, err := m.applied(ctx, db)
+			return err
+		},
+		"historyColumns": func(ctx context.Context, m *Migrator, db rowQueryable) error {
+			_, err := m.historyColumns(ctx, db)
+			return err
+		},
+	}
+	for name, query := range queries {
+		t.Run(name+"/cancel", func(t *testing.T) {
+			db := (&fakeDriver{hang: []string{"select"}}).db()
+			defer db.Close()
+
+			start := time.Now()
+			err := query(cancelSoon(t), New(), db)
+			if !errors.Is(err, context.Canceled) {
+				t.Fatalf("got error %v, want %v", err, context.Canceled)
+			}
+			if d := time.Since(start); d > prompt {
+				t.Errorf("returned after %s", d)
+			}
+		})
+		t.Run(name+"/query-timeout", func(t *testing.T) {
+			db := (&fakeDriver{hang: []string{"select"}}).db()
+			defer db.Close()
+
+			start := time.Now()
+			err := query(context.Background(), New(WithQueryTimeout(20*time.Millisecond)), db)
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("got error %v, want %v", err, context.DeadlineExceeded)
+			}
+			if d := time.Since(start); d > prompt {
+				t.Errorf("returned after %s", d)
+			}
+		})
+	}
+}
+
+func TestLockWaitCancel(t *testing.T) {
+	db := (&fakeDriver{hang: []string{"pg_advisory_lock"}}).db()
+	defer db.Close()
+
+	start := time.Now()
+	_, err := New().acquireLock(cancelSoon(t), db)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("got error %v, want %v", err, context.Canceled)
+	}
+	if d := time.Since(start); d > prompt {
+		t.Errorf("returned after %s", d)
+	}
+}
+
+func TestUnlockAfterCancel(t *testing.T) {
+	d := &fakeDriver{}
+	db := d.db()
+	defer db.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	unlock, err := New().acquireLock(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	unlock()
+
+	var unlocked bool
+	for _, q := range d.executed() {
+		unlocked = unlocked || strings.Contains(q, "pg_advisory_unlock")
+	}
+	if !unlocked {
+		t.Errorf("lock wasn't released after cancellation; executed %q", d.executed())
+	}
+}
+
+func TestSetLocalTimeouts(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      []Option
+		directive time.Duration
+		want      []string
+	}{
+		{
+			name: "none",
+		},
+		{
+			name: "lock and statement",
+			opts: []Option{WithLockTimeout(time.Second), WithStatementTimeout(time.Minute)},
+			want: []string{"set local lock_timeout = 1000", "set local statement_timeout = 60000"},
+		},
+		{
+			name:      "directive takes precedence",
+			opts:      []Option{WithStatementTimeout(time.Minute)},
+			directive: 30 * time.Second,
+			want:      []string{"set local statement_timeout = 30000"},
+		},
+		{
+			name:      "directive without a configured timeout",
+			directive: 30 * time.Second,
+			want:      []string{"set local statement_timeout = 30000"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &fakeDriver{}
+			db := d.db()
+			defer db.Close()
+
+			m := New(append([]Option{WithDialect(Postgres{})}, tt.opts...)...)
+			if err := m.setLocalTimeouts(context.Background(), db, tt.directive); err != nil {
+				t.Fatal(err)
+			}
+			if got := d.executed(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("executed %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnlockTimeout(t *testing.T) {
+	old := cleanupTimeout
+	cleanupTimeout = 50 * time.Millisecond
+	t.Cleanup(func() { cleanupTimeout = old })
+
+	db := (&fakeDriver{hang: []string{"pg_advisory_unlock"}}).db()
+	defer db.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	unlock, err := New().acquireLock(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+
+	start := time.Now()
+	unlock()
+	if d := time.Since(start); d > prompt {
+		t.Errorf("releasing a stuck lock returned after %s", d)
+	}
+}
+
+// stopOnExec closes stop when the first statement runs, like an interrupt
+// arriving mid-migration.
+type stopOnExec struct {
+	stop  chan struct{}
+	execs []string
+}
+
+func (q *stopOnExec) ExecContext(_ context.Context, query string, _ ...interface{}) (sql.Result, error) {
+	if len(q.execs) == 0 {
+		close(q.stop)
+	}
+	q.execs = append(q.execs, query)
+	return driver.RowsAffected(0), nil
+}
+
+func TestRunUntilStopped(t *testing.T) {
+	q := &stopOnExec{stop: make(chan struct{})}
+	m := New(WithStopSignal(q.stop))
+	err := m.runUntilStopped(context.Background(), q, "create index concurrently a on t (a);\ncreate index concurrently b on t (b);\n")
+	if !errors.Is(err, ErrStopped) {
+		t.Fatalf("got error %v, want %v", err, ErrStopped)
+	}
+	if len(q.execs) != 1 {
+		t.Errorf("ran %q, want only the first statement", q.execs)
+	}
+}
+
+func TestTimeoutsPostgresOnly(t *testing.T) {
+	for name, tc := range map[string]struct {
+		dialect Dialect
+		want    int
+	}{
+		"postgres":   {Postgres{}, 2},
+		"sql server": {SQLServer{}, 0},
+	} {
+		t.Run(name, func(t *testing.T) {
+			d := &fakeDriver{}
+			db := d.db()
+			defer db.Close()
+
+			m := New(WithDialect(tc.dialect), WithLockTimeout(time.Second))
+			if err := m.setLocalTimeouts(context.Background(), db, time.Minute); err != nil {
+				t.Fatal(err)
+			}
+			if got := d.executed(); len(got) != tc.want {
+				t.Errorf("executed %q, want %d statements", got, tc.want)
+			}
+		})
+	}
+
+	m := New(WithDialect(SQLServer{}), WithStatementTimeout(time.Minute))
+	if err := m.checkTimeouts(); !errors.Is(err, ErrTimeoutUnsupported) {
+		t.Errorf("got error %v, want %v", err, ErrTimeoutUnsupported)
+	}
+}
@@ -0,0 +1,124 @@
+package drift
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+var (
+	ErrUnknownMigration      = errors.New("no migration file with ID")
+	ErrUnsatisfiedDependency = errors.New("required migration has not been applied")
+)
+
+// WithOnly limits Migrate to the listed pending migrations. They're still
+// applied in ID order, and any migration they require (with
+// _drift_require_migration) must already be applied or also be listed.
+func WithOnly(ids ...MigrationID) Option {
+	return func(m *Migrator) {
+		m.only = ids
+	}
+}
+
+// reRequire finds calls to _drift_require_migration (or a module's suffixed
+// version of it) with a literal ID.
+var reRequire = regexp.MustCompile(`_drift_require_migration(?:_\w+)?\s*\(\s*(\d+)\s*\)`)
+
+// requires returns the IDs of the migrations that the content requires.
+func requires(content string) []MigrationID {
+	var ids []MigrationID
+	for _, m := range reRequire.FindAllStringSubmatch(content, -1) {
+		var id MigrationID
+		if err := id.Set(m[1]); err != nil {
+			// Too big to be a migration ID, so the database will reject it
+			// anyway.
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// dependencies returns the IDs of the migrations that must be applied before
+// this one: the ones it requires with _drift_require_migration and the ones
+// its depends-on directives name.
+func dependencies(f migrationFile) []MigrationID {
+	var ids []MigrationID
+	if f.summary != nil {
+		ids = append(ids, f.summary.requires...)
+	} else {
+		ids = requires(f.Content)
+	}
+	for _, d := range listDirectives(f.directiveText()) {
+		var id MigrationID
+		// Invalid IDs are reported when the directives are parsed.
+		if d.Name == "depends-on" && id.Set(d.Value) == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// checkDependencies returns an error if a planned migration depends on one
+// that is neither applied nor earlier in the plan.
+func checkDependencies(plan []migrationFile, records []migrationRecord) error {
+	done := make(map[MigrationID]bool)
+	for _, r := range records {
+		done[r.ID] = true
+	}
+	for _, f := range plan {
+		for _, dep := range f.directives.dependsOn {
+			if !done[dep] {
+				return fmt.Errorf("%w: %s depends on %d", ErrUnsatisfiedDependency, f.Name, dep)
+			}
+		}
+		done[f.ID] = true
+	}
+	return nil
+}
+
+// selectOnly filters the needed migrations down to the allowed IDs and checks
+// that their requirements will be satisfied. It reads the selected files with
+// read.
+func selectOnly(io IO, needed []migrationFile, records []migrationRecord, only []MigrationID, read func(*migrationFile) error) ([]migrationFile, error) {
+	done := make(map[MigrationID]bool)
+	for _, r := range records {
+		done[r.ID] = true
+	}
+	pending := make(map[MigrationID]bool)
+	for _, f := range needed {
+		pending[f.ID] = true
+	}
+
+	allow := make(map[MigrationID]bool)
+	for _, id := range only {
+		switch {
+		case done[id]:
+			io.Debugf("Skipping already-applied migration: %d", id)
+		case pending[id]:
+			allow[id] = true
+		default:
+			return nil, fmt.Errorf("%w: %d", ErrUnknownMigration, id)
+		}
+	}
+
+	var selected []migrationFile
+	for _, f := range needed {
+		if !allow[f.ID] {
+			io.Debugf("Skipping migration because of only: %s", f.Name)
+			continue
+		}
+		if err := read(&f); err != nil {
+			return nil, err
+		}
+		for _, dep := range dependencies(f) {
+			// Needed migrations are sorted, so a selected dependency with a
+			// smaller ID will already be done by the time this one runs.
+			if !done[dep] && !(allow[dep] && dep < f.ID) {
+				return nil, fmt.Errorf("%w: %s requires %d", ErrUnsatisfiedDependency, f.Name, dep)
+			}
+		}
+		selected = append(selected, f)
+	}
+	return selected, nil
+}
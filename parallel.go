@@ -0,0 +1,110 @@
+package drift
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// WithParallel applies up to n migrations at the same time, each on its own
+// connection. Only migrations with a --drift:concurrent-safe directive are
+// applied in parallel, and only alongside their concurrent-safe neighbors:
+// any other migration waits for everything before it to finish, and a
+// concurrent-safe migration that calls _drift_require_migration on one of its
+// neighbors waits for that neighbor.
+//
+// This is meant for creating environments from scratch, where hundreds of
+// independent migrations would otherwise run one at a time. Stop signals and
+// WithStopAfter are only checked between groups of parallel migrations.
+func WithParallel(n int) Option {
+	return func(m *Migrator) {
+		m.parallel = n
+	}
+}
+
+// nextBatch returns the migrations at the start of plan that can be applied
+// together. This is always at least the first migration.
+func (m *Migrator) nextBatch(plan []migrationFile) []migrationFile {
+	if m.parallel < 2 || !plan[0].directives.concurrentSafe || plan[0].stream {
+		return plan[:1]
+	}
+	batch := map[MigrationID]bool{plan[0].ID: true}
+	n := 1
+	for ; n < len(plan); n++ {
+		f := plan[n]
+		// Streamed files have no content left to check for requirements.
+		if !f.directives.concurrentSafe || f.stream || requiresAny(f, batch) {
+			break
+		}
+		batch[f.ID] = true
+	}
+	return plan[:n]
+}
+
+func requiresAny(f migrationFile, ids map[MigrationID]bool) bool {
+	for _, dep := range dependencies(f) {
+		if ids[dep] {
+			return true
+		}
+	}
+	return false
+}
+
+// applyBatch applies the batch of migrations, in parallel if there's more than
+// one. After a failure, no new migrations are started, but the ones already
+// running are allowed to finish.
+func (m *Migrator) applyBatch(ctx context.Context, db conn, batch []migrationFile, cols historyColumns, prog *progress, res *Result) error {
+	if len(batch) == 1 {
+		d, err := m.applyOne(ctx, db, batch[0], cols, prog)
+		if err != nil {
+			res.failed(batch[0], err)
+			return err
+		}
+		res.applied(batch[0], d)
+		return nil
+	}
+
+	m.io.Infof("Applying %d concurrent-safe migrations with up to %d connections", len(batch), m.parallel)
+	var (
+		wg     sync.WaitGroup
+		failed atomic.Bool
+		sem    = make(chan struct{}, m.parallel)
+		ran    = make([]bool, len(batch))
+		ds     = make([]time.Duration, len(batch))
+		errs   = make([]error, len(batch))
+	)
+	for i, f := range batch {
+		wg.Add(1)
+		go func(i int, f migrationFile) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			if failed.Load() {
+				return
+			}
+			ran[i] = true
+			ds[i], errs[i] = m.applyOne(ctx, db, f, cols, prog)
+			if errs[i] != nil {
+				failed.Store(true)
+			}
+		}(i, f)
+	}
+	wg.Wait()
+
+	var err error
+	for i, f := range batch {
+		switch {
+		case !ran[i]:
+			m.io.Debugf("Not starting migration after a failure: %s", f.Name)
+		case errs[i] != nil:
+			res.failed(f, errs[i])
+			if err == nil {
+				err = errs[i]
+			}
+		default:
+			res.applied(f, ds[i])
+		}
+	}
+	return err
+}
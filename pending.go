@@ -0,0 +1,32 @@
+package drift
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// A PendingMigration is a migration file that hasn't been applied.
+type PendingMigration struct {
+	ID   MigrationID
+	Slug string
+	Path string
+}
+
+// Pending returns the migrations in migrationsDir that haven't been applied,
+// in ID order. Before the init migration has run, every migration is pending.
+func (m *Migrator) Pending(ctx context.Context, db *sql.DB, migrationsDir string) ([]PendingMigration, error) {
+	records, err := m.applied(ctx, db)
+	if err != nil {
+		return nil, fmt.Errorf("could not get applied migrations: %w", err)
+	}
+	files, err := m.listFiles(migrationsDir)
+	if err != nil {
+		return nil, fmt.Errorf("could not get available migrations: %w", err)
+	}
+	var pending []PendingMigration
+	for _, f := range diff(records, files) {
+		pending = append(pending, PendingMigration{ID: f.ID, Slug: f.Slug, Path: f.Path})
+	}
+	return pending, nil
+}
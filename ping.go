@@ -0,0 +1,100 @@
+package drift
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Health describes the database connection and Drift's setup in it.
+type Health struct {
+	// ServerVersion is the database server version, like "16.2".
+	ServerVersion string
+	// Table is true if the migrations table exists.
+	Table bool
+	// ClaimFunction is true if the _drift_claim_migration function exists.
+	ClaimFunction bool
+	// ClaimStrategy is how migrations are recorded. Only ClaimFunction
+	// needs the function.
+	ClaimStrategy ClaimStrategy
+	// Version is the greatest applied migration ID, or -1 if there are none.
+	Version MigrationID
+}
+
+// Ready reports whether Drift is set up and can apply migrations.
+func (h Health) Ready() bool {
+	return h.Table && (h.ClaimFunction || h.ClaimStrategy != ClaimFunction)
+}
+
+// Current returns the applied migration with the greatest ID (the schema
+// version), or nil if none have been applied. It returns an error wrapping
+// ErrNoMigrationsTable if the migrations table doesn't exist.
+func (m *Migrator) Current(ctx context.Context, db *sql.DB) (*HistoryEntry, error) {
+	exists, err := m.tableExists(ctx, db)
+	if err != nil {
+		return nil, fmt.Errorf("could not check for the migrations table: %w", err)
+	}
+	if !exists {
+		return nil, ErrNoMigrationsTable
+	}
+	hs, err := m.History(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	if len(hs) == 0 {
+		return nil, nil
+	}
+	return &hs[len(hs)-1], nil
+}
+
+// Ping connects to the database and checks that Drift is set up. It returns
+// an error if the database isn't reachable; a database that's reachable but
+// not set up is reported in the Health instead.
+func (m *Migrator) Ping(ctx context.Context, db *sql.DB) (*Health, error) {
+	if err := db.PingContext(ctx); err != nil {
+		return nil, err
+	}
+	h := &Health{Version: -1, ClaimStrategy: m.claimStrategy}
+	version := "select current_setting('server_version')"
+	if vd, ok := m.dialect.(VersionDialect); ok {
+		version = vd.ServerVersionQuery()
+	}
+	if err := db.QueryRowContext(ctx, version).Scan(&h.ServerVersion); err != nil {
+		return nil, fmt.Errorf("could not get the server version: %w", err)
+	}
+
+	var err error
+	if h.Table, err = m.tableExists(ctx, db); err != nil {
+		return nil, fmt.Errorf("could not check for the migrations table: %w", err)
+	}
+	if m.claimStrategy == ClaimFunction {
+		if h.ClaimFunction, err = m.claimFunctionExists(ctx, db); err != nil {
+			return nil, fmt.Errorf("could not check for the claim function: %w", err)
+		}
+	}
+
+	if h.Table {
+		records, err := m.applied(ctx, db)
+		if err != nil {
+			return nil, fmt.Errorf("could not get applied migrations: %w", err)
+		}
+		h.Version = newResult(records).Version
+	}
+	return h, nil
+}
+
+// claimFunctionExists reports whether the _drift_claim_migration function (or
+// the ProcedureDialect's procedure) exists.
+func (m *Migrator) claimFunctionExists(ctx context.Context, db rowQueryable) (bool, error) {
+	if pd, ok := m.dialect.(ProcedureDialect); ok {
+		return exists(ctx, db, pd.ProcedureExistsQuery(), m.schema, m.moduleName("_drift_claim_migration"))
+	}
+	query, args, err := m.sb().
+		Select("1").
+		Where("to_regprocedure(?) is not null", m.funcName("_drift_claim_migration")+"(integer, text)").
+		ToSql()
+	if err != nil {
+		return false, err
+	}
+	return exists(ctx, db, query, args...)
+}
@@ -0,0 +1,192 @@
+package drift
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// WithProgressFile makes Migrate write its plan and progress as JSON to the
+// file at path, rewriting it after every state change. External orchestrators
+// can poll the file to follow long-running migrations.
+//
+// The file is replaced atomically, so readers never see a partial write. It
+// describes one run, so RunTenants refuses it.
+func WithProgressFile(path string) Option {
+	return func(m *Migrator) {
+		m.progressFile = path
+	}
+}
+
+// Run and migration states in the progress file.
+const (
+	statePending   = "pending"
+	stateRunning   = "running"
+	stateApplied   = "applied"
+	stateFailed    = "failed"
+	stateSucceeded = "succeeded"
+)
+
+type progressMigration struct {
+	ID         MigrationID `json:"id"`
+	Slug       string      `json:"slug"`
+	Name       string      `json:"name"`
+	State      string      `json:"state"`
+	StartedAt  *time.Time  `json:"started_at,omitempty"`
+	FinishedAt *time.Time  `json:"finished_at,omitempty"`
+	Error      string      `json:"error,omitempty"`
+}
+
+type progressSkipped struct {
+	ID     MigrationID `json:"id"`
+	Slug   string      `json:"slug"`
+	Name   string      `json:"name"`
+	Reason string      `json:"reason"`
+}
+
+type progressReport struct {
+	State      string              `json:"state"`
+	StartedAt  time.Time           `json:"started_at"`
+	UpdatedAt  time.Time           `json:"updated_at"`
+	FinishedAt *time.Time          `json:"finished_at,omitempty"`
+	Error      string              `json:"error,omitempty"`
+	Migrations []progressMigration `json:"migrations"`
+	// Skipped lists the pending migrations left out of the plan, and (once
+	// the run ends) the ones it stopped before.
+	Skipped []progressSkipped `json:"skipped,omitempty"`
+}
+
+// progress tracks a run for the progress file. All methods are no-ops on a nil
+// progress, which is what Migrate uses when there's no progress file.
+type progress struct {
+	m      *Migrator
+	res    *Result
+	path   string
+	mu     sync.Mutex // Parallel migrations update the report concurrently.
+	report progressReport
+}
+
+func (m *Migrator) newProgress(plan []migrationFile, res *Result) *progress {
+	if m.progressFile == "" {
+		return nil
+	}
+	now := m.clock()
+	p := &progress{
+		m:    m,
+		res:  res,
+		path: m.progressFile,
+		report: progressReport{
+			State:      stateRunning,
+			StartedAt:  now,
+			Migrations: make([]progressMigration, 0, len(plan)),
+		},
+	}
+	for _, f := range plan {
+		p.report.Migrations = append(p.report.Migrations, progressMigration{
+			ID:    f.ID,
+			Slug:  f.Slug,
+			Name:  f.Name,
+			State: statePending,
+		})
+	}
+	p.skip()
+	p.write()
+	return p
+}
+
+// skip copies the skipped migrations from the Result into the report.
+func (p *progress) skip() {
+	p.report.Skipped = p.report.Skipped[:0]
+	for _, s := range p.res.Skipped {
+		p.report.Skipped = append(p.report.Skipped, progressSkipped{
+			ID:     s.ID,
+			Slug:   s.Slug,
+			Name:   s.Name,
+			Reason: s.Reason,
+		})
+	}
+}
+
+func (p *progress) start(id MigrationID) {
+	p.update(id, func(pm *progressMigration, now time.Time) {
+		pm.State = stateRunning
+		pm.StartedAt = &now
+	})
+}
+
+func (p *progress) finish(id MigrationID) {
+	p.update(id, func(pm *progressMigration, now time.Time) {
+		pm.State = stateApplied
+		pm.FinishedAt = &now
+	})
+}
+
+func (p *progress) fail(id MigrationID, err error) {
+	p.update(id, func(pm *progressMigration, now time.Time) {
+		pm.State = stateFailed
+		pm.FinishedAt = &now
+		pm.Error = err.Error()
+	})
+}
+
+func (p *progress) end(err error) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.m.clock()
+	p.report.FinishedAt = &now
+	p.skip()
+	if err != nil {
+		p.report.State = stateFailed
+		p.report.Error = err.Error()
+	} else {
+		p.report.State = stateSucceeded
+	}
+	p.write()
+}
+
+func (p *progress) update(id MigrationID, f func(*progressMigration, time.Time)) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.m.clock()
+	for i := range p.report.Migrations {
+		if p.report.Migrations[i].ID == id {
+			f(&p.report.Migrations[i], now)
+		}
+	}
+	p.write()
+}
+
+func (p *progress) write() {
+	p.report.UpdatedAt = p.m.clock()
+	if err := writeJSONAtomic(p.m.files, p.path, p.report); err != nil {
+		// Progress reporting is best-effort: it shouldn't stop migrations.
+		warnf(p.m.io, "Could not write progress file: %s", err)
+	}
+}
+
+// writeJSONAtomic writes v as JSON to a temporary file next to path and then
+// renames it into place.
+func writeJSONAtomic(files FileSystem, path string, v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	// A unique name keeps concurrent writers from clobbering each other's
+	// temporary files.
+	tmp, err := files.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp", append(b, '\n'))
+	if err != nil {
+		return err
+	}
+	if err := files.Rename(tmp, path); err != nil {
+		files.Remove(tmp) //nolint:errcheck
+		return err
+	}
+	return nil
+}
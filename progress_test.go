@@ -0,0 +1,45 @@
+package drift
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/metagram-net/drift/internal/memfs"
+)
+
+func TestProgressFailDoesNotEnd(t *testing.T) {
+	fsys := memfs.New(nil)
+	m := New(WithFileSystem(fsys), WithProgressFile("progress.json"))
+	plan := []migrationFile{{ID: 1, Slug: "a"}, {ID: 2, Slug: "b"}}
+	p := m.newProgress(plan, &Result{})
+
+	read := func() progressReport {
+		t.Helper()
+		b, err := fsys.ReadFile("progress.json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var r progressReport
+		if err := json.Unmarshal(b, &r); err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+
+	p.start(1)
+	p.fail(1, errors.New("boom"))
+	if r := read(); r.State != stateRunning || r.FinishedAt != nil {
+		t.Fatalf("after a failed migration, got run state %q (finished %v), want it still running", r.State, r.FinishedAt)
+	}
+	p.start(2)
+	p.finish(2)
+	p.end(ErrMigrationsFailed)
+	r := read()
+	if r.State != stateFailed || r.FinishedAt == nil {
+		t.Errorf("got run state %q (finished %v), want it failed and finished", r.State, r.FinishedAt)
+	}
+	if got := r.Migrations[1].State; got != stateApplied {
+		t.Errorf("got state %q for the migration after the failure, want %q", got, stateApplied)
+	}
+}
@@ -0,0 +1,73 @@
+package drift
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// StaleRecords returns the applied migrations that have no file in
+// migrationsDir, like after squashing migrations or moving them to another
+// module.
+func (m *Migrator) StaleRecords(ctx context.Context, db *sql.DB, migrationsDir string) ([]HistoryEntry, error) {
+	hs, err := m.History(ctx, db)
+	if err != nil {
+		return nil, fmt.Errorf("could not get applied migrations: %w", err)
+	}
+	files, err := m.available(migrationsDir)
+	if err != nil {
+		return nil, fmt.Errorf("could not get available migrations: %w", err)
+	}
+	exists := make(map[MigrationID]bool, len(files))
+	for _, f := range files {
+		exists[f.ID] = true
+	}
+	var stale []HistoryEntry
+	for _, h := range hs {
+		if !exists[h.ID] {
+			stale = append(stale, h)
+		}
+	}
+	return stale, nil
+}
+
+// Prune deletes the records of the applied migrations with the IDs, in one
+// transaction. It doesn't undo any changes the migrations made. Use
+// StaleRecords to find the records that have no file.
+func (m *Migrator) Prune(ctx context.Context, db *sql.DB, ids []MigrationID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	// This is a no-op after a successful commit.
+	defer tx.Rollback() //nolint:errcheck
+
+	query, args, err := m.sb().
+		Delete(m.tableName()).
+		Where(sq.Eq{"id": ids}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	res, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n != int64(len(ids)) {
+		return fmt.Errorf("%w: expected to delete %d records but found %d", ErrNotApplied, len(ids), n)
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	m.io.Infof("Pruned %d migration records", n)
+	return nil
+}
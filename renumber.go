@@ -0,0 +1,469 @@
+package drift
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/olekukonko/tablewriter"
+)
+
+var (
+	// ErrRenameCollision means a renumbered file would overwrite a file that
+	// isn't being renamed.
+	ErrRenameCollision = errors.New("renamed file would overwrite an existing file")
+	// ErrRenumberInterrupted means an earlier renumber didn't finish.
+	ErrRenumberInterrupted = errors.New("an earlier renumber was interrupted; resume or roll it back first")
+	// ErrNoRenumberJournal means there's no interrupted renumber to resume or
+	// roll back.
+	ErrNoRenumberJournal = errors.New("no interrupted renumber to finish")
+)
+
+// WithSequentialIDs makes Renumber rewrite migration IDs to small sequential
+// integers (1, 2, 3, ...) in file order, for converting from timestamp IDs. An
+// init migration with ID 0 keeps its ID.
+//
+// Calls to _drift_claim_migration and _drift_require_migration with a literal
+// ID are updated to match. To update the records of applied migrations too,
+// use RenumberDB.
+func WithSequentialIDs() Option {
+	return func(m *Migrator) {
+		m.sequentialIDs = true
+	}
+}
+
+// renumberPrefix marks files and directories in the middle of being renamed.
+// Names starting with a dot aren't migrations, so other commands ignore them.
+const renumberPrefix = ".drift-renumber-"
+
+// renumberJournalName is the name of the file that records an unfinished
+// renumber, in the first migrations directory.
+const renumberJournalName = ".drift-renumber.json"
+
+// Renumber journal phases.
+const (
+	// phaseTemp is when files are being renamed to their temporary names.
+	phaseTemp = "temp"
+	// phaseFinal is when every file has its temporary name and they're being
+	// renamed to their new names.
+	phaseFinal = "final"
+	// phaseEdit is when every file has its new name and ID references are
+	// being updated.
+	phaseEdit = "edit"
+)
+
+type rename struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+func (r rename) temp() string {
+	return filepath.Join(filepath.Dir(r.From), renumberPrefix+filepath.Base(r.From))
+}
+
+// An edit replaces the content of a file. Path is where the file is after the
+// renames.
+type edit struct {
+	Path       string `json:"path"`
+	OldContent string `json:"old_content"`
+	Content    string `json:"content"`
+}
+
+// A renumberJournal records the renames and edits of a renumber in progress, so
+// that an interrupted one can be resumed or rolled back.
+type renumberJournal struct {
+	Phase   string   `json:"phase"`
+	Renames []rename `json:"renames"`
+	Edits   []edit   `json:"edits"`
+}
+
+// Renumber renames migration files so that their IDs all have the same width.
+// If write is false, this only prints the renames it would make.
+//
+// Files are renamed in two phases (to a temporary name, then to the new name),
+// so renames can't overwrite each other even when the new IDs overlap the old
+// ones. A journal file in the migrations directory records the renames while
+// they happen. If Renumber is interrupted, it refuses to start again until
+// RenumberResume or RenumberRollback has cleaned up.
+func (m *Migrator) Renumber(dir string, write bool) error {
+	return m.RenumberDB(context.Background(), dir, write)
+}
+
+// RenumberDB is like Renumber, but when IDs change (see WithSequentialIDs), it
+// also updates the records of applied migrations in each database to match.
+// Each database is updated in a transaction, and the files are only renamed
+// once every database has been updated.
+func (m *Migrator) RenumberDB(ctx context.Context, dir string, write bool, dbs ...*sql.DB) error {
+	io := m.io
+	journal := filepath.Join(dir, renumberJournalName)
+	if _, err := m.files.Stat(journal); err == nil {
+		return fmt.Errorf("%w: %s", ErrRenumberInterrupted, journal)
+	}
+	files, err := m.available(dir)
+	if err != nil {
+		return err
+	}
+	ids := m.renumberedIDs(files)
+	newIDs := make([]MigrationID, 0, len(ids))
+	for _, id := range ids {
+		newIDs = append(newIDs, id)
+	}
+	width := IDWidth(newIDs...)
+
+	var renames []rename
+	moved := make(map[string]string)
+	for _, f := range files {
+		entry := f.entryPath()
+		r := rename{
+			From: entry,
+			To:   filepath.Join(filepath.Dir(entry), f.renamed(width, ids[f.ID], f.Slug)),
+		}
+		if r.From == r.To {
+			continue
+		}
+		renames = append(renames, r)
+		moved[r.From] = r.To
+		// Keep the companion directory (from NewScaffold) with its file.
+		companion := strings.TrimSuffix(entry, ".sql")
+		if info, err := m.files.Stat(companion); !f.inDir && err == nil && info.IsDir() {
+			renames = append(renames, rename{
+				From: companion,
+				To:   strings.TrimSuffix(r.To, ".sql"),
+			})
+		}
+	}
+	edits := idReferenceEdits(files, ids, moved)
+
+	if len(renames) == 0 && len(edits) == 0 {
+		io.Infof("Nothing to do.")
+		return nil
+	}
+	if err := m.checkCollisions(renames); err != nil {
+		return err
+	}
+
+	if len(renames) > 0 {
+		io.Infof("Renames:")
+		var b bytes.Buffer
+		t := tablewriter.NewWriter(&b)
+		t.SetAutoFormatHeaders(false)
+		t.SetHeader([]string{"Old", "->", "New"})
+		for _, r := range renames {
+			t.Append([]string{filepath.Base(r.From), "->", filepath.Base(r.To)})
+		}
+		t.Render()
+		io.Infof(b.String())
+	}
+	for _, e := range edits {
+		io.Infof("Updating migration ID references: %s", e.Path)
+	}
+
+	changed := make(map[MigrationID]MigrationID)
+	for from, to := range ids {
+		if from != to {
+			changed[from] = to
+		}
+	}
+	if len(dbs) > 0 && len(changed) > 0 {
+		io.Infof("The IDs of applied migrations will be updated in %d databases", len(dbs))
+	}
+
+	if !write {
+		io.Infof("Skipping renames because write is off")
+		return nil
+	}
+
+	if len(dbs) > 0 && len(changed) > 0 {
+		if err := m.renumberRecords(ctx, dbs, changed); err != nil {
+			return fmt.Errorf("could not update the migrations table: %w", err)
+		}
+	}
+
+	j := &renumberJournal{Phase: phaseTemp, Renames: renames, Edits: edits}
+	if err := writeJSONAtomic(m.files, journal, j); err != nil {
+		return fmt.Errorf("could not write the renumber journal: %w", err)
+	}
+	if err := m.finishRenumber(journal, j); err != nil {
+		return err
+	}
+	if len(changed) > 0 && len(dbs) == 0 {
+		warnf(io, "Migration IDs changed, so update the migrations table of every database to match.")
+	}
+	io.Infof("Done!")
+	return nil
+}
+
+// RenumberResume finishes a renumber that was interrupted.
+func (m *Migrator) RenumberResume(dir string) error {
+	journal, j, err := m.readRenumberJournal(dir)
+	if err != nil {
+		return err
+	}
+	if err := m.finishRenumber(journal, j); err != nil {
+		return err
+	}
+	m.io.Infof("Done!")
+	return nil
+}
+
+// RenumberRollback undoes the renames and edits of a renumber that was
+// interrupted. It doesn't undo changes to the migrations table.
+func (m *Migrator) RenumberRollback(dir string) error {
+	journal, j, err := m.readRenumberJournal(dir)
+	if err != nil {
+		return err
+	}
+	m.io.Infof("Rolling back renumber from phase %s", j.Phase)
+	// Files are only edited once they have their new names.
+	if j.Phase == phaseEdit {
+		for _, e := range j.Edits {
+			if err := m.writeEdit(e.Path, e.OldContent); err != nil {
+				return err
+			}
+		}
+	}
+	// Every new name that exists was made by the final phase, since none of
+	// them existed before (unless they were renamed out of the way first).
+	if j.Phase != phaseTemp {
+		for _, r := range j.Renames {
+			if err := m.renameIfExists(r.To, r.temp()); err != nil {
+				return err
+			}
+		}
+	}
+	for _, r := range j.Renames {
+		if err := m.renameIfExists(r.temp(), r.From); err != nil {
+			return err
+		}
+	}
+	if err := m.files.Remove(journal); err != nil {
+		return err
+	}
+	m.io.Infof("Rolled back.")
+	return nil
+}
+
+// finishRenumber carries out the journal from its current phase, updating the
+// journal as each phase completes. Each step can be repeated, so this also
+// resumes an interrupted renumber.
+func (m *Migrator) finishRenumber(journal string, j *renumberJournal) error {
+	if j.Phase == phaseTemp {
+		m.io.Infof("Renaming files")
+		for _, r := range j.Renames {
+			if err := m.renameIfExists(r.From, r.temp()); err != nil {
+				return err
+			}
+		}
+		j.Phase = phaseFinal
+		if err := writeJSONAtomic(m.files, journal, j); err != nil {
+			return fmt.Errorf("could not update the renumber journal: %w", err)
+		}
+	}
+	if j.Phase == phaseFinal {
+		for _, r := range j.Renames {
+			if err := m.renameIfExists(r.temp(), r.To); err != nil {
+				return err
+			}
+		}
+		j.Phase = phaseEdit
+		if err := writeJSONAtomic(m.files, journal, j); err != nil {
+			return fmt.Errorf("could not update the renumber journal: %w", err)
+		}
+	}
+	for _, e := range j.Edits {
+		if err := m.writeEdit(e.Path, e.Content); err != nil {
+			return err
+		}
+	}
+	return m.files.Remove(journal)
+}
+
+func (m *Migrator) readRenumberJournal(dir string) (string, *renumberJournal, error) {
+	journal := filepath.Join(dir, renumberJournalName)
+	b, err := m.files.ReadFile(journal)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil, fmt.Errorf("%w: %s", ErrNoRenumberJournal, journal)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	var j renumberJournal
+	if err := json.Unmarshal(b, &j); err != nil {
+		return "", nil, fmt.Errorf("could not read the renumber journal: %w", err)
+	}
+	return journal, &j, nil
+}
+
+// renameIfExists renames from to to, unless from doesn't exist because an
+// earlier attempt already renamed it.
+func (m *Migrator) renameIfExists(from, to string) error {
+	if _, err := m.files.Stat(from); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return m.files.Rename(from, to)
+}
+
+// writeEdit replaces the content of the file at path, keeping its permissions.
+func (m *Migrator) writeEdit(path, content string) error {
+	info, err := m.files.Stat(path)
+	if err != nil {
+		return err
+	}
+	return m.files.WriteFile(path, []byte(content), info.Mode().Perm())
+}
+
+// renumberRecords changes the IDs of the applied migrations in every database.
+// Nothing is committed unless every database can be updated.
+func (m *Migrator) renumberRecords(ctx context.Context, dbs []*sql.DB, changed map[MigrationID]MigrationID) error {
+	olds := make([]MigrationID, 0, len(changed))
+	for from := range changed {
+		olds = append(olds, from)
+	}
+	sort.Slice(olds, func(i, j int) bool { return olds[i] < olds[j] })
+
+	txs := make([]*sql.Tx, 0, len(dbs))
+	defer func() {
+		// This is a no-op for the transactions that were committed.
+		for _, tx := range txs {
+			tx.Rollback() //nolint:errcheck
+		}
+	}()
+	for i, db := range dbs {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		txs = append(txs, tx)
+		n, err := m.renumberTable(ctx, tx, olds, changed)
+		if err != nil {
+			return fmt.Errorf("database %d: %w", i+1, err)
+		}
+		m.io.Infof("Database %d: updated %d migration records", i+1, n)
+	}
+	for i, tx := range txs {
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("database %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// renumberTable changes the IDs in the migrations table. The old IDs are
+// negated first, so that new IDs can reuse old ones without breaking the
+// primary key partway through.
+func (m *Migrator) renumberTable(ctx context.Context, tx *sql.Tx, olds []MigrationID, changed map[MigrationID]MigrationID) (int64, error) {
+	query, args, err := m.sb().
+		Update(m.tableName()).
+		Set("id", sq.Expr("-id")).
+		Where(sq.Eq{"id": olds}).
+		ToSql()
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, from := range olds {
+		query, args, err := m.sb().
+			Update(m.tableName()).
+			Set("id", changed[from]).
+			Where(sq.Eq{"id": -from}).
+			ToSql()
+		if err != nil {
+			return 0, err
+		}
+		res, err := tx.ExecContext(ctx, query, args...)
+		if err != nil {
+			return 0, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// renumberedIDs maps each file's ID to its ID after renumbering.
+func (m *Migrator) renumberedIDs(files []migrationFile) map[MigrationID]MigrationID {
+	ids := make(map[MigrationID]MigrationID, len(files))
+	next := MigrationID(1)
+	for _, f := range files {
+		if !m.sequentialIDs || f.ID == 0 {
+			ids[f.ID] = f.ID
+			continue
+		}
+		ids[f.ID] = next
+		next++
+	}
+	return ids
+}
+
+// checkCollisions returns an error if a rename would overwrite a file that
+// isn't renamed out of the way first.
+func (m *Migrator) checkCollisions(renames []rename) error {
+	moving := make(map[string]bool, len(renames))
+	for _, r := range renames {
+		moving[r.From] = true
+	}
+	seen := make(map[string]bool, len(renames))
+	for _, r := range renames {
+		// Compare case-insensitively, since some filesystems do.
+		key := strings.ToLower(r.To)
+		if seen[key] {
+			return fmt.Errorf("%w: %s", ErrRenameCollision, r.To)
+		}
+		seen[key] = true
+		if moving[r.To] {
+			continue
+		}
+		if _, err := m.files.Stat(r.To); err == nil {
+			return fmt.Errorf("%w: %s", ErrRenameCollision, r.To)
+		}
+	}
+	return nil
+}
+
+// reIDReference finds calls to Drift's functions (or a module's suffixed
+// versions of them) that refer to a migration by a literal ID.
+var reIDReference = regexp.MustCompile(`(_drift_(?:claim|require)_migration(?:_\w+)?\s*\(\s*)(\d+)`)
+
+// idReferenceEdits updates the migration IDs that files refer to. The moved
+// map gives the new path of each renamed file or directory.
+func idReferenceEdits(files []migrationFile, ids map[MigrationID]MigrationID, moved map[string]string) []edit {
+	var edits []edit
+	for _, f := range files {
+		content := reIDReference.ReplaceAllStringFunc(f.Content, func(call string) string {
+			sm := reIDReference.FindStringSubmatch(call)
+			var id MigrationID
+			if err := id.Set(sm[2]); err != nil {
+				return call
+			}
+			to, ok := ids[id]
+			if !ok || to == id {
+				return call
+			}
+			return sm[1] + to.String()
+		})
+		if content == f.Content {
+			continue
+		}
+		path := f.Path
+		if to, ok := moved[f.entryPath()]; ok {
+			path = filepath.Join(to, strings.TrimPrefix(f.Path, f.entryPath()))
+		}
+		edits = append(edits, edit{Path: path, OldContent: f.Content, Content: content})
+	}
+	return edits
+}
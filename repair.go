@@ -0,0 +1,175 @@
+package drift
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/blockloop/scan"
+	"github.com/jackc/pgx/v4"
+)
+
+// DropInvalidIndexes drops the invalid indexes in the default schema and
+// returns their names. See Migrator.DropInvalidIndexes for details.
+func DropInvalidIndexes(ctx context.Context, io IO, db *sql.DB) ([]string, error) {
+	return New(WithLogger(io)).DropInvalidIndexes(ctx, db)
+}
+
+// DropInvalidIndexes drops every invalid index in the migrations schema and
+// returns the names of the dropped indexes.
+//
+// A failed CREATE INDEX CONCURRENTLY leaves behind an invalid index that still
+// holds the name, so retrying the migration fails with a duplicate name error.
+// Dropping the invalid index makes the migration safe to retry. Indexes that
+// another session is still building are invalid too, so they're left alone.
+// This needs Postgres 12 or newer.
+func (m *Migrator) DropInvalidIndexes(ctx context.Context, db *sql.DB) ([]string, error) {
+	return m.dropInvalidIndexes(ctx, db, []string{m.schema}, nil)
+}
+
+// DropFailedIndexes is like DropInvalidIndexes, but it only drops the indexes
+// that the migration with the ID creates concurrently. Those can be in any
+// schema: an index name without a schema is looked for in the current schema.
+func (m *Migrator) DropFailedIndexes(ctx context.Context, db *sql.DB, migrationsDir string, id MigrationID) ([]string, error) {
+	files, err := m.available(migrationsDir)
+	if err != nil {
+		return nil, fmt.Errorf("could not get available migrations: %w", err)
+	}
+	for _, f := range files {
+		if f.ID != id {
+			continue
+		}
+		names := concurrentIndexes(f.Content)
+		var current string
+		for idx := range names {
+			if idx.Schema == "" {
+				if err := db.QueryRowContext(ctx, "select current_schema()").Scan(&current); err != nil {
+					return nil, fmt.Errorf("could not get the current schema: %w", err)
+				}
+				break
+			}
+		}
+		return m.dropInvalidIndexes(ctx, db, nil, qualifyIndexes(names, current))
+	}
+	return nil, fmt.Errorf("%w: %d", ErrUnknownMigration, id)
+}
+
+// reConcurrentIndex finds the names of indexes created concurrently.
+var reConcurrentIndex = regexp.MustCompile(`(?is)\bcreate\s+(?:unique\s+)?index\s+concurrently\s+(?:if\s+not\s+exists\s+)?([^\s(]+)\s+on\b`)
+
+// concurrentIndexes returns the normalized names of the indexes that the
+// content creates concurrently. The schema is empty if the name has none.
+func concurrentIndexes(content string) map[indexName]bool {
+	names := make(map[indexName]bool)
+	for _, sm := range reConcurrentIndex.FindAllStringSubmatch(stripComments(content), -1) {
+		var idx indexName
+		if i := strings.LastIndex(sm[1], "."); i >= 0 {
+			idx.Schema = sm[1][:i]
+		}
+		idx.Name = sm[1][strings.LastIndex(sm[1], ".")+1:]
+		names[idx.normalized()] = true
+	}
+	return names
+}
+
+// qualifyIndexes puts the index names without a schema in the current schema.
+func qualifyIndexes(names map[indexName]bool, current string) map[indexName]bool {
+	qualified := make(map[indexName]bool, len(names))
+	for idx := range names {
+		if idx.Schema == "" {
+			idx.Schema = sqlName(current)
+		}
+		qualified[idx] = true
+	}
+	return qualified
+}
+
+// dropInvalidIndexes drops the invalid indexes in the schemas (or every
+// schema, if schemas is nil), only the named ones if names isn't nil. The
+// names must be normalized and schema-qualified.
+func (m *Migrator) dropInvalidIndexes(ctx context.Context, db *sql.DB, schemas []string, names map[indexName]bool) ([]string, error) {
+	idxs, err := m.invalidIndexes(ctx, db, schemas)
+	if err != nil {
+		return nil, fmt.Errorf("could not find invalid indexes: %w", err)
+	}
+	var dropped []string
+	for _, idx := range idxs {
+		if names != nil && !names[idx.normalized()] {
+			continue
+		}
+		ident := pgx.Identifier{idx.Schema, idx.Name}.Sanitize()
+		// The index may have been dropped, or a build that was still going
+		// may have finished, since it was listed. Dropping concurrently
+		// would wait for such a build and then drop the finished index.
+		droppable, err := m.stillInvalid(ctx, db, ident)
+		if err != nil {
+			return dropped, fmt.Errorf("could not check index %s: %w", idx, err)
+		}
+		if !droppable {
+			m.io.Infof("Skipping index that's no longer invalid: %s", idx)
+			continue
+		}
+		m.io.Infof("Dropping invalid index: %s", idx)
+		// Concurrent drops can't run in a transaction, but they also don't
+		// block reads and writes on the table.
+		if _, err := db.ExecContext(ctx, "drop index concurrently if exists "+ident); err != nil {
+			return dropped, fmt.Errorf("could not drop index %s: %w", idx, err)
+		}
+		dropped = append(dropped, idx.String())
+	}
+	if len(dropped) == 0 {
+		m.io.Infof("No invalid indexes to drop.")
+	}
+	return dropped, nil
+}
+
+// notBuilding excludes indexes that a CREATE INDEX CONCURRENTLY (or REINDEX)
+// is still building, which are invalid until it finishes.
+const notBuilding = "not exists (select 1 from pg_stat_progress_create_index p where p.index_relid = i.indexrelid)"
+
+// invalidIndexes lists the invalid indexes in the schemas that aren't being
+// built. If schemas is nil, it looks in every schema except the system ones
+// (pg_catalog, pg_toast, the temporary schemas, and information_schema).
+func (m *Migrator) invalidIndexes(ctx context.Context, db *sql.DB, schemas []string) ([]indexName, error) {
+	b := pq.
+		Select("n.nspname as schema", "c.relname as name").
+		From("pg_index i").
+		Join("pg_class c on c.oid = i.indexrelid").
+		Join("pg_namespace n on n.oid = c.relnamespace").
+		Where("not i.indisvalid").
+		Where(`n.nspname not like 'pg\_%' and n.nspname <> 'information_schema'`).
+		Where(notBuilding).
+		OrderBy("n.nspname", "c.relname")
+	if schemas != nil {
+		b = b.Where(sq.Eq{"n.nspname": schemas})
+	}
+	query, args, err := b.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	var idxs []indexName
+	return idxs, scan.RowsStrict(&idxs, rows)
+}
+
+// stillInvalid reports whether the index still exists, is still invalid, and
+// isn't being built.
+func (m *Migrator) stillInvalid(ctx context.Context, db *sql.DB, ident string) (bool, error) {
+	query, args, err := pq.
+		Select("1").
+		From("pg_index i").
+		Where(sq.Expr("i.indexrelid = to_regclass(?)", ident)).
+		Where("not i.indisvalid").
+		Where(notBuilding).
+		ToSql()
+	if err != nil {
+		return false, err
+	}
+	return exists(ctx, db, query, args...)
+}
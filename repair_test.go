@@ -0,0 +1,44 @@
+package drift
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConcurrentIndexes(t *testing.T) {
+	content := `--drift:no-transaction
+create index concurrently users_email on users (email);
+CREATE UNIQUE INDEX CONCURRENTLY IF NOT EXISTS app."Teams_Name" ON app.teams (name);
+-- create index concurrently commented_out on users (name);
+create index users_plain on users (name);
+`
+	want := map[indexName]bool{
+		{Name: "users_email"}:               true,
+		{Schema: "app", Name: "teams_name"}: true,
+	}
+	if got := concurrentIndexes(content); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestQualifyIndexes(t *testing.T) {
+	names := qualifyIndexes(concurrentIndexes(`
+create index concurrently users_email on users (email);
+create index concurrently app."Teams_Name" on app.teams (name);
+`), "public")
+
+	tests := []struct {
+		idx  indexName
+		want bool
+	}{
+		{indexName{"public", "users_email"}, true},
+		{indexName{"app", "users_email"}, false},
+		{indexName{"app", "Teams_Name"}, true},
+		{indexName{"public", "Teams_Name"}, false},
+	}
+	for _, tt := range tests {
+		if got := names[tt.idx.normalized()]; got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.idx, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package drift
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Result describes what a migration run did.
+type Result struct {
+	// Applied lists the migrations applied during the run, in order.
+	Applied []AppliedMigration
+	// Skipped lists pending migrations that the run deliberately left
+	// unapplied (because of upto, WithOnly, WithSteps, WithStopAfter,
+	// WithStopSignal, a failure with WithContinueOnError, a rolled back
+	// TransactionAll batch, or WithDryRun).
+	Skipped []SkippedMigration
+	// Version is the greatest applied migration ID after the run, or -1 if no
+	// migrations have been applied.
+	Version MigrationID
+	// Pending is the number of available migrations left unapplied after the
+	// run, including skipped and failed ones.
+	Pending int
+	// Failed lists the migrations that failed during the run. It has more
+	// than one entry only with WithParallel or WithContinueOnError.
+	Failed []FailedMigration
+	// Explained lists the query plans of the DML statements in the planned
+	// migrations, with WithDryRun and WithExplain.
+	Explained []ExplainedStatement
+}
+
+// An AppliedMigration is a migration applied during a run.
+type AppliedMigration struct {
+	ID       MigrationID
+	Slug     string
+	Name     string
+	Path     string
+	Duration time.Duration
+}
+
+// A SkippedMigration is a pending migration left unapplied by a run.
+type SkippedMigration struct {
+	ID     MigrationID
+	Slug   string
+	Name   string
+	Path   string
+	Reason string
+}
+
+// A FailedMigration is a migration that failed to apply during a run.
+type FailedMigration struct {
+	ID   MigrationID
+	Slug string
+	Name string
+	Path string
+	Err  error
+}
+
+// Reasons for skipping a migration.
+const (
+	SkipUpto      = "upto"
+	SkipOnly      = "only"
+	SkipSteps     = "steps"
+	SkipStopAfter = "stop-after"
+	SkipStopped   = "stopped"
+	// SkipFailedDependency marks a migration left unapplied by
+	// WithContinueOnError because it depends on one that failed (or was
+	// itself skipped).
+	SkipFailedDependency = "failed-dependency"
+	// SkipUndeclaredDependencies marks a migration left unapplied by
+	// WithContinueOnError because it doesn't declare its dependencies, so it
+	// might depend on the one that failed.
+	SkipUndeclaredDependencies = "undeclared-dependencies"
+	// SkipRolledBack marks a migration that was applied in the batch
+	// transaction, or not reached, when TransactionAll rolled the batch back.
+	SkipRolledBack = "rolled-back"
+	// SkipDryRun marks a migration that WithDryRun would have applied.
+	SkipDryRun = "dry-run"
+)
+
+func newResult(records []migrationRecord) *Result {
+	r := &Result{Version: -1}
+	for _, rec := range records {
+		if rec.ID > r.Version {
+			r.Version = rec.ID
+		}
+	}
+	return r
+}
+
+func (r *Result) applied(f migrationFile, d time.Duration) {
+	r.Applied = append(r.Applied, AppliedMigration{
+		ID:       f.ID,
+		Slug:     f.Slug,
+		Name:     f.Name,
+		Path:     f.Path,
+		Duration: d,
+	})
+	if f.ID > r.Version {
+		r.Version = f.ID
+	}
+	r.Pending--
+}
+
+func (r *Result) failed(f migrationFile, err error) {
+	r.Failed = append(r.Failed, FailedMigration{
+		ID:   f.ID,
+		Slug: f.Slug,
+		Name: f.Name,
+		Path: f.Path,
+		Err:  err,
+	})
+}
+
+// SkippedFor returns the skipped migrations that were left unapplied for the
+// reason, like SkipUpto.
+func (r *Result) SkippedFor(reason string) []SkippedMigration {
+	var ss []SkippedMigration
+	for _, s := range r.Skipped {
+		if s.Reason == reason {
+			ss = append(ss, s)
+		}
+	}
+	return ss
+}
+
+// logFinished reports the end of a successful run. Only a run that left
+// nothing pending claims that all migrations were applied.
+func (m *Migrator) logFinished(res *Result) {
+	if len(res.Skipped) == 0 {
+		m.io.Infof("All migrations applied!")
+		return
+	}
+	var reasons []string
+	for _, reason := range []string{SkipUpto, SkipOnly, SkipSteps, SkipStopAfter, SkipStopped} {
+		ss := res.SkippedFor(reason)
+		if len(ss) == 0 {
+			continue
+		}
+		ids := make([]string, 0, len(ss))
+		for _, s := range ss {
+			ids = append(ids, s.ID.String())
+		}
+		reasons = append(reasons, fmt.Sprintf("%s: %s", reason, strings.Join(ids, ", ")))
+	}
+	warnf(m.io, "Applied %d migrations, but left %d pending (%s)", len(res.Applied), len(res.Skipped), strings.Join(reasons, "; "))
+}
+
+func (r *Result) skipped(f migrationFile, reason string) {
+	r.Skipped = append(r.Skipped, SkippedMigration{
+		ID:     f.ID,
+		Slug:   f.Slug,
+		Name:   f.Name,
+		Path:   f.Path,
+		Reason: reason,
+	})
+}
@@ -0,0 +1,297 @@
+package drift
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/blockloop/scan"
+)
+
+// A Schema is the tables in a database, as read from the system catalogs by
+// Inspect.
+type Schema struct {
+	Tables []Table
+}
+
+// A Table is one table in a Schema.
+type Table struct {
+	Schema      string
+	Name        string
+	Columns     []Column
+	Constraints []Constraint
+	// Indexes are the indexes that aren't part of a constraint.
+	Indexes []Index
+}
+
+// A Constraint is a table constraint, like a primary key or a foreign key.
+type Constraint struct {
+	Name string
+	// Type is the Postgres constraint type: c (check), f (foreign key), p
+	// (primary key), u (unique), or x (exclusion).
+	Type       string
+	Definition string
+}
+
+// An Index is an index on a table.
+type Index struct {
+	Name       string
+	Definition string
+}
+
+// QualifiedName returns the quoted, schema-qualified table name.
+func (t Table) QualifiedName() string {
+	return Postgres{}.Quote(t.Schema, t.Name)
+}
+
+// userRelations limits a query on pg_class c and pg_namespace n to the tables
+// in user schemas that don't belong to an extension.
+func userRelations(q string) string {
+	return q + ` where c.relkind in ('r', 'p')
+		and n.nspname not in ('pg_catalog', 'information_schema')
+		and n.nspname not like 'pg\_toast%'
+		and n.nspname not like 'pg\_temp%'
+		and not exists (
+			select 1 from pg_depend dep
+			where dep.classid = 'pg_class'::regclass and dep.objid = c.oid and dep.deptype = 'e'
+		)`
+}
+
+var qSchemaTables = userRelations(`
+	select n.nspname as schema, c.relname as table
+	from pg_class c
+	join pg_namespace n on n.oid = c.relnamespace`) + `
+	order by 1, 2`
+
+var qSchemaColumns = userRelations(`
+	select
+		n.nspname as schema,
+		c.relname as table,
+		a.attname as name,
+		format_type(a.atttypid, a.atttypmod) as type,
+		not a.attnotnull as nullable,
+		coalesce(pg_get_expr(d.adbin, d.adrelid), '') as "default"
+	from pg_attribute a
+	join pg_class c on c.oid = a.attrelid
+	join pg_namespace n on n.oid = c.relnamespace
+	left join pg_attrdef d on d.adrelid = a.attrelid and d.adnum = a.attnum`) + `
+		and a.attnum > 0
+		and not a.attisdropped
+	order by 1, 2, a.attnum`
+
+var qSchemaConstraints = userRelations(`
+	select
+		n.nspname as schema,
+		c.relname as table,
+		con.conname as name,
+		con.contype::text as type,
+		pg_get_constraintdef(con.oid) as definition
+	from pg_constraint con
+	join pg_class c on c.oid = con.conrelid
+	join pg_namespace n on n.oid = c.relnamespace`) + `
+	order by 1, 2, 3`
+
+var qSchemaIndexes = userRelations(`
+	select
+		n.nspname as schema,
+		c.relname as table,
+		i.relname as name,
+		pg_get_indexdef(i.oid) as definition
+	from pg_index x
+	join pg_class i on i.oid = x.indexrelid
+	join pg_class c on c.oid = x.indrelid
+	join pg_namespace n on n.oid = c.relnamespace`) + `
+		and not exists (select 1 from pg_constraint con where con.conindid = x.indexrelid)
+	order by 1, 2, 3`
+
+// Inspect reads the tables, columns, constraints, and indexes in every user
+// schema of the database from the system catalogs. Other objects (like views,
+// functions, and types) and objects that belong to extensions are left out.
+func Inspect(ctx context.Context, db *sql.DB) (*Schema, error) {
+	var trs []tableRef
+	if err := queryAll(ctx, db, &trs, qSchemaTables); err != nil {
+		return nil, fmt.Errorf("could not list tables: %w", err)
+	}
+	s := &Schema{Tables: make([]Table, 0, len(trs))}
+	for _, tr := range trs {
+		s.Tables = append(s.Tables, Table{Schema: tr.Schema, Name: tr.Table})
+	}
+	byName := make(map[tableRef]*Table, len(trs))
+	for i, tr := range trs {
+		byName[tr] = &s.Tables[i]
+	}
+
+	var cols []struct {
+		Schema   string `db:"schema"`
+		Table    string `db:"table"`
+		Name     string `db:"name"`
+		Type     string `db:"type"`
+		Nullable bool   `db:"nullable"`
+		Default  string `db:"default"`
+	}
+	if err := queryAll(ctx, db, &cols, qSchemaColumns); err != nil {
+		return nil, fmt.Errorf("could not list columns: %w", err)
+	}
+	for _, c := range cols {
+		if t := byName[tableRef{c.Schema, c.Table}]; t != nil {
+			t.Columns = append(t.Columns, Column{Name: c.Name, Type: c.Type, Nullable: c.Nullable, Default: c.Default})
+		}
+	}
+
+	var cons []struct {
+		Schema     string `db:"schema"`
+		Table      string `db:"table"`
+		Name       string `db:"name"`
+		Type       string `db:"type"`
+		Definition string `db:"definition"`
+	}
+	if err := queryAll(ctx, db, &cons, qSchemaConstraints); err != nil {
+		return nil, fmt.Errorf("could not list constraints: %w", err)
+	}
+	for _, c := range cons {
+		if t := byName[tableRef{c.Schema, c.Table}]; t != nil {
+			t.Constraints = append(t.Constraints, Constraint{Name: c.Name, Type: c.Type, Definition: c.Definition})
+		}
+	}
+
+	var idxs []struct {
+		Schema     string `db:"schema"`
+		Table      string `db:"table"`
+		Name       string `db:"name"`
+		Definition string `db:"definition"`
+	}
+	if err := queryAll(ctx, db, &idxs, qSchemaIndexes); err != nil {
+		return nil, fmt.Errorf("could not list indexes: %w", err)
+	}
+	for _, x := range idxs {
+		if t := byName[tableRef{x.Schema, x.Table}]; t != nil {
+			t.Indexes = append(t.Indexes, Index{Name: x.Name, Definition: x.Definition})
+		}
+	}
+	return s, nil
+}
+
+// Without returns a copy of the schema without the table.
+func (s *Schema) Without(schema, table string) *Schema {
+	out := &Schema{Tables: make([]Table, 0, len(s.Tables))}
+	for _, t := range s.Tables {
+		if t.Schema != schema || t.Name != table {
+			out.Tables = append(out.Tables, t)
+		}
+	}
+	return out
+}
+
+type tableRef struct {
+	Schema string `db:"schema"`
+	Table  string `db:"table"`
+}
+
+func queryAll(ctx context.Context, db *sql.DB, v any, query string) error {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	return scan.RowsStrict(v, rows)
+}
+
+// SQL returns DDL that creates the schema. Foreign keys are added after all
+// the tables are created, so the tables can be in any order.
+func (s *Schema) SQL() string {
+	var b strings.Builder
+	for _, t := range s.Tables {
+		b.WriteString(t.createSQL())
+		b.WriteString("\n")
+		for _, x := range t.Indexes {
+			b.WriteString(x.Definition + ";\n")
+		}
+		if len(t.Indexes) > 0 {
+			b.WriteString("\n")
+		}
+	}
+	for _, t := range s.Tables {
+		for _, c := range t.Constraints {
+			if c.Type == "f" {
+				b.WriteString(addConstraintSQL(t, c) + "\n")
+			}
+		}
+	}
+	return b.String()
+}
+
+// createSQL returns the CREATE TABLE statement for the table, with all of its
+// constraints except foreign keys.
+func (t Table) createSQL() string {
+	var lines []string
+	for _, c := range t.Columns {
+		lines = append(lines, "    "+columnSQL(c))
+	}
+	for _, c := range t.Constraints {
+		if c.Type != "f" {
+			lines = append(lines, "    "+constraintSQL(c))
+		}
+	}
+	return fmt.Sprintf("CREATE TABLE %s (\n%s\n);\n", t.QualifiedName(), strings.Join(lines, ",\n"))
+}
+
+// columnSQL returns the column definition as it appears in CREATE TABLE.
+func columnSQL(c Column) string {
+	s := Postgres{}.Quote(c.Name) + " " + c.Type
+	if !c.Nullable {
+		s += " NOT NULL"
+	}
+	if c.Default != "" {
+		s += " DEFAULT " + c.Default
+	}
+	return s
+}
+
+func constraintSQL(c Constraint) string {
+	return "CONSTRAINT " + Postgres{}.Quote(c.Name) + " " + c.Definition
+}
+
+func addConstraintSQL(t Table, c Constraint) string {
+	return fmt.Sprintf("ALTER TABLE %s ADD %s;", t.QualifiedName(), constraintSQL(c))
+}
+
+// DumpSchema writes a structure dump of the database to w: DDL for its schema
+// (see Inspect), without the lock and failures tables, followed by the
+// contents of the migrations table. The dump only depends on the database's
+// structure and applied migrations, so committing it makes schema changes
+// reviewable alongside the migrations.
+func (m *Migrator) DumpSchema(ctx context.Context, db *sql.DB, w io.Writer) error {
+	s, err := Inspect(ctx, db)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, m.withoutBookkeeping(s).SQL()); err != nil {
+		return err
+	}
+	return m.DumpRecords(ctx, db, w)
+}
+
+// DumpRecords writes an INSERT statement for the IDs and slugs of the applied
+// migrations to w. Other columns (like run_at) differ between databases, so
+// they're left out.
+func (m *Migrator) DumpRecords(ctx context.Context, db *sql.DB, w io.Writer) error {
+	records, err := m.applied(ctx, db)
+	if err != nil {
+		return fmt.Errorf("could not get applied migrations: %w", err)
+	}
+	if len(records) == 0 {
+		return nil
+	}
+	values := make([]string, 0, len(records))
+	for _, r := range records {
+		values = append(values, fmt.Sprintf("(%d, %s)", r.ID, quoteLiteral(r.Slug)))
+	}
+	_, err = fmt.Fprintf(w, "\nINSERT INTO %s (id, slug) VALUES\n%s;\n", m.tableName(), strings.Join(values, ",\n"))
+	return err
+}
+
+// quoteLiteral quotes s as a SQL string literal.
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,355 @@
+package drift
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrScratchNotEmpty means the scratch database given to PlanSchema already
+// has tables in it.
+var ErrScratchNotEmpty = errors.New("scratch database is not empty")
+
+// A SchemaChange is one difference between two schemas.
+type SchemaChange struct {
+	// Object is "table", "column", "constraint", or "index".
+	Object string
+	// Name identifies the object, like "public"."users"."email" for a column.
+	Name string
+	// Detail describes the difference, like "added" or "type text ->
+	// varchar(255)".
+	Detail string
+	// SQL is the DDL that changes the object from the first schema to match
+	// the second.
+	SQL string
+
+	// phase orders the changes so that each one's SQL can run after the
+	// previous ones, like creating tables before the foreign keys that
+	// reference them.
+	phase int
+}
+
+func (c SchemaChange) String() string {
+	return fmt.Sprintf("%s %s: %s", c.Object, c.Name, c.Detail)
+}
+
+const (
+	phaseDropForeignKey = iota
+	phaseDropIndex
+	phaseDropConstraint
+	phaseDropColumn
+	phaseDropTable
+	phaseCreateTable
+	phaseColumn
+	phaseAddConstraint
+	phaseCreateIndex
+	phaseAddForeignKey
+)
+
+const (
+	detailAdded   = "added"
+	detailDropped = "dropped"
+)
+
+// DiffSchemas compares two schemas (from Inspect) and returns what changes
+// from the first to the second, in an order in which the SQL of each change
+// can be run against the first to make it match the second.
+func DiffSchemas(from, to *Schema) []SchemaChange {
+	var cs []SchemaChange
+	src := tablesByName(from)
+	dst := tablesByName(to)
+	for _, t := range from.Tables {
+		if _, ok := dst[t.QualifiedName()]; !ok {
+			cs = append(cs, SchemaChange{
+				Object: "table",
+				Name:   t.QualifiedName(),
+				Detail: detailDropped,
+				SQL:    fmt.Sprintf("DROP TABLE %s;", t.QualifiedName()),
+				phase:  phaseDropTable,
+			})
+		}
+	}
+	for _, t := range to.Tables {
+		s, ok := src[t.QualifiedName()]
+		if !ok {
+			cs = append(cs, SchemaChange{
+				Object: "table",
+				Name:   t.QualifiedName(),
+				Detail: detailAdded,
+				SQL:    strings.TrimSuffix(t.createSQL(), "\n"),
+				phase:  phaseCreateTable,
+			})
+			// The new table's columns and other constraints are in the
+			// CREATE TABLE statement.
+			s = Table{Schema: t.Schema, Name: t.Name, Columns: t.Columns}
+			for _, c := range t.Constraints {
+				if c.Type != "f" {
+					s.Constraints = append(s.Constraints, c)
+				}
+			}
+		}
+		cs = append(cs, diffColumns(s, t)...)
+		cs = append(cs, diffConstraints(s, t)...)
+		cs = append(cs, diffIndexes(s, t)...)
+	}
+	sort.SliceStable(cs, func(i, j int) bool {
+		return cs[i].phase < cs[j].phase
+	})
+	return cs
+}
+
+// PlanSchema compares the database with the desired schema in schemaSQL (the
+// DDL that creates it from nothing, like a structure dump) and returns the
+// changes that would make the database match it.
+//
+// The desired schema is read back from scratch, an empty database that
+// schemaSQL runs in. Create one for the purpose, and drop it afterward.
+// Drift's migrations, lock, and failures tables are left out of the
+// comparison.
+func (m *Migrator) PlanSchema(ctx context.Context, db, scratch *sql.DB, schemaSQL string) ([]SchemaChange, error) {
+	empty, err := Inspect(ctx, scratch)
+	if err != nil {
+		return nil, fmt.Errorf("could not inspect the scratch database: %w", err)
+	}
+	if len(empty.Tables) > 0 {
+		return nil, fmt.Errorf("%w: it has table %s", ErrScratchNotEmpty, empty.Tables[0].QualifiedName())
+	}
+	if _, err := scratch.ExecContext(ctx, schemaSQL); err != nil {
+		return nil, fmt.Errorf("could not create the desired schema: %w", statementError(schemaSQL, err))
+	}
+	want, err := Inspect(ctx, scratch)
+	if err != nil {
+		return nil, fmt.Errorf("could not inspect the desired schema: %w", err)
+	}
+	have, err := Inspect(ctx, db)
+	if err != nil {
+		return nil, fmt.Errorf("could not inspect the database: %w", err)
+	}
+	return DiffSchemas(m.withoutHistory(have), m.withoutHistory(want)), nil
+}
+
+// withoutHistory returns the schema without the migrations table or Drift's
+// other tables (see withoutBookkeeping).
+func (m *Migrator) withoutHistory(s *Schema) *Schema {
+	out := &Schema{Tables: make([]Table, 0, len(s.Tables))}
+	for _, t := range s.Tables {
+		if t.QualifiedName() != m.tableName() {
+			out.Tables = append(out.Tables, t)
+		}
+	}
+	return m.withoutBookkeeping(out)
+}
+
+// withoutBookkeeping returns the schema without the lock and failures tables,
+// which only some databases have: the ones migrated with WithLock, and the ones
+// where a migration once failed.
+func (m *Migrator) withoutBookkeeping(s *Schema) *Schema {
+	return s.Without(m.LockTable()).Without(m.FailuresTable())
+}
+
+// SchemaChangesSQL returns the SQL of the changes as one script.
+func SchemaChangesSQL(cs []SchemaChange) string {
+	var b strings.Builder
+	for _, c := range cs {
+		fmt.Fprintf(&b, "-- %s\n%s\n\n", c, c.SQL)
+	}
+	return b.String()
+}
+
+func tablesByName(s *Schema) map[string]Table {
+	ts := make(map[string]Table, len(s.Tables))
+	for _, t := range s.Tables {
+		ts[t.QualifiedName()] = t
+	}
+	return ts
+}
+
+func diffColumns(from, to Table) []SchemaChange {
+	var cs []SchemaChange
+	table := to.QualifiedName()
+	name := func(c Column) string {
+		return table + "." + Postgres{}.Quote(c.Name)
+	}
+	alter := func(c Column, action string) string {
+		return fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s %s;", table, Postgres{}.Quote(c.Name), action)
+	}
+
+	dst := make(map[string]Column, len(to.Columns))
+	for _, c := range to.Columns {
+		dst[c.Name] = c
+	}
+	src := make(map[string]Column, len(from.Columns))
+	for _, c := range from.Columns {
+		src[c.Name] = c
+		if _, ok := dst[c.Name]; !ok {
+			cs = append(cs, SchemaChange{
+				Object: "column",
+				Name:   name(c),
+				Detail: detailDropped,
+				SQL:    fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s;", table, Postgres{}.Quote(c.Name)),
+				phase:  phaseDropColumn,
+			})
+		}
+	}
+	for _, c := range to.Columns {
+		s, ok := src[c.Name]
+		if !ok {
+			cs = append(cs, SchemaChange{
+				Object: "column",
+				Name:   name(c),
+				Detail: detailAdded,
+				SQL:    fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s;", table, columnSQL(c)),
+				phase:  phaseColumn,
+			})
+			continue
+		}
+		var details, stmts []string
+		if s.Type != c.Type {
+			details = append(details, fmt.Sprintf("type %s -> %s", s.Type, c.Type))
+			stmts = append(stmts, alter(c, fmt.Sprintf("TYPE %s USING %s::%s", c.Type, Postgres{}.Quote(c.Name), c.Type)))
+		}
+		if s.Default != c.Default {
+			if c.Default == "" {
+				details = append(details, fmt.Sprintf("default %s -> none", s.Default))
+				stmts = append(stmts, alter(c, "DROP DEFAULT"))
+			} else {
+				details = append(details, fmt.Sprintf("default %s -> %s", orNone(s.Default), c.Default))
+				stmts = append(stmts, alter(c, "SET DEFAULT "+c.Default))
+			}
+		}
+		if s.Nullable != c.Nullable {
+			if c.Nullable {
+				details = append(details, "not null -> nullable")
+				stmts = append(stmts, alter(c, "DROP NOT NULL"))
+			} else {
+				details = append(details, "nullable -> not null")
+				stmts = append(stmts, alter(c, "SET NOT NULL"))
+			}
+		}
+		if len(details) > 0 {
+			cs = append(cs, SchemaChange{
+				Object: "column",
+				Name:   name(c),
+				Detail: strings.Join(details, "; "),
+				SQL:    strings.Join(stmts, "\n"),
+				phase:  phaseColumn,
+			})
+		}
+	}
+	return cs
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return s
+}
+
+func diffConstraints(from, to Table) []SchemaChange {
+	var cs []SchemaChange
+	table := to.QualifiedName()
+	name := func(c Constraint) string {
+		return Postgres{}.Quote(c.Name) + " on " + table
+	}
+	drop := func(c Constraint, detail string) SchemaChange {
+		phase := phaseDropConstraint
+		if c.Type == "f" {
+			phase = phaseDropForeignKey
+		}
+		return SchemaChange{
+			Object: "constraint",
+			Name:   name(c),
+			Detail: detail,
+			SQL:    fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s;", table, Postgres{}.Quote(c.Name)),
+			phase:  phase,
+		}
+	}
+	add := func(c Constraint, detail string) SchemaChange {
+		phase := phaseAddConstraint
+		if c.Type == "f" {
+			phase = phaseAddForeignKey
+		}
+		return SchemaChange{
+			Object: "constraint",
+			Name:   name(c),
+			Detail: detail,
+			SQL:    addConstraintSQL(to, c),
+			phase:  phase,
+		}
+	}
+
+	dst := make(map[string]Constraint, len(to.Constraints))
+	for _, c := range to.Constraints {
+		dst[c.Name] = c
+	}
+	src := make(map[string]Constraint, len(from.Constraints))
+	for _, c := range from.Constraints {
+		src[c.Name] = c
+		if _, ok := dst[c.Name]; !ok {
+			cs = append(cs, drop(c, detailDropped))
+		}
+	}
+	for _, c := range to.Constraints {
+		s, ok := src[c.Name]
+		switch {
+		case !ok:
+			cs = append(cs, add(c, detailAdded))
+		case s.Definition != c.Definition:
+			detail := fmt.Sprintf("%s -> %s", s.Definition, c.Definition)
+			cs = append(cs, drop(s, detail), add(c, detail))
+		}
+	}
+	return cs
+}
+
+func diffIndexes(from, to Table) []SchemaChange {
+	var cs []SchemaChange
+	name := func(x Index) string {
+		return Postgres{}.Quote(to.Schema, x.Name)
+	}
+	drop := func(x Index, detail string) SchemaChange {
+		return SchemaChange{
+			Object: "index",
+			Name:   name(x),
+			Detail: detail,
+			SQL:    fmt.Sprintf("DROP INDEX %s;", name(x)),
+			phase:  phaseDropIndex,
+		}
+	}
+	create := func(x Index, detail string) SchemaChange {
+		return SchemaChange{
+			Object: "index",
+			Name:   name(x),
+			Detail: detail,
+			SQL:    x.Definition + ";",
+			phase:  phaseCreateIndex,
+		}
+	}
+
+	dst := make(map[string]Index, len(to.Indexes))
+	for _, x := range to.Indexes {
+		dst[x.Name] = x
+	}
+	src := make(map[string]Index, len(from.Indexes))
+	for _, x := range from.Indexes {
+		src[x.Name] = x
+		if _, ok := dst[x.Name]; !ok {
+			cs = append(cs, drop(x, detailDropped))
+		}
+	}
+	for _, x := range to.Indexes {
+		s, ok := src[x.Name]
+		switch {
+		case !ok:
+			cs = append(cs, create(x, detailAdded))
+		case s.Definition != x.Definition:
+			detail := fmt.Sprintf("%s -> %s", s.Definition, x.Definition)
+			cs = append(cs, drop(s, detail), create(x, detail))
+		}
+	}
+	return cs
+}
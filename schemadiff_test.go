@@ -0,0 +1,224 @@
+package drift
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffSchemas(t *testing.T) {
+	users := func() Table {
+		return Table{
+			Schema: "public",
+			Name:   "users",
+			Columns: []Column{
+				{Name: "id", Type: "bigint"},
+				{Name: "email", Type: "text"},
+				{Name: "name", Type: "text", Nullable: true},
+			},
+			Constraints: []Constraint{
+				{Name: "users_pkey", Type: "p", Definition: "PRIMARY KEY (id)"},
+				{Name: "users_email_key", Type: "u", Definition: "UNIQUE (email)"},
+			},
+			Indexes: []Index{
+				{Name: "users_name_idx", Definition: "CREATE INDEX users_name_idx ON public.users USING btree (name)"},
+			},
+		}
+	}
+
+	tests := map[string]struct {
+		change func(*Table)
+		want   []SchemaChange
+	}{
+		"unchanged": {
+			change: func(*Table) {},
+		},
+		"added column": {
+			change: func(t *Table) {
+				t.Columns = append(t.Columns, Column{Name: "active", Type: "boolean", Default: "true"})
+			},
+			want: []SchemaChange{{
+				Object: "column",
+				Name:   `"public"."users"."active"`,
+				Detail: "added",
+				SQL:    `ALTER TABLE "public"."users" ADD COLUMN "active" boolean NOT NULL DEFAULT true;`,
+			}},
+		},
+		"dropped column": {
+			change: func(t *Table) { t.Columns = t.Columns[:2] },
+			want: []SchemaChange{{
+				Object: "column",
+				Name:   `"public"."users"."name"`,
+				Detail: "dropped",
+				SQL:    `ALTER TABLE "public"."users" DROP COLUMN "name";`,
+			}},
+		},
+		"altered column": {
+			change: func(t *Table) {
+				t.Columns[1] = Column{Name: "email", Type: "varchar(255)", Nullable: true, Default: "''"}
+			},
+			want: []SchemaChange{{
+				Object: "column",
+				Name:   `"public"."users"."email"`,
+				Detail: "type text -> varchar(255); default none -> ''; not null -> nullable",
+				SQL: `ALTER TABLE "public"."users" ALTER COLUMN "email" TYPE varchar(255) USING "email"::varchar(255);` + "\n" +
+					`ALTER TABLE "public"."users" ALTER COLUMN "email" SET DEFAULT '';` + "\n" +
+					`ALTER TABLE "public"."users" ALTER COLUMN "email" DROP NOT NULL;`,
+			}},
+		},
+		"set default": {
+			change: func(t *Table) {
+				t.Columns[0].Default = "0"
+			},
+			want: []SchemaChange{{
+				Object: "column",
+				Name:   `"public"."users"."id"`,
+				Detail: "default none -> 0",
+				SQL:    `ALTER TABLE "public"."users" ALTER COLUMN "id" SET DEFAULT 0;`,
+			}},
+		},
+		"added index": {
+			change: func(t *Table) {
+				t.Indexes = append(t.Indexes, Index{Name: "users_lower_email_idx", Definition: "CREATE INDEX users_lower_email_idx ON public.users USING btree (lower(email))"})
+			},
+			want: []SchemaChange{{
+				Object: "index",
+				Name:   `"public"."users_lower_email_idx"`,
+				Detail: "added",
+				SQL:    "CREATE INDEX users_lower_email_idx ON public.users USING btree (lower(email));",
+			}},
+		},
+		"dropped index": {
+			change: func(t *Table) { t.Indexes = nil },
+			want: []SchemaChange{{
+				Object: "index",
+				Name:   `"public"."users_name_idx"`,
+				Detail: "dropped",
+				SQL:    `DROP INDEX "public"."users_name_idx";`,
+			}},
+		},
+		"altered index": {
+			change: func(t *Table) {
+				t.Indexes[0].Definition = "CREATE INDEX users_name_idx ON public.users USING hash (name)"
+			},
+			want: []SchemaChange{
+				{
+					Object: "index",
+					Name:   `"public"."users_name_idx"`,
+					Detail: "CREATE INDEX users_name_idx ON public.users USING btree (name) -> CREATE INDEX users_name_idx ON public.users USING hash (name)",
+					SQL:    `DROP INDEX "public"."users_name_idx";`,
+				},
+				{
+					Object: "index",
+					Name:   `"public"."users_name_idx"`,
+					Detail: "CREATE INDEX users_name_idx ON public.users USING btree (name) -> CREATE INDEX users_name_idx ON public.users USING hash (name)",
+					SQL:    "CREATE INDEX users_name_idx ON public.users USING hash (name);",
+				},
+			},
+		},
+		"added constraint": {
+			change: func(t *Table) {
+				t.Constraints = append(t.Constraints, Constraint{Name: "users_email_check", Type: "c", Definition: "CHECK (email <> '')"})
+			},
+			want: []SchemaChange{{
+				Object: "constraint",
+				Name:   `"users_email_check" on "public"."users"`,
+				Detail: "added",
+				SQL:    `ALTER TABLE "public"."users" ADD CONSTRAINT "users_email_check" CHECK (email <> '');`,
+			}},
+		},
+		"dropped constraint": {
+			change: func(t *Table) { t.Constraints = t.Constraints[:1] },
+			want: []SchemaChange{{
+				Object: "constraint",
+				Name:   `"users_email_key" on "public"."users"`,
+				Detail: "dropped",
+				SQL:    `ALTER TABLE "public"."users" DROP CONSTRAINT "users_email_key";`,
+			}},
+		},
+		"altered constraint": {
+			change: func(t *Table) {
+				t.Constraints[1].Definition = "UNIQUE (email, name)"
+			},
+			want: []SchemaChange{
+				{
+					Object: "constraint",
+					Name:   `"users_email_key" on "public"."users"`,
+					Detail: "UNIQUE (email) -> UNIQUE (email, name)",
+					SQL:    `ALTER TABLE "public"."users" DROP CONSTRAINT "users_email_key";`,
+				},
+				{
+					Object: "constraint",
+					Name:   `"users_email_key" on "public"."users"`,
+					Detail: "UNIQUE (email) -> UNIQUE (email, name)",
+					SQL:    `ALTER TABLE "public"."users" ADD CONSTRAINT "users_email_key" UNIQUE (email, name);`,
+				},
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			to := users()
+			tt.change(&to)
+			got := withoutPhases(DiffSchemas(&Schema{Tables: []Table{users()}}, &Schema{Tables: []Table{to}}))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DiffSchemas() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffSchemasOrder(t *testing.T) {
+	teams := Table{
+		Schema:  "public",
+		Name:    "teams",
+		Columns: []Column{{Name: "id", Type: "bigint"}},
+		Constraints: []Constraint{
+			{Name: "teams_pkey", Type: "p", Definition: "PRIMARY KEY (id)"},
+		},
+	}
+	from := &Schema{Tables: []Table{{
+		Schema:  "public",
+		Name:    "users",
+		Columns: []Column{{Name: "id", Type: "bigint"}, {Name: "org_id", Type: "bigint"}},
+		Constraints: []Constraint{
+			{Name: "users_org_id_fkey", Type: "f", Definition: "FOREIGN KEY (org_id) REFERENCES orgs(id)"},
+		},
+	}, {
+		Schema:  "public",
+		Name:    "orgs",
+		Columns: []Column{{Name: "id", Type: "bigint"}},
+	}}}
+	to := &Schema{Tables: []Table{{
+		Schema:  "public",
+		Name:    "users",
+		Columns: []Column{{Name: "id", Type: "bigint"}, {Name: "team_id", Type: "bigint"}},
+		Constraints: []Constraint{
+			{Name: "users_team_id_fkey", Type: "f", Definition: "FOREIGN KEY (team_id) REFERENCES teams(id)"},
+		},
+	}, teams}}
+
+	var got []string
+	for _, c := range DiffSchemas(from, to) {
+		got = append(got, c.String())
+	}
+	// Foreign keys are dropped before the tables they reference, and added
+	// after the tables and columns they depend on.
+	want := []string{
+		`constraint "users_org_id_fkey" on "public"."users": dropped`,
+		`column "public"."users"."org_id": dropped`,
+		`table "public"."orgs": dropped`,
+		`table "public"."teams": added`,
+		`column "public"."users"."team_id": added`,
+		`constraint "users_team_id_fkey" on "public"."users": added`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DiffSchemas() = %q, want %q", got, want)
+	}
+}
+
+func withoutPhases(cs []SchemaChange) []SchemaChange {
+	for i := range cs {
+		cs[i].phase = 0
+	}
+	return cs
+}
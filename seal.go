@@ -0,0 +1,152 @@
+package drift
+
+import (
+	"bufio"
+	"crypto/sha1" //#nosec G505 // Git object IDs are SHA-1, not a security boundary here.
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"path"
+	"sort"
+	"strings"
+)
+
+var (
+	// ErrChecksumMismatch means content doesn't match the checksum it was
+	// sealed or published with. ErrModifiedMigration wraps it, as do the
+	// errors from fetching remote migrations whose checksum is wrong.
+	ErrChecksumMismatch  = errors.New("checksum does not match")
+	ErrUnsealedMigration = errors.New("migration is not in the seal")
+	ErrModifiedMigration = fmt.Errorf("%w: migration has changed since it was sealed", ErrChecksumMismatch)
+	ErrInvalidSeal       = errors.New("invalid seal line")
+)
+
+// A Seal records the reviewed contents of migration files, as a map from file
+// name to git blob hash. A seal can come from a git tag (via git ls-tree) or
+// from a lockfile written by Seal.WriteTo.
+//
+// With WithSeal, Migrate refuses to apply a migration unless its content
+// matches the seal exactly.
+type Seal map[string]string
+
+// WithSeal makes Migrate refuse to apply any migration that isn't in the seal
+// or whose content differs from the sealed version.
+func WithSeal(s Seal) Option {
+	return func(m *Migrator) {
+		m.seal = s
+	}
+}
+
+// SealDir computes the seal for the migration files currently in the
+// directory, and in the more directories if there are any (see WithDirs).
+func SealDir(io IO, dir string, more ...string) (Seal, error) {
+	files, err := available(io, OSFileSystem{}, append([]string{dir}, more...))
+	if err != nil {
+		return nil, err
+	}
+	s := make(Seal)
+	for _, f := range files {
+		s[f.Name] = blobHash(f.Content)
+	}
+	return s, nil
+}
+
+// ReadSeal parses a seal. Each line is either "<hash> <name>" (the lockfile
+// format) or a line of `git ls-tree` output. Blank lines and lines starting
+// with # are ignored.
+func ReadSeal(r io.Reader) (Seal, error) {
+	s := make(Seal)
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var hash, name string
+		if meta, p, ok := strings.Cut(line, "\t"); ok {
+			// git ls-tree: <mode> SP <type> SP <object> TAB <path>
+			fields := strings.Fields(meta)
+			if len(fields) != 3 || fields[1] != "blob" {
+				continue
+			}
+			hash, name = fields[2], sealName(p)
+		} else {
+			fields := strings.Fields(line)
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("%w: %q", ErrInvalidSeal, line)
+			}
+			hash, name = fields[0], fields[1]
+		}
+		s[name] = hash
+	}
+	return s, sc.Err()
+}
+
+// sealName returns the name a migration file at the path has in a seal: the
+// base name, or the directory and up.sql in the directory layout.
+func sealName(p string) string {
+	base := path.Base(p)
+	dir := path.Base(path.Dir(p))
+	if base == upFile && reDirname.MatchString(dir) {
+		return path.Join(dir, base)
+	}
+	return base
+}
+
+// WriteTo writes the seal in the lockfile format, sorted by name.
+func (s Seal) WriteTo(w io.Writer) (int64, error) {
+	names := make([]string, 0, len(s))
+	for name := range s {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var total int64
+	for _, name := range names {
+		n, err := fmt.Fprintf(w, "%s %s\n", s[name], name)
+		total += int64(n)
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// check returns an error if the file doesn't match the seal.
+func (s Seal) check(f migrationFile) error {
+	want, ok := s[f.Name]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnsealedMigration, f.Name)
+	}
+	if got := f.hash(); got != want {
+		return fmt.Errorf("%w: %s (sealed %s, found %s)", ErrModifiedMigration, f.Name, want, got)
+	}
+	return nil
+}
+
+// hash returns the file's blobHash. Streamed files were hashed as they were
+// scanned.
+func (f migrationFile) hash() string {
+	if f.summary != nil {
+		return f.summary.hash
+	}
+	return blobHash(f.Content)
+}
+
+// blobHash computes the git object ID of a blob with the content.
+func blobHash(content string) string {
+	h := newBlobHash(int64(len(content)))
+	io.WriteString(h, content)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// newBlobHash starts the git object ID of a blob of the size. Write the
+// content to it.
+func newBlobHash(size int64) hash.Hash {
+	h := sha1.New() //#nosec G401
+	fmt.Fprintf(h, "blob %d\x00", size)
+	return h
+}
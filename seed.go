@@ -0,0 +1,193 @@
+package drift
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/blockloop/scan"
+)
+
+// DefaultSeedTable is the default name of the table that records which seed
+// files have run.
+const DefaultSeedTable = "schema_seeds"
+
+// A SeedMode is how Seed decides which seed files to run.
+type SeedMode string
+
+const (
+	// SeedOnce runs each seed file once, recording it in the seeds table.
+	// This is the default.
+	SeedOnce SeedMode = "once"
+	// SeedAlways runs every seed file every time, so the files must be
+	// idempotent (like INSERT ... ON CONFLICT DO NOTHING).
+	SeedAlways SeedMode = "always"
+)
+
+// SeedModes lists every valid SeedMode.
+var SeedModes = []SeedMode{SeedOnce, SeedAlways}
+
+// WithSeedTable sets the name of the table that records which seed files have
+// run. It's in the same schema as the migrations table.
+func WithSeedTable(name string) Option {
+	return func(m *Migrator) {
+		m.seedTable = name
+	}
+}
+
+// A SeedResult is what Seed did with one seed file.
+type SeedResult struct {
+	Name string
+	// Ran is false if the file was skipped because it already ran.
+	Ran bool
+	// Changed is true if the file was skipped but has changed since it ran.
+	Changed bool
+}
+
+type seedRecord struct {
+	Name     string `db:"name"`
+	Checksum string `db:"checksum"`
+}
+
+// Seed runs the .sql files in seedsDir in name order, like 01-users.sql
+// before 02-posts.sql, to load development or staging data. Each file runs in
+// its own transaction, with ${DRIFT_VAR_name} placeholders substituted as in
+// migrations.
+//
+// In SeedOnce mode, each file is recorded in the seeds table (created if it
+// doesn't exist) and skipped on later runs. Files that changed after they ran
+// are skipped too, and reported as changed.
+func (m *Migrator) Seed(ctx context.Context, db *sql.DB, seedsDir string, mode SeedMode) ([]SeedResult, error) {
+	files, err := seedFiles(m.files, seedsDir)
+	if err != nil {
+		return nil, fmt.Errorf("could not list seed files: %w", err)
+	}
+	var ran map[string]string
+	if mode != SeedAlways {
+		if ran, err = m.seedRecords(ctx, db); err != nil {
+			return nil, fmt.Errorf("could not get seed records: %w", err)
+		}
+	}
+
+	res := make([]SeedResult, 0, len(files))
+	for _, path := range files {
+		name := filepath.Base(path)
+		b, err := m.files.ReadFile(path)
+		if err != nil {
+			return res, err
+		}
+		content := string(b)
+		sum := blobHash(content)
+		if prev, ok := ran[name]; ok {
+			r := SeedResult{Name: name, Changed: prev != sum}
+			if r.Changed {
+				warnf(m.io, "Skipping seed that changed after it ran: %s", name)
+			} else {
+				m.io.Debugf("Skipping seed that already ran: %s", name)
+			}
+			res = append(res, r)
+			continue
+		}
+		m.io.Infof("Running seed: %s", path)
+		if err := m.runSeed(ctx, db, name, content, sum, mode); err != nil {
+			return res, fmt.Errorf("seed %s: %w", name, err)
+		}
+		res = append(res, SeedResult{Name: name, Ran: true})
+	}
+	return res, nil
+}
+
+// seedFiles lists the seed files in the directory, in name order. A missing
+// directory has no seeds.
+func seedFiles(fsys FileSystem, dir string) ([]string, error) {
+	entries, err := fsys.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".sql") {
+			files = append(files, filepath.Join(dir, e.Name()))
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func (m *Migrator) seedTableName() string {
+	return m.dialect.Quote(m.schema, m.seedTable)
+}
+
+// seedRecords creates the seeds table if needed and returns the checksums of
+// the seeds that ran, by name.
+func (m *Migrator) seedRecords(ctx context.Context, db *sql.DB) (map[string]string, error) {
+	create := fmt.Sprintf(`create table if not exists %s (
+	name text primary key,
+	checksum text not null,
+	run_at timestamptz not null default now()
+)`, m.seedTableName())
+	if _, err := db.ExecContext(ctx, create); err != nil {
+		return nil, err
+	}
+	query, args, err := m.sb().
+		Select("name", "checksum").
+		From(m.seedTableName()).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	var records []seedRecord
+	if err := scan.RowsStrict(&records, rows); err != nil {
+		return nil, err
+	}
+	ran := make(map[string]string, len(records))
+	for _, r := range records {
+		ran[r.Name] = r.Checksum
+	}
+	return ran, nil
+}
+
+// runSeed runs one seed file in a transaction, recording it unless the mode
+// is SeedAlways.
+func (m *Migrator) runSeed(ctx context.Context, db *sql.DB, name, content, sum string, mode SeedMode) error {
+	content, err := m.substituteVars(content)
+	if err != nil {
+		return err
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	// This is a no-op after a successful commit.
+	defer tx.Rollback() //nolint:errcheck
+
+	if err := run(ctx, tx, content); err != nil {
+		return statementError(content, err)
+	}
+	if mode != SeedAlways {
+		query, args, err := m.sb().
+			Insert(m.seedTableName()).
+			Columns("name", "checksum").
+			Values(name, sum).
+			ToSql()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,153 @@
+package drift
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrNoMatchingMigration = errors.New("no migration file matches")
+	ErrAmbiguousMigration  = errors.New("several migration files match")
+)
+
+// MigrationInfo describes a single migration file.
+type MigrationInfo struct {
+	ID      MigrationID
+	Slug    string
+	Path    string
+	Content string
+
+	// Directives lists the --drift: directive comments in the file, in order.
+	Directives []Directive
+	// Transaction is false if the file has a no-transaction directive.
+	Transaction bool
+	// Timeout is the limit set by a timeout directive, or zero.
+	Timeout time.Duration
+	// ConcurrentSafe is true if the file has a concurrent-safe directive.
+	ConcurrentSafe bool
+
+	// Applied is the migration's record, or nil if it hasn't been applied (or
+	// Show wasn't given a database).
+	Applied *HistoryEntry
+}
+
+// Empty reports whether the file has no SQL statements yet, only comments and
+// whitespace, like a migration just written by NewFile.
+func (i MigrationInfo) Empty() bool {
+	return len(splitSQL(i.Content)) == 0
+}
+
+// A Directive is a --drift:name, --drift:name=value, or --drift:name
+// key=value ... comment.
+type Directive struct {
+	Name  string
+	Value string
+	// Args are the space-separated key=value arguments after the name, like
+	// "table=users file=users.csv".
+	Args string
+}
+
+func (d Directive) String() string {
+	s := "--drift:" + d.Name
+	if d.Value != "" {
+		s += "=" + d.Value
+	}
+	if d.Args != "" {
+		s += " " + d.Args
+	}
+	return s
+}
+
+// Show finds the migration file in migrationsDir whose ID, slug, name, or path
+// is key. If db is not nil, it also looks up whether the migration has been
+// applied.
+func (m *Migrator) Show(ctx context.Context, db *sql.DB, migrationsDir string, key string) (*MigrationInfo, error) {
+	files, err := m.available(migrationsDir)
+	if err != nil {
+		return nil, fmt.Errorf("could not get available migrations: %w", err)
+	}
+	f, err := findMigration(files, key)
+	if err != nil {
+		return nil, err
+	}
+	d, err := parseDirectives(f.Content)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", f.Name, err)
+	}
+
+	info := &MigrationInfo{
+		ID:             f.ID,
+		Slug:           f.Slug,
+		Path:           f.Path,
+		Content:        f.Content,
+		Transaction:    !d.noTransaction,
+		Timeout:        d.timeout,
+		ConcurrentSafe: d.concurrentSafe,
+	}
+	info.Directives = listDirectives(f.Content)
+
+	if db == nil {
+		return info, nil
+	}
+	hs, err := m.History(ctx, db)
+	if err != nil {
+		return nil, fmt.Errorf("could not get applied migrations: %w", err)
+	}
+	for i := range hs {
+		if hs[i].ID == f.ID {
+			info.Applied = &hs[i]
+		}
+	}
+	return info, nil
+}
+
+// List returns the migration files in migrationsDir in ID order. Only the ID,
+// Slug, Path, and Content of each are set.
+func (m *Migrator) List(migrationsDir string) ([]MigrationInfo, error) {
+	files, err := m.available(migrationsDir)
+	if err != nil {
+		return nil, fmt.Errorf("could not get available migrations: %w", err)
+	}
+	infos := make([]MigrationInfo, 0, len(files))
+	for _, f := range files {
+		infos = append(infos, MigrationInfo{ID: f.ID, Slug: f.Slug, Path: f.Path, Content: f.Content})
+	}
+	return infos, nil
+}
+
+// findMigration returns the file whose ID, slug, name, or path is key.
+func findMigration(files []migrationFile, key string) (migrationFile, error) {
+	for _, f := range files {
+		if key == f.Name || filepath.Clean(key) == filepath.Clean(f.Path) {
+			return f, nil
+		}
+	}
+	if id, err := strconv.ParseInt(key, 10, 64); err == nil {
+		for _, f := range files {
+			if f.ID == MigrationID(id) {
+				return f, nil
+			}
+		}
+		return migrationFile{}, fmt.Errorf("%w: %s", ErrNoMatchingMigration, key)
+	}
+
+	var found []migrationFile
+	for _, f := range files {
+		if f.Slug == key {
+			found = append(found, f)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return migrationFile{}, fmt.Errorf("%w: %s", ErrNoMatchingMigration, key)
+	case 1:
+		return found[0], nil
+	default:
+		return migrationFile{}, fmt.Errorf("%w: %s (use the ID instead)", ErrAmbiguousMigration, key)
+	}
+}
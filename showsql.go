@@ -0,0 +1,82 @@
+package drift
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// WithShowSQL logs each statement before Drift runs it, and the number of
+// rows the server reports it affected afterward. The claim call is logged
+// with its arguments. This is for reproducing a failure locally, so it's
+// logged at the info level.
+//
+// To report each statement separately, Drift runs a migration's statements
+// one at a time instead of sending the whole file at once. Outside of a
+// transaction, this means an earlier statement isn't rolled back when a later
+// one fails. The statements around a copy directive are logged together, and
+// the copied data isn't logged.
+func WithShowSQL() Option {
+	return func(m *Migrator) {
+		m.showSQL = true
+	}
+}
+
+// shown returns q, or a wrapper that logs what runs through it with
+// WithShowSQL.
+func (m *Migrator) shown(q Queryable) Queryable {
+	if !m.showSQL {
+		return q
+	}
+	return shownQueryable{q: q, io: m.io}
+}
+
+// A shownQueryable logs each statement and the rows it affected.
+type shownQueryable struct {
+	q  Queryable
+	io IO
+}
+
+func (s shownQueryable) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	s.io.Infof("%s", showStatement(query, args))
+	res, err := s.q.ExecContext(ctx, query, args...)
+	if err != nil {
+		return res, err
+	}
+	if n, err := res.RowsAffected(); err == nil {
+		s.io.Infof("-- %d rows affected", n)
+	}
+	return res, nil
+}
+
+// showStatement formats a statement and its arguments for the log.
+func showStatement(query string, args []interface{}) string {
+	query = strings.TrimSpace(query)
+	if len(args) == 0 {
+		return query
+	}
+	vals := make([]string, len(args))
+	for i, a := range args {
+		if s, ok := a.(string); ok {
+			vals[i] = "'" + strings.ReplaceAll(s, "'", "''") + "'"
+		} else {
+			vals[i] = fmt.Sprint(a)
+		}
+	}
+	return fmt.Sprintf("%s -- args: %s", query, strings.Join(vals, ", "))
+}
+
+// runEach runs the content's statements one at a time, so that each one is
+// logged (with WithShowSQL) or traced (with WithTracer) with the rows it
+// affected.
+func (m *Migrator) runEach(ctx context.Context, q Queryable, content string) error {
+	q = m.shown(q)
+	sts := splitSQL(content)
+	for i, st := range sts {
+		if err := run(ctx, q, st.text); err != nil {
+			return locateError(content, sts, i, st.start, err)
+		}
+	}
+	return nil
+}
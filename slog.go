@@ -0,0 +1,97 @@
+package drift
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// SlogIO adapts a *slog.Logger to the IO interface.
+//
+// Messages about a specific migration also get structured attributes:
+// migration_id, slug, and (once it has finished) duration.
+type SlogIO struct {
+	logger *slog.Logger
+}
+
+var _ LevelIO = (*SlogIO)(nil)
+
+// NewSlogIO wraps the logger. A nil logger uses slog.Default().
+func NewSlogIO(logger *slog.Logger) *SlogIO {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &SlogIO{logger: logger}
+}
+
+func (s *SlogIO) Infof(format string, args ...interface{}) (int, error) {
+	s.logger.Info(fmt.Sprintf(format, args...))
+	return 0, nil
+}
+
+func (s *SlogIO) Debugf(format string, args ...interface{}) (int, error) {
+	s.logger.Debug(fmt.Sprintf(format, args...))
+	return 0, nil
+}
+
+func (s *SlogIO) Warnf(format string, args ...interface{}) (int, error) {
+	s.logger.Warn(fmt.Sprintf(format, args...))
+	return 0, nil
+}
+
+func (s *SlogIO) Errorf(format string, args ...interface{}) (int, error) {
+	s.logger.Error(fmt.Sprintf(format, args...))
+	return 0, nil
+}
+
+func (s *SlogIO) logAttrs(level slog.Level, msg string, attrs ...slog.Attr) {
+	s.logger.LogAttrs(context.Background(), level, msg, attrs...)
+}
+
+// attrIO is implemented by IOs that accept structured attributes.
+type attrIO interface {
+	logAttrs(level slog.Level, msg string, attrs ...slog.Attr)
+}
+
+// logMigration logs a message about a migration, with structured attributes
+// if the IO supports them. A zero duration is left out.
+func (m *Migrator) logMigration(level slog.Level, f migrationFile, d time.Duration, format string, args ...interface{}) {
+	if aio, ok := m.io.(attrIO); ok {
+		attrs := []slog.Attr{
+			slog.Int64("migration_id", int64(f.ID)),
+			slog.String("slug", f.Slug),
+		}
+		if d > 0 {
+			attrs = append(attrs, slog.Duration("duration", d))
+		}
+		aio.logAttrs(level, fmt.Sprintf(format, args...), attrs...)
+		return
+	}
+	logf(m.io, level, format, args...)
+}
+
+// warnf logs a warning, or an info message if the IO has no warning level.
+func warnf(io IO, format string, args ...interface{}) {
+	logf(io, slog.LevelWarn, format, args...)
+}
+
+// errorf logs an error, or an info message if the IO has no error level.
+func errorf(io IO, format string, args ...interface{}) {
+	logf(io, slog.LevelError, format, args...)
+}
+
+// logf logs the message with the IO method for the level.
+func logf(io IO, level slog.Level, format string, args ...interface{}) {
+	lio, hasLevels := io.(LevelIO)
+	switch {
+	case level >= slog.LevelError && hasLevels:
+		lio.Errorf(format, args...)
+	case level >= slog.LevelWarn && hasLevels:
+		lio.Warnf(format, args...)
+	case level >= slog.LevelInfo:
+		io.Infof(format, args...)
+	default:
+		io.Debugf(format, args...)
+	}
+}
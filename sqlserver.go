@@ -0,0 +1,118 @@
+package drift
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// SQLServer is the dialect for Microsoft SQL Server. Drift doesn't include a
+// driver for it, so register one (like github.com/microsoft/go-mssqldb) before
+// opening the database.
+//
+// Migration files can be split into batches with GO lines, like in sqlcmd,
+// for statements that have to start a batch (like create procedure). Drift's
+// functions are stored procedures, and the migrations table lives in the
+// configured schema, so set WithSchema("dbo") (or another schema) since the
+// default is Postgres's "public".
+type SQLServer struct{}
+
+var (
+	_ Dialect          = SQLServer{}
+	_ InitDialect      = SQLServer{}
+	_ BatchDialect     = SQLServer{}
+	_ ProcedureDialect = SQLServer{}
+	_ TableDialect     = SQLServer{}
+	_ VersionDialect   = SQLServer{}
+)
+
+func (SQLServer) Placeholders() sq.PlaceholderFormat {
+	return sq.AtP
+}
+
+func (SQLServer) Quote(parts ...string) string {
+	quoted := make([]string, len(parts))
+	for i, p := range parts {
+		quoted[i] = "[" + strings.ReplaceAll(p, "]", "]]") + "]"
+	}
+	return strings.Join(quoted, ".")
+}
+
+// sqlServerError is implemented by the errors from the go-mssqldb driver.
+type sqlServerError interface {
+	SQLErrorNumber() int32
+}
+
+func (SQLServer) IsUndefinedTable(err error) bool {
+	var serr sqlServerError
+	return errors.As(err, &serr) && serr.SQLErrorNumber() == 208 // Invalid object name
+}
+
+func (SQLServer) TableExistsQuery() string {
+	return "select 1 from sys.tables t join sys.schemas s on s.schema_id = t.schema_id where s.name = @p1 and t.name = @p2"
+}
+
+func (SQLServer) ProcedureExistsQuery() string {
+	return "select 1 from sys.procedures p join sys.schemas s on s.schema_id = p.schema_id where s.name = @p1 and p.name = @p2"
+}
+
+func (SQLServer) ServerVersionQuery() string {
+	return "select cast(serverproperty('ProductVersion') as nvarchar(128))"
+}
+
+func (SQLServer) Lock(ctx context.Context, conn *sql.Conn, key int64) error {
+	_, err := conn.ExecContext(ctx, `declare @result int;
+exec @result = sp_getapplock @Resource = @p1, @LockMode = 'Exclusive', @LockOwner = 'Session', @LockTimeout = -1;
+if @result < 0 throw 50000, 'could not acquire the migration lock', 1;`, lockResource(key))
+	return err
+}
+
+func (SQLServer) Unlock(ctx context.Context, conn *sql.Conn, key int64) error {
+	_, err := conn.ExecContext(ctx, "exec sp_releaseapplock @Resource = @p1, @LockOwner = 'Session'", lockResource(key))
+	return err
+}
+
+func lockResource(key int64) string {
+	return "drift:" + strconv.FormatInt(key, 10)
+}
+
+func (SQLServer) Call(name string, args int) string {
+	return "exec " + name + " " + strings.TrimSuffix(strings.Repeat("?, ", args), ", ")
+}
+
+func (SQLServer) InitTemplate() string {
+	return sqlServerInitContent
+}
+
+// reGo matches a batch separator line: GO, optionally with a repeat count.
+var reGo = regexp.MustCompile(`(?im)^[ \t]*go(?:[ \t]+(\d+))?[ \t]*(?:--.*)?\r?$`)
+
+func (SQLServer) SplitBatches(content string) []string {
+	var batches []string
+	prev := 0
+	add := func(end, count int) {
+		text := strings.Repeat("\n", strings.Count(content[:prev], "\n")) + content[prev:end]
+		for i := 0; i < count; i++ {
+			batches = append(batches, text)
+		}
+	}
+	for _, loc := range reGo.FindAllStringSubmatchIndex(content, -1) {
+		count := 1
+		if loc[2] >= 0 {
+			count, _ = strconv.Atoi(content[loc[2]:loc[3]])
+		}
+		add(loc[0], count)
+		prev = loc[1]
+	}
+	add(len(content), 1)
+	return batches
+}
+
+//go:embed templates/init_sqlserver.sql
+var sqlServerInitContent string
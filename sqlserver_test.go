@@ -0,0 +1,56 @@
+package drift
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitBatches(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{
+			name:    "no separator",
+			content: "select 1;\n",
+			want:    []string{"select 1;\n"},
+		},
+		{
+			name:    "separators",
+			content: "create table a (id int);\nGO\ninsert into a values (1);\ngo -- insert\nselect 1;\n",
+			want: []string{
+				"create table a (id int);\n",
+				"\n\ninsert into a values (1);\n",
+				"\n\n\n\nselect 1;\n",
+			},
+		},
+		{
+			name:    "repeat count",
+			content: "insert into a default values;\nGO 2\n",
+			want: []string{
+				"insert into a default values;\n",
+				"insert into a default values;\n",
+				"\n\n",
+			},
+		},
+		{
+			name:    "CRLF",
+			content: "select 1;\r\ngo\r\nselect 2;\r\n",
+			want:    []string{"select 1;\r\n", "\n\nselect 2;\r\n"},
+		},
+		{
+			name:    "go inside a line isn't a separator",
+			content: "select 'go';\nselect 1 -- go\n",
+			want:    []string{"select 'go';\nselect 1 -- go\n"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SQLServer{}.SplitBatches(tt.content)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,336 @@
+package drift
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// A StatementError locates the statement in a migration file that failed.
+type StatementError struct {
+	// Index is the 1-based position of the statement in the file.
+	Index int
+	// Line and Column are the 1-based location of the error in the file. If
+	// the database didn't report a position, this is where the statement
+	// starts.
+	Line   int
+	Column int
+	// Excerpt is the start of the statement, on one line.
+	Excerpt string
+	Err     error
+}
+
+func (e *StatementError) Error() string {
+	return fmt.Sprintf("statement %d at line %d, column %d (%s): %s", e.Index, e.Line, e.Column, e.Excerpt, e.Err)
+}
+
+func (e *StatementError) Unwrap() error {
+	return e.Err
+}
+
+// A MigrationError is the failure of one migration. Get it from the error
+// returned by Migrate (and the others that apply migrations) with errors.As.
+type MigrationError struct {
+	ID   MigrationID
+	Slug string
+	Path string
+	// Stmt locates the statement that failed, or is nil if the failure wasn't
+	// in a statement or Drift couldn't tell which one.
+	Stmt *StatementError
+	// Cause is why the migration failed: usually the database's error, with
+	// Stmt in its chain if it's set. MigrationError unwraps to it, so
+	// errors.Is and errors.As see through to the cause.
+	Cause error
+}
+
+func migrationError(f migrationFile, err error) *MigrationError {
+	e := &MigrationError{ID: f.ID, Slug: f.Slug, Path: f.Path, Cause: err}
+	errors.As(err, &e.Stmt)
+	return e
+}
+
+func (e *MigrationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Cause)
+}
+
+func (e *MigrationError) Unwrap() error {
+	return e.Cause
+}
+
+// WithDebugKeep makes a failed migration keep the statements that ran before
+// the failing one, so the database can be inspected in the state the failing
+// statement saw. Each statement runs under its own savepoint. After a failure,
+// the failing statement is rolled back, the migration's record is removed, and
+// the rest of the transaction commits.
+//
+// This only affects migrations that run in their own transaction, and is only
+// meant for development databases: fix the migration and clean up the kept
+// changes by hand before applying it again.
+func WithDebugKeep() Option {
+	return func(m *Migrator) {
+		m.debugKeep = true
+	}
+}
+
+// A keptError is a migration failure after which the earlier statements were
+// kept with WithDebugKeep.
+type keptError struct {
+	err  error
+	kept int
+}
+
+func (e *keptError) Error() string {
+	return e.err.Error()
+}
+
+func (e *keptError) Unwrap() error {
+	return e.err
+}
+
+// runStatements runs the statements in the content one at a time, each under
+// a savepoint. If one fails, it's rolled back and the error is a keptError.
+func (m *Migrator) runStatements(ctx context.Context, tx *sql.Tx, content string) error {
+	sts := splitSQL(content)
+	for i, st := range sts {
+		if _, err := tx.ExecContext(ctx, "savepoint drift_statement"); err != nil {
+			return err
+		}
+		if err := run(ctx, m.shown(tx), st.text); err != nil {
+			err = locateError(content, sts, i, st.start, err)
+			// The context may be what failed the statement, but the
+			// transaction is still good.
+			undo, cancel := cleanupContext(ctx)
+			defer cancel()
+			if _, rerr := tx.ExecContext(undo, "rollback to savepoint drift_statement"); rerr != nil {
+				return err
+			}
+			return &keptError{err: err, kept: i}
+		}
+		if _, err := tx.ExecContext(ctx, "release savepoint drift_statement"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// keepFailed removes the claim on a migration that failed with a keptError and
+// commits the statements that ran before the failure.
+func (m *Migrator) keepFailed(ctx context.Context, tx *sql.Tx, f migrationFile, kept *keptError) error {
+	query, args, err := m.sb().
+		Delete(m.tableName()).
+		Where(sq.Eq{"id": f.ID}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	m.io.Infof("Kept %d statements that ran before the failure in %s", kept.kept, f.Name)
+	return nil
+}
+
+// statementError adds the location of the failed statement to an error from
+// running the whole content, if the database reported a position.
+func statementError(content string, err error) error {
+	_, pos, _ := pgError(err)
+	if pos <= 0 {
+		return err
+	}
+	offset := byteOffset(content, 0, int(pos))
+	sts := splitSQL(content)
+	for i, st := range sts {
+		if offset < st.start+len(st.text) || i == len(sts)-1 {
+			return locateError(content, sts, i, 0, err)
+		}
+	}
+	return err
+}
+
+// locateError wraps the error from the statement at index i with its location
+// in the content. The location is the database's reported position (counted
+// from the base byte offset) if there is one, or else the start of the
+// statement.
+func locateError(content string, sts []sqlStatement, i, base int, err error) error {
+	offset := sts[i].start
+	if _, pos, _ := pgError(err); pos > 0 {
+		offset = byteOffset(content, base, int(pos))
+	}
+	line, col := lineColumn(content, offset)
+	return &StatementError{
+		Index:   i + 1,
+		Line:    line,
+		Column:  col,
+		Excerpt: excerpt(sts[i].text),
+		Err:     err,
+	}
+}
+
+// byteOffset converts a 1-based character position, counted from the start
+// byte offset, to a byte offset in the content.
+func byteOffset(content string, start, pos int) int {
+	offset := start
+	for n := 1; n < pos && offset < len(content); n++ {
+		_, size := utf8.DecodeRuneInString(content[offset:])
+		offset += size
+	}
+	return offset
+}
+
+// lineColumn returns the 1-based line and column (in characters) of the byte
+// offset in the content.
+func lineColumn(content string, offset int) (int, int) {
+	before := content[:offset]
+	line := strings.Count(before, "\n") + 1
+	col := utf8.RuneCountInString(before[strings.LastIndex(before, "\n")+1:]) + 1
+	return line, col
+}
+
+// excerptLength is the most characters of a statement shown in errors.
+const excerptLength = 60
+
+// excerpt returns the start of the statement on one line.
+func excerpt(text string) string {
+	s := strings.Join(strings.Fields(text), " ")
+	if utf8.RuneCountInString(s) <= excerptLength {
+		return s
+	}
+	return string([]rune(s)[:excerptLength]) + "..."
+}
+
+type sqlStatement struct {
+	text string
+	// start is the byte offset of the statement text in the content. The
+	// text starts at the first character that isn't whitespace or a comment.
+	start int
+}
+
+// splitSQL splits SQL into statements at semicolons, skipping over quoted
+// strings, identifiers, dollar-quoted bodies, and comments. Statements that
+// are only whitespace and comments are left out.
+func splitSQL(content string) []sqlStatement {
+	var sts []sqlStatement
+	start := -1
+	add := func(end int) {
+		if start >= 0 {
+			sts = append(sts, sqlStatement{text: content[start:end], start: start})
+		}
+		start = -1
+	}
+	for i := 0; i < len(content); {
+		c := content[i]
+		switch {
+		case c == '-' && strings.HasPrefix(content[i:], "--"):
+			if end := strings.IndexByte(content[i:], '\n'); end >= 0 {
+				i += end + 1
+			} else {
+				i = len(content)
+			}
+			continue
+		case c == '/' && strings.HasPrefix(content[i:], "/*"):
+			i = skipBlockComment(content, i)
+			continue
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+			continue
+		}
+
+		if start < 0 {
+			start = i
+		}
+		switch {
+		case c == ';':
+			i++
+			add(i)
+		case c == '\'':
+			escapes := i > 0 && (content[i-1] == 'e' || content[i-1] == 'E') &&
+				(i == 1 || !isIdentChar(content[i-2]))
+			i = skipQuoted(content, i, '\'', escapes)
+		case c == '"':
+			i = skipQuoted(content, i, '"', false)
+		case c == '$' && (i == 0 || !isIdentChar(content[i-1])):
+			i = skipDollarQuoted(content, i)
+		default:
+			i++
+		}
+	}
+	add(len(content))
+	return sts
+}
+
+// skipBlockComment returns the offset after the (possibly nested) block comment
+// starting at i.
+func skipBlockComment(content string, i int) int {
+	depth := 0
+	for i < len(content) {
+		switch {
+		case strings.HasPrefix(content[i:], "/*"):
+			depth++
+			i += 2
+		case strings.HasPrefix(content[i:], "*/"):
+			depth--
+			i += 2
+			if depth == 0 {
+				return i
+			}
+		default:
+			i++
+		}
+	}
+	return i
+}
+
+// skipQuoted returns the offset after the quoted text starting at i. A doubled
+// quote is an escaped quote, as is a backslash-escaped one if escapes is set.
+func skipQuoted(content string, i int, quote byte, escapes bool) int {
+	for i++; i < len(content); i++ {
+		switch content[i] {
+		case '\\':
+			if escapes {
+				i++
+			}
+		case quote:
+			if i+1 < len(content) && content[i+1] == quote {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return i
+}
+
+// skipDollarQuoted returns the offset after the dollar-quoted text starting at
+// i, or just after the dollar sign if it doesn't start a dollar quote (like a
+// $1 parameter).
+func skipDollarQuoted(content string, i int) int {
+	end := i + 1
+	for end < len(content) && content[end] != '$' {
+		if !isIdentChar(content[end]) || (content[end] >= '0' && content[end] <= '9' && end == i+1) {
+			return i + 1
+		}
+		end++
+	}
+	if end >= len(content) {
+		return i + 1
+	}
+	tag := content[i : end+1]
+	body := end + 1
+	if n := strings.Index(content[body:], tag); n >= 0 {
+		return body + n + len(tag)
+	}
+	return len(content)
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c == '$' || c >= 0x80 ||
+		('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
@@ -0,0 +1,160 @@
+package drift
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/jackc/pgconn"
+)
+
+func TestSplitSQL(t *testing.T) {
+	tests := map[string]struct {
+		content string
+		want    []string
+	}{
+		"empty": {
+			content: "",
+			want:    nil,
+		},
+		"comments only": {
+			content: "-- nothing\n/* at */ -- all\n",
+			want:    nil,
+		},
+		"statements": {
+			content: "create table a ();\n\ncreate table b ();\n",
+			want:    []string{"create table a ();", "create table b ();"},
+		},
+		"no final semicolon": {
+			content: "select 1;\nselect 2\n",
+			want:    []string{"select 1;", "select 2\n"},
+		},
+		"leading comments": {
+			content: "-- first\nselect 1; /* second */ select 2;",
+			want:    []string{"select 1;", "select 2;"},
+		},
+		"nested comments": {
+			content: "select /* a; /* nested; */ still a comment; */ 1; select 2;",
+			want:    []string{"select /* a; /* nested; */ still a comment; */ 1;", "select 2;"},
+		},
+		"quotes": {
+			content: `select 'a;b', 'it''s;'; select "x;""y";`,
+			want:    []string{`select 'a;b', 'it''s;';`, `select "x;""y";`},
+		},
+		"E strings": {
+			content: `select E'a\';b'; select e'\\'; select 1;`,
+			want:    []string{`select E'a\';b';`, `select e'\\';`, "select 1;"},
+		},
+		"not an E string": {
+			content: `select name'a\';b';`,
+			want:    []string{`select name'a\';`, "b';"},
+		},
+		"dollar quotes": {
+			content: "create function f() returns int as $body$ select 1; $x$ $body$ language sql; select 2;",
+			want:    []string{"create function f() returns int as $body$ select 1; $x$ $body$ language sql;", "select 2;"},
+		},
+		"empty dollar tag": {
+			content: "do $$ begin perform 1; end $$; select 2;",
+			want:    []string{"do $$ begin perform 1; end $$;", "select 2;"},
+		},
+		"parameters": {
+			content: "select $1; select a$b;",
+			want:    []string{"select $1;", "select a$b;"},
+		},
+		"unterminated": {
+			content: "select 1; select 'never; closed",
+			want:    []string{"select 1;", "select 'never; closed"},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var got []string
+			for _, st := range splitSQL(tt.content) {
+				if tt.content[st.start:st.start+len(st.text)] != st.text {
+					t.Errorf("statement %q doesn't start at offset %d", st.text, st.start)
+				}
+				got = append(got, st.text)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatementError(t *testing.T) {
+	content := "create table a ();\n-- ünïcode comment\ncreate table b (\n    x intt\n);\n"
+	tests := map[string]struct {
+		err       error
+		index     int
+		line, col int
+		unlocated bool
+	}{
+		// The position counts characters, not bytes, from the start of the
+		// content.
+		"position": {
+			err:   &pgconn.PgError{Code: "42704", Position: 62},
+			index: 2, line: 4, col: 7,
+		},
+		"position in first statement": {
+			err:   &pgconn.PgError{Code: "42601", Position: 14},
+			index: 1, line: 1, col: 14,
+		},
+		"no position": {
+			err:       &pgconn.PgError{Code: "42704"},
+			unlocated: true,
+		},
+		"not a database error": {
+			err:       errors.New("connection reset"),
+			unlocated: true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := statementError(content, tt.err)
+			var se *StatementError
+			if !errors.As(err, &se) {
+				if !tt.unlocated {
+					t.Fatalf("got %v, want a StatementError", err)
+				}
+				return
+			}
+			if tt.unlocated {
+				t.Fatalf("got %v, want the error unchanged", err)
+			}
+			if se.Index != tt.index || se.Line != tt.line || se.Column != tt.col {
+				t.Errorf("got statement %d at %d:%d, want statement %d at %d:%d", se.Index, se.Line, se.Column, tt.index, tt.line, tt.col)
+			}
+			if !errors.Is(err, tt.err) {
+				t.Errorf("%v doesn't wrap %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestLocateError(t *testing.T) {
+	content := "select 1;\n\n  select 'ø' + x;\n"
+	sts := splitSQL(content)
+
+	// Without a position, the error is at the start of the statement.
+	err := locateError(content, sts, 1, sts[1].start, errors.New("boom"))
+	var se *StatementError
+	if !errors.As(err, &se) {
+		t.Fatalf("got %v, want a StatementError", err)
+	}
+	if se.Index != 2 || se.Line != 3 || se.Column != 3 {
+		t.Errorf("got statement %d at %d:%d, want statement 2 at 3:3", se.Index, se.Line, se.Column)
+	}
+	if se.Excerpt != "select 'ø' + x;" {
+		t.Errorf("got excerpt %q", se.Excerpt)
+	}
+
+	// A position is counted from the start of the statement that ran alone.
+	err = locateError(content, sts, 1, sts[1].start, &pgconn.PgError{Position: 14})
+	if !errors.As(err, &se) {
+		t.Fatalf("got %v, want a StatementError", err)
+	}
+	if se.Line != 3 || se.Column != 16 {
+		t.Errorf("got %d:%d, want 3:16", se.Line, se.Column)
+	}
+}
@@ -0,0 +1,525 @@
+package drift
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// DefaultStreamSize is the size above which migration files are streamed.
+const DefaultStreamSize = 64 << 20
+
+// streamChunkSize is how much of a streamed file is read at a time. Tests
+// shorten it.
+var streamChunkSize = 1 << 20
+
+// WithStreamSize sets the size in bytes above which a migration file is
+// streamed: instead of sending the whole file to the database in one Exec,
+// Drift reads it a chunk at a time and sends each statement on its own, so a
+// multi-hundred-megabyte backfill doesn't have to fit in one query. The
+// default is DefaultStreamSize, and n <= 0 turns streaming off.
+//
+// Streamed files are checked a chunk at a time before anything is applied,
+// and read again to apply them. Their statements run on one connection (in the
+// migration's transaction, if it has one), but WithDebugKeep and
+// WithAuditContent don't apply to them, and they're never applied in parallel.
+// Files with copy or batched directives are never streamed, and neither is
+// anything when the dialect is a BatchDialect, since its batches can't be told
+// apart by semicolons alone.
+func WithStreamSize(n int64) Option {
+	return func(m *Migrator) {
+		m.streamSize = n
+	}
+}
+
+// runStream runs a streamed migration outside of a transaction. It uses a
+// dedicated connection so that session settings carry over between
+// statements.
+func (m *Migrator) runStream(ctx context.Context, db conn, f migrationFile, timeout time.Duration) error {
+	return onConn(ctx, db, timeout, func(conn *sql.Conn) error {
+		return m.streamStatements(f, func(text string) error {
+			return run(ctx, m.shown(conn), text)
+		})
+	})
+}
+
+// streamStatements reads the migration file a chunk at a time and calls fn
+// with each statement, with variables substituted and (for the
+// strip-transaction directive) transaction control statements left out. An
+// error from fn is located in the file with a StatementError.
+func (m *Migrator) streamStatements(f migrationFile, fn func(text string) error) error {
+	var index int
+	return scanFile(f, nil, func(st scannedStatement) error {
+		index++
+		if f.directives.stripTransaction && reTransactionControl.MatchString(st.text) {
+			return nil
+		}
+		// The variables were all checked with the rest of the plan.
+		text, _ := m.substituteVars(st.text)
+		if err := fn(text); err != nil {
+			return streamError(text, index, st.line, st.col, err)
+		}
+		return nil
+	})
+}
+
+// scanFile reads the migration file a chunk at a time and calls fn with each
+// statement as soon as it's complete. Each chunk is passed to read first, if
+// it isn't nil.
+func scanFile(f migrationFile, read func(chunk []byte), fn func(st scannedStatement) error) error {
+	r, err := f.fsys.Open(f.Name)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	var sc statementScanner
+	chunk := make([]byte, streamChunkSize)
+	for !sc.eof {
+		n, err := io.ReadFull(r, chunk)
+		switch {
+		case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+			sc.eof = true
+		case err != nil:
+			return err
+		}
+		if read != nil {
+			read(chunk[:n])
+		}
+		sc.write(chunk[:n])
+		for {
+			st, ok := sc.next()
+			if !ok {
+				break
+			}
+			if err := fn(st); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// A streamSummary is what the checks before applying a plan need to know
+// about a streamed file, gathered in one pass so that its content never has to
+// be in memory all at once.
+type streamSummary struct {
+	// hash is the blobHash of the content.
+	hash string
+	// directives are the file's directive lines.
+	directives string
+	// requires are the migrations its statements require.
+	requires []MigrationID
+	// destructive are its destructive statements, like destructive returns.
+	destructive []string
+	// txControl is its first transaction control statement, if it has any.
+	txControl *scannedStatement
+	// varErr is the first error substituting variables in its statements.
+	varErr error
+	// findings are the lint findings in its statements, before the rules'
+	// allowed checks, if the Migrator lints.
+	findings []Finding
+}
+
+// readPlanned reads a planned file's content, or, if it's larger than the
+// stream size, checks it a chunk at a time and marks it to be streamed.
+// Copies, batched migrations, and files for a BatchDialect need their whole
+// content, so they're read even if they're large.
+func (m *Migrator) readPlanned(f *migrationFile) error {
+	if f.loaded || f.stream || m.streamSize <= 0 || f.size <= m.streamSize {
+		return f.load()
+	}
+	if _, ok := m.dialect.(BatchDialect); ok {
+		return f.load()
+	}
+	s, err := m.scanStream(*f)
+	if err != nil {
+		return err
+	}
+	// A directive error is reported with the other checks.
+	if d, err := parseDirectives(s.directives); err == nil && (len(d.copies) > 0 || d.batched) {
+		return f.load()
+	}
+	m.io.Debugf("Streaming migration of %d bytes: %s", f.size, f.Name)
+	f.summary = s
+	f.stream = true
+	return nil
+}
+
+// scanStream reads the file a chunk at a time and summarizes it for the
+// checks.
+func (m *Migrator) scanStream(f migrationFile) (*streamSummary, error) {
+	var (
+		s     streamSummary
+		size  int64
+		dl    directiveLines
+		seen  = make(map[string]bool)
+		state lintState
+	)
+	h := newBlobHash(f.size)
+	read := func(chunk []byte) {
+		size += int64(len(chunk))
+		h.Write(chunk)
+		dl.write(chunk)
+	}
+	err := scanFile(f, read, func(st scannedStatement) error {
+		s.requires = append(s.requires, requires(st.text)...)
+		for _, d := range destructive(st.text) {
+			if !seen[d] {
+				seen[d] = true
+				s.destructive = append(s.destructive, d)
+			}
+		}
+		if s.txControl == nil && reTransactionControl.MatchString(st.text) {
+			s.txControl = &st
+		}
+		if s.varErr == nil {
+			_, s.varErr = m.substituteVars(st.text)
+		}
+		if m.lint != nil {
+			s.findings = append(s.findings, m.lint.find(f.Path, st.text, st.line, &state)...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if size != f.size {
+		return nil, fmt.Errorf("%s changed while it was being read", f.Name)
+	}
+	s.hash = hex.EncodeToString(h.Sum(nil))
+	s.directives = dl.text()
+	return &s, nil
+}
+
+// directiveLines collects the lines that start like a directive from text
+// that arrives a chunk at a time.
+type directiveLines struct {
+	lines []string
+	// cur is the start of the current line, while it might still be a
+	// directive. done is set once it can't be.
+	cur  []byte
+	done bool
+}
+
+func (d *directiveLines) write(p []byte) {
+	for len(p) > 0 {
+		end := bytes.IndexByte(p, '\n')
+		seg := p
+		if end >= 0 {
+			seg = p[:end]
+		}
+		if !d.done {
+			d.cur = append(d.cur, seg...)
+			n := min(len(d.cur), len(directivePrefix))
+			d.done = string(d.cur[:n]) != directivePrefix[:n]
+		}
+		if end < 0 {
+			return
+		}
+		d.flush()
+		p = p[end+1:]
+	}
+}
+
+// flush ends the current line.
+func (d *directiveLines) flush() {
+	if !d.done && len(d.cur) >= len(directivePrefix) {
+		d.lines = append(d.lines, string(d.cur))
+	}
+	d.cur = d.cur[:0]
+	d.done = false
+}
+
+// text returns the directive lines, ending the last line.
+func (d *directiveLines) text() string {
+	d.flush()
+	return strings.Join(d.lines, "\n")
+}
+
+// directivePrefix starts every line that reDirectiveLine finds.
+const directivePrefix = "--drift:"
+
+// A scannedStatement is a statement from a statementScanner.
+type scannedStatement struct {
+	sqlStatement
+	// line and col are the 1-based location of the start of the statement.
+	line, col int
+}
+
+// scanState is where a statementScanner is within the SQL.
+type scanState int
+
+const (
+	scanCode scanState = iota
+	scanLineComment
+	scanBlockComment
+	scanQuoted
+	scanDollarQuoted
+)
+
+// A statementScanner splits SQL into statements like splitSQL does, but it
+// takes the text a chunk at a time. It keeps its place between chunks, so each
+// byte is only scanned once, and it only holds on to the text of the
+// statement it's in the middle of.
+type statementScanner struct {
+	buf []byte
+	// pos is the next byte of buf to scan, and start is the start of the
+	// current statement, or -1 between statements.
+	pos, start int
+	// base is the offset in the text of buf[0].
+	base int
+	// eof is set once the last chunk has been written.
+	eof bool
+
+	state scanState
+	// depth is how deeply nested a block comment is.
+	depth int
+	// quote and escapes describe the quoted text being scanned (see
+	// skipQuoted).
+	quote   byte
+	escapes bool
+	// tag ends the dollar-quoted text being scanned.
+	tag []byte
+
+	// line and col are the location of buf[mark].
+	mark, line, col int
+}
+
+// write adds a chunk of text. The scanner keeps only what it still needs of the
+// earlier chunks.
+func (s *statementScanner) write(p []byte) {
+	if s.buf == nil {
+		s.start, s.line, s.col = -1, 1, 1
+	}
+	// Quotes look back two bytes for an escape prefix (see splitSQL), so
+	// those are kept even between statements.
+	cut := max(s.pos-2, 0)
+	if s.start >= 0 {
+		cut = min(cut, s.start)
+	}
+	if cut > s.mark {
+		s.line, s.col = advance(s.line, s.col, s.buf[s.mark:cut])
+		s.mark = cut
+	}
+	if cut > 0 {
+		n := copy(s.buf, s.buf[cut:])
+		s.buf = s.buf[:n]
+		s.pos -= cut
+		s.mark -= cut
+		s.base += cut
+		if s.start >= 0 {
+			s.start -= cut
+		}
+	}
+	s.buf = append(s.buf, p...)
+}
+
+// next returns the next complete statement, if there is one yet. Until eof is
+// set, a statement is only complete once its semicolon has been scanned.
+func (s *statementScanner) next() (scannedStatement, bool) {
+	if s.buf == nil {
+		s.write(nil)
+	}
+	for {
+		if s.state != scanCode {
+			if !s.skip() {
+				return scannedStatement{}, false
+			}
+			continue
+		}
+		if s.pos >= len(s.buf) {
+			if !s.eof || s.start < 0 {
+				return scannedStatement{}, false
+			}
+			return s.emit(), true
+		}
+		// Two-byte tokens need the next byte to be told apart.
+		c := s.buf[s.pos]
+		if (c == '-' || c == '/') && s.pos+1 >= len(s.buf) && !s.eof {
+			return scannedStatement{}, false
+		}
+		switch {
+		case c == '-' && s.peek("--"):
+			s.pos += 2
+			s.state = scanLineComment
+			continue
+		case c == '/' && s.peek("/*"):
+			s.pos += 2
+			s.state, s.depth = scanBlockComment, 1
+			continue
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			s.pos++
+			continue
+		}
+
+		if s.start < 0 {
+			s.start = s.pos
+		}
+		switch {
+		case c == ';':
+			s.pos++
+			return s.emit(), true
+		case c == '\'':
+			i := s.pos
+			s.escapes = i > 0 && (s.buf[i-1] == 'e' || s.buf[i-1] == 'E') &&
+				(s.base+i == 1 || !isIdentChar(s.buf[i-2]))
+			s.pos++
+			s.state, s.quote = scanQuoted, c
+		case c == '"':
+			s.pos++
+			s.state, s.quote, s.escapes = scanQuoted, c, false
+		case c == '$' && (s.pos == 0 || !isIdentChar(s.buf[s.pos-1])):
+			if !s.dollarTag() {
+				return scannedStatement{}, false
+			}
+		default:
+			s.pos++
+		}
+	}
+}
+
+// peek reports whether the text at pos starts with the token.
+func (s *statementScanner) peek(token string) bool {
+	return bytes.HasPrefix(s.buf[s.pos:], []byte(token))
+}
+
+// skip scans through a comment or quoted text, and returns false if it needs
+// more text to find the end.
+func (s *statementScanner) skip() bool {
+	switch s.state {
+	case scanLineComment:
+		if end := bytes.IndexByte(s.buf[s.pos:], '\n'); end >= 0 {
+			s.pos += end + 1
+			s.state = scanCode
+			return true
+		}
+		s.pos = len(s.buf)
+	case scanBlockComment:
+		for s.pos < len(s.buf) {
+			if s.pos+1 >= len(s.buf) && !s.eof {
+				return false
+			}
+			switch {
+			case s.peek("/*"):
+				s.depth++
+				s.pos += 2
+			case s.peek("*/"):
+				s.depth--
+				s.pos += 2
+				if s.depth == 0 {
+					s.state = scanCode
+					return true
+				}
+			default:
+				s.pos++
+			}
+		}
+	case scanQuoted:
+		for s.pos < len(s.buf) {
+			c := s.buf[s.pos]
+			if (c == s.quote || (c == '\\' && s.escapes)) && s.pos+1 >= len(s.buf) && !s.eof {
+				return false
+			}
+			switch {
+			case c == '\\' && s.escapes:
+				s.pos = min(s.pos+2, len(s.buf))
+			case c == s.quote && s.pos+1 < len(s.buf) && s.buf[s.pos+1] == s.quote:
+				s.pos += 2
+			case c == s.quote:
+				s.pos++
+				s.state = scanCode
+				return true
+			default:
+				s.pos++
+			}
+		}
+	case scanDollarQuoted:
+		if end := bytes.Index(s.buf[s.pos:], s.tag); end >= 0 {
+			s.pos += end + len(s.tag)
+			s.state = scanCode
+			return true
+		}
+		// The tag might straddle the end of the chunk.
+		s.pos = max(s.pos, len(s.buf)-len(s.tag)+1)
+		if s.eof {
+			s.pos = len(s.buf)
+		}
+	}
+	if s.eof {
+		// Unterminated, so it runs to the end, like in splitSQL.
+		s.state = scanCode
+		return true
+	}
+	return false
+}
+
+// dollarTag starts dollar-quoted text at pos, or skips the dollar sign if it
+// doesn't start a dollar quote (see skipDollarQuoted). It returns false if it
+// needs more text to tell.
+func (s *statementScanner) dollarTag() bool {
+	i := s.pos
+	end := i + 1
+	for end < len(s.buf) && s.buf[end] != '$' {
+		if !isIdentChar(s.buf[end]) || (s.buf[end] >= '0' && s.buf[end] <= '9' && end == i+1) {
+			s.pos++
+			return true
+		}
+		end++
+	}
+	if end >= len(s.buf) {
+		if !s.eof {
+			return false
+		}
+		s.pos++
+		return true
+	}
+	s.tag = append(s.tag[:0], s.buf[i:end+1]...)
+	s.pos = end + 1
+	s.state = scanDollarQuoted
+	return true
+}
+
+// emit returns the statement that ends at pos.
+func (s *statementScanner) emit() scannedStatement {
+	s.line, s.col = advance(s.line, s.col, s.buf[s.mark:s.start])
+	st := scannedStatement{
+		sqlStatement: sqlStatement{text: string(s.buf[s.start:s.pos]), start: s.base + s.start},
+		line:         s.line,
+		col:          s.col,
+	}
+	s.line, s.col = advance(s.line, s.col, s.buf[s.start:s.pos])
+	s.mark, s.start = s.pos, -1
+	return st
+}
+
+// streamError locates the error from a streamed statement, which starts at
+// line and col of the file.
+func streamError(text string, index, line, col int, err error) error {
+	var st *StatementError
+	if !errors.As(locateError(text, []sqlStatement{{text: text}}, 0, 0, err), &st) {
+		return err
+	}
+	if st.Line == 1 {
+		st.Column += col - 1
+	}
+	st.Line += line - 1
+	st.Index = index
+	return st
+}
+
+// advance returns the line and column after the text, which starts at line
+// and col.
+func advance(line, col int, text []byte) (int, int) {
+	if n := bytes.Count(text, []byte("\n")); n > 0 {
+		return line + n, utf8.RuneCount(text[bytes.LastIndexByte(text, '\n')+1:]) + 1
+	}
+	return line, col + utf8.RuneCount(text)
+}
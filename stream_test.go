@@ -0,0 +1,245 @@
+package drift
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/metagram-net/drift/internal/memfs"
+)
+
+func TestStreamStatements(t *testing.T) {
+	// Enough statements to span two chunks, with the last ones in the second.
+	var b strings.Builder
+	b.WriteString("begin;\n")
+	n := streamChunkSize / len("insert into t values (00000);\n")
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "insert into t values (%05d);\n", i)
+	}
+	b.WriteString("insert into t values ('${DRIFT_VAR_name}');\nselect 1; select bad;\ncommit;\n")
+	f := migrationFile{
+		Name:       "1-big.sql",
+		fsys:       fstest.MapFS{"1-big.sql": {Data: []byte(b.String())}},
+		directives: directives{stripTransaction: true},
+	}
+	m := New(WithVars(map[string]string{"name": "x"}))
+
+	errBad := errors.New("bad statement")
+	var got []string
+	err := m.streamStatements(f, func(text string) error {
+		if strings.Contains(text, "bad") {
+			return errBad
+		}
+		got = append(got, strings.TrimSpace(text))
+		return nil
+	})
+	if !errors.Is(err, errBad) {
+		t.Fatalf("got error %v, want %v", err, errBad)
+	}
+	var st *StatementError
+	if !errors.As(err, &st) {
+		t.Fatalf("got error %v, want a StatementError", err)
+	}
+	if st.Index != n+4 || st.Line != n+3 || st.Column != 11 {
+		t.Errorf("got statement %d at %d:%d, want %d at %d:11", st.Index, st.Line, st.Column, n+4, n+3)
+	}
+	if len(got) != n+2 {
+		t.Fatalf("ran %d statements, want %d", len(got), n+2)
+	}
+	if got[0] != "insert into t values (00000);" || got[n] != "insert into t values ('x');" || got[n+1] != "select 1;" {
+		t.Errorf("ran %q, ..., %q", got[0], got[n:])
+	}
+}
+
+// scanAll feeds the content to a statementScanner in chunks of the size.
+func scanAll(content string, size int) []scannedStatement {
+	var sc statementScanner
+	var sts []scannedStatement
+	for i := 0; !sc.eof; i += size {
+		end := min(i+size, len(content))
+		sc.eof = end == len(content)
+		sc.write([]byte(content[i:end]))
+		for {
+			st, ok := sc.next()
+			if !ok {
+				break
+			}
+			sts = append(sts, st)
+		}
+	}
+	return sts
+}
+
+func TestStatementScanner(t *testing.T) {
+	tests := map[string]string{
+		"empty":           "",
+		"comments only":   "-- nothing\n/* at */ -- all\n",
+		"simple":          "create table a ();\ncreate table b ();\n",
+		"no semicolon":    "select 1;\nselect 2",
+		"line comment":    "select 1; -- a; comment\nselect 2;",
+		"block comment":   "select /* a; /* nested; */ comment; */ 1; select 2;",
+		"quoted":          "select 'a;b', 'it''s;'; select \"x;\"\"y\";",
+		"escape string":   "select E'a\\';b'; select e'\\\\'; select 1;",
+		"not an escape":   "select name'a\\';b';",
+		"escape after ;":  "select 1;E'x';e'y';",
+		"dollar quoted":   "create function f() returns int as $body$ select 1; $x$ $body$ language sql; select 2;",
+		"empty dollar":    "do $$ begin perform 1; end $$; select 2;",
+		"parameter":       "select $1; select a$b; select $2x$ ; $2x$;",
+		"unterminated":    "select 1; select 'never; closed",
+		"unterminated $":  "select 1; do $a$ never; closed",
+		"unicode":         "select 'héllo;'; -- ünïcode\nselect 'ø';",
+		"crlf":            "select 1;\r\n-- x\r\nselect 2;\r\n",
+		"trailing dash":   "select 1 -",
+		"trailing slash":  "select 4 /",
+		"trailing quote":  "select ''",
+		"trailing dollar": "select $",
+	}
+	for name, content := range tests {
+		want := splitSQL(content)
+		for size := 1; size <= len(content)+1; size++ {
+			got := scanAll(content, size)
+			if len(got) != len(want) {
+				t.Fatalf("%s in chunks of %d: got %d statements %+v, want %+v", name, size, len(got), got, want)
+			}
+			for i, st := range got {
+				if st.sqlStatement != want[i] {
+					t.Fatalf("%s in chunks of %d: statement %d: got %+v, want %+v", name, size, i, st.sqlStatement, want[i])
+				}
+				line, col := lineColumn(content, st.start)
+				if st.line != line || st.col != col {
+					t.Fatalf("%s in chunks of %d: statement %d at %d:%d, want %d:%d", name, size, i, st.line, st.col, line, col)
+				}
+			}
+		}
+	}
+}
+
+func TestDirectiveLines(t *testing.T) {
+	content := "--drift:no-transaction\n-- --drift:not-this\nselect '\n--drift:timeout=5s -- why';\n--drift:\n--dri"
+	want := "--drift:no-transaction\n--drift:timeout=5s -- why';\n--drift:"
+	if got := strings.Join(reDirectiveLine.FindAllString(content, -1), "\n"); got != want {
+		t.Fatalf("reDirectiveLine found %q, want %q", got, want)
+	}
+	for size := 1; size <= len(content); size++ {
+		var dl directiveLines
+		for i := 0; i < len(content); i += size {
+			dl.write([]byte(content[min(i, len(content)):min(i+size, len(content))]))
+		}
+		if got := dl.text(); got != want {
+			t.Errorf("in chunks of %d: got %q, want %q", size, got, want)
+		}
+	}
+}
+
+// withChunkSize shortens the stream chunk size for the test.
+func withChunkSize(t *testing.T, n int) {
+	old := streamChunkSize
+	streamChunkSize = n
+	t.Cleanup(func() { streamChunkSize = old })
+}
+
+func TestReadPlannedStream(t *testing.T) {
+	withChunkSize(t, 7)
+	content := "--drift:timeout=5s\n" +
+		"select _drift_require_migration(3);\n" +
+		"drop table old_users;\n" +
+		"insert into t values ('${DRIFT_VAR_name}');\n" +
+		"commit;\n" +
+		"create index i on t (a);\n"
+	fsys := memfs.New(map[string]string{"migrations/4-big.sql": content})
+	m := New(WithFileSystem(fsys), WithStreamSize(10), WithLint(LintConfig{}))
+	files, err := m.listFiles("migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &files[0]
+	if err := m.readPlanned(f); err != nil {
+		t.Fatal(err)
+	}
+	if !f.stream || f.loaded || f.Content != "" {
+		t.Fatalf("got stream=%v loaded=%v content=%q, want only streamed", f.stream, f.loaded, f.Content)
+	}
+	if got, want := f.hash(), blobHash(content); got != want {
+		t.Errorf("got hash %s, want %s", got, want)
+	}
+	if got, want := f.directiveText(), "--drift:timeout=5s"; got != want {
+		t.Errorf("got directives %q, want %q", got, want)
+	}
+	if got, want := dependencies(*f), []MigrationID{3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got dependencies %v, want %v", got, want)
+	}
+	if got, want := f.destructiveStatements(), []string{"drop table"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got destructive %v, want %v", got, want)
+	}
+	if err := m.checkVars(*f); !errors.Is(err, ErrUnboundVariable) {
+		t.Errorf("got vars error %v, want %v", err, ErrUnboundVariable)
+	}
+	if err := m.checkTransactionControl(*f); err == nil || !strings.Contains(err.Error(), "line 5 (commit;)") {
+		t.Errorf("got transaction control error %v, want one at line 5", err)
+	}
+	var lines []int
+	for _, finding := range m.lint.lint(*f) {
+		lines = append(lines, finding.Line)
+	}
+	if want := []int{3, 3, 6}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("got findings at lines %v, want %v", lines, want)
+	}
+
+	var got []string
+	err = m.streamStatements(*f, func(text string) error {
+		got = append(got, text)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want []string
+	for _, st := range splitSQL(content) {
+		want = append(want, st.text)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("streamed %q, want %q", got, want)
+	}
+}
+
+func TestReadPlannedSmall(t *testing.T) {
+	fsys := memfs.New(map[string]string{
+		"migrations/1-small.sql": "select 1;",
+		"migrations/2-copy.sql":  "--drift:copy=users.csv\n" + strings.Repeat("-- padding\n", 10),
+	})
+	m := New(WithFileSystem(fsys), WithStreamSize(20))
+	files, err := m.listFiles("migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range files {
+		f := &files[i]
+		if err := m.readPlanned(f); err != nil {
+			t.Fatal(err)
+		}
+		if f.stream || !f.loaded {
+			t.Errorf("%s: got stream=%v loaded=%v, want it loaded", f.Name, f.stream, f.loaded)
+		}
+	}
+}
+
+func TestReadPlannedBatchDialect(t *testing.T) {
+	fsys := memfs.New(map[string]string{
+		"migrations/1-proc.sql": "create procedure p as begin select 1; select 2; end\nGO\n",
+	})
+	m := New(WithFileSystem(fsys), WithStreamSize(10), WithDialect(SQLServer{}))
+	files, err := m.listFiles("migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &files[0]
+	if err := m.readPlanned(f); err != nil {
+		t.Fatal(err)
+	}
+	if f.stream || !f.loaded {
+		t.Errorf("got stream=%v loaded=%v, want it loaded", f.stream, f.loaded)
+	}
+}
@@ -22,51 +22,52 @@ You can also modify _drift_require_migration; Drift doesn't use it. It's useful
 to call within a migration when it would only make sense to run after some
 earlier one has completed.
 
-You can also modify the schema_migrations table, but (at least for now) Drift
-assumes that the migration records table has exactly that name and has the
-integer primary key id column.
+You can also modify the {{.Table}} table, but (at least for now) Drift assumes
+that the migration records table has exactly that name (configured with the
+migrations table option) and has the integer primary key id column.
+
+Drift always refers to the table and the _drift_claim_migration function by
+their schema-qualified names, so the search_path doesn't matter. The schema
+must already exist. For a module (configured with the module option), the
+function names end with the module name, so several modules can share a
+schema.
 */
 --drift:no-transaction
 
 begin;
 
-create table schema_migrations (
-    id integer primary key,
-    slug text not null,
-    run_at timestamp not null default current_timestamp
-);
-
--- _drift_claim_migration registers a migration in the schema_migrations table.
+{{template "table" .}}
+-- _drift_claim_migration registers a migration in the {{.Table}} table.
 -- It will fail if the migration ID has already been claimed.
 --
 -- Drift will call this at the start of every migration transaction. For
 -- migrations that cannot be run within transactions, it is the migration's
 -- responsibility to call this.
-create function _drift_claim_migration(mid integer, mslug text) returns void as $$
-    insert into schema_migrations (id, slug) values (mid, mslug);
+create function {{.ClaimFunc}}(mid integer, mslug text) returns void as $$
+    insert into {{.Table}} (id, slug) values (mid, mslug);
 $$ language sql;
 
--- _drift_unclaim_migration removes a migration from the schema_migrations
+-- _drift_unclaim_migration removes a migration from the {{.Table}}
 -- table.
 --
 -- When iterating on a migration in development, it's useful to have an "undo"
 -- or "down" migration to reset back to the previous schema. Call this to undo
 -- the automatic _drift_claim_migration to be able to re-run the "up"
 -- migration.
-create function _drift_unclaim_migration(mid integer) returns void as $$
-    delete from schema_migrations where id = mid;
+create function {{.UnclaimFunc}}(mid integer) returns void as $$
+    delete from {{.Table}} where id = mid;
 $$ language sql;
 
 -- _drift_require_migration asserts that the migration ID has already been
--- claimed in the schema_migrations table.
+-- claimed in the {{.Table}} table.
 --
 -- Call this from within a migration to ensure that another migration has
 -- already run to completion.
-create function _drift_require_migration(mid integer) returns void as $$
+create function {{.RequireFunc}}(mid integer) returns void as $$
 declare
-    mrow schema_migrations%rowtype;
+    mrow {{.Table}}%rowtype;
 begin
-    select * into mrow from schema_migrations where id = mid;
+    select * into mrow from {{.Table}} where id = mid;
     if not found then
         raise exception 'Required migration has not been run: %', mid;
     end if;
@@ -74,7 +75,7 @@ end;
 $$ language plpgsql;
 
 -- Normally, this would be the first thing in the migration, but we had to
--- create the schema_migrations table first!
-select _drift_claim_migration(0, 'init');
+-- create the {{.Table}} table first!
+select {{.ClaimFunc}}(0, 'init');
 
 commit;
@@ -0,0 +1,28 @@
+/*
+Set up the Drift framework requirements for ClickHouse.
+
+ClickHouse has no transactions or functions like the Postgres init migration
+uses (run "drift init-template" to read it), so this only creates the
+{{.Table}} table. It's a ReplacingMergeTree ordered by the migration ID, so
+recording a migration twice leaves one row once the parts merge, and Drift
+ignores the duplicates until then.
+
+Drift records each migration by inserting into the table after it has run.
+Migrations with a no-transaction directive have to do it themselves, like this
+one does at the end:
+
+    insert into {{.Table}} (id, slug) values (1234, 'create_events');
+
+You can add columns like duration_ms and applied_by, but Drift can't fill them
+in, since ClickHouse doesn't support updating rows the way Drift does.
+*/
+--drift:no-transaction
+
+create table if not exists {{.Table}} (
+    id Int64,
+    slug String,
+    run_at DateTime64(3) default now64(3)
+) engine = ReplacingMergeTree
+order by id;
+
+insert into {{.Table}} (id, slug) values (0, 'init');
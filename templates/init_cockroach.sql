@@ -0,0 +1,46 @@
+/*
+Set up the Drift framework requirements for CockroachDB. This is like the
+Postgres init migration, so read the comments there (run "drift init-template")
+for the details. The differences are:
+
+1. Everything uses "if not exists" or "or replace", so it's safe to run again
+   if it fails partway.
+2. _drift_require_migration is written without %rowtype.
+
+This needs CockroachDB 23.1 or later for PL/pgSQL functions.
+*/
+--drift:no-transaction
+
+create table if not exists {{.Table}} (
+    id integer primary key,
+    slug text not null,
+    run_at timestamp not null default current_timestamp,
+    duration_ms integer,
+    applied_by text,
+    content text,
+    faked boolean not null default false
+);
+
+-- _drift_claim_migration registers a migration in the {{.Table}} table.
+-- It will fail if the migration ID has already been claimed.
+create or replace function {{.ClaimFunc}}(mid integer, mslug text) returns void as $$
+    insert into {{.Table}} (id, slug) values (mid, mslug);
+$$ language sql;
+
+-- _drift_unclaim_migration removes a migration from the {{.Table}}
+-- table.
+create or replace function {{.UnclaimFunc}}(mid integer) returns void as $$
+    delete from {{.Table}} where id = mid;
+$$ language sql;
+
+-- _drift_require_migration asserts that the migration ID has already been
+-- claimed in the {{.Table}} table.
+create or replace function {{.RequireFunc}}(mid integer) returns void as $$
+begin
+    if not exists (select 1 from {{.Table}} where id = mid) then
+        raise exception 'Required migration has not been run: %', mid;
+    end if;
+end;
+$$ language plpgsql;
+
+select {{.ClaimFunc}}(0, 'init');
@@ -0,0 +1,45 @@
+/*
+Set up the Drift framework requirements for SQL Server. This is like the
+Postgres init migration (run "drift init-template" to read it), but Drift's
+functions are stored procedures, and each one has to start its own batch, so
+the batches are separated with GO lines.
+
+Drift calls {{.ClaimFunc}} with exec at the start of every migration
+transaction. Migrations with a no-transaction directive have to call it
+themselves:
+
+    exec {{.ClaimFunc}} 1234, 'create_users';
+*/
+--drift:no-transaction
+
+create table {{.Table}} (
+    id bigint primary key,
+    slug nvarchar(max) not null,
+    run_at datetime2 not null default sysutcdatetime(),
+    duration_ms int,
+    applied_by nvarchar(max),
+    content nvarchar(max),
+    faked bit not null default 0
+);
+GO
+
+-- _drift_claim_migration registers a migration in the {{.Table}} table.
+-- It will fail if the migration ID has already been claimed.
+create procedure {{.ClaimFunc}} @mid bigint, @mslug nvarchar(max) as
+    insert into {{.Table}} (id, slug) values (@mid, @mslug);
+GO
+
+-- _drift_unclaim_migration removes a migration from the {{.Table}}
+-- table.
+create procedure {{.UnclaimFunc}} @mid bigint as
+    delete from {{.Table}} where id = @mid;
+GO
+
+-- _drift_require_migration asserts that the migration ID has already been
+-- claimed in the {{.Table}} table.
+create procedure {{.RequireFunc}} @mid bigint as
+    if not exists (select 1 from {{.Table}} where id = @mid)
+        throw 50000, 'Required migration has not been run', 1;
+GO
+
+exec {{.ClaimFunc}} 0, 'init';
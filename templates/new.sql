@@ -1,4 +1,4 @@
 -- Timestamp: {{.ID}}
 -- Slug:      {{.Slug}}
 --
--- TODO: Write your migration here!
+{{if .Body}}{{.Body}}{{else}}-- TODO: Write your migration here!{{end}}
@@ -0,0 +1,9 @@
+create table {{.Table}} (
+    id integer primary key,
+    slug text not null,
+    run_at timestamp not null default current_timestamp,
+    duration_ms integer,
+    applied_by text,
+    content text,
+    faked boolean not null default false
+);
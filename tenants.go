@@ -0,0 +1,209 @@
+package drift
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+)
+
+// ErrTenantsFailed is returned by RunTenants with WithKeepGoing when any tenant
+// failed.
+var ErrTenantsFailed = errors.New("some tenants failed")
+
+// ErrTenantProgressFile is returned by RunTenants with WithProgressFile. The
+// file describes one run, so concurrent tenants would overwrite each other's
+// reports.
+var ErrTenantProgressFile = errors.New("a progress file can't follow several tenants")
+
+// WithTenantWorkers makes RunTenants migrate up to n tenants at once.
+func WithTenantWorkers(n int) Option {
+	return func(m *Migrator) {
+		m.tenantWorkers = n
+	}
+}
+
+// WithKeepGoing makes RunTenants carry on with the other tenants when one
+// fails, instead of stopping.
+func WithKeepGoing() Option {
+	return func(m *Migrator) {
+		m.keepGoing = true
+	}
+}
+
+// A TenantResult is the outcome of migrating one tenant schema.
+type TenantResult struct {
+	Schema string
+	// Result is what the run did, or nil if it failed before planning.
+	Result *Result
+	Err    error
+}
+
+// RunTenants applies the migrations in migrationsDir to each tenant schema, for
+// schema-per-tenant databases. It migrates the tenants in order, or up to the
+// WithTenantWorkers limit at once, and logs each tenant's progress with the
+// schema name as a prefix.
+//
+// It stops starting new tenants after the first failure, unless WithKeepGoing
+// is set, in which case it returns ErrTenantsFailed at the end. Either way,
+// the results list every tenant it started, in the order of schemas.
+//
+// Each tenant has its own migrations table and Drift functions in its schema,
+// which RunTenants creates (as the init migration would) if they're missing.
+// So the init migration is recorded as applied for new tenants without
+// running it.
+//
+// open must return a connection pool whose search_path is the tenant's schema,
+// so that unqualified names in the migrations refer to the tenant's tables.
+// RunTenants closes it when the tenant is done.
+func (m *Migrator) RunTenants(ctx context.Context, open func(schema string) (*sql.DB, error), migrationsDir string, schemas []string, upto *MigrationID) ([]TenantResult, error) {
+	if m.progressFile != "" {
+		return nil, ErrTenantProgressFile
+	}
+	workers := m.tenantWorkers
+	if workers < 1 {
+		workers = 1
+	}
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		failed  bool
+		sem     = make(chan struct{}, workers)
+		started = make([]bool, len(schemas))
+		results = make([]TenantResult, len(schemas))
+	)
+	for i, schema := range schemas {
+		sem <- struct{}{}
+		mu.Lock()
+		stop := failed && !m.keepGoing
+		mu.Unlock()
+		if stop {
+			<-sem
+			break
+		}
+
+		started[i] = true
+		wg.Add(1)
+		go func(i int, schema string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			t := m.forTenant(schema)
+			t.io.Infof("Migrating tenant")
+			res, err := t.runTenant(ctx, open, migrationsDir, upto)
+			results[i] = TenantResult{Schema: schema, Result: res, Err: err}
+			if err != nil {
+				mu.Lock()
+				failed = true
+				mu.Unlock()
+			}
+		}(i, schema)
+	}
+	wg.Wait()
+
+	var (
+		done     []TenantResult
+		firstErr error
+		nfailed  int
+	)
+	for i, r := range results {
+		if !started[i] {
+			continue
+		}
+		done = append(done, r)
+		if r.Err != nil {
+			nfailed++
+			if firstErr == nil {
+				firstErr = fmt.Errorf("tenant %s: %w", r.Schema, r.Err)
+			}
+		}
+	}
+	if m.keepGoing && nfailed > 0 {
+		return done, fmt.Errorf("%w: %d of %d", ErrTenantsFailed, nfailed, len(done))
+	}
+	return done, firstErr
+}
+
+// forTenant returns a copy of the Migrator that keeps its records in the
+// tenant's schema and labels its logs with the schema.
+func (m *Migrator) forTenant(schema string) *Migrator {
+	t := *m
+	t.schema = schema
+	t.io = tenantIO{io: m.io, schema: schema}
+	return &t
+}
+
+// tenantIO prefixes log messages with the tenant schema, so concurrent
+// tenants' logs can be told apart.
+type tenantIO struct {
+	io     IO
+	schema string
+}
+
+func (t tenantIO) Infof(format string, args ...interface{}) (int, error) {
+	return t.io.Infof("[%s] "+format, append([]interface{}{t.schema}, args...)...)
+}
+
+func (t tenantIO) Debugf(format string, args ...interface{}) (int, error) {
+	return t.io.Debugf("[%s] "+format, append([]interface{}{t.schema}, args...)...)
+}
+
+func (t tenantIO) Warnf(format string, args ...interface{}) (int, error) {
+	warnf(t.io, "[%s] "+format, append([]interface{}{t.schema}, args...)...)
+	return 0, nil
+}
+
+func (t tenantIO) Errorf(format string, args ...interface{}) (int, error) {
+	errorf(t.io, "[%s] "+format, append([]interface{}{t.schema}, args...)...)
+	return 0, nil
+}
+
+func (t tenantIO) logAttrs(level slog.Level, msg string, attrs ...slog.Attr) {
+	if aio, ok := t.io.(attrIO); ok {
+		aio.logAttrs(level, msg, append(attrs, slog.String("tenant", t.schema))...)
+		return
+	}
+	logf(t, level, "%s", msg)
+}
+
+func (m *Migrator) runTenant(ctx context.Context, open func(schema string) (*sql.DB, error), migrationsDir string, upto *MigrationID) (*Result, error) {
+	db, err := open(m.schema)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect: %w", err)
+	}
+	defer db.Close()
+
+	if err := m.setupSchema(ctx, db); err != nil {
+		return nil, err
+	}
+	return m.Run(ctx, db, migrationsDir, upto)
+}
+
+// setupSchema runs the init migration for the Migrator's schema if its
+// migrations table doesn't exist yet.
+func (m *Migrator) setupSchema(ctx context.Context, db *sql.DB) error {
+	query, args, err := m.sb().Select("to_regclass(?) is not null").ToSql()
+	if err != nil {
+		return err
+	}
+	args = append(args, m.tableName())
+	var exists bool
+	if err := db.QueryRowContext(ctx, query, args...).Scan(&exists); err != nil {
+		return fmt.Errorf("could not check for the migrations table: %w", err)
+	}
+	if exists {
+		return nil
+	}
+
+	m.io.Infof("Setting up Drift in schema %s", m.schema)
+	var content bytes.Buffer
+	if err := initTemplate.Execute(&content, m.initData()); err != nil {
+		return fmt.Errorf("could not render init migration: %w", err)
+	}
+	if _, err := db.ExecContext(ctx, content.String()); err != nil {
+		return fmt.Errorf("could not set up the migrations table: %w", err)
+	}
+	return nil
+}
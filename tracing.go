@@ -0,0 +1,65 @@
+package drift
+
+import (
+	"context"
+	"log/slog"
+)
+
+// A Tracer starts spans for tracing migration runs. It's shaped like
+// OpenTelemetry's trace.Tracer, so an adapter takes only a few lines, but it
+// keeps Drift free of tracing dependencies.
+//
+// Drift starts these spans:
+//
+//   - drift.migrate for each run, with drift.applied and drift.version
+//   - drift.migration for each migration, with migration.id and
+//     migration.slug
+//   - drift.statement for each statement sent to the database, with
+//     db.rows_affected
+//
+// To trace each statement separately, Drift runs a migration's statements one
+// at a time instead of sending the whole file at once, as with WithShowSQL.
+// Dialects that split migrations into batches (like SQL Server) send, and
+// trace, a whole batch at a time, as do seeds and the statements around a
+// copy directive.
+type Tracer interface {
+	Start(ctx context.Context, name string, attrs ...slog.Attr) (context.Context, Span)
+}
+
+// A Span is a traced operation started by a Tracer.
+type Span interface {
+	SetAttributes(attrs ...slog.Attr)
+	RecordError(err error)
+	End()
+}
+
+// WithTracer makes Migrate trace its work with the tracer.
+func WithTracer(t Tracer) Option {
+	return func(m *Migrator) {
+		m.tracer = t
+	}
+}
+
+type tracerKey struct{}
+
+// startSpan starts a span if there's a tracer, and remembers the tracer in the
+// context so that functions without access to the Migrator can start child
+// spans.
+func startSpan(ctx context.Context, t Tracer, name string, attrs ...slog.Attr) (context.Context, Span) {
+	if t == nil {
+		return ctx, nopSpan{}
+	}
+	ctx, s := t.Start(ctx, name, attrs...)
+	return context.WithValue(ctx, tracerKey{}, t), s
+}
+
+func tracerFrom(ctx context.Context) Tracer {
+	t, _ := ctx.Value(tracerKey{}).(Tracer)
+	return t
+}
+
+type nopSpan struct{}
+
+func (nopSpan) SetAttributes(...slog.Attr) {}
+func (nopSpan) RecordError(error)          {}
+func (nopSpan) End()                       {}
@@ -0,0 +1,264 @@
+package drift
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ErrNoTransactionInBatch means a migration that can't run in a transaction
+// was planned in TransactionAll mode.
+var ErrNoTransactionInBatch = errors.New("no-transaction migration can't be applied in a single-transaction batch")
+
+// ErrRolledBack means a migration failed in TransactionAll mode (or the
+// transaction couldn't commit), so the whole batch was rolled back and none
+// of the migrations were applied. The Result lists the others as skipped with
+// SkipRolledBack.
+var ErrRolledBack = errors.New("rolled back the whole batch")
+
+// ErrNotAtomic means WithAtomic was used with a dialect or transaction mode
+// that can't apply the migrations all-or-nothing.
+var ErrNotAtomic = errors.New("can't apply migrations atomically")
+
+// ErrTransactionControl means a migration that Drift runs in a transaction
+// has its own transaction control statements, like BEGIN and COMMIT.
+var ErrTransactionControl = errors.New("migration has its own transaction control statements")
+
+// A TransactionMode is how Migrate wraps migrations in transactions.
+type TransactionMode string
+
+const (
+	// TransactionEach applies each migration in its own transaction. This is
+	// the default.
+	TransactionEach TransactionMode = "each"
+	// TransactionAll applies every planned migration in one transaction, so
+	// either all of them are applied or none are.
+	TransactionAll TransactionMode = "all"
+	// TransactionNone applies migrations without a transaction. A migration
+	// that fails partway through leaves its earlier statements in place and
+	// isn't recorded as applied.
+	TransactionNone TransactionMode = "none"
+)
+
+// TransactionModes lists every valid TransactionMode.
+var TransactionModes = []TransactionMode{TransactionEach, TransactionAll, TransactionNone}
+
+// WithTransactionMode sets how migrations are wrapped in transactions.
+// Migrations with a --drift:no-transaction directive never run in a
+// transaction, so TransactionAll refuses to apply a plan that includes one.
+//
+// In TransactionAll mode, migrations are applied one at a time (WithParallel
+// has no effect), and stop signals and WithStopAfter are only checked before
+// the transaction starts.
+func WithTransactionMode(mode TransactionMode) Option {
+	return func(m *Migrator) {
+		m.txMode = mode
+	}
+}
+
+// WithAtomic makes a run all-or-nothing, for applications that migrate on
+// startup and would rather not start on a half-migrated schema. It applies
+// every planned migration in one transaction, like TransactionAll, and
+// refuses to run (with ErrNotAtomic) if the dialect can't do that. A failure
+// rolls back the whole batch and returns ErrRolledBack.
+func WithAtomic() Option {
+	return func(m *Migrator) {
+		m.txMode = TransactionAll
+		m.atomic = true
+	}
+}
+
+// checkTransactionMode returns an error if the plan can't be applied in the
+// Migrator's transaction mode.
+func (m *Migrator) checkTransactionMode(plan []migrationFile) error {
+	if m.atomic && m.txMode != TransactionAll {
+		return fmt.Errorf("%w: migrations are applied in transaction mode %q", ErrNotAtomic, m.txMode)
+	}
+	if m.txMode != TransactionAll {
+		return nil
+	}
+	for _, f := range plan {
+		if f.directives.noTransaction {
+			return fmt.Errorf("%w: %s", ErrNoTransactionInBatch, f.Name)
+		}
+		if f.directives.batched {
+			return fmt.Errorf("%w: %s commits each of its batches", ErrNoTransactionInBatch, f.Name)
+		}
+	}
+	return nil
+}
+
+// applyAll applies the whole plan in one transaction. Nothing is recorded as
+// applied until the transaction commits.
+func (m *Migrator) applyAll(ctx context.Context, db conn, plan []migrationFile, prog *progress, res *Result) error {
+	if m.stopRequested() {
+		for _, f := range plan {
+			res.skipped(f, SkipStopped)
+		}
+		prog.end(ErrStopped)
+		return ErrStopped
+	}
+
+	m.io.Infof("Applying %d migrations in a single transaction", len(plan))
+	// COPY needs the connection under the transaction.
+	var raw *sql.Conn
+	if hasCopies(plan) {
+		c, release, err := dedicatedConn(ctx, db)
+		if err != nil {
+			return err
+		}
+		defer release()
+		raw, db = c, c
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	// This is a no-op after a successful commit.
+	defer tx.Rollback() //nolint:errcheck
+
+	ds, err := m.applyAllTx(ctx, tx, raw, plan, prog, res)
+	if err != nil {
+		return m.rolledBack(plan, res, err)
+	}
+	if err := tx.Commit(); err != nil {
+		err = fmt.Errorf("could not commit the migrations: %w", err)
+		prog.end(err)
+		return m.rolledBack(plan, res, err)
+	}
+	for i, f := range plan {
+		prog.finish(f.ID)
+		res.applied(f, ds[i])
+	}
+	prog.end(nil)
+	m.logFinished(res)
+	return nil
+}
+
+// rolledBack reports that the batch transaction was rolled back: every
+// planned migration that didn't fail is skipped, since none were applied.
+func (m *Migrator) rolledBack(plan []migrationFile, res *Result, err error) error {
+	failed := make(map[MigrationID]bool)
+	for _, f := range res.Failed {
+		failed[f.ID] = true
+	}
+	for _, f := range plan {
+		if !failed[f.ID] {
+			res.skipped(f, SkipRolledBack)
+		}
+	}
+	warnf(m.io, "Rolled back the transaction, so none of the %d migrations were applied", len(plan))
+	return fmt.Errorf("%w: %w", ErrRolledBack, err)
+}
+
+// applyAllTx applies the whole plan in the transaction without committing it.
+// The raw connection is the one under the transaction, if Drift opened it.
+func (m *Migrator) applyAllTx(ctx context.Context, tx *sql.Tx, raw *sql.Conn, plan []migrationFile, prog *progress, res *Result) ([]time.Duration, error) {
+	var cols historyColumns
+	ds := make([]time.Duration, len(plan))
+	for i, f := range plan {
+		// The init migration creates the table inside this transaction, so
+		// look for the columns here rather than on another connection.
+		var err error
+		cols, err = m.refreshColumns(ctx, tx, cols)
+		if err != nil {
+			return nil, fmt.Errorf("could not inspect the migrations table: %w", err)
+		}
+		d, err := m.applyInBatch(ctx, tx, raw, f, cols, prog)
+		if err != nil {
+			res.failed(f, err)
+			prog.end(err)
+			return nil, err
+		}
+		ds[i] = d
+	}
+	return ds, nil
+}
+
+// applyInBatch applies one migration in the batch transaction and reports its
+// progress.
+func (m *Migrator) applyInBatch(ctx context.Context, tx *sql.Tx, raw *sql.Conn, f migrationFile, cols historyColumns, prog *progress) (time.Duration, error) {
+	m.logMigration(slog.LevelInfo, f, 0, "Applying migration: %s", f.Path)
+	ctx, span := startSpan(ctx, m.tracer, "drift.migration",
+		slog.Int64("migration.id", int64(f.ID)),
+		slog.String("migration.slug", f.Slug),
+	)
+	defer span.End()
+	prog.start(f.ID)
+	start := m.clock()
+	if err := m.applyTx(ctx, tx, raw, f, cols, start); err != nil {
+		span.RecordError(err)
+		prog.fail(f.ID, err)
+		return 0, migrationError(f, err)
+	}
+	d := m.clock().Sub(start)
+	m.logMigration(slog.LevelDebug, f, d, "Ran migration in %s: %s", d, f.Name)
+	return d, nil
+}
+
+// reTransactionControl finds statements that start, end, or abort a
+// transaction. ROLLBACK TO SAVEPOINT doesn't end the transaction, so it's
+// allowed.
+var reTransactionControl = regexp.MustCompile(`(?i)^(begin|start\s+transaction|commit|end|abort|rollback)\b(\s+(work|transaction))?\s*(;|$|isolation|read|deferrable|not|and)`)
+
+// transactionControl returns the top-level transaction control statements in
+// the content.
+func transactionControl(content string) []sqlStatement {
+	var found []sqlStatement
+	for _, st := range splitSQL(content) {
+		if reTransactionControl.MatchString(st.text) {
+			found = append(found, st)
+		}
+	}
+	return found
+}
+
+// checkTransactionControl returns an error if a migration that runs in
+// Drift's transaction has transaction control statements of its own, unless
+// its strip-transaction directive says to remove them.
+func (m *Migrator) checkTransactionControl(f migrationFile) error {
+	if f.directives.noTransaction || m.txMode == TransactionNone || f.directives.stripTransaction {
+		return nil
+	}
+	var st sqlStatement
+	var line int
+	if f.summary != nil {
+		if f.summary.txControl == nil {
+			return nil
+		}
+		st, line = f.summary.txControl.sqlStatement, f.summary.txControl.line
+	} else {
+		sts := transactionControl(f.Content)
+		if len(sts) == 0 {
+			return nil
+		}
+		st = sts[0]
+		line, _ = lineColumn(f.Content, st.start)
+	}
+	return fmt.Errorf("%w: %s line %d (%s): Drift already runs each migration in a transaction, "+
+		"so remove them, add --drift:strip-transaction to ignore them, or add --drift:no-transaction to manage the transaction yourself",
+		ErrTransactionControl, f.Name, line, excerpt(st.text))
+}
+
+// stripTransactionControl blanks out the transaction control statements in the
+// content, keeping line breaks so that error locations still match the file.
+func stripTransactionControl(content string) string {
+	sts := transactionControl(content)
+	if len(sts) == 0 {
+		return content
+	}
+	var b strings.Builder
+	prev := 0
+	for _, st := range sts {
+		b.WriteString(content[prev:st.start])
+		b.WriteString(strings.Repeat("\n", strings.Count(st.text, "\n")))
+		prev = st.start + len(st.text)
+	}
+	b.WriteString(content[prev:])
+	return b.String()
+}
@@ -0,0 +1,66 @@
+package drift
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var ErrUnboundVariable = errors.New("migration uses variables with no value")
+
+// reVar matches ${DRIFT_VAR_name} placeholders in migration files.
+var reVar = regexp.MustCompile(`\$\{DRIFT_VAR_(\w+)\}`)
+
+// WithVars sets values for ${DRIFT_VAR_name} placeholders in migration files,
+// for things that differ between environments like role names and
+// tablespaces. Names are case-insensitive. Calling it again adds to (and
+// overrides) the earlier values. Values are substituted as they are, even
+// inside quoted strings, without any quoting or escaping.
+//
+// Migrate substitutes the values just before applying each file, and refuses
+// to apply any migration if a planned file uses a variable with no value.
+// Seals and lint checks see the files as written.
+func WithVars(vars map[string]string) Option {
+	return func(m *Migrator) {
+		if m.vars == nil {
+			m.vars = make(map[string]string)
+		}
+		for name, value := range vars {
+			m.vars[strings.ToLower(name)] = value
+		}
+	}
+}
+
+// substituteVars replaces the placeholders in the content with their values.
+func (m *Migrator) substituteVars(content string) (string, error) {
+	var unbound []string
+	seen := make(map[string]bool)
+	out := reVar.ReplaceAllStringFunc(content, func(match string) string {
+		name := strings.ToLower(reVar.FindStringSubmatch(match)[1])
+		if v, ok := m.vars[name]; ok {
+			return v
+		}
+		if !seen[name] {
+			seen[name] = true
+			unbound = append(unbound, name)
+		}
+		return match
+	})
+	if len(unbound) > 0 {
+		sort.Strings(unbound)
+		return content, fmt.Errorf("%w: %s", ErrUnboundVariable, strings.Join(unbound, ", "))
+	}
+	return out, nil
+}
+
+// checkVars returns an error if the file uses a variable that isn't set.
+// Streamed files were checked as they were scanned.
+func (m *Migrator) checkVars(f migrationFile) error {
+	if f.summary != nil {
+		return f.summary.varErr
+	}
+	_, err := m.substituteVars(f.Content)
+	return err
+}
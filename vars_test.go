@@ -0,0 +1,98 @@
+package drift
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSubstituteVars(t *testing.T) {
+	m := New(WithVars(map[string]string{
+		"role":   "app",
+		"Schema": "tenant_1",
+		"quote":  "it's",
+		"regexp": `$1 \1 ${2}`,
+	}), WithVars(map[string]string{"ROLE": "web"}))
+
+	tests := map[string]struct {
+		content string
+		want    string
+	}{
+		"bare": {
+			content: "grant select on users to ${DRIFT_VAR_role};",
+			want:    "grant select on users to web;",
+		},
+		"case-insensitive names": {
+			content: "create schema ${DRIFT_VAR_schema}; set search_path to ${DRIFT_VAR_SCHEMA};",
+			want:    "create schema tenant_1; set search_path to tenant_1;",
+		},
+		"quoted string": {
+			content: "insert into roles values ('${DRIFT_VAR_role}');",
+			want:    "insert into roles values ('web');",
+		},
+		"escape string": {
+			content: `insert into notes values (E'\n${DRIFT_VAR_role}\t');`,
+			want:    `insert into notes values (E'\nweb\t');`,
+		},
+		"quoted identifier": {
+			content: `create table "${DRIFT_VAR_schema}".users ();`,
+			want:    `create table "tenant_1".users ();`,
+		},
+		"dollar quotes": {
+			content: "do $body$ begin execute 'grant usage on schema x to ${DRIFT_VAR_role}'; end $body$;",
+			want:    "do $body$ begin execute 'grant usage on schema x to web'; end $body$;",
+		},
+		"anonymous dollar quotes": {
+			content: "create function f() returns text as $$ select '${DRIFT_VAR_role}' $$ language sql;",
+			want:    "create function f() returns text as $$ select 'web' $$ language sql;",
+		},
+		"values are not escaped": {
+			content: "comment on table users is '${DRIFT_VAR_quote}';",
+			want:    "comment on table users is 'it's';",
+		},
+		"values are not expanded": {
+			content: "select '${DRIFT_VAR_regexp}';",
+			want:    `select '$1 \1 ${2}';`,
+		},
+		"not placeholders": {
+			content: "select '$DRIFT_VAR_role', '${drift_var_role}', '${DRIFT_VAR_}', '{DRIFT_VAR_role}', $1;",
+			want:    "select '$DRIFT_VAR_role', '${drift_var_role}', '${DRIFT_VAR_}', '{DRIFT_VAR_role}', $1;",
+		},
+		"adjacent": {
+			content: "${DRIFT_VAR_role}${DRIFT_VAR_schema}",
+			want:    "webtenant_1",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := m.substituteVars(tt.content)
+			if err != nil {
+				t.Fatalf("substituteVars() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("substituteVars() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSubstituteVarsUnbound(t *testing.T) {
+	m := New(WithVars(map[string]string{"role": "app"}))
+	content := "grant ${DRIFT_VAR_Privilege} on ${DRIFT_VAR_table} to ${DRIFT_VAR_role};\n" +
+		"grant ${DRIFT_VAR_privilege} on $$${DRIFT_VAR_table}$$ to ${DRIFT_VAR_role};\n"
+
+	got, err := m.substituteVars(content)
+	if !errors.Is(err, ErrUnboundVariable) {
+		t.Fatalf("substituteVars() error = %v, want %v", err, ErrUnboundVariable)
+	}
+	// Each unbound name is listed once, in order.
+	if want := ErrUnboundVariable.Error() + ": privilege, table"; err.Error() != want {
+		t.Errorf("substituteVars() error = %q, want %q", err, want)
+	}
+	if got != content {
+		t.Errorf("substituteVars() = %q, want the content unchanged", got)
+	}
+
+	if _, err := New().substituteVars("select 1;"); err != nil {
+		t.Errorf("substituteVars() without placeholders error = %v", err)
+	}
+}
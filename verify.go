@@ -0,0 +1,246 @@
+package drift
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/blockloop/scan"
+)
+
+// Invariants are catalog facts that should hold once all migrations have been
+// applied. They're usually declared in the config file.
+type Invariants struct {
+	// Indexes are index names (optionally schema-qualified) that must exist and
+	// be valid.
+	Indexes []string
+	// Constraints are constraint names that must exist and be validated (not
+	// left as NOT VALID).
+	Constraints []string
+}
+
+// A Discrepancy is a problem found by Verify, along with a suggestion for how
+// to fix it.
+type Discrepancy struct {
+	Object  string
+	Problem string
+	Fix     string
+}
+
+// Verify compares the applied migration records to the migration files and
+// reports any differences. Besides missing and renamed files, it reports
+// applied files whose content has changed: against the seal, with WithSeal,
+// and against the SQL recorded with WithAuditContent, where there is some.
+//
+// If deep is true, this also checks the database catalog: every invariant must
+// hold, and there must be no invalid indexes left behind by failed CREATE INDEX
+// CONCURRENTLY builds in any schema but the system ones.
+func (m *Migrator) Verify(ctx context.Context, db *sql.DB, migrationsDir string, deep bool, inv Invariants) ([]Discrepancy, error) {
+	io := m.io
+	records, err := m.records(ctx, db, true)
+	if err != nil {
+		return nil, fmt.Errorf("could not get applied migrations: %w", err)
+	}
+	files, err := m.available(migrationsDir)
+	if err != nil {
+		return nil, fmt.Errorf("could not get available migrations: %w", err)
+	}
+	ds := m.verifyRecords(records, files)
+
+	if !deep {
+		return ds, nil
+	}
+
+	io.Debugf("Checking for invalid indexes")
+	invalid, err := m.invalidIndexes(ctx, db, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not check for invalid indexes: %w", err)
+	}
+	for _, idx := range invalid {
+		ds = append(ds, Discrepancy{
+			Object:  idx.String(),
+			Problem: "index is invalid (probably from a failed CREATE INDEX CONCURRENTLY)",
+			Fix:     fmt.Sprintf("drop index concurrently %s; then re-run the migration that creates it", m.dialect.Quote(idx.Schema, idx.Name)),
+		})
+	}
+
+	for _, name := range inv.Indexes {
+		io.Debugf("Checking required index: %s", name)
+		d, err := m.verifyIndex(ctx, db, name)
+		if err != nil {
+			return nil, fmt.Errorf("could not check index %s: %w", name, err)
+		}
+		if d != nil {
+			ds = append(ds, *d)
+		}
+	}
+
+	for _, name := range inv.Constraints {
+		io.Debugf("Checking required constraint: %s", name)
+		d, err := m.verifyConstraint(ctx, db, name)
+		if err != nil {
+			return nil, fmt.Errorf("could not check constraint %s: %w", name, err)
+		}
+		if d != nil {
+			ds = append(ds, *d)
+		}
+	}
+	return ds, nil
+}
+
+func (m *Migrator) verifyRecords(records []migrationRecord, files []migrationFile) []Discrepancy {
+	byID := make(map[MigrationID]migrationFile)
+	for _, f := range files {
+		byID[f.ID] = f
+	}
+
+	var ds []Discrepancy
+	for _, r := range records {
+		f, ok := byID[r.ID]
+		if !ok {
+			ds = append(ds, Discrepancy{
+				Object:  fmt.Sprintf("migration %d (%s)", r.ID, r.Slug),
+				Problem: "applied migration has no file",
+				Fix:     "restore the migration file from version control",
+			})
+			continue
+		}
+		object := fmt.Sprintf("migration %d (%s)", r.ID, r.Slug)
+		if f.Slug != r.Slug {
+			ds = append(ds, Discrepancy{
+				Object:  object,
+				Problem: fmt.Sprintf("file slug %q does not match the applied slug", f.Slug),
+				Fix:     fmt.Sprintf("rename %s back to %s", filepath.Base(f.entryPath()), f.renamed(len(f.idRaw), r.ID, r.Slug)),
+			})
+		}
+		if m.seal != nil {
+			if err := m.seal.check(f); errors.Is(err, ErrUnsealedMigration) {
+				ds = append(ds, Discrepancy{
+					Object:  object,
+					Problem: "applied migration is not in the seal",
+					Fix:     "seal the reviewed file, or restore the seal from version control",
+				})
+			} else if err != nil {
+				ds = append(ds, Discrepancy{
+					Object:  object,
+					Problem: "file has changed since it was sealed",
+					Fix:     fmt.Sprintf("restore the sealed version of %s from version control", f.Path),
+				})
+			}
+		}
+		if r.Content.Valid && r.Content.String != "" && r.Content.String != m.appliedContent(f) {
+			ds = append(ds, Discrepancy{
+				Object:  object,
+				Problem: "file content does not match the SQL that was applied",
+				Fix:     fmt.Sprintf("restore the applied version of %s (see drift show %d)", f.Path, r.ID),
+			})
+		}
+	}
+	return ds
+}
+
+// appliedContent returns the file's content the way it's recorded with
+// WithAuditContent: with variables substituted and, for the strip-transaction
+// directive, transaction control statements left out.
+func (m *Migrator) appliedContent(f migrationFile) string {
+	content, _ := m.substituteVars(f.Content)
+	if d, err := parseDirectives(f.directiveText()); err == nil && d.stripTransaction {
+		content = stripTransactionControl(content)
+	}
+	return content
+}
+
+type indexName struct {
+	Schema string `db:"schema"`
+	Name   string `db:"name"`
+}
+
+func (i indexName) String() string {
+	return i.Schema + "." + i.Name
+}
+
+// normalized returns the name with both parts normalized like sqlName.
+func (i indexName) normalized() indexName {
+	return indexName{Schema: sqlName(i.Schema), Name: sqlName(i.Name)}
+}
+
+func (m *Migrator) verifyIndex(ctx context.Context, db *sql.DB, name string) (*Discrepancy, error) {
+	query, args, err := pq.
+		Select("n.nspname", "c.relname", "i.indisvalid").
+		From("pg_index i").
+		Join("pg_class c on c.oid = i.indexrelid").
+		Join("pg_namespace n on n.oid = c.relnamespace").
+		Where(sq.Expr("i.indexrelid = to_regclass(?)", name)).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	var idx indexName
+	var valid bool
+	err = db.QueryRowContext(ctx, query, args...).Scan(&idx.Schema, &idx.Name, &valid)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return &Discrepancy{
+			Object:  name,
+			Problem: "required index does not exist",
+			Fix:     "write a migration that creates it",
+		}, nil
+	case err != nil:
+		return nil, err
+	case !valid:
+		return &Discrepancy{
+			Object:  name,
+			Problem: "required index is invalid",
+			Fix:     fmt.Sprintf("drop index concurrently %s; then re-run the migration that creates it", m.dialect.Quote(idx.Schema, idx.Name)),
+		}, nil
+	}
+	return nil, nil
+}
+
+func (m *Migrator) verifyConstraint(ctx context.Context, db *sql.DB, name string) (*Discrepancy, error) {
+	query, args, err := pq.
+		Select("n.nspname as schema", "c.relname as relation", "k.convalidated as valid").
+		From("pg_constraint k").
+		Join("pg_class c on c.oid = k.conrelid").
+		Join("pg_namespace n on n.oid = c.relnamespace").
+		Where(sq.Eq{"k.conname": name}).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	var cs []struct {
+		Schema string `db:"schema"`
+		Table  string `db:"relation"`
+		Valid  bool   `db:"valid"`
+	}
+	if err := scan.RowsStrict(&cs, rows); err != nil {
+		return nil, err
+	}
+
+	if len(cs) == 0 {
+		return &Discrepancy{
+			Object:  name,
+			Problem: "required constraint does not exist",
+			Fix:     "write a migration that creates it",
+		}, nil
+	}
+	for _, c := range cs {
+		if !c.Valid {
+			table := m.dialect.Quote(c.Schema, c.Table)
+			return &Discrepancy{
+				Object:  name,
+				Problem: fmt.Sprintf("required constraint on %s is NOT VALID", table),
+				Fix:     fmt.Sprintf("alter table %s validate constraint %s;", table, m.dialect.Quote(name)),
+			}, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,69 @@
+package drift
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+
+	"github.com/metagram-net/drift/internal/memfs"
+)
+
+func TestVerifyRecords(t *testing.T) {
+	files := []migrationFile{
+		{Path: "migrations/1-create_users.sql", ID: 1, Slug: "create_users", idRaw: "1"},
+		{Path: "migrations/2-add_email.sql", ID: 2, Slug: "add_email", idRaw: "2"},
+	}
+	records := []migrationRecord{
+		{ID: 1, Slug: "create_users"},
+		{ID: 2, Slug: "add_emails"},
+		{ID: 3, Slug: "drop_teams"},
+	}
+	var got []string
+	for _, d := range New().verifyRecords(records, files) {
+		got = append(got, d.Object+": "+d.Problem)
+	}
+	want := []string{
+		`migration 2 (add_emails): file slug "add_email" does not match the applied slug`,
+		"migration 3 (drop_teams): applied migration has no file",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestVerifyRecordsContent(t *testing.T) {
+	fsys := memfs.New(map[string]string{
+		"migrations/1-same.sql":     "create table a ();\n",
+		"migrations/2-edited.sql":   "create table b (id int);\n",
+		"migrations/3-unsealed.sql": "create table c ();\n",
+		"migrations/4-vars.sql":     "create role ${DRIFT_VAR_role};\n",
+	})
+	m := New(WithFileSystem(fsys), WithVars(map[string]string{"role": "app"}), WithSeal(Seal{
+		"1-same.sql":   blobHash("create table a ();\n"),
+		"2-edited.sql": blobHash("create table b ();\n"),
+		"4-vars.sql":   blobHash("create role ${DRIFT_VAR_role};\n"),
+	}))
+	files, err := m.available("migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := func(s string) sql.NullString { return sql.NullString{String: s, Valid: true} }
+	records := []migrationRecord{
+		{ID: 1, Slug: "same", Content: content("create table a ();\n")},
+		{ID: 2, Slug: "edited", Content: content("create table b ();\n")},
+		{ID: 3, Slug: "unsealed"},
+		{ID: 4, Slug: "vars", Content: content("create role app;\n")},
+	}
+	var got []string
+	for _, d := range m.verifyRecords(records, files) {
+		got = append(got, d.Object+": "+d.Problem)
+	}
+	want := []string{
+		"migration 2 (edited): file has changed since it was sealed",
+		"migration 2 (edited): file content does not match the SQL that was applied",
+		"migration 3 (unsealed): applied migration is not in the seal",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,53 @@
+package drift
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrDatabaseUnavailable = errors.New("database did not become reachable in time")
+
+// The backoff between connection attempts starts at minWaitBackoff and doubles
+// up to maxWaitBackoff.
+const (
+	minWaitBackoff = 100 * time.Millisecond
+	maxWaitBackoff = 5 * time.Second
+)
+
+// WithWaitTimeout makes Migrate retry connecting to the database with
+// exponential backoff until it's reachable or the timeout expires. This helps
+// when Drift starts alongside the database, like in a Kubernetes init
+// container or docker-compose.
+func WithWaitTimeout(d time.Duration) Option {
+	return func(m *Migrator) {
+		m.waitTimeout = d
+	}
+}
+
+func (m *Migrator) waitForDB(ctx context.Context, db conn) error {
+	ctx, cancel := context.WithTimeout(ctx, m.waitTimeout)
+	defer cancel()
+
+	backoff := minWaitBackoff
+	for {
+		err := db.PingContext(ctx)
+		if err == nil {
+			return nil
+		}
+		m.io.Infof("Waiting for the database (retrying in %s): %s", backoff, err)
+
+		t := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return fmt.Errorf("%w after %s: %s", ErrDatabaseUnavailable, m.waitTimeout, err)
+		case <-t.C:
+		}
+		backoff *= 2
+		if backoff > maxWaitBackoff {
+			backoff = maxWaitBackoff
+		}
+	}
+}